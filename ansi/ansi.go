@@ -0,0 +1,159 @@
+// Package ansi strips and parses ANSI escape sequences in terminal output,
+// and measures the visible width of text that contains them. It is useful
+// for custom assertions on a program's raw output or on styled captures.
+//
+// The parser recognizes the sequences TUIs emit: CSI sequences (colors,
+// cursor movement, erasing), OSC sequences (window titles, hyperlinks), DCS,
+// APC, PM, and SOS strings, and other two-byte escapes. It does not
+// interpret them.
+package ansi
+
+import (
+	"iter"
+	"strings"
+
+	"github.com/cboone/strider/internal/cellwidth"
+)
+
+// Kind identifies the type of a Segment.
+type Kind int
+
+const (
+	// Text is printable text, including control characters other than ESC.
+	Text Kind = iota
+	// CSI is a Control Sequence Introducer sequence: ESC [ params final.
+	CSI
+	// OSC is an Operating System Command: ESC ] ... terminated by BEL or
+	// ESC \.
+	OSC
+	// ControlString is a DCS, APC, PM, or SOS string: ESC P, ESC _, ESC ^,
+	// or ESC X, then a string terminated by ESC \.
+	ControlString
+	// Escape is any other escape sequence: ESC followed by one byte, or by
+	// intermediate bytes and a final byte (for example ESC ( B).
+	Escape
+)
+
+// String returns the name of k.
+func (k Kind) String() string {
+	switch k {
+	case Text:
+		return "Text"
+	case CSI:
+		return "CSI"
+	case OSC:
+		return "OSC"
+	case ControlString:
+		return "ControlString"
+	case Escape:
+		return "Escape"
+	}
+	return "Kind(?)"
+}
+
+// Segment is a run of text or a single escape sequence.
+type Segment struct {
+	Kind Kind
+	// Raw is the segment exactly as it appears in the input.
+	Raw string
+	// Params holds, for CSI sequences, the parameter and intermediate bytes
+	// between ESC [ and the final byte, and for OSC sequences and control
+	// strings, the content before the terminator. It is empty otherwise.
+	Params string
+	// Final is the final byte of a CSI or Escape sequence, for example 'm'
+	// for SGR (colors and attributes). It is 0 otherwise.
+	Final byte
+}
+
+// Parse returns an iterator over the text runs and escape sequences in s,
+// in order. Concatenating the Raw fields of all segments yields s. An
+// unterminated sequence at the end of s is returned as is, with Final 0.
+func Parse(s string) iter.Seq[Segment] {
+	return func(yield func(Segment) bool) {
+		for i := 0; i < len(s); {
+			if s[i] != '\x1b' {
+				j := strings.IndexByte(s[i:], '\x1b')
+				if j < 0 {
+					j = len(s) - i
+				}
+				if !yield(Segment{Kind: Text, Raw: s[i : i+j]}) {
+					return
+				}
+				i += j
+				continue
+			}
+
+			seg := parseEscape(s[i:])
+			if !yield(seg) {
+				return
+			}
+			i += len(seg.Raw)
+		}
+	}
+}
+
+// parseEscape parses the escape sequence at the start of s, which begins
+// with ESC.
+func parseEscape(s string) Segment {
+	if len(s) < 2 {
+		return Segment{Kind: Escape, Raw: s}
+	}
+
+	switch s[1] {
+	case '[':
+		// Parameter and intermediate bytes, then a final byte in 0x40-0x7E.
+		for j := 2; j < len(s); j++ {
+			if s[j] >= 0x40 && s[j] <= 0x7e {
+				return Segment{Kind: CSI, Raw: s[:j+1], Params: s[2:j], Final: s[j]}
+			}
+		}
+		return Segment{Kind: CSI, Raw: s, Params: s[2:]}
+	case ']', 'P', '_', '^', 'X':
+		kind := ControlString
+		if s[1] == ']' {
+			kind = OSC
+		}
+		for j := 2; j < len(s); j++ {
+			if s[j] == '\a' && kind == OSC {
+				return Segment{Kind: kind, Raw: s[:j+1], Params: s[2:j]}
+			}
+			if s[j] == '\x1b' && j+1 < len(s) && s[j+1] == '\\' {
+				return Segment{Kind: kind, Raw: s[:j+2], Params: s[2:j]}
+			}
+		}
+		return Segment{Kind: kind, Raw: s, Params: s[2:]}
+	}
+
+	// Intermediate bytes (0x20-0x2F), then a final byte.
+	j := 1
+	for j < len(s) && s[j] >= 0x20 && s[j] <= 0x2f {
+		j++
+	}
+	if j < len(s) {
+		return Segment{Kind: Escape, Raw: s[:j+1], Final: s[j]}
+	}
+	return Segment{Kind: Escape, Raw: s}
+}
+
+// Strip returns s with every escape sequence removed.
+func Strip(s string) string {
+	if !strings.Contains(s, "\x1b") {
+		return s
+	}
+	var b strings.Builder
+	b.Grow(len(s))
+	for seg := range Parse(s) {
+		if seg.Kind == Text {
+			b.WriteString(seg.Raw)
+		}
+	}
+	return b.String()
+}
+
+// VisibleLength returns the number of terminal columns s occupies once
+// escape sequences are removed: wide characters such as CJK ideographs and
+// most emoji count as two columns, and combining marks and control
+// characters as zero. s should hold a single line.
+func VisibleLength(s string) int {
+	return cellwidth.String(Strip(s))
+}
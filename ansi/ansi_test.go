@@ -0,0 +1,108 @@
+package ansi_test
+
+import (
+	"fmt"
+	"strings"
+	"testing"
+
+	"github.com/cboone/strider/ansi"
+)
+
+func TestStrip(t *testing.T) {
+	tests := []struct {
+		name string
+		in   string
+		want string
+	}{
+		{"plain", "hello", "hello"},
+		{"sgr", "\x1b[1;31mred\x1b[0m", "red"},
+		{"cursor", "a\x1b[2;5Hb\x1b[K", "ab"},
+		{"private mode", "\x1b[?1049hscreen\x1b[?25l", "screen"},
+		{"osc bel", "\x1b]0;title\atext", "text"},
+		{"osc hyperlink", "\x1b]8;;https://example.com\x1b\\link\x1b]8;;\x1b\\", "link"},
+		{"dcs", "\x1bP1$r0m\x1b\\after", "after"},
+		{"charset", "\x1b(Bq\x1b(0", "q"},
+		{"two-byte", "\x1b7saved\x1b8", "saved"},
+		{"unterminated csi", "text\x1b[31", "text"},
+		{"lone esc", "text\x1b", "text"},
+		{"control characters kept", "a\tb\r\n", "a\tb\r\n"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := ansi.Strip(tt.in); got != tt.want {
+				t.Errorf("Strip(%q) = %q, want %q", tt.in, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestParse(t *testing.T) {
+	in := "a\x1b[38;5;196mb\x1b]2;t\x1b\\c\x1b=d"
+	var got []string
+	var raw strings.Builder
+	for seg := range ansi.Parse(in) {
+		got = append(got, fmt.Sprintf("%v %q %q %q", seg.Kind, seg.Raw, seg.Params, seg.Final))
+		raw.WriteString(seg.Raw)
+	}
+	want := []string{
+		`Text "a" "" '\x00'`,
+		`CSI "\x1b[38;5;196m" "38;5;196" 'm'`,
+		`Text "b" "" '\x00'`,
+		`OSC "\x1b]2;t\x1b\\" "2;t" '\x00'`,
+		`Text "c" "" '\x00'`,
+		`Escape "\x1b=" "" '='`,
+		`Text "d" "" '\x00'`,
+	}
+	if strings.Join(got, "\n") != strings.Join(want, "\n") {
+		t.Errorf("Parse(%q) =\n%s\nwant\n%s", in, strings.Join(got, "\n"), strings.Join(want, "\n"))
+	}
+	if raw.String() != in {
+		t.Errorf("concatenated Raw = %q, want %q", raw.String(), in)
+	}
+}
+
+func TestParseStopsEarly(t *testing.T) {
+	n := 0
+	for range ansi.Parse("a\x1b[mb\x1b[mc") {
+		n++
+		if n == 2 {
+			break
+		}
+	}
+	if n != 2 {
+		t.Errorf("iterated %d segments, want 2", n)
+	}
+}
+
+func TestVisibleLength(t *testing.T) {
+	tests := []struct {
+		in   string
+		want int
+	}{
+		{"", 0},
+		{"hello", 5},
+		{"\x1b[1mbold\x1b[0m", 4},
+		{"\x1b[31m日本\x1b[0m", 4},
+		{"é", 1},
+	}
+	for _, tt := range tests {
+		if got := ansi.VisibleLength(tt.in); got != tt.want {
+			t.Errorf("VisibleLength(%q) = %d, want %d", tt.in, got, tt.want)
+		}
+	}
+}
+
+func ExampleStrip() {
+	fmt.Println(ansi.Strip("\x1b[1;32mPASS\x1b[0m all tests"))
+	// Output: PASS all tests
+}
+
+func ExampleParse() {
+	for seg := range ansi.Parse("\x1b[31mred\x1b[0m") {
+		fmt.Printf("%v %q\n", seg.Kind, seg.Raw)
+	}
+	// Output:
+	// CSI "\x1b[31m"
+	// Text "red"
+	// CSI "\x1b[0m"
+}
@@ -0,0 +1,27 @@
+package strider
+
+import "testing"
+
+// AssertRestoresScreen checks that the program recovers from a disruption:
+// it captures the screen, runs disrupt (for example a resize there and back,
+// or a suspend and resume), and then waits until the screen has the captured
+// size and content again. Failures are reported to t, with a diff between
+// the captured and the final screen.
+//
+//	strider.AssertRestoresScreen(t, term, func() {
+//		term.Resize(40, 10)
+//		term.WaitFor(strider.SizeIs(40, 10))
+//		term.Resize(80, 24)
+//	})
+func AssertRestoresScreen(t testing.TB, term *Terminal, disrupt func(), wopts ...WaitOption) {
+	t.Helper()
+
+	ref := term.Screen()
+	width, height := ref.Size()
+	disrupt()
+
+	origT, origHost := term.t, term.host
+	term.t, term.host = t, t
+	defer func() { term.t, term.host = origT, origHost }()
+	term.waitForInternal("restores-screen", All(SizeIs(width, height), SameAs(ref)), wopts...)
+}
@@ -0,0 +1,200 @@
+package strider
+
+import (
+	"fmt"
+	"os"
+	"strings"
+
+	"github.com/cboone/strider/internal/tmuxcli"
+)
+
+// Backend selects what runs a Terminal's program and emulates its terminal
+// (see WithBackend).
+type Backend int
+
+const (
+	// Tmux runs the program in a tmux session. It is the default.
+	Tmux Backend = iota + 1
+	// PTY runs the program on a pseudo-terminal and renders its output with
+	// a terminal emulator in the test process, which follows tmux, so that
+	// tests run where tmux is not installed. It needs Linux or macOS.
+	PTY
+	// Auto uses tmux if it is installed and recent enough, and the PTY
+	// backend otherwise.
+	Auto
+)
+
+// String returns the name of b, as STRIDER_BACKEND accepts it.
+func (b Backend) String() string {
+	switch b {
+	case Tmux:
+		return "tmux"
+	case PTY:
+		return "pty"
+	case Auto:
+		return "auto"
+	}
+	return fmt.Sprintf("Backend(%d)", int(b))
+}
+
+// applyEnvBackend sets the backend named by STRIDER_BACKEND, unless
+// WithBackend chose one. An unknown name is reported by validate.
+func applyEnvBackend(o *options) {
+	name := os.Getenv("STRIDER_BACKEND")
+	if o.backend != 0 || name == "" {
+		return
+	}
+	for _, b := range []Backend{Tmux, PTY, Auto} {
+		if strings.EqualFold(name, b.String()) {
+			o.backend = b
+			return
+		}
+	}
+	o.backendErr = fmt.Sprintf("STRIDER_BACKEND: unknown backend %q (want tmux, pty, or auto)", name)
+}
+
+// resolveBackend returns the backend that runs the program, with the path
+// of tmux if it is tmux. Auto picks tmux if it is found and recent enough,
+// and the PTY backend otherwise. For Tmux, the error for a missing or old
+// tmux skips the test, or fails it when strict (see environmentError).
+func resolveBackend(opts options, strict bool) (Backend, string, error) {
+	switch opts.backend {
+	case PTY:
+		return PTY, "", nil
+	case Auto:
+		if path, _, err := findTmux(opts.tmuxPath); err == nil {
+			if version, err := tmuxcli.Version(path); err == nil && versionAtLeast(version, minTmuxVersion) {
+				return Tmux, path, nil
+			}
+		}
+		return PTY, "", nil
+	}
+	tmuxPath, explicit, err := resolveTmuxPath(opts.tmuxPath, strict)
+	if err != nil {
+		return 0, "", err
+	}
+	if err := checkTmuxVersion(tmuxPath, explicit || strict); err != nil {
+		return 0, "", err
+	}
+	return Tmux, tmuxPath, nil
+}
+
+// backend runs a Terminal's program and emulates the terminal it draws on:
+// a tmux pane (tmuxBackend), or a pseudo-terminal with an emulator in the
+// test process (ptyBackend, see WithBackend). Its methods answer what the
+// Terminal asks of the pane, in tmux's terms.
+type backend interface {
+	// capture captures the visible screen, with SGR sequences if styled,
+	// together with the pane state and geometry.
+	capture(styled bool) (string, paneState, paneGeometry, error)
+	// captureExact captures the visible screen, keeping trailing spaces.
+	captureExact() (string, error)
+	// captureSaved captures the primary screen while the alternate screen
+	// is displayed.
+	captureSaved() (string, error)
+	// captureScrollback captures the history and the visible screen, with
+	// wrapped lines joined if joined.
+	captureScrollback(joined bool) (string, error)
+	// captureScrolled captures the rows shown after scrolling the terminal
+	// up by lines.
+	captureScrolled(lines, height int) (string, error)
+	alternateScreen() (bool, error)
+	geometry() (paneGeometry, error)
+	state() (paneState, error)
+	pid() (int, error)
+	mouseMode() (mouseMode, error)
+
+	// sendKeys sends tmux key names (see Key), sendLiteral text, and
+	// sendBytes bytes as they are.
+	sendKeys(keys []string) error
+	sendLiteral(s string) error
+	sendBytes(b []byte) error
+
+	resize(width, height int) error
+	// respawn kills the program, if it is still running, and starts command
+	// in its place on a cleared screen.
+	respawn(dir string, command []string) error
+	clearHistory() error
+	// stop ends the program and releases the backend.
+	stop() error
+}
+
+// tmuxBackend runs the program in a tmux pane.
+type tmuxBackend struct {
+	runner *tmuxcli.Runner
+	pane   string
+	// session names the pane's session on a shared server (see
+	// WithSharedServer), or is "" if the server is the Terminal's own.
+	session string
+}
+
+func (b *tmuxBackend) capture(styled bool) (string, paneState, paneGeometry, error) {
+	return capturePaneWithState(b.runner, b.pane, styled)
+}
+
+func (b *tmuxBackend) captureExact() (string, error) {
+	return capturePaneExact(b.runner, b.pane)
+}
+
+func (b *tmuxBackend) captureSaved() (string, error) {
+	return capturePaneSaved(b.runner, b.pane)
+}
+
+func (b *tmuxBackend) captureScrollback(joined bool) (string, error) {
+	if joined {
+		return capturePaneJoined(b.runner, b.pane)
+	}
+	return capturePaneScrollback(b.runner, b.pane)
+}
+
+func (b *tmuxBackend) captureScrolled(lines, height int) (string, error) {
+	return captureScrolledView(b.runner, b.pane, lines, height)
+}
+
+func (b *tmuxBackend) alternateScreen() (bool, error) {
+	return alternateScreenOn(b.runner, b.pane)
+}
+
+func (b *tmuxBackend) geometry() (paneGeometry, error) {
+	return getPaneGeometry(b.runner, b.pane)
+}
+
+func (b *tmuxBackend) state() (paneState, error) {
+	return getPaneState(b.runner, b.pane)
+}
+
+func (b *tmuxBackend) pid() (int, error) {
+	return getPanePID(b.runner, b.pane)
+}
+
+func (b *tmuxBackend) mouseMode() (mouseMode, error) {
+	return getMouseMode(b.runner, b.pane)
+}
+
+func (b *tmuxBackend) sendKeys(keys []string) error {
+	return sendKeys(b.runner, b.pane, keys)
+}
+
+func (b *tmuxBackend) sendLiteral(s string) error {
+	return sendLiteral(b.runner, b.pane, s)
+}
+
+func (b *tmuxBackend) sendBytes(p []byte) error {
+	return sendBytes(b.runner, b.pane, p)
+}
+
+func (b *tmuxBackend) resize(width, height int) error {
+	return resizeWindow(b.runner, b.pane, width, height)
+}
+
+func (b *tmuxBackend) respawn(dir string, command []string) error {
+	return respawnPane(b.runner, b.pane, dir, command)
+}
+
+func (b *tmuxBackend) clearHistory() error {
+	return clearHistory(b.runner, b.pane)
+}
+
+func (b *tmuxBackend) stop() error {
+	return stopSession(b.runner, b.session)
+}
@@ -0,0 +1,39 @@
+package strider
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/cboone/strider/internal/bidi"
+)
+
+// ContainsLogical reports whether a row of the screen shows text given in
+// logical order, the order it is typed and read in. Programs that support
+// right-to-left scripts such as Hebrew and Arabic draw them in visual order,
+// so the captured cells of "hello שלום" read "hello םולש". ContainsLogical
+// reorders text with the Unicode Bidirectional Algorithm, for both a
+// left-to-right and a right-to-left line, and looks for either result.
+//
+// The reordering is a simplified version of the algorithm: it ignores
+// explicit embedding and isolate characters, and it reorders text on its
+// own rather than as part of the row, which can differ at the edges of a
+// substring whose direction depends on its neighbors. Text does not match
+// across rows.
+func (s *Screen) ContainsLogical(text string) bool {
+	ltr, rtl := bidi.Visual(text, false), bidi.Visual(text, true)
+	for _, line := range s.lines {
+		if strings.Contains(line, ltr) || strings.Contains(line, rtl) {
+			return true
+		}
+	}
+	return false
+}
+
+// TextLogical matches if a row of the screen shows text given in logical
+// order (see Screen.ContainsLogical), for assertions on right-to-left text
+// that read the way the text is written.
+func TextLogical(text string) Matcher {
+	return func(scr *Screen) (bool, string) {
+		return scr.ContainsLogical(text), fmt.Sprintf("screen to contain %q in logical order", text)
+	}
+}
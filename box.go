@@ -0,0 +1,130 @@
+package strider
+
+import (
+	"fmt"
+	"strings"
+)
+
+// Box is a rectangle drawn with box-drawing characters, as detected by
+// Screen.Boxes. Bounds are the rows and display columns of the border
+// cells (0-indexed, inclusive).
+type Box struct {
+	Top    int
+	Left   int
+	Bottom int
+	Right  int
+
+	// Content holds the rows inside the border, one string per row, each
+	// exactly Right-Left-1 display columns wide.
+	Content []string
+}
+
+// Contains reports whether the box content contains the substring on any
+// single row.
+func (b Box) Contains(substr string) bool {
+	for _, line := range b.Content {
+		if strings.Contains(line, substr) {
+			return true
+		}
+	}
+	return false
+}
+
+// String returns the box content joined by newlines.
+func (b Box) String() string {
+	return strings.Join(b.Content, "\n")
+}
+
+// Corner and edge characters recognized by Boxes: light, rounded, heavy,
+// and double Unicode box drawing, plus ASCII "+-|".
+const (
+	boxTopLeft     = "┌╭┏╔+"
+	boxTopRight    = "┐╮┓╗+"
+	boxBottomLeft  = "└╰┗╚+"
+	boxBottomRight = "┘╯┛╝+"
+	boxHorizontal  = "─━═-┬┴┳┻╦╩┼╋╬"
+	boxVertical    = "│┃║|├┤┣┫╠╣┼╋╬"
+)
+
+// Boxes detects rectangles drawn with box-drawing characters and returns them
+// in reading order (top to bottom, then left to right). Nested boxes are
+// returned individually, outer box first.
+func (s *Screen) Boxes() []Box {
+	grid := make([][]rune, len(s.lines))
+	for i, line := range s.lines {
+		grid[i] = lineCells(line)
+	}
+	at := func(row, col int) rune {
+		if row < 0 || row >= len(grid) || col < 0 || col >= len(grid[row]) {
+			return ' '
+		}
+		return grid[row][col]
+	}
+	is := func(r rune, set string) bool {
+		return strings.ContainsRune(set, r)
+	}
+
+	var boxes []Box
+	for top := range grid {
+		for left := range grid[top] {
+			if !is(at(top, left), boxTopLeft) {
+				continue
+			}
+			for right := left + 1; right < len(grid[top]); right++ {
+				r := at(top, right)
+				if is(r, boxTopRight) {
+					if b, ok := closeBox(at, is, top, left, right); ok {
+						b.Content = make([]string, 0, b.Bottom-b.Top-1)
+						for row := b.Top + 1; row < b.Bottom; row++ {
+							b.Content = append(b.Content, cellSlice(s.lines[row], b.Left+1, b.Right-b.Left-1))
+						}
+						boxes = append(boxes, b)
+						break
+					}
+				}
+				if !is(r, boxHorizontal) && !is(r, boxTopRight) {
+					break
+				}
+			}
+		}
+	}
+	return boxes
+}
+
+// closeBox looks for the left, right, and bottom edges of a box whose top
+// edge spans columns left through right on row top.
+func closeBox(at func(row, col int) rune, is func(rune, string) bool, top, left, right int) (Box, bool) {
+	for bottom := top + 1; ; bottom++ {
+		l, r := at(bottom, left), at(bottom, right)
+		if is(l, boxBottomLeft) && is(r, boxBottomRight) && bottomEdge(at, is, bottom, left, right) {
+			return Box{Top: top, Left: left, Bottom: bottom, Right: right}, true
+		}
+		if !is(l, boxVertical) || !is(r, boxVertical) {
+			return Box{}, false
+		}
+	}
+}
+
+func bottomEdge(at func(row, col int) rune, is func(rune, string) bool, row, left, right int) bool {
+	for col := left + 1; col < right; col++ {
+		if !is(at(row, col), boxHorizontal) {
+			return false
+		}
+	}
+	return true
+}
+
+// BoxContaining matches if the screen has a box (see Screen.Boxes) whose
+// content contains the given substring on a single row.
+func BoxContaining(text string) Matcher {
+	return func(scr *Screen) (bool, string) {
+		desc := fmt.Sprintf("box containing %q", text)
+		boxes := scr.Boxes()
+		for _, b := range boxes {
+			if b.Contains(text) {
+				return true, desc
+			}
+		}
+		return false, desc + fmt.Sprintf(" (found %d boxes)", len(boxes))
+	}
+}
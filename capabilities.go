@@ -0,0 +1,141 @@
+package strider
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/cboone/strider/ansi"
+	"github.com/cboone/strider/internal/tmuxcli"
+)
+
+// Capabilities describes what the tmux that strider drives supports. Get
+// them with DetectCapabilities.
+type Capabilities struct {
+	// TmuxPath is the tmux binary, from STRIDER_TMUX or $PATH.
+	TmuxPath string
+	// TmuxVersion is its version, such as "3.3a".
+	TmuxVersion string
+	// TrueColor reports whether panes keep the 24-bit colors programs
+	// write, so styled captures (see WithStyles) report them as RGBColor
+	// values rather than nearby palette colors.
+	TrueColor bool
+}
+
+// TmuxAtLeast reports whether the tmux version is at least version, such as
+// "3.3". Letter suffixes are ignored: "3.3a" is at least "3.3".
+func (c Capabilities) TmuxAtLeast(version string) bool {
+	return versionAtLeast(c.TmuxVersion, version)
+}
+
+// probeTimeout bounds how long the true-color probe waits for its pane.
+const probeTimeout = 5 * time.Second
+
+var (
+	capabilitiesOnce     sync.Once
+	detectedCapabilities Capabilities
+	capabilitiesExplicit bool
+	capabilitiesErr      error
+)
+
+// DetectCapabilities probes the tmux found through STRIDER_TMUX or $PATH,
+// starting a short-lived tmux server to test what panes support. The probe
+// runs once per process and its result is reused. Terminals opened with
+// WithTmuxPath may use a different tmux.
+func DetectCapabilities() (Capabilities, error) {
+	capabilitiesOnce.Do(func() {
+		detectedCapabilities, capabilitiesExplicit, capabilitiesErr = probeCapabilities()
+	})
+	return detectedCapabilities, capabilitiesErr
+}
+
+// probeCapabilities implements DetectCapabilities, also reporting whether
+// tmux was explicitly configured through STRIDER_TMUX.
+func probeCapabilities() (Capabilities, bool, error) {
+	explicit, err := checkRequirements()
+	if err != nil {
+		return Capabilities{}, explicit, err
+	}
+	path, _, _ := findTmux("")
+	version, err := tmuxcli.Version(path)
+	if err != nil {
+		return Capabilities{}, explicit, fmt.Errorf("strider: capabilities: %v", err)
+	}
+
+	caps := Capabilities{TmuxPath: path, TmuxVersion: version}
+	if caps.TrueColor, err = probeTrueColor(path); err != nil {
+		return Capabilities{}, explicit, fmt.Errorf("strider: capabilities: true color: %v", err)
+	}
+	return caps, explicit, nil
+}
+
+// probeTrueColor writes a 24-bit color to a pane of a new tmux server and
+// reports whether a styled capture returns it unchanged.
+func probeTrueColor(tmuxPath string) (bool, error) {
+	dir, err := os.MkdirTemp("", "strider-probe-*")
+	if err != nil {
+		return false, err
+	}
+	defer os.RemoveAll(dir)
+
+	runner := tmuxcli.New(tmuxPath, filepath.Join(dir, "sock"))
+	runner.SetConfigPath(os.DevNull)
+	if _, err := runner.Run("new-session", "-d", "-s", "probe", "-x", "20", "-y", "2", "--",
+		"/bin/sh", "-c", `printf '\033[38;2;1;2;3mX\033[0m'; exec cat`); err != nil {
+		return false, err
+	}
+	defer killServer(runner)
+
+	deadline := time.Now().Add(probeTimeout)
+	for {
+		out, err := capturePaneStyled(runner, "probe")
+		if err != nil {
+			return false, err
+		}
+		if strings.Contains(ansi.Strip(out), "X") {
+			return strings.Contains(out, "38;2;1;2;3"), nil
+		}
+		if time.Now().After(deadline) {
+			return false, errors.New("the probe pane did not draw its output")
+		}
+		time.Sleep(defaultPollInterval)
+	}
+}
+
+// requireCapabilities returns the detected capabilities, or ends the test
+// when they cannot be detected: with a skip if tmux was found in $PATH (or
+// not found), and a failure if it was configured with STRIDER_TMUX or the
+// environment is strict, as Open does. op names the helper.
+func requireCapabilities(t testing.TB, op string) Capabilities {
+	t.Helper()
+	caps, err := DetectCapabilities()
+	if err != nil {
+		missingEnvironment(t, capabilitiesExplicit || strictByDefault(), "strider: %s: %v", op, err)
+	}
+	return caps
+}
+
+// SkipIfNoTrueColor skips the test unless tmux keeps the 24-bit colors
+// programs write (see Capabilities.TrueColor).
+func SkipIfNoTrueColor(t testing.TB) {
+	t.Helper()
+	if caps := requireCapabilities(t, "skip-if-no-true-color"); !caps.TrueColor {
+		t.Skipf("strider: tmux %s does not keep 24-bit colors", caps.TmuxVersion)
+	}
+}
+
+// SkipIfTmuxOlderThan skips the test if the tmux version is below version,
+// such as "3.3":
+//
+//	strider.SkipIfTmuxOlderThan(t, "3.3")
+func SkipIfTmuxOlderThan(t testing.TB, version string) {
+	t.Helper()
+	if caps := requireCapabilities(t, "skip-if-tmux-older-than"); !caps.TmuxAtLeast(version) {
+		t.Skipf("strider: requires tmux %s or newer, found %s", version, caps.TmuxVersion)
+	}
+}
@@ -0,0 +1,203 @@
+// Command strider provides developer tooling for the strider testing library.
+//
+// Usage:
+//
+//	strider snapshots [-list] [-accept-all] [-reject-all] [dir ...]
+//
+// The snapshots subcommand finds pending snapshots left behind by failed
+// MatchSnapshot comparisons (saved next to the golden file as
+// <name>.txt.new), shows a diff against the current golden file, and asks
+// whether to accept it (overwrite the golden file), reject it (delete the
+// pending file), or skip it. Directories default to the current directory
+// and are searched recursively.
+package main
+
+import (
+	"bufio"
+	"flag"
+	"fmt"
+	"io"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"github.com/cboone/strider/internal/textdiff"
+)
+
+// pendingSuffix matches the suffix strider uses for pending snapshots.
+const pendingSuffix = ".new"
+
+func main() {
+	os.Exit(run(os.Args[1:], os.Stdin, os.Stdout, os.Stderr))
+}
+
+func run(args []string, stdin io.Reader, stdout, stderr io.Writer) int {
+	if len(args) == 0 || args[0] != "snapshots" {
+		fmt.Fprintln(stderr, "usage: strider snapshots [-list] [-accept-all] [-reject-all] [dir ...]")
+		return 2
+	}
+
+	fset := flag.NewFlagSet("snapshots", flag.ContinueOnError)
+	fset.SetOutput(stderr)
+	list := fset.Bool("list", false, "list pending snapshots without reviewing them")
+	acceptAll := fset.Bool("accept-all", false, "accept every pending snapshot without prompting")
+	rejectAll := fset.Bool("reject-all", false, "reject every pending snapshot without prompting")
+	if err := fset.Parse(args[1:]); err != nil {
+		return 2
+	}
+	if *acceptAll && *rejectAll {
+		fmt.Fprintln(stderr, "strider: snapshots: -accept-all and -reject-all are mutually exclusive")
+		return 2
+	}
+
+	roots := fset.Args()
+	if len(roots) == 0 {
+		roots = []string{"."}
+	}
+
+	pending, err := findPending(roots)
+	if err != nil {
+		fmt.Fprintf(stderr, "strider: snapshots: %v\n", err)
+		return 1
+	}
+	if len(pending) == 0 {
+		fmt.Fprintln(stdout, "no pending snapshots")
+		return 0
+	}
+
+	if *list {
+		for _, p := range pending {
+			fmt.Fprintln(stdout, strings.TrimSuffix(p, pendingSuffix))
+		}
+		return 0
+	}
+
+	in := bufio.NewReader(stdin)
+	accepted, rejected, skipped := 0, 0, 0
+	for i, p := range pending {
+		golden := strings.TrimSuffix(p, pendingSuffix)
+
+		action := "s"
+		switch {
+		case *acceptAll:
+			action = "a"
+		case *rejectAll:
+			action = "r"
+		default:
+			if err := showDiff(stdout, golden, p, i+1, len(pending)); err != nil {
+				fmt.Fprintf(stderr, "strider: snapshots: %v\n", err)
+				return 1
+			}
+			action = prompt(in, stdout)
+		}
+
+		switch action {
+		case "a":
+			if err := os.Rename(p, golden); err != nil {
+				fmt.Fprintf(stderr, "strider: snapshots: accept %s: %v\n", golden, err)
+				return 1
+			}
+			accepted++
+		case "r":
+			if err := os.Remove(p); err != nil {
+				fmt.Fprintf(stderr, "strider: snapshots: reject %s: %v\n", golden, err)
+				return 1
+			}
+			rejected++
+		case "q":
+			skipped += len(pending) - i
+			fmt.Fprintf(stdout, "%d accepted, %d rejected, %d skipped\n", accepted, rejected, skipped)
+			return 0
+		default:
+			skipped++
+		}
+	}
+
+	fmt.Fprintf(stdout, "%d accepted, %d rejected, %d skipped\n", accepted, rejected, skipped)
+	return 0
+}
+
+// findPending returns the sorted paths of all pending snapshot files under
+// roots. Only files inside a testdata directory are considered.
+func findPending(roots []string) ([]string, error) {
+	var found []string
+	for _, root := range roots {
+		err := filepath.WalkDir(root, func(path string, d fs.DirEntry, err error) error {
+			if err != nil {
+				return err
+			}
+			if d.IsDir() {
+				return nil
+			}
+			if strings.HasSuffix(path, ".txt"+pendingSuffix) && inTestdata(path) {
+				found = append(found, path)
+			}
+			return nil
+		})
+		if err != nil {
+			return nil, err
+		}
+	}
+	sort.Strings(found)
+	return found, nil
+}
+
+func inTestdata(path string) bool {
+	for _, part := range strings.Split(filepath.ToSlash(filepath.Dir(path)), "/") {
+		if part == "testdata" {
+			return true
+		}
+	}
+	return false
+}
+
+// showDiff prints a line diff between the golden file and the pending file.
+// A missing golden file is shown as empty.
+func showDiff(w io.Writer, golden, pending string, n, total int) error {
+	want, err := os.ReadFile(golden)
+	if err != nil && !os.IsNotExist(err) {
+		return err
+	}
+	got, err := os.ReadFile(pending)
+	if err != nil {
+		return err
+	}
+
+	status := "changed"
+	if want == nil {
+		status = "new"
+	}
+	fmt.Fprintf(w, "\n[%d/%d] %s (%s)\n", n, total, golden, status)
+	for _, line := range textdiff.Lines(splitLines(string(want)), splitLines(string(got))) {
+		fmt.Fprintln(w, line)
+	}
+	return nil
+}
+
+// prompt asks for an action until a valid answer is read. End of input is
+// treated as quit.
+func prompt(in *bufio.Reader, w io.Writer) string {
+	for {
+		fmt.Fprint(w, "[a]ccept, [r]eject, [s]kip, [q]uit? ")
+		line, err := in.ReadString('\n')
+		answer := strings.ToLower(strings.TrimSpace(line))
+		switch answer {
+		case "a", "r", "s", "q":
+			return answer
+		}
+		if err != nil {
+			fmt.Fprintln(w)
+			return "q"
+		}
+	}
+}
+
+func splitLines(s string) []string {
+	s = strings.TrimSuffix(s, "\n")
+	if s == "" {
+		return nil
+	}
+	return strings.Split(s, "\n")
+}
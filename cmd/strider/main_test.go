@@ -0,0 +1,71 @@
+package main
+
+import (
+	"bytes"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func writeFile(t *testing.T, path, content string) {
+	t.Helper()
+	if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(path, []byte(content), 0o644); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestSnapshotsReview(t *testing.T) {
+	root := t.TempDir()
+	accept := filepath.Join(root, "testdata", "TestA-00000000", "screen.txt")
+	reject := filepath.Join(root, "testdata", "TestB-00000000", "screen.txt")
+	writeFile(t, accept, "old\n")
+	writeFile(t, accept+pendingSuffix, "new\n")
+	writeFile(t, reject, "keep\n")
+	writeFile(t, reject+pendingSuffix, "discard\n")
+
+	var stdout, stderr bytes.Buffer
+	code := run([]string{"snapshots", root}, strings.NewReader("a\nr\n"), &stdout, &stderr)
+	if code != 0 {
+		t.Fatalf("run() = %d, stderr:\n%s", code, stderr.String())
+	}
+
+	out := stdout.String()
+	if !strings.Contains(out, "- old") || !strings.Contains(out, "+ new") {
+		t.Errorf("expected diff in output, got:\n%s", out)
+	}
+	if !strings.Contains(out, "1 accepted, 1 rejected, 0 skipped") {
+		t.Errorf("expected summary in output, got:\n%s", out)
+	}
+
+	if got, _ := os.ReadFile(accept); string(got) != "new\n" {
+		t.Errorf("accepted golden = %q, want %q", got, "new\n")
+	}
+	if got, _ := os.ReadFile(reject); string(got) != "keep\n" {
+		t.Errorf("rejected golden = %q, want %q", got, "keep\n")
+	}
+	for _, p := range []string{accept + pendingSuffix, reject + pendingSuffix} {
+		if _, err := os.Stat(p); !os.IsNotExist(err) {
+			t.Errorf("expected pending file %s to be removed", p)
+		}
+	}
+}
+
+func TestSnapshotsList(t *testing.T) {
+	root := t.TempDir()
+	golden := filepath.Join(root, "testdata", "TestA-00000000", "screen.txt")
+	writeFile(t, golden+pendingSuffix, "new\n")
+	writeFile(t, filepath.Join(root, "other", "ignored.txt"+pendingSuffix), "x\n")
+
+	var stdout, stderr bytes.Buffer
+	code := run([]string{"snapshots", "-list", root}, strings.NewReader(""), &stdout, &stderr)
+	if code != 0 {
+		t.Fatalf("run() = %d, stderr:\n%s", code, stderr.String())
+	}
+	if got := strings.TrimSpace(stdout.String()); got != golden {
+		t.Errorf("list output = %q, want %q", got, golden)
+	}
+}
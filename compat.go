@@ -0,0 +1,48 @@
+package strider
+
+import "strings"
+
+// tmuxShim smooths over one known difference in how tmux versions render
+// the same output in captures.
+type tmuxShim struct {
+	// desc says what differs between versions.
+	desc  string
+	apply func(string) string
+}
+
+// tmuxShims are applied, in order, to both sides of a snapshot comparison
+// made with WithTmuxCompat. The version that recorded a golden file is not
+// known, so every shim applies on every version and maps all known
+// renderings to the same text. Shims must only remove differences that do
+// not change what a user sees.
+var tmuxShims = []tmuxShim{
+	{
+		desc:  "variation selectors (U+FE0E, U+FE0F) after emoji are kept in captures by some tmux versions and dropped by others",
+		apply: stripVariationSelectors,
+	},
+}
+
+// tmuxCompat applies every shim in tmuxShims to s.
+func tmuxCompat(s string) string {
+	for _, shim := range tmuxShims {
+		s = shim.apply(s)
+	}
+	return s
+}
+
+// stripVariationSelectors removes the text and emoji presentation selectors.
+func stripVariationSelectors(s string) string {
+	if !strings.ContainsAny(s, "\uFE0E\uFE0F") {
+		return s
+	}
+	return strings.NewReplacer("\uFE0E", "", "\uFE0F", "").Replace(s)
+}
+
+// canonical returns the function matchGolden compares golden files with,
+// or nil for an exact comparison.
+func (o snapshotOptions) canonical() func(string) string {
+	if o.tmuxCompat {
+		return tmuxCompat
+	}
+	return nil
+}
@@ -0,0 +1,74 @@
+package strider
+
+import (
+	"strings"
+	"time"
+)
+
+// composeQuietPolls is the number of poll intervals the screen must stay
+// unchanged after a key of Compose before it counts as settled.
+const composeQuietPolls = 3
+
+// Compose sends seq one key at a time, the way a user enters text through a
+// composition: a dead key and the letter it accents, or pinyin letters
+// followed by the digit selecting a candidate. After each key it waits for
+// the screen to settle, staying unchanged for three poll intervals, and
+// returns the screen after each key, so the partial (pre-edit) states a
+// program draws in between can be checked:
+//
+//	frames := term.Compose("n", "i", "h", "a", "o", "1")
+//	if !frames[4].Contains("nihao") {
+//		t.Errorf("pre-edit text not shown:\n%s", frames[4])
+//	}
+//	term.WaitFor(strider.Text("你好"))
+//
+// Compose is for programs that compose text themselves from the keys they
+// receive. Input methods of the desktop compose outside the terminal and
+// deliver only the finished text, which Type sends the same way. Compose
+// fails the test if the screen keeps changing for the whole wait timeout
+// after a key.
+func (term *Terminal) Compose(seq ...Key) []*Screen {
+	term.t.Helper()
+	keys := make([]string, len(seq))
+	for i, k := range seq {
+		if err := ValidateKey(k); err != nil {
+			term.fatalf("strider: compose: %v", err)
+		}
+		keys[i] = string(k)
+	}
+	term.record("compose %s", strings.Join(keys, " "))
+
+	frames := make([]*Screen, 0, len(keys))
+	for i, k := range keys {
+		term.check(term.sendKeys([]string{k}))
+		scr, ok := term.waitSettled("compose")
+		if !ok {
+			term.fatalf("strider: compose: the screen did not settle within %v after key %d (%q)\n%s",
+				term.opts.timeout, i+1, k, formatScreenBox(scr))
+		}
+		frames = append(frames, scr)
+	}
+	return frames
+}
+
+// waitSettled waits, up to the Terminal's timeout, for the screen to stay
+// unchanged for composeQuietPolls poll intervals. It returns the last
+// capture and whether the screen settled.
+func (term *Terminal) waitSettled(op string) (*Screen, bool) {
+	term.t.Helper()
+	deadline := time.Now().Add(term.opts.timeout)
+	last := term.captureScreen(op)
+	quiet := 0
+	for time.Now().Before(deadline) {
+		time.Sleep(term.opts.pollInterval)
+		scr := term.captureScreen(op)
+		if !sameCapture(scr, last) {
+			last, quiet = scr, 0
+			continue
+		}
+		if quiet++; quiet >= composeQuietPolls {
+			return last, true
+		}
+	}
+	return last, false
+}
@@ -45,24 +45,45 @@
 //   - Per-call negative timeout or poll values fail the test immediately
 //   - If the process exits early, waits fail immediately with diagnostics
 //
-// Built-in matchers include [Text], [Regexp], [Line], [LineContains], [Not],
-// [All], [Any], [Empty], and [Cursor].
+// Built-in matchers:
+//
+//   - Content: [Text], [TextWrapped], [TextAll], [TextAny], [Regexp]
+//   - Lines: [Line], [LineContains], [LineMatches]
+//   - Position and layout: [Cursor], [CursorWithin], [SizeIs],
+//     [RightAlignedOn], [CenteredOn], [RegionEquals], [BoxContaining]
+//   - State: [Empty], [SameAs]
+//   - Composition: [Not], [All], [Any]
+//
+// [Textf], [Linef], and [LineContainsf] build their expected value with
+// fmt.Sprintf.
 //
 // # Screen Capture
 //
 // [Terminal.Screen] captures the visible pane. [Terminal.Scrollback] captures
 // full scrollback history. A [Screen] is immutable and provides helpers such as
-// [Screen.String], [Screen.Lines], [Screen.Line], [Screen.Contains], and
-// [Screen.Size].
+// [Screen.String], [Screen.Lines], [Screen.Line], [Screen.Column],
+// [Screen.Boxes], [Screen.Contains], and [Screen.Size]. For scrollback
+// captures, [Screen.TotalLines] and [Screen.VisibleRange] distinguish history
+// rows from the visible pane.
+// [Terminal.ScreenPrimary] and [Terminal.ScreenAlternate] capture a specific
+// screen buffer regardless of which one the program is displaying, and
+// [Terminal.ScrollView] captures the view after scrolling the terminal up.
 //
 // # Snapshots
 //
 // [Terminal.MatchSnapshot] and [Screen.MatchSnapshot] compare screen content to
 // golden files under testdata. Set STRIDER_UPDATE=1 to create or update golden
-// files.
+// files, or pass -strider.update when flags are registered with
+// [RegisterFlags].
 //
 // Snapshot content is normalized for stable diffs by trimming trailing spaces,
 // trimming trailing blank lines, and writing a single trailing newline.
+// [WithEscapedFormat] skips normalization and stores every row quoted, to pin
+// exact spacing.
+//
+// [WithTranscript] records a whole session, input interleaved with the screen
+// each wait matched, and compares it to a golden transcript at the end of the
+// test.
 //
 // # Diagnostics
 //
@@ -71,13 +92,18 @@
 //   - expected matcher description
 //   - timeout or exit details
 //   - multiple recent screen captures (oldest to newest)
+//   - optionally, the tail of the scrollback buffer ([WithScrollbackTail])
+//
+// If the program exited unexpectedly, the failure also shows the command,
+// environment, and working directory it was started with, and the tail of
+// its output.
 //
 // This keeps failures actionable without extra debug tooling.
 //
 // # Requirements
 //
 //   - Go 1.24+
-//   - tmux 3.0+
+//   - tmux 3.0+, unless [WithBackend] selects the PTY backend
 //   - Linux or macOS
 //
 // tmux is resolved in this order:
@@ -85,4 +111,8 @@
 //   - [WithTmuxPath]
 //   - STRIDER_TMUX
 //   - PATH lookup for tmux
+//
+// Without tmux, [WithBackend] with [PTY], or STRIDER_BACKEND=pty, runs the
+// program on a pseudo-terminal and renders its output with an emulator in
+// the test process that follows tmux, so snapshots match on both.
 package strider
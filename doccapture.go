@@ -0,0 +1,120 @@
+package strider
+
+import (
+	"fmt"
+	"html"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// Capture saves the current screen for documentation under name, if
+// WithDocCaptures is set, and does nothing otherwise. It writes
+// <dir>/<sanitized-name>.txt and <dir>/<sanitized-name>.svg, replacing any
+// earlier capture of the same name, whether or not the test passes.
+//
+// Capture names are not scoped to the test: two tests capturing under the
+// same name overwrite each other's files.
+func (term *Terminal) Capture(name string) {
+	term.t.Helper()
+	if term.opts.docCaptures == "" {
+		return
+	}
+	term.docCapture(name, term.Screen())
+}
+
+// docCapture writes scr to the WithDocCaptures directory under name, if the
+// option is set. Fails the test if the files cannot be written.
+func (term *Terminal) docCapture(name string, scr *Screen) {
+	term.t.Helper()
+	dir := term.opts.docCaptures
+	if dir == "" {
+		return
+	}
+	if err := os.MkdirAll(dir, 0o755); err != nil {
+		term.fatalf("strider: capture: failed to create directory: %v", err)
+	}
+	base := filepath.Join(dir, sanitizeName(name))
+	if err := writeFileAtomic(base+".txt", NormalizeScreen(scr.String())); err != nil {
+		term.fatalf("strider: capture: failed to write capture: %v", err)
+	}
+	if err := writeFileAtomic(base+".svg", renderSVG(scr)); err != nil {
+		term.fatalf("strider: capture: failed to write capture: %v", err)
+	}
+}
+
+// Layout of the SVG rendering, in pixels.
+const (
+	svgCellWidth  = 8.4 // advance of a 14px monospace character
+	svgCellHeight = 18
+	svgFontSize   = 14
+	svgPadding    = 12
+)
+
+// renderSVG renders scr as a standalone SVG image: light text on a dark
+// background, with the cursor, when known, drawn as a block. Characters are
+// placed by display column, so wide characters keep the grid aligned
+// regardless of the viewer's font.
+func renderSVG(scr *Screen) string {
+	w := float64(scr.width)*svgCellWidth + 2*svgPadding
+	h := max(scr.height, len(scr.lines))*svgCellHeight + 2*svgPadding
+
+	var b strings.Builder
+	fmt.Fprintf(&b, `<svg xmlns="http://www.w3.org/2000/svg" width="%g" height="%d" viewBox="0 0 %g %d">`+"\n", w, h, w, h)
+	fmt.Fprintf(&b, `<rect width="100%%" height="100%%" rx="6" fill="#1e1e1e"/>`+"\n")
+	if row, col := scr.cursorRow, scr.cursorCol; row >= 0 && row < scr.height && col >= 0 && col < scr.width {
+		fmt.Fprintf(&b, `<rect x="%g" y="%d" width="%g" height="%d" fill="#808080"/>`+"\n",
+			svgPadding+float64(col)*svgCellWidth, svgPadding+row*svgCellHeight, svgCellWidth, svgCellHeight)
+	}
+	fmt.Fprintf(&b, `<g font-family="Menlo, Consolas, 'DejaVu Sans Mono', monospace" font-size="%d" fill="#d4d4d4" xml:space="preserve">`+"\n", svgFontSize)
+	for i, line := range scr.lines {
+		line = strings.TrimRight(line, " ")
+		if line == "" {
+			continue
+		}
+		// The baseline sits about a quarter of the cell above its bottom.
+		y := svgPadding + i*svgCellHeight + svgCellHeight*3/4
+		fmt.Fprintf(&b, `<text y="%d">`, y)
+		for _, seg := range svgSegments(line) {
+			fmt.Fprintf(&b, `<tspan x="%g">%s</tspan>`, svgPadding+float64(seg.col)*svgCellWidth, html.EscapeString(seg.text))
+		}
+		b.WriteString("</text>\n")
+	}
+	b.WriteString("</g>\n</svg>\n")
+	return b.String()
+}
+
+// svgSegment is a run of line starting at display column col.
+type svgSegment struct {
+	col  int
+	text string
+}
+
+// svgSegments splits line into runs of single-width characters, each
+// placed at its own column, and gives every wide character a run of its
+// own, so that no run depends on the font's width for wide characters.
+func svgSegments(line string) []svgSegment {
+	var segs []svgSegment
+	var cur strings.Builder
+	start, col := 0, 0
+	flush := func() {
+		if cur.Len() > 0 {
+			segs = append(segs, svgSegment{col: start, text: cur.String()})
+			cur.Reset()
+		}
+	}
+	for _, r := range line {
+		w := runeWidth(r)
+		if w > 1 {
+			flush()
+			segs = append(segs, svgSegment{col: col, text: string(r)})
+			col += w
+			start = col
+			continue
+		}
+		cur.WriteRune(r)
+		col += w
+	}
+	flush()
+	return segs
+}
@@ -0,0 +1,102 @@
+package strider
+
+import (
+	"fmt"
+	"math/rand/v2"
+	"os"
+	"runtime"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/cboone/strider/internal/terminfo"
+)
+
+// childEnv returns the environment entries passed to the program: entries
+// derived from options first, then the entries from WithEnv, so that
+// WithEnv can override anything strider sets.
+func childEnv(opts options) []string {
+	var env []string
+	if opts.sizeEnv && !opts.noSizeEnv {
+		env = append(env,
+			fmt.Sprintf("COLUMNS=%d", opts.width),
+			fmt.Sprintf("LINES=%d", opts.height),
+		)
+	}
+	if opts.frozenClock != nil {
+		env = append(env, frozenClockEnv(*opts.frozenClock)...)
+	}
+	if opts.seed != nil {
+		env = append(env, fmt.Sprintf("STRIDER_SEED=%d", *opts.seed))
+	}
+	if opts.terminfoDir != "" {
+		env = append(env, "TERM="+terminfo.Name, "TERMINFO="+opts.terminfoDir)
+	}
+	return append(env, opts.env...)
+}
+
+// chooseSeed returns the seed for WithRandomSeed: STRIDER_SEED from the
+// test process's environment if set, or a random non-negative seed.
+func chooseSeed() (int64, error) {
+	if v := os.Getenv("STRIDER_SEED"); v != "" {
+		n, err := strconv.ParseInt(v, 10, 64)
+		if err != nil {
+			return 0, fmt.Errorf("invalid STRIDER_SEED %q: %w", v, err)
+		}
+		return n, nil
+	}
+	return rand.Int64(), nil
+}
+
+// frozenClockEnv returns the environment entries for WithFrozenClock.
+func frozenClockEnv(t0 time.Time) []string {
+	t0 = t0.UTC()
+	env := []string{
+		"STRIDER_NOW=" + t0.Format(time.RFC3339Nano),
+		"FAKETIME=" + t0.Format("2006-01-02 15:04:05"),
+		"TZ=UTC",
+	}
+
+	lib := findLibfaketime()
+	if lib == "" {
+		return env
+	}
+	if runtime.GOOS == "darwin" {
+		return append(env, "DYLD_INSERT_LIBRARIES="+lib, "DYLD_FORCE_FLAT_NAMESPACE=1")
+	}
+	return append(env, "LD_PRELOAD="+lib)
+}
+
+// libfaketimePaths lists common install locations of libfaketime.
+var libfaketimePaths = []string{
+	"/usr/lib/x86_64-linux-gnu/faketime/libfaketime.so.1",
+	"/usr/lib/aarch64-linux-gnu/faketime/libfaketime.so.1",
+	"/usr/lib/faketime/libfaketime.so.1",
+	"/usr/lib64/faketime/libfaketime.so.1",
+	"/usr/local/lib/faketime/libfaketime.so.1",
+	"/opt/homebrew/lib/faketime/libfaketime.1.dylib",
+	"/usr/local/lib/faketime/libfaketime.1.dylib",
+}
+
+// findLibfaketime returns the path of libfaketime, or "" if it is not
+// installed. STRIDER_LIBFAKETIME overrides the search.
+func findLibfaketime() string {
+	if p := os.Getenv("STRIDER_LIBFAKETIME"); p != "" {
+		return p
+	}
+	for _, p := range libfaketimePaths {
+		if _, err := os.Stat(p); err == nil {
+			return p
+		}
+	}
+	return ""
+}
+
+// shellQuote quotes s for display as a POSIX shell word, leaving it bare when
+// it contains only characters that need no quoting.
+func shellQuote(s string) string {
+	if s != "" && strings.Trim(s, "abcdefghijklmnopqrstuvwxyzABCDEFGHIJKLMNOPQRSTUVWXYZ0123456789_@%+=:,./-") == "" {
+		return s
+	}
+	return "'" + strings.ReplaceAll(s, "'", `'\''`) + "'"
+}
@@ -1,6 +1,7 @@
 package strider_test
 
 import (
+	"fmt"
 	"testing"
 	"time"
 
@@ -38,3 +39,9 @@ func ExampleTerminal_MatchSnapshot() {
 		term.MatchSnapshot("dashboard")
 	}
 }
+
+func ExampleNormalizeScreen() {
+	raw := "\x1b[1mName:\x1b[0m    Alice   \n\n\n"
+	fmt.Printf("%q\n", strider.NormalizeScreen(raw, strider.WithStripANSI(), strider.WithCollapseSpaces()))
+	// Output: "Name: Alice\n"
+}
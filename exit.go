@@ -0,0 +1,44 @@
+package strider
+
+import (
+	"errors"
+	"fmt"
+)
+
+// ExitState describes a program exit the test did not expect: the program
+// exited while a Terminal method needed it running.
+type ExitState struct {
+	// Op is the operation that found the program exited, such as "wait-for"
+	// or "send-keys".
+	Op string
+	// Code is the program's exit status.
+	Code int
+}
+
+// ErrSkip, returned or wrapped by an exit classifier, skips the test instead
+// of failing it (see WithExitClassifier).
+var ErrSkip = errors.New("skip")
+
+// classifyExit runs the classifier set with WithExitClassifier on an
+// unexpected exit. It returns the classifier's explanation formatted for
+// failure output, or "" when there is none, and a skipError if the
+// classifier returns ErrSkip.
+func (term *Terminal) classifyExit(op string, status int, scr *Screen) (string, error) {
+	if term.opts.exitClassifier == nil {
+		return "", nil
+	}
+	if scr == nil {
+		scr = term.captureScreenRaw()
+	}
+	if scr == nil {
+		scr = NewScreen(term.opts.width, term.opts.height)
+	}
+	err := term.opts.exitClassifier(ExitState{Op: op, Code: status}, scr)
+	if err == nil {
+		return "", nil
+	}
+	if errors.Is(err, ErrSkip) {
+		return "", &skipError{msg: fmt.Sprintf("strider: %s: process exited (status %d): %v", op, status, err)}
+	}
+	return "\n    exit: " + err.Error(), nil
+}
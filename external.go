@@ -0,0 +1,123 @@
+package strider
+
+import (
+	"fmt"
+	"net"
+	"net/http"
+	"os"
+	"strings"
+	"time"
+)
+
+// markExternal records that a matcher evaluated on s checked state outside
+// the screen. Waits then run matchers on every poll, even when the screen
+// has not changed, and Memoize does not cache the result.
+func (s *Screen) markExternal() {
+	s.external.Store(true)
+}
+
+// FileExists matches when a file exists at path, whatever the screen shows.
+// Combine it with screen matchers to wait for a program's side effects in
+// the same wait:
+//
+//	term.Press(strider.Ctrl('s'))
+//	term.WaitFor(strider.All(
+//		strider.Text("Saved"),
+//		strider.FileExists(filepath.Join(term.Dir(), "notes.txt")),
+//	))
+//
+// A relative path is resolved against the test's working directory, not
+// the program's; join it with Terminal.Dir for files the program writes.
+func FileExists(path string) Matcher {
+	return func(scr *Screen) (bool, string) {
+		scr.markExternal()
+		desc := fmt.Sprintf("file %s to exist", path)
+		if _, err := os.Stat(path); err != nil {
+			return false, desc + fmt.Sprintf(" (%v)", describeFileError(err))
+		}
+		return true, desc
+	}
+}
+
+// FileContains matches when the file at path exists and contains substr,
+// whatever the screen shows. See FileExists.
+func FileContains(path, substr string) Matcher {
+	return func(scr *Screen) (bool, string) {
+		scr.markExternal()
+		desc := fmt.Sprintf("file %s to contain %q", path, substr)
+		data, err := os.ReadFile(path)
+		if err != nil {
+			return false, desc + fmt.Sprintf(" (%v)", describeFileError(err))
+		}
+		if !strings.Contains(string(data), substr) {
+			return false, desc + fmt.Sprintf(" (actual content: %s)", truncateContent(string(data)))
+		}
+		return true, desc
+	}
+}
+
+// describeFileError shortens the error of a failed file access for a
+// matcher description.
+func describeFileError(err error) string {
+	if os.IsNotExist(err) {
+		return "not found"
+	}
+	return err.Error()
+}
+
+// maxContentInDescription bounds the file content quoted in descriptions.
+const maxContentInDescription = 200
+
+// truncateContent quotes s for a description, keeping only its end when it
+// is long: appended output is usually the interesting part.
+func truncateContent(s string) string {
+	if len(s) <= maxContentInDescription {
+		return fmt.Sprintf("%q", s)
+	}
+	return fmt.Sprintf("...%q (%d bytes)", s[len(s)-maxContentInDescription:], len(s))
+}
+
+// externalCheckTimeout bounds each network check of PortOpen and
+// HTTPHealthy, so one poll cannot hold up a wait for long.
+const externalCheckTimeout = time.Second
+
+// PortOpen matches when a TCP connection to addr ("host:port") succeeds,
+// whatever the screen shows. Use it to wait for a companion service, such
+// as a backend the program under test connects to, in the same way as for
+// the program itself:
+//
+//	term.WaitFor(strider.All(
+//		strider.PortOpen("127.0.0.1:8080"),
+//		strider.Text("Connected"),
+//	))
+func PortOpen(addr string) Matcher {
+	return func(scr *Screen) (bool, string) {
+		scr.markExternal()
+		desc := fmt.Sprintf("port %s to accept connections", addr)
+		conn, err := net.DialTimeout("tcp", addr, externalCheckTimeout)
+		if err != nil {
+			return false, desc + fmt.Sprintf(" (%v)", err)
+		}
+		conn.Close()
+		return true, desc
+	}
+}
+
+// HTTPHealthy matches when a GET request to url returns a 2xx status,
+// whatever the screen shows. See PortOpen.
+func HTTPHealthy(url string) Matcher {
+	client := &http.Client{Timeout: externalCheckTimeout}
+	return func(scr *Screen) (bool, string) {
+		scr.markExternal()
+		desc := fmt.Sprintf("GET %s to succeed", url)
+		resp, err := client.Get(url)
+		if err != nil {
+			return false, desc + fmt.Sprintf(" (%v)", err)
+		}
+		resp.Body.Close()
+		if resp.StatusCode < 200 || resp.StatusCode > 299 {
+			return false, desc + fmt.Sprintf(" (status %s)", resp.Status)
+		}
+		return true, desc
+	}
+}
@@ -0,0 +1,57 @@
+package strider
+
+import (
+	"flag"
+	"fmt"
+	"regexp"
+	"time"
+)
+
+// flagConfig holds values set through the flags registered by RegisterFlags.
+// It is written during flag parsing (before tests run) and read-only after.
+var flagConfig struct {
+	update        bool
+	timeout       time.Duration
+	keep          bool
+	updatePattern *regexp.Regexp
+}
+
+// RegisterFlags registers strider's command-line flags on fs. If fs is nil,
+// flag.CommandLine is used. Call it from TestMain before m.Run:
+//
+//	func TestMain(m *testing.M) {
+//		strider.RegisterFlags(nil)
+//		os.Exit(m.Run())
+//	}
+//
+// The registered flags are:
+//
+//   - -strider.update: create or update golden files (like STRIDER_UPDATE=1)
+//   - -strider.timeout: default wait timeout for terminals that do not set
+//     WithTimeout
+//   - -strider.keep: leave tmux servers running after each test and log the
+//     command to attach to them
+//   - -strider.run-pattern-update: update golden files only for tests whose
+//     full name matches the regular expression
+func RegisterFlags(fs *flag.FlagSet) {
+	if fs == nil {
+		fs = flag.CommandLine
+	}
+
+	fs.BoolVar(&flagConfig.update, "strider.update", false,
+		"create or update strider golden files")
+	fs.DurationVar(&flagConfig.timeout, "strider.timeout", 0,
+		"default strider wait timeout (0 uses the built-in default)")
+	fs.BoolVar(&flagConfig.keep, "strider.keep", false,
+		"leave strider tmux servers running after each test")
+	fs.Func("strider.run-pattern-update",
+		"update strider golden files only for tests matching `regexp`",
+		func(s string) error {
+			re, err := regexp.Compile(s)
+			if err != nil {
+				return fmt.Errorf("invalid pattern: %w", err)
+			}
+			flagConfig.updatePattern = re
+			return nil
+		})
+}
@@ -0,0 +1,34 @@
+package strider
+
+// Inputter sends input to a program. *Terminal implements it, as does the
+// fake Terminal in package striderfake.
+//
+// The interfaces in this file let helpers such as page objects accept the
+// smallest set of Terminal methods they use, so they compose and can be
+// tested with a fake.
+type Inputter interface {
+	Type(s string)
+	Press(keys ...Key)
+	SendKeys(keys ...string)
+}
+
+// Capturer captures the screen of a program.
+type Capturer interface {
+	Screen() *Screen
+}
+
+// Waiter waits for the screen of a program to match.
+type Waiter interface {
+	WaitFor(m Matcher, wopts ...WaitOption)
+	WaitForScreen(m Matcher, wopts ...WaitOption) *Screen
+}
+
+// Console combines Inputter, Capturer, and Waiter: everything a helper
+// needs to drive a program through its screen.
+type Console interface {
+	Inputter
+	Capturer
+	Waiter
+}
+
+var _ Console = (*Terminal)(nil)
@@ -0,0 +1,358 @@
+// Package bidi reorders a line of text from logical to visual order with a
+// simplified Unicode Bidirectional Algorithm (UAX #9). It is internal to the
+// strider module.
+//
+// The simplification: explicit embeddings, overrides, and isolates are
+// treated as neutrals, and the text is a single line of a single paragraph.
+// That covers what terminal programs render: runs of right-to-left words and
+// numbers inside left-to-right text, or the reverse.
+package bidi
+
+import "unicode"
+
+// class is the bidirectional character type of a rune, reduced to the types
+// the simplified algorithm distinguishes.
+type class uint8
+
+const (
+	classL   class = iota // left-to-right letter
+	classR                // right-to-left letter (Hebrew and similar)
+	classAL               // Arabic letter
+	classEN               // European digit
+	classAN               // Arabic-Indic digit
+	classES               // number separator: + -
+	classET               // number terminator: # $ % and currency signs
+	classCS               // common number separator: , . / :
+	classNSM              // nonspacing mark
+	classWS               // whitespace
+	classON               // other neutral
+)
+
+// classify returns the bidirectional type of r.
+func classify(r rune) class {
+	switch {
+	case r >= '0' && r <= '9', r >= 0x06f0 && r <= 0x06f9:
+		return classEN
+	case r >= 0x0660 && r <= 0x0669:
+		return classAN
+	case r == '+' || r == '-':
+		return classES
+	case r == '#' || r == '$' || r == '%' || unicode.Is(unicode.Sc, r):
+		return classET
+	case r == ',' || r == '.' || r == '/' || r == ':':
+		return classCS
+	case unicode.Is(unicode.Mn, r):
+		return classNSM
+	case unicode.IsSpace(r):
+		return classWS
+	case unicode.In(r, unicode.Arabic, unicode.Syriac, unicode.Thaana):
+		if unicode.IsLetter(r) {
+			return classAL
+		}
+		return classON
+	case unicode.In(r, unicode.Hebrew, unicode.Nko, unicode.Samaritan, unicode.Mandaic):
+		if unicode.IsLetter(r) {
+			return classR
+		}
+		return classON
+	case unicode.IsLetter(r) || unicode.IsDigit(r):
+		return classL
+	}
+	return classON
+}
+
+// Visual returns line in visual order, the left-to-right order of the
+// terminal's cells, for a paragraph with the given base direction.
+func Visual(line string, rtl bool) string {
+	runes := []rune(line)
+	if len(runes) == 0 {
+		return line
+	}
+	base := classL
+	if rtl {
+		base = classR
+	}
+
+	types := make([]class, len(runes))
+	for i, r := range runes {
+		types[i] = classify(r)
+	}
+	resolveWeak(types, base)
+	resolveBrackets(types, runes, base)
+	resolveNeutrals(types, base)
+	levels := resolveLevels(types, runes, rtl)
+	return string(reorder(runes, levels))
+}
+
+// resolveWeak applies rules W1-W7 to types.
+func resolveWeak(types []class, base class) {
+	// W1: a nonspacing mark takes the type of the character before it.
+	for i, t := range types {
+		if t == classNSM {
+			if i == 0 {
+				types[i] = base
+			} else {
+				types[i] = types[i-1]
+			}
+		}
+	}
+
+	// W2: European digits after an Arabic letter are Arabic digits.
+	// W3: Arabic letters are right-to-left.
+	lastStrong := base
+	for i, t := range types {
+		switch t {
+		case classL, classR, classAL:
+			lastStrong = t
+		case classEN:
+			if lastStrong == classAL {
+				types[i] = classAN
+			}
+		}
+	}
+	for i, t := range types {
+		if t == classAL {
+			types[i] = classR
+		}
+	}
+
+	// W4: a single separator between two numbers of the same type joins
+	// them.
+	for i := 1; i+1 < len(types); i++ {
+		prev, next := types[i-1], types[i+1]
+		switch types[i] {
+		case classES:
+			if prev == classEN && next == classEN {
+				types[i] = classEN
+			}
+		case classCS:
+			if prev == next && (prev == classEN || prev == classAN) {
+				types[i] = prev
+			}
+		}
+	}
+
+	// W5: terminators next to European digits are part of the number.
+	for i := 0; i < len(types); i++ {
+		if types[i] != classET {
+			continue
+		}
+		j := i
+		for j < len(types) && types[j] == classET {
+			j++
+		}
+		if (i > 0 && types[i-1] == classEN) || (j < len(types) && types[j] == classEN) {
+			for k := i; k < j; k++ {
+				types[k] = classEN
+			}
+		}
+		i = j
+	}
+
+	// W6: remaining separators and terminators are neutral.
+	for i, t := range types {
+		if t == classES || t == classET || t == classCS {
+			types[i] = classON
+		}
+	}
+
+	// W7: European digits after a left-to-right letter are left-to-right.
+	lastStrong = base
+	for i, t := range types {
+		switch t {
+		case classL, classR:
+			lastStrong = t
+		case classEN:
+			if lastStrong == classL {
+				types[i] = classL
+			}
+		}
+	}
+}
+
+// direction returns the strong direction t counts as for neutrals: numbers
+// count as right-to-left.
+func direction(t class) class {
+	if t == classEN || t == classAN {
+		return classR
+	}
+	return t
+}
+
+// resolveBrackets applies rule N0: a pair of brackets takes the base
+// direction if the text between them has it, else the opposite direction if
+// the text between them and the text before the pair have it.
+func resolveBrackets(types []class, runes []rune, base class) {
+	var open []int // indexes of unmatched opening brackets
+	for i, r := range runes {
+		if types[i] != classON {
+			continue
+		}
+		switch r {
+		case '(', '[', '{':
+			open = append(open, i)
+			continue
+		case ')', ']', '}':
+		default:
+			continue
+		}
+		if len(open) == 0 || mirror(runes[open[len(open)-1]]) != r {
+			continue
+		}
+		start := open[len(open)-1]
+		open = open[:len(open)-1]
+
+		hasBase, hasOpposite := false, false
+		for _, t := range types[start+1 : i] {
+			switch d := direction(t); {
+			case d == base:
+				hasBase = true
+			case d == classL || d == classR:
+				hasOpposite = true
+			}
+		}
+		resolved := classON
+		switch {
+		case hasBase:
+			resolved = base
+		case hasOpposite:
+			resolved = base
+			context := base
+			for k := start - 1; k >= 0; k-- {
+				if d := direction(types[k]); d == classL || d == classR {
+					context = d
+					break
+				}
+			}
+			if context != base {
+				resolved = context
+			}
+		}
+		if resolved != classON {
+			types[start], types[i] = resolved, resolved
+		}
+	}
+}
+
+// resolveNeutrals applies rules N1 and N2: a run of neutrals takes the
+// direction of the text on both sides when it agrees, numbers counting as
+// right-to-left, and the base direction otherwise.
+func resolveNeutrals(types []class, base class) {
+	for i := 0; i < len(types); i++ {
+		if types[i] != classWS && types[i] != classON {
+			continue
+		}
+		j := i
+		for j < len(types) && (types[j] == classWS || types[j] == classON) {
+			j++
+		}
+		before, after := base, base
+		if i > 0 {
+			before = direction(types[i-1])
+		}
+		if j < len(types) {
+			after = direction(types[j])
+		}
+		resolved := base
+		if before == after {
+			resolved = before
+		}
+		for k := i; k < j; k++ {
+			types[k] = resolved
+		}
+		i = j
+	}
+}
+
+// resolveLevels applies rules I1, I2, and L1, returning the embedding level
+// of each rune.
+func resolveLevels(types []class, runes []rune, rtl bool) []int {
+	baseLevel := 0
+	if rtl {
+		baseLevel = 1
+	}
+	levels := make([]int, len(types))
+	for i, t := range types {
+		switch {
+		case baseLevel == 0 && t == classR:
+			levels[i] = 1
+		case baseLevel == 0 && (t == classEN || t == classAN):
+			levels[i] = 2
+		case baseLevel == 1 && (t == classL || t == classEN || t == classAN):
+			levels[i] = 2
+		default:
+			levels[i] = baseLevel
+		}
+	}
+
+	// L1: trailing whitespace takes the base level.
+	for i := len(runes) - 1; i >= 0 && unicode.IsSpace(runes[i]); i-- {
+		levels[i] = baseLevel
+	}
+	return levels
+}
+
+// reorder applies rules L2 and L4: from the highest level down to 1, each
+// run at that level or higher is reversed, and brackets in right-to-left
+// runs are mirrored.
+func reorder(runes []rune, levels []int) []rune {
+	out := make([]rune, len(runes))
+	for i, r := range runes {
+		if levels[i]%2 == 1 {
+			r = mirror(r)
+		}
+		out[i] = r
+	}
+
+	// Levels are at most 2, so the lowest odd level is 1.
+	highest := 0
+	for _, l := range levels {
+		highest = max(highest, l)
+	}
+
+	lv := append([]int(nil), levels...)
+	for level := highest; level >= 1; level-- {
+		for i := 0; i < len(out); i++ {
+			if lv[i] < level {
+				continue
+			}
+			j := i
+			for j < len(out) && lv[j] >= level {
+				j++
+			}
+			for a, b := i, j-1; a < b; a, b = a+1, b-1 {
+				out[a], out[b] = out[b], out[a]
+				lv[a], lv[b] = lv[b], lv[a]
+			}
+			i = j
+		}
+	}
+	return out
+}
+
+// mirror returns the mirrored form of a bracket, or r.
+func mirror(r rune) rune {
+	switch r {
+	case '(':
+		return ')'
+	case ')':
+		return '('
+	case '[':
+		return ']'
+	case ']':
+		return '['
+	case '{':
+		return '}'
+	case '}':
+		return '{'
+	case '<':
+		return '>'
+	case '>':
+		return '<'
+	case '«':
+		return '»'
+	case '»':
+		return '«'
+	}
+	return r
+}
@@ -0,0 +1,50 @@
+package bidi_test
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/cboone/strider/internal/bidi"
+)
+
+// hebrew maps the uppercase letters of s to Hebrew letters (A to alef, B to
+// bet, ...), the convention of the UAX #9 examples, so test cases stay
+// readable.
+func hebrew(s string) string {
+	return strings.Map(func(r rune) rune {
+		if r >= 'A' && r <= 'V' {
+			return 0x05d0 + (r - 'A')
+		}
+		return r
+	}, s)
+}
+
+func TestVisual(t *testing.T) {
+	tests := []struct {
+		name    string
+		logical string
+		rtl     bool
+		want    string
+	}{
+		{"ascii", "hello world", false, "hello world"},
+		{"rtl word in ltr", "hello ABC world", false, "hello CBA world"},
+		{"rtl words keep their order", "see ABC DEF now", false, "see FED CBA now"},
+		{"number in rtl run", "ABC 123 DEF", false, "FED 123 CBA"},
+		{"number after rtl word", "ABC 123", false, "123 CBA"},
+		{"rtl paragraph", "ABC def", true, "def CBA"},
+		{"ltr word in rtl paragraph", "ABC def ghi JKL", true, "LKJ def ghi CBA"},
+		{"brackets mirrored", "ABC (DEF)", false, "(FED) CBA"},
+		{"ltr brackets", "ABC (def)", false, "CBA (def)"},
+		{"trailing spaces stay", "ABC  ", false, "CBA  "},
+		{"decimal number", "ABC 1.5", false, "1.5 CBA"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := bidi.Visual(hebrew(tt.logical), tt.rtl)
+			if want := hebrew(tt.want); got != want {
+				t.Errorf("Visual(%q, %v) = %q, want %q", hebrew(tt.logical), tt.rtl, got, want)
+			}
+		})
+	}
+}
@@ -0,0 +1,87 @@
+// Package cellwidth computes how many terminal columns runes and strings
+// occupy. It is internal to the strider module.
+package cellwidth
+
+import (
+	"strings"
+	"unicode"
+)
+
+// Rune returns the number of terminal columns r occupies: 0 for
+// combining marks and other zero-width characters, 2 for East Asian wide and
+// fullwidth characters (and most emoji), and 1 otherwise. It covers the
+// ranges that matter for TUI layout without a full Unicode width table.
+func Rune(r rune) int {
+	switch {
+	case r == 0:
+		return 0
+	case r < 0x20 || (r >= 0x7f && r < 0xa0):
+		return 0
+	case unicode.Is(unicode.Mn, r) || unicode.Is(unicode.Me, r) || unicode.Is(unicode.Cf, r):
+		return 0
+	case r >= 0x1100 && r <= 0x115f, // Hangul Jamo
+		r >= 0x2e80 && r <= 0x303e,   // CJK radicals, punctuation
+		r >= 0x3041 && r <= 0x33ff,   // Hiragana, Katakana, CJK symbols
+		r >= 0x3400 && r <= 0x4dbf,   // CJK extension A
+		r >= 0x4e00 && r <= 0x9fff,   // CJK unified ideographs
+		r >= 0xa000 && r <= 0xa4cf,   // Yi
+		r >= 0xac00 && r <= 0xd7a3,   // Hangul syllables
+		r >= 0xf900 && r <= 0xfaff,   // CJK compatibility ideographs
+		r >= 0xfe30 && r <= 0xfe4f,   // CJK compatibility forms
+		r >= 0xff00 && r <= 0xff60,   // fullwidth forms
+		r >= 0xffe0 && r <= 0xffe6,   // fullwidth signs
+		r >= 0x1f300 && r <= 0x1f64f, // pictographs, emoticons
+		r >= 0x1f900 && r <= 0x1f9ff, // supplemental symbols and pictographs
+		r >= 0x20000 && r <= 0x3fffd: // CJK extensions B and beyond
+		return 2
+	}
+	return 1
+}
+
+// String returns the number of terminal columns s occupies.
+func String(s string) int {
+	w := 0
+	for _, r := range s {
+		w += Rune(r)
+	}
+	return w
+}
+
+// Slice returns the content of line between display columns left
+// (inclusive) and left+width (exclusive). Cells past the end of the line are
+// filled with spaces, as are the halves of wide characters cut by either
+// boundary.
+func Slice(line string, left, width int) string {
+	var b strings.Builder
+	right := left + width
+	pos := 0
+	for _, r := range line {
+		if pos >= right {
+			break
+		}
+		w := Rune(r)
+		switch {
+		case w == 0:
+			if pos > left && pos <= right {
+				b.WriteRune(r)
+			}
+		case pos >= left && pos+w <= right:
+			b.WriteRune(r)
+		default:
+			// Only part of a wide character falls inside the range.
+			for c := pos; c < pos+w; c++ {
+				if c >= left && c < right {
+					b.WriteByte(' ')
+				}
+			}
+		}
+		pos += w
+	}
+	if pos < left {
+		pos = left
+	}
+	for ; pos < right; pos++ {
+		b.WriteByte(' ')
+	}
+	return b.String()
+}
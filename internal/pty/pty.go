@@ -0,0 +1,14 @@
+// Package pty allocates pseudo-terminals and starts programs on them, for
+// strider's PTY backend. It is internal to the strider module.
+//
+// It supports Linux and macOS. On other systems Start fails with
+// ErrUnsupported.
+package pty
+
+import (
+	"errors"
+	"runtime"
+)
+
+// ErrUnsupported is returned by Start on systems without PTY support.
+var ErrUnsupported = errors.New("pseudo-terminals are not supported on " + runtime.GOOS)
@@ -0,0 +1,48 @@
+package pty
+
+import (
+	"bytes"
+	"os"
+	"syscall"
+	"unsafe"
+)
+
+// open allocates a pseudo-terminal through /dev/ptmx and returns its
+// controlling side and the terminal, with UTF-8 input processing on, so that
+// the line discipline erases whole characters.
+func open() (ptmx, tty *os.File, err error) {
+	ptmx, err = os.OpenFile("/dev/ptmx", os.O_RDWR|syscall.O_NOCTTY, 0)
+	if err != nil {
+		return nil, nil, err
+	}
+	if err := ioctl(ptmx, syscall.TIOCPTYGRANT, 0); err != nil {
+		ptmx.Close()
+		return nil, nil, err
+	}
+	if err := ioctl(ptmx, syscall.TIOCPTYUNLK, 0); err != nil {
+		ptmx.Close()
+		return nil, nil, err
+	}
+	var name [128]byte
+	if err := ioctl(ptmx, syscall.TIOCPTYGNAME, uintptr(unsafe.Pointer(&name[0]))); err != nil {
+		ptmx.Close()
+		return nil, nil, err
+	}
+	if i := bytes.IndexByte(name[:], 0); i >= 0 {
+		tty, err = os.OpenFile(string(name[:i]), os.O_RDWR|syscall.O_NOCTTY, 0)
+	}
+	if tty == nil || err != nil {
+		ptmx.Close()
+		if err == nil {
+			err = syscall.EINVAL
+		}
+		return nil, nil, err
+	}
+
+	var t syscall.Termios
+	if err := ioctl(tty, syscall.TIOCGETA, uintptr(unsafe.Pointer(&t))); err == nil {
+		t.Iflag |= syscall.IUTF8
+		_ = ioctl(tty, syscall.TIOCSETA, uintptr(unsafe.Pointer(&t)))
+	}
+	return ptmx, tty, nil
+}
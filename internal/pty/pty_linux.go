@@ -0,0 +1,40 @@
+package pty
+
+import (
+	"os"
+	"strconv"
+	"syscall"
+	"unsafe"
+)
+
+// open allocates a pseudo-terminal through /dev/ptmx and returns its
+// controlling side and the terminal, with UTF-8 input processing on, so that
+// the line discipline erases whole characters.
+func open() (ptmx, tty *os.File, err error) {
+	ptmx, err = os.OpenFile("/dev/ptmx", os.O_RDWR|syscall.O_NOCTTY, 0)
+	if err != nil {
+		return nil, nil, err
+	}
+	var unlock int32
+	if err := ioctl(ptmx, syscall.TIOCSPTLCK, uintptr(unsafe.Pointer(&unlock))); err != nil {
+		ptmx.Close()
+		return nil, nil, err
+	}
+	var n uint32
+	if err := ioctl(ptmx, syscall.TIOCGPTN, uintptr(unsafe.Pointer(&n))); err != nil {
+		ptmx.Close()
+		return nil, nil, err
+	}
+	tty, err = os.OpenFile("/dev/pts/"+strconv.Itoa(int(n)), os.O_RDWR|syscall.O_NOCTTY, 0)
+	if err != nil {
+		ptmx.Close()
+		return nil, nil, err
+	}
+
+	var t syscall.Termios
+	if err := ioctl(tty, syscall.TCGETS, uintptr(unsafe.Pointer(&t))); err == nil {
+		t.Iflag |= syscall.IUTF8
+		_ = ioctl(tty, syscall.TCSETS, uintptr(unsafe.Pointer(&t)))
+	}
+	return ptmx, tty, nil
+}
@@ -0,0 +1,18 @@
+//go:build !linux && !darwin
+
+package pty
+
+import (
+	"os"
+	"os/exec"
+)
+
+// Start fails with ErrUnsupported: pseudo-terminals need Linux or macOS.
+func Start(cmd *exec.Cmd, width, height int) (*os.File, error) {
+	return nil, ErrUnsupported
+}
+
+// Setsize fails with ErrUnsupported.
+func Setsize(ptmx *os.File, width, height int) error {
+	return ErrUnsupported
+}
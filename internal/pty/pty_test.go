@@ -0,0 +1,28 @@
+package pty
+
+import (
+	"io"
+	"os/exec"
+	"runtime"
+	"strings"
+	"testing"
+)
+
+func TestStart(t *testing.T) {
+	if runtime.GOOS != "linux" && runtime.GOOS != "darwin" {
+		t.Skip(ErrUnsupported)
+	}
+	cmd := exec.Command("/bin/sh", "-c", "stty size; tty -s && echo terminal")
+	ptmx, err := Start(cmd, 30, 7)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer ptmx.Close()
+	defer cmd.Wait()
+
+	// Reading fails with EIO on Linux once the program has exited.
+	out, _ := io.ReadAll(ptmx)
+	if got, want := string(out), "7 30\r\nterminal\r\n"; !strings.HasPrefix(got, want) {
+		t.Errorf("output %q, want %q", got, want)
+	}
+}
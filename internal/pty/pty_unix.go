@@ -0,0 +1,70 @@
+//go:build linux || darwin
+
+package pty
+
+import (
+	"os"
+	"os/exec"
+	"syscall"
+	"unsafe"
+)
+
+// Start allocates a pseudo-terminal of width columns and height rows and
+// starts cmd on it, in a new session with the terminal as its controlling
+// terminal, as a terminal emulator does. cmd's standard input, output, and
+// error are set to the terminal. It returns the controlling side, which
+// reads the program's output and writes its input.
+func Start(cmd *exec.Cmd, width, height int) (*os.File, error) {
+	ptmx, tty, err := open()
+	if err != nil {
+		return nil, err
+	}
+	defer tty.Close()
+	if err := Setsize(ptmx, width, height); err != nil {
+		ptmx.Close()
+		return nil, err
+	}
+
+	cmd.Stdin, cmd.Stdout, cmd.Stderr = tty, tty, tty
+	if cmd.SysProcAttr == nil {
+		cmd.SysProcAttr = &syscall.SysProcAttr{}
+	}
+	cmd.SysProcAttr.Setsid = true
+	cmd.SysProcAttr.Setctty = true
+	cmd.SysProcAttr.Ctty = 0 // standard input, in the child
+	if err := cmd.Start(); err != nil {
+		ptmx.Close()
+		return nil, err
+	}
+	return ptmx, nil
+}
+
+// winsize is struct winsize of <sys/ioctl.h>.
+type winsize struct {
+	rows, cols, xpixel, ypixel uint16
+}
+
+// Setsize sets the size of the terminal whose controlling side is ptmx. The
+// kernel sends SIGWINCH to the terminal's foreground process group.
+func Setsize(ptmx *os.File, width, height int) error {
+	ws := winsize{rows: uint16(height), cols: uint16(width)}
+	return ioctl(ptmx, syscall.TIOCSWINSZ, uintptr(unsafe.Pointer(&ws)))
+}
+
+// ioctl calls ioctl(2) on f.
+func ioctl(f *os.File, req, arg uintptr) error {
+	conn, err := f.SyscallConn()
+	if err != nil {
+		return err
+	}
+	var errno syscall.Errno
+	if err := conn.Control(func(fd uintptr) {
+		_, _, errno = syscall.Syscall(syscall.SYS_IOCTL, fd, req, arg)
+	}); err != nil {
+		return err
+	}
+	if errno != 0 {
+		return errno
+	}
+	return nil
+}
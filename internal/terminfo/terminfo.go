@@ -0,0 +1,35 @@
+// Package terminfo installs the pinned terminfo entry strider gives programs
+// with WithPinnedTerminfo. It is internal to the strider module.
+//
+// The entry is shipped compiled, so installing it needs neither tic nor the
+// host's terminfo database. Its source is tmux-256color.ti.
+package terminfo
+
+import (
+	_ "embed"
+	"fmt"
+	"os"
+	"path/filepath"
+)
+
+// Name is the name of the pinned entry, the TERM programs see.
+const Name = "tmux-256color"
+
+//go:embed tmux-256color.terminfo
+var compiled []byte
+
+// Install writes the compiled entry to dir, a directory to use as TERMINFO.
+// The entry is written both under the first letter of its name, where
+// ncurses looks on most systems, and under the letter's hex code, where it
+// looks on case-insensitive filesystems such as macOS's.
+func Install(dir string) error {
+	for _, sub := range []string{Name[:1], fmt.Sprintf("%02x", Name[0])} {
+		if err := os.MkdirAll(filepath.Join(dir, sub), 0o755); err != nil {
+			return err
+		}
+		if err := os.WriteFile(filepath.Join(dir, sub, Name), compiled, 0o644); err != nil {
+			return err
+		}
+	}
+	return nil
+}
@@ -0,0 +1,78 @@
+package terminfo
+
+import (
+	"bytes"
+	"encoding/binary"
+	"fmt"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"testing"
+)
+
+func TestInstall(t *testing.T) {
+	dir := t.TempDir()
+	if err := Install(dir); err != nil {
+		t.Fatal(err)
+	}
+	for _, sub := range []string{"t", "74"} {
+		data, err := os.ReadFile(filepath.Join(dir, sub, Name))
+		if err != nil {
+			t.Fatal(err)
+		}
+		// The legacy compiled format: magic 0432 (octal), then the size of
+		// the names section, which starts with the entry's name.
+		if len(data) < 12 || binary.LittleEndian.Uint16(data) != 0o432 {
+			t.Fatalf("%s/%s: not a legacy compiled terminfo entry", sub, Name)
+		}
+		if !bytes.HasPrefix(data[12:], []byte(Name+"|")) {
+			t.Errorf("%s/%s: names section %q, want it to start with %q", sub, Name, data[12:40], Name+"|")
+		}
+	}
+}
+
+// TestCompiledMatchesSource checks that the compiled entry is up to date
+// with its source, using the host's infocmp.
+func TestCompiledMatchesSource(t *testing.T) {
+	infocmp, err := exec.LookPath("infocmp")
+	if err != nil {
+		t.Skip("infocmp not found in PATH")
+	}
+	dir := t.TempDir()
+	if err := Install(dir); err != nil {
+		t.Fatal(err)
+	}
+	out, err := exec.Command(infocmp, "-1", "-A", dir, Name).Output()
+	if err != nil {
+		t.Fatalf("infocmp: %v", err)
+	}
+	src, err := os.ReadFile("tmux-256color.ti")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// caps returns the capability lines of an entry, with numbers in
+	// decimal: infocmp versions differ in whether they print hex.
+	caps := func(s string) []string {
+		var lines []string
+		for _, line := range strings.Split(s, "\n") {
+			if !strings.HasPrefix(line, "\t") {
+				continue
+			}
+			line = strings.TrimSpace(line)
+			if name, num, ok := strings.Cut(line, "#"); ok {
+				if n, err := strconv.ParseInt(strings.TrimSuffix(num, ","), 0, 32); err == nil {
+					line = fmt.Sprintf("%s#%d,", name, n)
+				}
+			}
+			lines = append(lines, line)
+		}
+		return lines
+	}
+	got, want := caps(string(out)), caps(string(src))
+	if strings.Join(got, "\n") != strings.Join(want, "\n") {
+		t.Errorf("compiled entry differs from tmux-256color.ti; regenerate it with tic\ncompiled:\n%s", strings.Join(got, "\n"))
+	}
+}
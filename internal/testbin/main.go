@@ -8,6 +8,9 @@
 //   - "fail": exits with status 1
 //   - "lines N": prints N numbered lines (for scrollback testing)
 //   - "size": prints the terminal size
+//   - "grid": switches to raw mode on a cleared screen where the arrow keys
+//     move the cursor and typed characters are drawn at it; Ctrl+D returns
+//     to the prompt
 //   - Anything else: prints "echo: <line>" and a new "ready>" prompt
 package main
 
@@ -15,6 +18,7 @@ import (
 	"bufio"
 	"fmt"
 	"os"
+	"os/exec"
 	"os/signal"
 	"strconv"
 	"strings"
@@ -52,9 +56,13 @@ func main() {
 
 	fmt.Print("ready>")
 
-	scanner := bufio.NewScanner(os.Stdin)
-	for scanner.Scan() {
-		input := scanner.Text()
+	reader := bufio.NewReader(os.Stdin)
+	for {
+		line, err := reader.ReadString('\n')
+		if err != nil {
+			return
+		}
+		input := strings.TrimSuffix(line, "\n")
 
 		switch {
 		case input == "quit":
@@ -81,6 +89,13 @@ func main() {
 			mu.Unlock()
 			fmt.Print("ready>")
 
+		case input == "grid":
+			mu.Lock()
+			w, h := cols, rows
+			mu.Unlock()
+			runGrid(reader, w, h)
+			fmt.Print("ready>")
+
 		default:
 			fmt.Printf("echo: %s\n", input)
 			fmt.Print("ready>")
@@ -88,6 +103,54 @@ func main() {
 	}
 }
 
+// runGrid runs the "grid" mode: in raw mode, the arrow keys move the cursor
+// within a width x height screen (below the header row) and printable
+// characters are drawn at the cursor, until Ctrl+D.
+func runGrid(reader *bufio.Reader, width, height int) {
+	stty("raw", "-echo")
+	defer func() {
+		stty("sane")
+		fmt.Print("\x1b[2J\x1b[H")
+	}()
+
+	fmt.Print("\x1b[2J\x1b[Hgrid: arrows move, Ctrl+D exits")
+	row, col := 1, 0
+	for {
+		fmt.Printf("\x1b[%d;%dH", row+1, col+1)
+		b, err := reader.ReadByte()
+		if err != nil || b == 4 {
+			return
+		}
+		switch {
+		case b == 0x1b:
+			if next, _ := reader.ReadByte(); next != '[' {
+				continue
+			}
+			dir, _ := reader.ReadByte()
+			switch dir {
+			case 'A':
+				row = max(row-1, 1)
+			case 'B':
+				row = min(row+1, height-1)
+			case 'C':
+				col = min(col+1, width-1)
+			case 'D':
+				col = max(col-1, 0)
+			}
+		case b >= 0x20 && b < 0x7f:
+			fmt.Printf("%c", b)
+			col = min(col+1, width-1)
+		}
+	}
+}
+
+// stty runs stty with args on the terminal.
+func stty(args ...string) {
+	cmd := exec.Command("stty", args...)
+	cmd.Stdin = os.Stdin
+	_ = cmd.Run()
+}
+
 type winsize struct {
 	Row    uint16
 	Col    uint16
@@ -0,0 +1,30 @@
+package textdiff_test
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/cboone/strider/internal/textdiff"
+)
+
+func TestLines(t *testing.T) {
+	tests := []struct {
+		name string
+		a, b []string
+		want []string
+	}{
+		{"equal", []string{"a", "b"}, []string{"a", "b"}, []string{"  a", "  b"}},
+		{"changed", []string{"a", "b", "c"}, []string{"a", "x", "c"}, []string{"  a", "- b", "+ x", "  c"}},
+		{"added", nil, []string{"a"}, []string{"+ a"}},
+		{"removed", []string{"a"}, nil, []string{"- a"}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := textdiff.Lines(tt.a, tt.b)
+			if strings.Join(got, "\n") != strings.Join(tt.want, "\n") {
+				t.Errorf("Lines() = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
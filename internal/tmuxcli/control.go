@@ -0,0 +1,282 @@
+package tmuxcli
+
+import (
+	"bufio"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"os/exec"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// controlStartTimeout bounds how long StartControl waits for the control
+// client to attach.
+const controlStartTimeout = 5 * time.Second
+
+// controlCloseTimeout bounds how long Close waits for the control client to
+// exit after its input is closed, before killing it.
+const controlCloseTimeout = time.Second
+
+// errCommandFailed is the Err of an Error for a command that failed in
+// control mode, where there is no process exit status to report.
+var errCommandFailed = errors.New("command failed")
+
+// errControlClosed reports that the client exited before answering.
+var errControlClosed = errors.New("control client exited")
+
+// control is a tmux control-mode client (tmux -C) attached to the Runner's
+// server. Commands are written to its input one per line, and tmux answers
+// each with its output between %begin and %end (or %error) lines.
+type control struct {
+	cmd   *exec.Cmd
+	stdin io.WriteCloser
+
+	mu      sync.Mutex // held while a command is in flight
+	results chan controlResult
+	ready   chan struct{} // closed when the client has attached
+	done    chan struct{} // closed when the client's output ends
+}
+
+// controlResult is the answer to one command.
+type controlResult struct {
+	output string
+	failed bool
+}
+
+// StartControl starts a control-mode client attached to session, or to the
+// server's most recent session if session is "", and sends later commands
+// through it instead of starting a tmux process for each. Start it once the
+// session exists. It returns an error, leaving the Runner starting a process
+// per command, if the client cannot attach or tmux is too old (control mode
+// without output notifications needs tmux 3.2).
+//
+// tmux detaches the client if its session is killed, and the Runner then
+// goes back to starting a process per command.
+func (r *Runner) StartControl(session string) error {
+	args := append(r.baseArgs(), "-C", "attach-session")
+	if session != "" {
+		args = append(args, "-t", "="+session)
+	}
+	cmd := exec.Command(r.tmuxPath, args...)
+	cmd.Env = environ()
+	stdin, err := cmd.StdinPipe()
+	if err != nil {
+		return err
+	}
+	stdout, err := cmd.StdoutPipe()
+	if err != nil {
+		return err
+	}
+	invocations.Add(1)
+	if err := cmd.Start(); err != nil {
+		return err
+	}
+
+	c := &control{
+		cmd:     cmd,
+		stdin:   stdin,
+		results: make(chan controlResult, 1),
+		ready:   make(chan struct{}),
+		done:    make(chan struct{}),
+	}
+	go c.read(stdout)
+
+	// Commands sent before the client attaches fail with "no current client".
+	select {
+	case <-c.ready:
+	case <-c.done:
+		c.close()
+		return errors.New("control client exited before attaching")
+	case <-time.After(controlStartTimeout):
+		c.close()
+		return fmt.Errorf("control client did not attach within %v", controlStartTimeout)
+	}
+
+	// The client does not need the program's output, which tmux would
+	// otherwise send as %output notifications.
+	msg, failed, err := c.run(context.Background(), []string{"refresh-client", "-f", "no-output"})
+	if err == nil && failed {
+		err = errors.New(strings.TrimSpace(msg))
+	}
+	if err != nil {
+		c.close()
+		return err
+	}
+	r.control.Store(c)
+	return nil
+}
+
+// Close stops the control client, if StartControl started one. Later
+// commands start a tmux process each.
+func (r *Runner) Close() {
+	if c := r.control.Swap(nil); c != nil {
+		c.close()
+	}
+}
+
+// close ends the client by closing its input, and kills it if it does not
+// exit.
+func (c *control) close() {
+	c.stdin.Close()
+	select {
+	case <-c.done:
+	case <-time.After(controlCloseTimeout):
+		_ = c.cmd.Process.Kill()
+		<-c.done
+	}
+	_ = c.cmd.Wait()
+}
+
+// read reads the client's output: the blocks answering commands, which it
+// passes to run, and notifications, which it ignores.
+func (c *control) read(stdout io.Reader) {
+	defer close(c.done)
+	br := bufio.NewReaderSize(stdout, 64<<10)
+	var out strings.Builder
+	inBlock, fromClient, attached := false, false, false
+	var number string
+	for {
+		line, err := br.ReadString('\n')
+		if err != nil {
+			return
+		}
+		line = strings.TrimSuffix(line, "\n")
+
+		if inBlock {
+			if end, failed := blockEnd(line, number); end {
+				inBlock = false
+				if fromClient {
+					c.results <- controlResult{output: out.String(), failed: failed}
+				}
+				continue
+			}
+			out.WriteString(line)
+			out.WriteByte('\n')
+			continue
+		}
+
+		switch {
+		case strings.HasPrefix(line, "%begin "):
+			// %begin <time> <number> <flags>; flags 1 marks the answer to a
+			// command this client sent.
+			fields := strings.Fields(line)
+			if len(fields) != 4 {
+				continue
+			}
+			number, fromClient = fields[2], fields[3] == "1"
+			inBlock = true
+			out.Reset()
+		case strings.HasPrefix(line, "%session-changed ") && !attached:
+			attached = true
+			close(c.ready)
+		case line == "%exit" || strings.HasPrefix(line, "%exit "):
+			return
+		}
+	}
+}
+
+// blockEnd reports whether line ends the block with the given number, and
+// whether the command failed.
+func blockEnd(line, number string) (end, failed bool) {
+	var rest string
+	switch {
+	case strings.HasPrefix(line, "%end "):
+		rest = line[len("%end "):]
+	case strings.HasPrefix(line, "%error "):
+		rest, failed = line[len("%error "):], true
+	default:
+		return false, false
+	}
+	fields := strings.Fields(rest)
+	return len(fields) == 3 && fields[1] == number, failed
+}
+
+// run sends a command and returns its output, or tmux's message and failed
+// if the command failed. A lone ";" argument separates commands, as on
+// tmux's command line; their outputs are joined, and the first that fails
+// ends the sequence. errControlClosed means the client exited before
+// answering.
+func (c *control) run(ctx context.Context, args []string) (output string, failed bool, err error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	var line strings.Builder
+	commands := 1
+	for i, arg := range args {
+		if i > 0 {
+			line.WriteByte(' ')
+		}
+		if arg == ";" {
+			line.WriteByte(';')
+			commands++
+			continue
+		}
+		line.WriteString(controlQuote(arg))
+	}
+	line.WriteByte('\n')
+	if _, err := io.WriteString(c.stdin, line.String()); err != nil {
+		return "", false, errControlClosed
+	}
+
+	// tmux answers each command of a sequence with its own block.
+	var out strings.Builder
+	for range commands {
+		var res controlResult
+		select {
+		case res = <-c.results:
+		case <-c.done:
+			select {
+			case res = <-c.results:
+			default:
+				return "", false, errControlClosed
+			}
+		case <-ctx.Done():
+			// The answer would arrive for the next command; stop the client
+			// rather than mix them up.
+			c.stdin.Close()
+			return "", false, ctx.Err()
+		}
+		if res.failed {
+			return res.output, true, nil
+		}
+		out.WriteString(res.output)
+	}
+	return out.String(), false, nil
+}
+
+// controlQuote quotes arg as a double-quoted argument of a control-mode
+// command line, which tmux parses as it parses a config file: backslash,
+// double quote, dollar, and a leading tilde would be interpreted, and
+// control characters, such as a newline typed with send-keys -l, would end
+// or corrupt the line.
+func controlQuote(arg string) string {
+	var b strings.Builder
+	b.Grow(len(arg) + 2)
+	b.WriteByte('"')
+	for i := 0; i < len(arg); i++ {
+		switch ch := arg[i]; ch {
+		case '\\', '"', '$', '~':
+			b.WriteByte('\\')
+			b.WriteByte(ch)
+		case '\n':
+			b.WriteString(`\n`)
+		case '\r':
+			b.WriteString(`\r`)
+		case '\t':
+			b.WriteString(`\t`)
+		default:
+			if ch < 0x20 || ch == 0x7f {
+				b.WriteByte('\\')
+				b.WriteString(strconv.FormatInt(int64(ch)+01000, 8)[1:]) // three octal digits
+				continue
+			}
+			b.WriteByte(ch)
+		}
+	}
+	b.WriteByte('"')
+	return b.String()
+}
@@ -5,17 +5,55 @@ package tmuxcli
 import (
 	"bytes"
 	"context"
+	"errors"
 	"fmt"
+	"os"
 	"os/exec"
 	"strings"
+	"sync"
+	"sync/atomic"
 	"time"
 )
 
+// invocations counts the tmux processes started by this package.
+var invocations atomic.Int64
+
+// Invocations returns the number of tmux processes started so far, by all
+// Runners and Version, in this process.
+func Invocations() int64 {
+	return invocations.Load()
+}
+
+// bufferPool holds the buffers RunContext collects tmux's output in. A wait
+// runs several commands per poll, and reusing the buffers saves growing new
+// ones to the size of a screen capture each time.
+var bufferPool = sync.Pool{New: func() any { return new(bytes.Buffer) }}
+
+// maxPooledBuffer is the capacity above which a buffer is not returned to
+// bufferPool, so one large scrollback capture does not stay allocated.
+const maxPooledBuffer = 1 << 20
+
+func getBuffer() *bytes.Buffer {
+	return bufferPool.Get().(*bytes.Buffer)
+}
+
+func putBuffer(b *bytes.Buffer) {
+	if b.Cap() > maxPooledBuffer {
+		return
+	}
+	b.Reset()
+	bufferPool.Put(b)
+}
+
 // Runner executes tmux commands against a specific server socket.
 type Runner struct {
 	tmuxPath   string
 	socketPath string
 	configPath string
+
+	// control is the control-mode client commands are sent through, or nil
+	// to start a tmux process per command (see StartControl).
+	control atomic.Pointer[control]
 }
 
 // New creates a Runner bound to the given tmux binary and socket path.
@@ -34,7 +72,8 @@ func (r *Runner) SetConfigPath(path string) {
 
 // Run executes a tmux command with the given arguments and returns its
 // standard output. If the command fails, it returns an error containing
-// the captured standard error output.
+// the captured standard error output. As on tmux's command line, a lone ";"
+// argument separates commands, and their outputs are joined.
 func (r *Runner) Run(args ...string) (string, error) {
 	return r.RunContext(context.Background(), args...)
 }
@@ -43,17 +82,38 @@ func (r *Runner) Run(args ...string) (string, error) {
 // returning the command's standard output. On failure, it returns an error
 // that includes the captured standard error output.
 func (r *Runner) RunContext(ctx context.Context, args ...string) (string, error) {
-	var fullArgs []string
-	if r.configPath != "" {
-		fullArgs = append(fullArgs, "-f", r.configPath)
+	if c := r.control.Load(); c != nil {
+		output, failed, err := c.run(ctx, args)
+		switch {
+		case err == nil && !failed:
+			return output, nil
+		case err == nil:
+			return "", &Error{
+				Op:     args[0],
+				Args:   append(r.baseArgs(), args...),
+				Stderr: strings.TrimSpace(output),
+				Err:    errCommandFailed,
+			}
+		case errors.Is(err, errControlClosed):
+			// The server may have exited; a tmux process reports how.
+			if r.control.CompareAndSwap(c, nil) {
+				c.close()
+			}
+		default:
+			return "", err
+		}
 	}
-	fullArgs = append(fullArgs, "-S", r.socketPath)
-	fullArgs = append(fullArgs, args...)
+
+	fullArgs := append(r.baseArgs(), args...)
 	cmd := exec.CommandContext(ctx, r.tmuxPath, fullArgs...)
+	cmd.Env = environ()
+	invocations.Add(1)
 
-	var stdout, stderr bytes.Buffer
-	cmd.Stdout = &stdout
-	cmd.Stderr = &stderr
+	stdout, stderr := getBuffer(), getBuffer()
+	defer putBuffer(stdout)
+	defer putBuffer(stderr)
+	cmd.Stdout = stdout
+	cmd.Stderr = stderr
 
 	if err := cmd.Run(); err != nil {
 		return "", &Error{
@@ -67,6 +127,40 @@ func (r *Runner) RunContext(ctx context.Context, args ...string) (string, error)
 	return stdout.String(), nil
 }
 
+// baseArgs returns the arguments every tmux command of the Runner starts
+// with, selecting its config file and server socket.
+func (r *Runner) baseArgs() []string {
+	args := make([]string, 0, 8)
+	if r.configPath != "" {
+		args = append(args, "-f", r.configPath)
+	}
+	return append(args, "-S", r.socketPath)
+}
+
+// environ returns the environment for tmux commands: the process
+// environment without TMUX and TMUX_PANE. Those are set when tests run
+// inside a user's tmux session; removing them keeps tmux from treating the
+// test server as nested in that session and keeps them out of the server's
+// global environment, so tests behave the same inside tmux as in CI. When
+// neither is set it returns nil, so the command inherits the environment
+// without a filtered copy being made for every command.
+func environ() []string {
+	_, tmux := os.LookupEnv("TMUX")
+	_, pane := os.LookupEnv("TMUX_PANE")
+	if !tmux && !pane {
+		return nil
+	}
+	env := os.Environ()
+	out := env[:0:0]
+	for _, e := range env {
+		if strings.HasPrefix(e, "TMUX=") || strings.HasPrefix(e, "TMUX_PANE=") {
+			continue
+		}
+		out = append(out, e)
+	}
+	return out
+}
+
 // SocketPath returns the socket path used by this runner.
 func (r *Runner) SocketPath() string {
 	return r.socketPath
@@ -100,6 +194,7 @@ func (e *Error) Unwrap() error {
 // Version runs "tmux -V" and returns the version string (e.g. "3.4").
 func Version(tmuxPath string) (string, error) {
 	cmd := exec.Command(tmuxPath, "-V")
+	invocations.Add(1)
 	var stdout, stderr bytes.Buffer
 	cmd.Stdout = &stdout
 	cmd.Stderr = &stderr
@@ -1,6 +1,7 @@
 package tmuxcli_test
 
 import (
+	"errors"
 	"os/exec"
 	"strings"
 	"testing"
@@ -94,3 +95,157 @@ func TestRunnerError(t *testing.T) {
 		t.Errorf("Op = %q, want %q", tmuxErr.Op, "list-panes")
 	}
 }
+
+func TestRunnerIgnoresHostTmux(t *testing.T) {
+	tmuxPath := findTmux(t)
+
+	// Pretend the tests run inside a user's tmux session.
+	t.Setenv("TMUX", "/tmp/tmux-1000/default,1234,0")
+	t.Setenv("TMUX_PANE", "%42")
+
+	runner := tmuxcli.New(tmuxPath, t.TempDir()+"/test.sock")
+	if _, err := runner.Run("new-session", "-d", "-x", "80", "-y", "24", "--", "/bin/sh"); err != nil {
+		t.Fatalf("Failed to start session: %v", err)
+	}
+	defer func() { _, _ = runner.Run("kill-server") }()
+
+	output, err := runner.Run("show-environment", "-g")
+	if err != nil {
+		t.Fatalf("show-environment: %v", err)
+	}
+	for _, line := range strings.Split(output, "\n") {
+		if strings.HasPrefix(line, "TMUX=") || strings.HasPrefix(line, "TMUX_PANE=") {
+			t.Errorf("host tmux variable leaked into the server environment: %s", line)
+		}
+	}
+}
+
+func TestInvocations(t *testing.T) {
+	tmuxPath := findTmux(t)
+	runner := tmuxcli.New(tmuxPath, t.TempDir()+"/nonexistent.sock")
+
+	before := tmuxcli.Invocations()
+	_, _ = runner.Run("list-panes")
+	_, _ = tmuxcli.Version(tmuxPath)
+	if got := tmuxcli.Invocations() - before; got != 2 {
+		t.Errorf("Invocations() grew by %d, want 2", got)
+	}
+}
+
+func TestRunnerOutputNotReused(t *testing.T) {
+	tmuxPath := findTmux(t)
+	runner := tmuxcli.New(tmuxPath, t.TempDir()+"/test.sock")
+	if _, err := runner.Run("new-session", "-d", "-x", "80", "-y", "24", "--", "/bin/sh"); err != nil {
+		t.Fatalf("Failed to start session: %v", err)
+	}
+	defer func() { _, _ = runner.Run("kill-server") }()
+
+	// Output buffers are pooled; earlier results must not change.
+	first, err := runner.Run("display-message", "-p", "first")
+	if err != nil {
+		t.Fatalf("display-message: %v", err)
+	}
+	if _, err := runner.Run("display-message", "-p", "second"); err != nil {
+		t.Fatalf("display-message: %v", err)
+	}
+	if first != "first\n" {
+		t.Errorf("first output = %q after a second command, want %q", first, "first\n")
+	}
+}
+
+func BenchmarkRun(b *testing.B) {
+	path, err := exec.LookPath("tmux")
+	if err != nil {
+		b.Skip("tmux not found in PATH")
+	}
+	runner := tmuxcli.New(path, b.TempDir()+"/test.sock")
+	if _, err := runner.Run("new-session", "-d", "-x", "80", "-y", "24", "--", "/bin/sh"); err != nil {
+		b.Fatalf("Failed to start session: %v", err)
+	}
+	defer func() { _, _ = runner.Run("kill-server") }()
+
+	b.ReportAllocs()
+	for b.Loop() {
+		if _, err := runner.Run("capture-pane", "-p"); err != nil {
+			b.Fatal(err)
+		}
+	}
+}
+
+func TestControl(t *testing.T) {
+	tmuxPath := findTmux(t)
+	runner := tmuxcli.New(tmuxPath, t.TempDir()+"/test.sock")
+	if _, err := runner.Run("new-session", "-d", "-x", "80", "-y", "24", "--", "/bin/sh"); err != nil {
+		t.Fatalf("Failed to start session: %v", err)
+	}
+	defer func() { _, _ = runner.Run("kill-server") }()
+	if err := runner.StartControl(""); err != nil {
+		t.Skipf("control mode not available: %v", err)
+	}
+
+	// Arguments reach tmux unchanged, and output comes back as a tmux
+	// process would print it, without starting a process.
+	plain := tmuxcli.New(tmuxPath, runner.SocketPath())
+	show := func(r *tmuxcli.Runner, set *tmuxcli.Runner, arg string) string {
+		t.Helper()
+		if _, err := set.Run("set-option", "-g", "@test", arg); err != nil {
+			t.Fatalf("set-option %q: %v", arg, err)
+		}
+		output, err := r.Run("show-options", "-gv", "@test")
+		if err != nil {
+			t.Fatalf("show-options: %v", err)
+		}
+		return output
+	}
+	for _, arg := range []string{
+		"plain",
+		`a "quoted" \ back$lash`,
+		"~tilde and x~y",
+		"#{pane_id} ; { } '",
+		"tab\there\r",
+		"line\nbreak",
+		"\x1b[1mbold\x7f",
+		"caf\u00e9",
+	} {
+		want := show(plain, plain, arg)
+		before := tmuxcli.Invocations()
+		if got := show(plain, runner, arg); got != want {
+			t.Errorf("set in control mode: show-options = %q, want %q", got, want)
+		}
+		if got := show(runner, plain, arg); got != want {
+			t.Errorf("shown in control mode: show-options = %q, want %q", got, want)
+		}
+		if got := tmuxcli.Invocations() - before; got != 2 {
+			t.Errorf("Invocations() grew by %d, want 2 (only the commands run without control mode)", got)
+		}
+	}
+
+	// Failures report tmux's message as exec does.
+	_, err := runner.Run("show-buffer", "-b", "missing")
+	var tmuxErr *tmuxcli.Error
+	if !errors.As(err, &tmuxErr) || tmuxErr.Op != "show-buffer" || !strings.Contains(tmuxErr.Stderr, "missing") {
+		t.Errorf("show-buffer of a missing buffer: got %v", err)
+	}
+
+	// Sequences join the outputs of their commands, and stop at a failure.
+	sequence := []string{"display-message", "-p", "one", ";", "display-message", "-p", "two"}
+	for name, r := range map[string]*tmuxcli.Runner{"control": runner, "exec": plain} {
+		if got, err := r.Run(sequence...); err != nil || got != "one\ntwo\n" {
+			t.Errorf("%s: sequence = %q, %v, want %q", name, got, err, "one\ntwo\n")
+		}
+		_, err := r.Run("show-buffer", "-b", "missing", ";", "display-message", "-p", "two")
+		if !errors.As(err, &tmuxErr) || !strings.Contains(tmuxErr.Stderr, "missing") {
+			t.Errorf("%s: failing sequence: got %v", name, err)
+		}
+	}
+
+	// After Close, commands start a process again.
+	runner.Close()
+	before := tmuxcli.Invocations()
+	if _, err := runner.Run("list-panes"); err != nil {
+		t.Fatalf("list-panes after Close: %v", err)
+	}
+	if got := tmuxcli.Invocations() - before; got != 1 {
+		t.Errorf("Invocations() grew by %d after Close, want 1", got)
+	}
+}
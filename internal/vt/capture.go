@@ -0,0 +1,141 @@
+package vt
+
+import (
+	"errors"
+	"strconv"
+	"strings"
+)
+
+// ErrNoAlternateScreen is returned by Primary while the primary screen is
+// displayed, as tmux's capture-pane -a fails then.
+var ErrNoAlternateScreen = errors.New("no alternate screen")
+
+// Plain returns the displayed screen as tmux's capture-pane -p does: every
+// row, without trailing spaces, each followed by a newline.
+func (t *Terminal) Plain() string {
+	return capture(t.lines, captureTrim)
+}
+
+// Exact returns the displayed screen like Plain, keeping the trailing
+// spaces up to the last column the program wrote to (capture-pane -N).
+func (t *Terminal) Exact() string {
+	return capture(t.lines, 0)
+}
+
+// Styled returns the displayed screen like Plain, with SGR sequences for
+// its colors and attributes (capture-pane -e). As in tmux, a sequence is
+// written where the style changes, and the style carries over from one row
+// to the next.
+func (t *Terminal) Styled() string {
+	return capture(t.lines, captureTrim|captureStyles)
+}
+
+// Primary returns the primary screen, saved while the alternate screen is
+// displayed, like Plain (capture-pane -a).
+func (t *Terminal) Primary() (string, error) {
+	if t.alt == nil {
+		return "", ErrNoAlternateScreen
+	}
+	return capture(t.alt.lines, captureTrim), nil
+}
+
+// Scrollback returns the history followed by the displayed screen, like
+// Plain (capture-pane -S - -E -). With joined, the rows of a line the
+// terminal wrapped are joined into one, with their trailing spaces kept
+// (capture-pane -J).
+func (t *Terminal) Scrollback(joined bool) string {
+	rows := make([]line, 0, len(t.history)+len(t.lines))
+	rows = append(append(rows, t.history...), t.lines...)
+	if joined {
+		return capture(rows, captureJoin)
+	}
+	return capture(rows, captureTrim)
+}
+
+// HistorySize returns the number of lines in the history.
+func (t *Terminal) HistorySize() int {
+	return len(t.history)
+}
+
+// Capture flags.
+const (
+	captureTrim = 1 << iota
+	captureStyles
+	captureJoin
+)
+
+func capture(rows []line, flags int) string {
+	var b strings.Builder
+	var cur style
+	for _, l := range rows {
+		end := min(l.used, len(l.cells))
+		if flags&captureJoin != 0 && l.wrapped {
+			end = len(l.cells)
+		}
+		if flags&captureTrim != 0 {
+			for end > 0 && (l.cells[end-1].text == " " || l.cells[end-1].text == "") {
+				end--
+			}
+			if end > 0 && l.cells[end-1].width == 2 && end < len(l.cells) {
+				end++ // keep the spill column of a final wide character
+			}
+		}
+		for _, c := range l.cells[:end] {
+			if c.text == "" {
+				continue
+			}
+			if flags&captureStyles != 0 && c.style != cur {
+				writeStyleChange(&b, cur, c.style)
+				cur = c.style
+			}
+			b.WriteString(c.text)
+		}
+		if flags&captureJoin == 0 || !l.wrapped {
+			b.WriteByte('\n')
+		}
+	}
+	return b.String()
+}
+
+// writeStyleChange writes the SGR sequences that change from to to, one per
+// change, as tmux writes them: a reset if an attribute is turned off, the
+// attributes turned on, then the foreground and background colors.
+func writeStyleChange(b *strings.Builder, from, to style) {
+	sgr := func(code string) {
+		b.WriteString("\x1b[" + code + "m")
+	}
+	if from.attrs&^to.attrs != 0 {
+		sgr("0")
+		from = style{}
+	}
+	for _, a := range attrCodes {
+		if to.attrs&a.a != 0 && from.attrs&a.a == 0 {
+			sgr(a.code)
+		}
+	}
+	if to.fg != from.fg {
+		sgr(colorCode(to.fg, 30, 90))
+	}
+	if to.bg != from.bg {
+		sgr(colorCode(to.bg, 40, 100))
+	}
+}
+
+// colorCode returns the SGR code that selects c, where base is 30 for the
+// foreground and 40 for the background, and bright 90 or 100.
+func colorCode(c color, base, bright int) string {
+	switch c.kind {
+	case colorIndexed:
+		switch {
+		case c.value < 8:
+			return strconv.Itoa(base + int(c.value))
+		case c.value < 16:
+			return strconv.Itoa(bright + int(c.value) - 8)
+		}
+		return strconv.Itoa(base+8) + ";5;" + strconv.Itoa(int(c.value))
+	case colorRGB:
+		return strconv.Itoa(base+8) + ";2;" + strconv.Itoa(int(c.value>>16)) + ";" +
+			strconv.Itoa(int(c.value>>8&0xff)) + ";" + strconv.Itoa(int(c.value&0xff))
+	}
+	return strconv.Itoa(base + 9)
+}
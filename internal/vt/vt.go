@@ -0,0 +1,1326 @@
+// Package vt is the terminal emulator of strider's PTY backend. It is fed a
+// program's output and answers what strider asks tmux about a pane: the
+// screen text with or without styles, the scrollback, the cursor, and the
+// modes the program set. It follows tmux's behavior, including where tmux
+// differs from xterm, so that both backends capture the same screens. It is
+// internal to the strider module.
+package vt
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+	"unicode/utf8"
+
+	"github.com/cboone/strider/internal/cellwidth"
+)
+
+// colorKind distinguishes the default color from indexed and RGB colors.
+type colorKind uint8
+
+const (
+	colorDefault colorKind = iota
+	colorIndexed
+	colorRGB
+)
+
+// color is a cell color. value is the palette index, or the RGB components
+// packed as 0xrrggbb.
+type color struct {
+	kind  colorKind
+	value uint32
+}
+
+// attr is a set of text attributes.
+type attr uint16
+
+const (
+	attrBold attr = 1 << iota
+	attrDim
+	attrItalic
+	attrUnderline
+	attrBlink
+	attrReverse
+	attrHidden
+	attrStrike
+	attrOverline
+)
+
+// attrCodes are the SGR codes that set each attribute, in the order tmux
+// writes them.
+var attrCodes = [...]struct {
+	a    attr
+	code string
+}{
+	{attrBold, "1"}, {attrDim, "2"}, {attrItalic, "3"}, {attrUnderline, "4"},
+	{attrBlink, "5"}, {attrReverse, "7"}, {attrHidden, "8"}, {attrStrike, "9"},
+	{attrOverline, "53"},
+}
+
+type style struct {
+	fg, bg color
+	attrs  attr
+}
+
+// cell is one column of a line. text is the character with any combining
+// marks, or "" for the column a wide character spills into.
+type cell struct {
+	text  string
+	width uint8
+	style style
+}
+
+// line is a row of the screen or the history. used is the number of
+// columns the program wrote to, which exact captures keep trailing spaces
+// up to (tmux's cellused), and wrapped is set when the text continues on
+// the next line because it reached the right margin.
+type line struct {
+	cells   []cell
+	used    int
+	wrapped bool
+}
+
+// savedCursor is the state DECSC saves.
+type savedCursor struct {
+	x, y     int
+	pen      style
+	charsets [2]byte
+	shift    int
+	origin   bool
+	set      bool
+}
+
+// altState is the primary screen, saved while the alternate screen is
+// displayed.
+type altState struct {
+	lines      []line
+	x, y       int
+	restoreCur bool
+}
+
+// Modes are the terminal modes a program sets that change how input is
+// encoded.
+type Modes struct {
+	CursorKeys     bool // application cursor keys (DECCKM)
+	Keypad         bool // application keypad (DECKPAM)
+	BracketedPaste bool
+	Mouse          bool // any mouse reporting mode (1000, 1002, or 1003)
+	MouseSGR       bool // SGR mouse encoding (1006)
+	MouseUTF8      bool // UTF-8 mouse encoding (1005)
+}
+
+// Parser states.
+const (
+	stateGround = iota
+	stateEscape
+	stateCSI
+	stateOSC
+	stateOSCEscape
+	stateString
+	stateStringEscape
+)
+
+// maxOSC bounds the OSC strings the emulator collects.
+const maxOSC = 4096
+
+// Terminal is an emulated terminal. It is not safe for concurrent use.
+type Terminal struct {
+	width, height int
+
+	lines        []line // the displayed screen
+	history      []line // lines scrolled off the primary screen, oldest first
+	historyLimit int
+	scrolled     int // history lines that a taller screen can take back
+	alt          *altState
+
+	// x may equal width after a character is written in the last column:
+	// the next character wraps, as in tmux.
+	x, y    int
+	pen     style
+	saved   savedCursor
+	top     int
+	bottom  int
+	tabs    []bool
+	last    rune
+	lastSet bool
+
+	charsets [2]byte // G0 and G1: 'B' for ASCII, '0' for line drawing
+	shift    int
+
+	insert, origin, noWrap bool
+	modes                  Modes
+	mouse                  int // the mouse mode set last: 1000, 1002, 1003, or 0
+
+	title string
+
+	state   int
+	utf8    []byte
+	private byte
+	params  []byte
+	inter   []byte
+	osc     []byte
+	replies []byte
+}
+
+// New returns a terminal of the given size that keeps up to historyLimit
+// lines of scrollback.
+func New(width, height, historyLimit int) *Terminal {
+	t := &Terminal{historyLimit: historyLimit}
+	t.width, t.height = max(width, 1), max(height, 1)
+	t.reset()
+	return t
+}
+
+// reset returns the terminal to its initial state, keeping the history.
+func (t *Terminal) reset() {
+	t.lines = make([]line, t.height)
+	for i := range t.lines {
+		t.lines[i] = t.blankLine(style{})
+	}
+	t.alt = nil
+	t.x, t.y = 0, 0
+	t.pen = style{}
+	t.saved = savedCursor{}
+	t.top, t.bottom = 0, t.height-1
+	t.resetTabs()
+	t.charsets = [2]byte{'B', 'B'}
+	t.shift = 0
+	t.insert, t.origin, t.noWrap = false, false, false
+	t.modes = Modes{}
+	t.mouse = 0
+	t.lastSet = false
+	t.state = stateGround
+	t.utf8 = t.utf8[:0]
+}
+
+// Reset returns the terminal to its initial state and clears the history,
+// as when the program is restarted.
+func (t *Terminal) Reset() {
+	t.reset()
+	t.ClearHistory()
+}
+
+// ClearHistory discards the scrollback history.
+func (t *Terminal) ClearHistory() {
+	t.history = nil
+	t.scrolled = 0
+}
+
+func (t *Terminal) resetTabs() {
+	t.tabs = make([]bool, t.width)
+	for i := 8; i < t.width; i += 8 {
+		t.tabs[i] = true
+	}
+}
+
+func (t *Terminal) blankLine(st style) line {
+	l := line{cells: make([]cell, t.width)}
+	for i := range l.cells {
+		l.cells[i] = blank(st)
+	}
+	return l
+}
+
+// blank is an erased cell, which keeps the background color of the pen
+// that erased it.
+func blank(st style) cell {
+	return cell{text: " ", width: 1, style: style{bg: st.bg}}
+}
+
+// Size returns the width and height of the terminal.
+func (t *Terminal) Size() (width, height int) {
+	return t.width, t.height
+}
+
+// Cursor returns the cursor position, zero-based. The column equals the
+// width after a character is written in the last column, as in tmux.
+func (t *Terminal) Cursor() (x, y int) {
+	return t.x, t.y
+}
+
+// AltScreen reports whether the alternate screen is displayed.
+func (t *Terminal) AltScreen() bool {
+	return t.alt != nil
+}
+
+// Modes returns the input modes the program has set.
+func (t *Terminal) Modes() Modes {
+	m := t.modes
+	m.Mouse = t.mouse != 0
+	return m
+}
+
+// Title returns the window title the program set last.
+func (t *Terminal) Title() string {
+	return t.title
+}
+
+// TakeReplies returns the replies to the program's queries, such as its
+// cursor position, which the caller writes back as input, and forgets them.
+func (t *Terminal) TakeReplies() []byte {
+	r := t.replies
+	t.replies = nil
+	return r
+}
+
+// Write feeds the program's output to the terminal. It never fails.
+func (t *Terminal) Write(p []byte) (int, error) {
+	for _, b := range p {
+		t.feed(b)
+	}
+	return len(p), nil
+}
+
+func (t *Terminal) feed(b byte) {
+	switch t.state {
+	case stateGround:
+		t.ground(b)
+	case stateEscape:
+		t.escape(b)
+	case stateCSI:
+		t.csi(b)
+	case stateOSC:
+		switch b {
+		case 0x07:
+			t.oscDispatch()
+			t.state = stateGround
+		case 0x1b:
+			t.state = stateOSCEscape
+		default:
+			if len(t.osc) < maxOSC {
+				t.osc = append(t.osc, b)
+			}
+		}
+	case stateOSCEscape:
+		t.oscDispatch()
+		t.state = stateGround
+		if b != '\\' {
+			t.feed(b)
+		}
+	case stateString:
+		if b == 0x1b {
+			t.state = stateStringEscape
+		}
+	case stateStringEscape:
+		if b == '\\' {
+			t.state = stateGround
+		} else {
+			t.state = stateString
+		}
+	}
+}
+
+func (t *Terminal) ground(b byte) {
+	if b < 0x20 || b == 0x7f {
+		if len(t.utf8) > 0 {
+			t.utf8 = t.utf8[:0]
+			t.print(utf8.RuneError)
+		}
+		t.control(b)
+		return
+	}
+	if b < 0x80 && len(t.utf8) == 0 {
+		t.print(rune(b))
+		return
+	}
+	t.utf8 = append(t.utf8, b)
+	if !utf8.FullRune(t.utf8) {
+		return
+	}
+	r, n := utf8.DecodeRune(t.utf8)
+	rest := append([]byte(nil), t.utf8[n:]...)
+	t.utf8 = t.utf8[:0]
+	t.print(r)
+	for _, c := range rest {
+		t.ground(c)
+	}
+}
+
+// control executes a C0 control character.
+func (t *Terminal) control(b byte) {
+	switch b {
+	case 0x08: // BS
+		t.backspace()
+	case 0x09: // HT
+		t.tab(1)
+	case 0x0a, 0x0b, 0x0c: // LF, VT, FF
+		t.linefeed()
+	case 0x0d: // CR
+		t.x = 0
+	case 0x0e: // SO
+		t.shift = 1
+	case 0x0f: // SI
+		t.shift = 0
+	case 0x1b:
+		t.state = stateEscape
+		t.inter = t.inter[:0]
+	}
+}
+
+func (t *Terminal) escape(b byte) {
+	switch {
+	case b == 0x18 || b == 0x1a: // CAN, SUB
+		t.state = stateGround
+	case b < 0x20:
+		t.control(b)
+	case b >= 0x20 && b <= 0x2f:
+		t.inter = append(t.inter, b)
+	case len(t.inter) > 0:
+		t.state = stateGround
+		t.escDispatchIntermediate(t.inter[0], b)
+	case b == '[':
+		t.state = stateCSI
+		t.private = 0
+		t.params = t.params[:0]
+		t.inter = t.inter[:0]
+	case b == ']':
+		t.state = stateOSC
+		t.osc = t.osc[:0]
+	case b == 'P' || b == 'X' || b == '^' || b == '_':
+		t.state = stateString
+	default:
+		t.state = stateGround
+		t.escDispatch(b)
+	}
+}
+
+func (t *Terminal) escDispatch(b byte) {
+	switch b {
+	case '7':
+		t.saveCursor()
+	case '8':
+		t.restoreCursor()
+	case 'D':
+		t.linefeed()
+	case 'E':
+		t.x = 0
+		t.linefeed()
+	case 'M':
+		t.reverseIndex()
+	case 'H':
+		if t.x < t.width {
+			t.tabs[t.x] = true
+		}
+	case 'c':
+		t.reset()
+		t.eraseDisplay(2, false)
+	case '=':
+		t.modes.Keypad = true
+	case '>':
+		t.modes.Keypad = false
+	}
+}
+
+func (t *Terminal) escDispatchIntermediate(inter, b byte) {
+	switch inter {
+	case '(':
+		t.charsets[0] = b
+	case ')':
+		t.charsets[1] = b
+	case '#':
+		if b == '8' { // DECALN
+			for y := range t.lines {
+				for x := range t.lines[y].cells {
+					t.lines[y].cells[x] = cell{text: "E", width: 1}
+				}
+				t.lines[y].used = t.width
+			}
+			t.x, t.y = 0, 0
+		}
+	}
+}
+
+func (t *Terminal) csi(b byte) {
+	switch {
+	case b == 0x1b:
+		t.state = stateEscape
+		t.inter = t.inter[:0]
+	case b == 0x18 || b == 0x1a:
+		t.state = stateGround
+	case b < 0x20:
+		t.control(b)
+	case b >= '<' && b <= '?' && len(t.params) == 0 && t.private == 0:
+		t.private = b
+	case b >= 0x30 && b <= 0x3f:
+		t.params = append(t.params, b)
+	case b >= 0x20 && b <= 0x2f:
+		t.inter = append(t.inter, b)
+	case b >= 0x40 && b <= 0x7e:
+		t.state = stateGround
+		t.csiDispatch(b)
+	default:
+		t.state = stateGround
+	}
+}
+
+// param returns parameter i of the CSI sequence, or def if it is missing
+// or zero.
+func param(ps []int, i, def int) int {
+	if i < len(ps) && ps[i] > 0 {
+		return ps[i]
+	}
+	return def
+}
+
+// parseParams splits the CSI parameters at semicolons. Subparameters after
+// a colon are dropped; SGR reads them from the raw parameters.
+func parseParams(raw []byte) []int {
+	if len(raw) == 0 {
+		return nil
+	}
+	var ps []int
+	for _, f := range strings.Split(string(raw), ";") {
+		f, _, _ = strings.Cut(f, ":")
+		n, _ := strconv.Atoi(f)
+		ps = append(ps, min(n, 65535))
+	}
+	return ps
+}
+
+func (t *Terminal) csiDispatch(final byte) {
+	ps := parseParams(t.params)
+	if len(t.inter) > 0 {
+		if t.inter[0] == '!' && final == 'p' { // DECSTR
+			t.softReset()
+		}
+		return // DECSCUSR and others change nothing captured
+	}
+	switch t.private {
+	case '?':
+		switch final {
+		case 'h':
+			t.setPrivateModes(ps, true)
+		case 'l':
+			t.setPrivateModes(ps, false)
+		}
+		return
+	case '>':
+		if final == 'c' {
+			t.replies = append(t.replies, "\x1b[>84;0;0c"...)
+		}
+		return
+	case 0:
+	default:
+		return
+	}
+
+	n := param(ps, 0, 1)
+	switch final {
+	case '@':
+		t.insertCells(n)
+	case 'A':
+		t.cursorUp(n)
+	case 'B', 'e':
+		t.cursorDown(n)
+	case 'C', 'a':
+		t.cursorRight(n)
+	case 'D':
+		t.cursorLeft(n)
+	case 'E':
+		t.cursorDown(n)
+		t.x = 0
+	case 'F':
+		t.cursorUp(n)
+		t.x = 0
+	case 'G', '`':
+		t.x = min(n, t.width) - 1
+	case 'H', 'f':
+		t.moveTo(param(ps, 1, 1)-1, n-1)
+	case 'I':
+		t.tab(n)
+	case 'J':
+		t.eraseDisplay(param(ps, 0, 0), true)
+	case 'K':
+		t.eraseLine(param(ps, 0, 0))
+	case 'L':
+		t.insertLines(n)
+	case 'M':
+		t.deleteLines(n)
+	case 'P':
+		t.deleteCells(n)
+	case 'S':
+		for range min(n, t.height) {
+			t.scrollUp(t.top, t.bottom)
+		}
+	case 'T':
+		for range min(n, t.height) {
+			t.scrollDown(t.top, t.bottom)
+		}
+	case 'X':
+		t.eraseCells(n)
+	case 'Z':
+		t.backTab(n)
+	case 'b':
+		if t.lastSet {
+			for range min(n, t.width*t.height) {
+				t.print(t.last)
+			}
+		}
+	case 'c':
+		if param(ps, 0, 0) == 0 {
+			t.replies = append(t.replies, "\x1b[?1;2c"...)
+		}
+	case 'd':
+		y := n - 1
+		if t.origin {
+			y += t.top
+		}
+		t.y = min(y, t.height-1)
+		if t.x == t.width {
+			t.x--
+		}
+	case 'g':
+		switch param(ps, 0, 0) {
+		case 0:
+			if t.x < t.width {
+				t.tabs[t.x] = false
+			}
+		case 3:
+			clear(t.tabs)
+		}
+	case 'h':
+		t.setModes(ps, true)
+	case 'l':
+		t.setModes(ps, false)
+	case 'm':
+		t.sgr(string(t.params))
+	case 'n':
+		switch param(ps, 0, 0) {
+		case 5:
+			t.replies = append(t.replies, "\x1b[0n"...)
+		case 6:
+			y := t.y
+			if t.origin {
+				y -= t.top
+			}
+			t.replies = fmt.Appendf(t.replies, "\x1b[%d;%dR", y+1, min(t.x, t.width-1)+1)
+		}
+	case 'r':
+		top, bottom := param(ps, 0, 1)-1, param(ps, 1, t.height)-1
+		bottom = min(bottom, t.height-1)
+		if top < bottom {
+			t.top, t.bottom = top, bottom
+			t.moveTo(0, 0)
+		}
+	case 's':
+		t.saveCursor()
+	case 'u':
+		t.restoreCursor()
+	}
+}
+
+func (t *Terminal) setModes(ps []int, on bool) {
+	for _, p := range ps {
+		if p == 4 {
+			t.insert = on
+		}
+	}
+}
+
+func (t *Terminal) setPrivateModes(ps []int, on bool) {
+	for _, p := range ps {
+		switch p {
+		case 1:
+			t.modes.CursorKeys = on
+		case 6:
+			t.origin = on
+			t.moveTo(0, 0)
+		case 7:
+			t.noWrap = !on
+		case 47, 1047:
+			if on {
+				t.enterAlt(false)
+			} else {
+				t.leaveAlt()
+			}
+		case 1049:
+			if on {
+				t.enterAlt(true)
+			} else {
+				t.leaveAlt()
+			}
+		case 66:
+			t.modes.Keypad = on
+		case 1000, 1002, 1003:
+			if on {
+				t.mouse = p
+			} else if t.mouse == p {
+				t.mouse = 0
+			}
+		case 1005:
+			t.modes.MouseUTF8 = on
+		case 1006:
+			t.modes.MouseSGR = on
+		case 2004:
+			t.modes.BracketedPaste = on
+		}
+	}
+}
+
+// softReset implements DECSTR.
+func (t *Terminal) softReset() {
+	t.insert, t.origin, t.noWrap = false, false, false
+	t.modes.CursorKeys, t.modes.Keypad = false, false
+	t.pen = style{}
+	t.top, t.bottom = 0, t.height-1
+	t.charsets = [2]byte{'B', 'B'}
+	t.shift = 0
+	t.saved = savedCursor{}
+}
+
+func (t *Terminal) oscDispatch() {
+	code, text, _ := strings.Cut(string(t.osc), ";")
+	if code == "0" || code == "2" {
+		t.title = text
+	}
+}
+
+// print writes r at the cursor, as tmux does.
+func (t *Terminal) print(r rune) {
+	if t.charsets[t.shift] == '0' && r >= 0x5f && r <= 0x7e {
+		r = lineDrawing[r-0x5f]
+	}
+	w := cellwidth.Rune(r)
+	if w == 0 {
+		t.combine(r)
+		return
+	}
+	t.last, t.lastSet = r, true
+
+	if !t.noWrap && t.x > t.width-w {
+		t.lines[t.y].wrapped = true
+		t.linefeed()
+		t.x = 0
+	}
+	if t.x > t.width-w {
+		t.x = t.width - w // without autowrap, overwrite the last column
+	}
+	l := &t.lines[t.y]
+	if t.insert {
+		t.shiftRight(l, t.x, w)
+	}
+	t.clearWide(l, t.x)
+	if w == 2 {
+		t.clearWide(l, t.x+1)
+	}
+	l.cells[t.x] = cell{text: string(r), width: uint8(w), style: t.pen}
+	if w == 2 {
+		l.cells[t.x+1] = cell{width: 0, style: t.pen}
+	}
+	l.used = max(l.used, t.x+w)
+
+	if t.noWrap {
+		t.x = min(t.x+w, t.width-1)
+	} else {
+		t.x += w
+	}
+}
+
+// combine appends a zero-width character, such as a combining accent or a
+// variation selector, to the character before the cursor.
+func (t *Terminal) combine(r rune) {
+	x, y := t.x-1, t.y
+	if x < 0 {
+		return
+	}
+	l := &t.lines[y]
+	if x < t.width && l.cells[x].text == "" && x > 0 {
+		x--
+	}
+	if x >= t.width || l.cells[x].text == "" {
+		return
+	}
+	l.cells[x].text += string(r)
+}
+
+// clearWide blanks both halves of a wide character at column x of l, if
+// x is part of one, before it is overwritten.
+func (t *Terminal) clearWide(l *line, x int) {
+	if x >= t.width {
+		return
+	}
+	c := l.cells[x]
+	switch {
+	case c.text == "" && x > 0:
+		l.cells[x-1] = cell{text: " ", width: 1, style: l.cells[x-1].style}
+		l.cells[x] = cell{text: " ", width: 1, style: c.style}
+	case c.width == 2 && x+1 < t.width:
+		l.cells[x+1] = cell{text: " ", width: 1, style: l.cells[x+1].style}
+	}
+}
+
+// linefeed moves the cursor down a line, scrolling the scroll region at its
+// bottom margin.
+func (t *Terminal) linefeed() {
+	switch {
+	case t.y == t.bottom:
+		t.scrollUp(t.top, t.bottom)
+	case t.y < t.height-1:
+		t.y++
+	}
+}
+
+func (t *Terminal) reverseIndex() {
+	switch {
+	case t.y == t.top:
+		t.scrollDown(t.top, t.bottom)
+	case t.y > 0:
+		t.y--
+	}
+}
+
+// scrollUp scrolls lines top to bottom up by one. On the primary screen,
+// the top line goes to the history, even from a scroll region that does not
+// cover the whole screen, as in tmux.
+func (t *Terminal) scrollUp(top, bottom int) {
+	if t.alt == nil && t.historyLimit > 0 {
+		t.collectHistory()
+		t.history = append(t.history, t.lines[top])
+		t.scrolled++
+	}
+	copy(t.lines[top:bottom], t.lines[top+1:bottom+1])
+	t.lines[bottom] = t.blankLine(t.pen)
+	t.lines[bottom].used = 0
+}
+
+func (t *Terminal) scrollDown(top, bottom int) {
+	copy(t.lines[top+1:bottom+1], t.lines[top:bottom])
+	t.lines[top] = t.blankLine(t.pen)
+}
+
+// collectHistory makes room for a line in a full history, dropping a tenth
+// of it at once as tmux does.
+func (t *Terminal) collectHistory() {
+	if len(t.history) < t.historyLimit {
+		return
+	}
+	n := min(max(t.historyLimit/10, 1), len(t.history))
+	t.history = append(t.history[:0:0], t.history[n:]...)
+	t.scrolled = min(t.scrolled, len(t.history))
+}
+
+func (t *Terminal) backspace() {
+	switch {
+	case t.x > 0:
+		t.x = min(t.x, t.width) - 1
+	case t.y > 0 && t.lines[t.y-1].wrapped:
+		t.y--
+		t.x = t.width - 1
+	}
+}
+
+func (t *Terminal) tab(n int) {
+	if t.x >= t.width {
+		return
+	}
+	for ; n > 0 && t.x < t.width-1; n-- {
+		t.x++
+		for t.x < t.width-1 && !t.tabs[t.x] {
+			t.x++
+		}
+	}
+}
+
+func (t *Terminal) backTab(n int) {
+	t.x = min(t.x, t.width-1)
+	for ; n > 0 && t.x > 0; n-- {
+		t.x--
+		for t.x > 0 && !t.tabs[t.x] {
+			t.x--
+		}
+	}
+}
+
+// moveTo moves the cursor to column x and row y, relative to the scroll
+// region in origin mode.
+func (t *Terminal) moveTo(x, y int) {
+	if t.origin {
+		y = min(y+t.top, t.bottom)
+	}
+	t.x = min(max(x, 0), t.width-1)
+	t.y = min(max(y, 0), t.height-1)
+}
+
+func (t *Terminal) cursorUp(n int) {
+	if t.y < t.top {
+		n = min(n, t.y)
+	} else {
+		n = min(n, t.y-t.top)
+	}
+	t.y -= n
+	if t.x == t.width {
+		t.x--
+	}
+}
+
+func (t *Terminal) cursorDown(n int) {
+	if t.y > t.bottom {
+		n = min(n, t.height-1-t.y)
+	} else {
+		n = min(n, t.bottom-t.y)
+	}
+	t.y += n
+	if t.x == t.width {
+		t.x--
+	}
+}
+
+func (t *Terminal) cursorRight(n int) {
+	t.x = min(t.x+n, t.width-1)
+}
+
+func (t *Terminal) cursorLeft(n int) {
+	t.x = max(min(t.x, t.width)-n, 0)
+}
+
+// eraseDisplay implements ED. scroll pushes the content of the primary
+// screen into the history before a full clear, as tmux's scroll-on-clear
+// option does.
+func (t *Terminal) eraseDisplay(mode int, scroll bool) {
+	switch mode {
+	case 0:
+		t.eraseLine(0)
+		for y := t.y + 1; y < t.height; y++ {
+			t.lines[y] = t.blankLine(t.pen)
+		}
+	case 1:
+		t.eraseLine(1)
+		for y := 0; y < t.y; y++ {
+			t.lines[y] = t.blankLine(t.pen)
+		}
+	case 2:
+		if scroll && t.alt == nil && t.historyLimit > 0 {
+			last := -1
+			for y := range t.lines {
+				if t.lines[y].used > 0 {
+					last = y
+				}
+			}
+			for y := 0; y <= last; y++ {
+				t.collectHistory()
+				t.history = append(t.history, t.lines[y])
+			}
+		}
+		for y := range t.lines {
+			t.lines[y] = t.blankLine(t.pen)
+		}
+	case 3:
+		t.ClearHistory()
+	}
+}
+
+// eraseLine implements EL.
+func (t *Terminal) eraseLine(mode int) {
+	l := &t.lines[t.y]
+	x := min(t.x, t.width-1)
+	switch mode {
+	case 0:
+		t.fill(l, x, t.width)
+		if x == 0 && t.pen.bg.kind == colorDefault {
+			l.used = 0
+		}
+	case 1:
+		t.fill(l, 0, x+1)
+	case 2:
+		*l = t.blankLine(t.pen)
+	}
+}
+
+// fill erases columns from to to of l.
+func (t *Terminal) fill(l *line, from, to int) {
+	if from < to {
+		t.clearWide(l, from)
+		t.clearWide(l, to-1)
+	}
+	for x := from; x < to; x++ {
+		l.cells[x] = blank(t.pen)
+	}
+}
+
+func (t *Terminal) eraseCells(n int) {
+	x := min(t.x, t.width-1)
+	t.fill(&t.lines[t.y], x, min(x+n, t.width))
+}
+
+func (t *Terminal) shiftRight(l *line, x, n int) {
+	n = min(n, t.width-x)
+	t.clearWide(l, x)
+	t.clearWide(l, t.width-n)
+	copy(l.cells[x+n:], l.cells[x:t.width-n])
+	for i := x; i < x+n; i++ {
+		l.cells[i] = blank(t.pen)
+	}
+}
+
+func (t *Terminal) insertCells(n int) {
+	x := min(t.x, t.width-1)
+	l := &t.lines[t.y]
+	t.shiftRight(l, x, n)
+	l.used = t.width
+}
+
+func (t *Terminal) deleteCells(n int) {
+	x := min(t.x, t.width-1)
+	n = min(n, t.width-x)
+	l := &t.lines[t.y]
+	t.clearWide(l, x)
+	t.clearWide(l, x+n-1)
+	copy(l.cells[x:], l.cells[x+n:])
+	for i := t.width - n; i < t.width; i++ {
+		l.cells[i] = blank(t.pen)
+	}
+	l.used = max(l.used, t.width-n)
+}
+
+func (t *Terminal) insertLines(n int) {
+	if t.y < t.top || t.y > t.bottom {
+		return
+	}
+	for range min(n, t.bottom-t.y+1) {
+		t.scrollDown(t.y, t.bottom)
+	}
+	t.x = 0
+}
+
+func (t *Terminal) deleteLines(n int) {
+	if t.y < t.top || t.y > t.bottom {
+		return
+	}
+	for range min(n, t.bottom-t.y+1) {
+		copy(t.lines[t.y:t.bottom], t.lines[t.y+1:t.bottom+1])
+		t.lines[t.bottom] = t.blankLine(t.pen)
+	}
+	t.x = 0
+}
+
+func (t *Terminal) saveCursor() {
+	t.saved = savedCursor{x: t.x, y: t.y, pen: t.pen, charsets: t.charsets, shift: t.shift, origin: t.origin, set: true}
+}
+
+func (t *Terminal) restoreCursor() {
+	s := t.saved
+	if !s.set {
+		t.x, t.y = 0, 0
+		return
+	}
+	t.x, t.y = min(s.x, t.width), min(s.y, t.height-1)
+	t.pen, t.charsets, t.shift, t.origin = s.pen, s.charsets, s.shift, s.origin
+}
+
+// enterAlt switches to the alternate screen, which starts out clear.
+// saveCursor also saves the cursor, for mode 1049.
+func (t *Terminal) enterAlt(saveCursor bool) {
+	if t.alt != nil {
+		return
+	}
+	t.alt = &altState{lines: t.lines, x: t.x, y: t.y, restoreCur: saveCursor}
+	t.lines = make([]line, t.height)
+	for i := range t.lines {
+		t.lines[i] = t.blankLine(t.pen)
+	}
+}
+
+// leaveAlt switches back to the primary screen, with the content it had,
+// and restores the cursor if entering the alternate screen saved it. A
+// primary screen saved at another size is resized as it is restored.
+func (t *Terminal) leaveAlt() {
+	a := t.alt
+	if a == nil {
+		return
+	}
+	t.alt = nil
+	w, h := t.width, t.height
+	t.lines = a.lines
+	t.width, t.height = len(a.lines[0].cells), len(a.lines)
+	if a.restoreCur {
+		t.x, t.y = a.x, a.y
+	}
+	t.x, t.y = min(t.x, t.width), min(t.y, t.height-1)
+	if w != t.width || h != t.height {
+		t.Resize(w, h)
+	}
+}
+
+// Resize changes the size of the terminal. As in tmux, lines below the
+// cursor are dropped before lines at the top go to the history when the
+// terminal gets shorter, lines come back from the history when it gets
+// taller, and the primary screen and its history are rewrapped to a new
+// width.
+func (t *Terminal) Resize(width, height int) {
+	width, height = max(width, 1), max(height, 1)
+	if width == t.width && height == t.height {
+		return
+	}
+	if height != t.height {
+		t.resizeHeight(height)
+		t.height = height
+	}
+	if width != t.width {
+		if t.alt == nil {
+			t.reflow(width)
+		} else {
+			t.setWidth(width)
+		}
+	}
+	t.width, t.height = width, height
+	t.resetTabs()
+	t.top, t.bottom = 0, height-1
+	t.x, t.y = min(t.x, width-1), min(t.y, height-1)
+}
+
+func (t *Terminal) resizeHeight(height int) {
+	old := len(t.lines)
+	primary := t.alt == nil
+	if height < old {
+		needed := old - height
+		below := min(old-1-t.y, needed)
+		t.lines = t.lines[:old-below]
+		needed -= below
+		if needed > 0 {
+			if primary && t.historyLimit > 0 {
+				for _, l := range t.lines[:needed] {
+					t.collectHistory()
+					t.history = append(t.history, l)
+					t.scrolled++
+				}
+			}
+			t.lines = t.lines[needed:]
+			t.y -= needed
+		}
+		return
+	}
+
+	needed := height - old
+	if primary {
+		back := min(t.scrolled, len(t.history), needed)
+		split := len(t.history) - back
+		t.lines = append(append([]line(nil), t.history[split:]...), t.lines...)
+		t.history = t.history[:split]
+		t.scrolled -= back
+		t.y += back
+		needed -= back
+	}
+	for range needed {
+		t.lines = append(t.lines, t.blankLine(style{}))
+	}
+}
+
+// setWidth changes the width of every line without rewrapping them, for
+// the alternate screen.
+func (t *Terminal) setWidth(width int) {
+	for i := range t.lines {
+		l := &t.lines[i]
+		if width < len(l.cells) {
+			if c := l.cells[width-1]; c.width == 2 {
+				l.cells[width-1] = blank(c.style)
+			}
+			l.cells = l.cells[:width]
+		}
+		for len(l.cells) < width {
+			l.cells = append(l.cells, blank(style{}))
+		}
+		l.used = min(l.used, width)
+	}
+}
+
+// reflow rewraps the primary screen and its history to width, keeping the
+// cursor on the character it was on.
+func (t *Terminal) reflow(width int) {
+	rows := append(t.history, t.lines...)
+	cursorRow := len(t.history) + t.y
+
+	var out []line
+	newCursorRow, newCursorX := -1, 0
+	for i := 0; i < len(rows); {
+		// Join the rows of one logical line, noting where the cursor is.
+		var cells []cell
+		cursorAt := -1
+		for {
+			r := rows[i]
+			if i == cursorRow {
+				cursorAt = len(cells) + t.x
+			}
+			i++
+			if !r.wrapped || i == len(rows) {
+				cells = append(cells, r.cells[:min(r.used, len(r.cells))]...)
+				break
+			}
+			cells = append(cells, r.cells...)
+		}
+
+		// Split it again at the new width.
+		cur := line{cells: make([]cell, 0, width)}
+		for x, c := range cells {
+			if x == cursorAt {
+				newCursorRow, newCursorX = len(out), len(cur.cells)
+			}
+			if c.width == 0 {
+				if len(cur.cells) > 0 || len(out) == 0 {
+					cur.cells = append(cur.cells, c)
+				}
+				continue
+			}
+			if len(cur.cells)+int(c.width) > width {
+				cur.wrapped = true
+				out = append(out, t.padLine(cur, width))
+				cur = line{cells: make([]cell, 0, width)}
+			}
+			cur.cells = append(cur.cells, c)
+		}
+		if cursorAt >= len(cells) {
+			col := len(cur.cells) + cursorAt - len(cells)
+			newCursorRow, newCursorX = len(out), min(col, width-1)
+		}
+		out = append(out, t.padLine(cur, width))
+	}
+
+	for len(out) < t.height {
+		out = append(out, t.padLine(line{}, width))
+	}
+	hist := len(out) - t.height
+	if newCursorRow >= 0 && newCursorRow < hist {
+		hist = newCursorRow
+	}
+	if t.historyLimit <= 0 {
+		hist = 0
+	}
+	t.scrolled = min(t.scrolled+max(hist-len(t.history), 0), hist)
+	t.history = out[:hist:hist]
+	t.lines = append([]line(nil), out[hist:hist+t.height]...)
+	if len(t.history) > t.historyLimit {
+		t.history = t.history[len(t.history)-t.historyLimit:]
+		t.scrolled = min(t.scrolled, len(t.history))
+	}
+	if newCursorRow >= 0 {
+		t.x, t.y = newCursorX, newCursorRow-hist
+	}
+}
+
+// padLine fills l with blank cells to width, recording the columns in use.
+func (t *Terminal) padLine(l line, width int) line {
+	l.used = len(l.cells)
+	for len(l.cells) < width {
+		l.cells = append(l.cells, blank(style{}))
+	}
+	return l
+}
+
+// sgr applies the Select Graphic Rendition parameters params, the part of
+// an ESC [ ... m sequence between the bracket and the m.
+func (t *Terminal) sgr(params string) {
+	if params == "" {
+		t.pen = style{}
+		return
+	}
+	fields := strings.Split(params, ";")
+	for i := 0; i < len(fields); i++ {
+		code, sub, _ := strings.Cut(fields[i], ":")
+		n, _ := strconv.Atoi(code)
+		p := &t.pen
+		switch {
+		case n == 0:
+			*p = style{}
+		case n == 1:
+			p.attrs |= attrBold
+		case n == 2:
+			p.attrs |= attrDim
+		case n == 3:
+			p.attrs |= attrItalic
+		case n == 4:
+			if sub == "0" {
+				p.attrs &^= attrUnderline
+			} else {
+				p.attrs |= attrUnderline
+			}
+		case n == 5 || n == 6:
+			p.attrs |= attrBlink
+		case n == 7:
+			p.attrs |= attrReverse
+		case n == 8:
+			p.attrs |= attrHidden
+		case n == 9:
+			p.attrs |= attrStrike
+		case n == 21:
+			p.attrs |= attrUnderline
+		case n == 22:
+			p.attrs &^= attrBold | attrDim
+		case n == 23:
+			p.attrs &^= attrItalic
+		case n == 24:
+			p.attrs &^= attrUnderline
+		case n == 25:
+			p.attrs &^= attrBlink
+		case n == 27:
+			p.attrs &^= attrReverse
+		case n == 28:
+			p.attrs &^= attrHidden
+		case n == 29:
+			p.attrs &^= attrStrike
+		case n >= 30 && n <= 37:
+			p.fg = color{colorIndexed, uint32(n - 30)}
+		case n == 38:
+			p.fg, i = extendedColor(fields, i)
+		case n == 39:
+			p.fg = color{}
+		case n >= 40 && n <= 47:
+			p.bg = color{colorIndexed, uint32(n - 40)}
+		case n == 48:
+			p.bg, i = extendedColor(fields, i)
+		case n == 49:
+			p.bg = color{}
+		case n == 53:
+			p.attrs |= attrOverline
+		case n == 55:
+			p.attrs &^= attrOverline
+		case n == 58:
+			_, i = extendedColor(fields, i) // underline color, not kept
+		case n >= 90 && n <= 97:
+			p.fg = color{colorIndexed, uint32(n - 90 + 8)}
+		case n >= 100 && n <= 107:
+			p.bg = color{colorIndexed, uint32(n - 100 + 8)}
+		}
+	}
+}
+
+// extendedColor parses the color of an SGR 38, 48, or 58 parameter at
+// fields[i], in either the 38;5;n or the 38:5:n form, and returns it with
+// the index of the last field it used.
+func extendedColor(fields []string, i int) (color, int) {
+	var args []string
+	if parts := strings.Split(fields[i], ":"); len(parts) > 1 {
+		args = parts[1:]
+		if len(args) >= 5 && args[0] == "2" {
+			args = append([]string{"2"}, args[2:]...) // 38:2::r:g:b
+		}
+	} else {
+		args = fields[i+1:]
+		switch {
+		case len(args) > 0 && args[0] == "5":
+			i += 2
+		case len(args) > 0 && args[0] == "2":
+			i += 4
+		}
+		i = min(i, len(fields)-1)
+	}
+	num := func(k int) uint32 {
+		if k >= len(args) {
+			return 0
+		}
+		n, _ := strconv.Atoi(args[k])
+		return uint32(n & 0xff)
+	}
+	switch {
+	case len(args) > 1 && args[0] == "5":
+		return color{colorIndexed, num(1)}, i
+	case len(args) > 0 && args[0] == "2":
+		return color{colorRGB, num(1)<<16 | num(2)<<8 | num(3)}, i
+	}
+	return color{}, i
+}
+
+// lineDrawing maps the characters 0x5f to 0x7e of the DEC Special Graphics
+// character set to Unicode, as tmux stores them.
+var lineDrawing = [...]rune{
+	' ', '◆', '▒', '␉', '␌', '␍', '␊', '°', '±', '␤', '␋', '┘', '┐', '┌', '└', '┼',
+	'⎺', '⎻', '─', '⎼', '⎽', '├', '┤', '┴', '┬', '│', '≤', '≥', 'π', '≠', '£', '·',
+}
@@ -0,0 +1,218 @@
+package vt
+
+import (
+	"strings"
+	"testing"
+)
+
+// The expected captures in these tests were recorded from tmux 3.3a with the
+// same output and pane size. Styled captures may write the SGR sequences
+// differently, as long as they select the same styles.
+
+func feed(t *testing.T, term *Terminal, s string) {
+	t.Helper()
+	// Like the terminal driver, turn newlines into CR LF.
+	term.Write([]byte(strings.ReplaceAll(s, "\n", "\r\n")))
+}
+
+func rows(s ...string) string {
+	return strings.Join(s, "\n") + "\n"
+}
+
+func TestPlain(t *testing.T) {
+	term := New(20, 5, 100)
+	feed(t, term, "hello\nwor\x1b[1mld\x1b[m\n\x1b[5Cx\ttab")
+	want := rows("hello", "world", "     x  tab", "", "")
+	if got := term.Plain(); got != want {
+		t.Errorf("Plain() = %q, want %q", got, want)
+	}
+	if x, y := term.Cursor(); x != 11 || y != 2 {
+		t.Errorf("Cursor() = %d, %d, want 11, 2", x, y)
+	}
+}
+
+func TestExact(t *testing.T) {
+	term := New(20, 5, 100)
+	feed(t, term, "ab   \nxy\x1b[5Cz\x1b[K\n   \nabcdef\x1b[3D\x1b[1P\nabcdef\x1b[3D\x1b[1@")
+	want := rows("ab   ", "xy     z", "   ", "abcef"+strings.Repeat(" ", 14), "abc def"+strings.Repeat(" ", 13))
+	if got := term.Exact(); got != want {
+		t.Errorf("Exact() = %q, want %q", got, want)
+	}
+
+	term = New(20, 5, 100)
+	feed(t, term, "abcdef\r\x1b[2C\x1b[K\nabcdef\x1b[2K\nab\x1b[3X")
+	want = rows("ab    ", "", "ab", "", "")
+	if got := term.Exact(); got != want {
+		t.Errorf("Exact() after erasing = %q, want %q", got, want)
+	}
+}
+
+func TestStyled(t *testing.T) {
+	term := New(20, 5, 100)
+	feed(t, term, "abc\x1b[1;31mdef\x1b[0m \x1b[4mgh\x1b[m\n\x1b[38;5;100mx\x1b[38;2;1;2;3my\x1b[92mz\x1b[m")
+	want := rows(
+		"abc\x1b[1m\x1b[31mdef\x1b[0m \x1b[4mgh",
+		"\x1b[0m\x1b[38;5;100mx\x1b[38;2;1;2;3my\x1b[92mz",
+		"", "", "")
+	if got := term.Styled(); got != want {
+		t.Errorf("Styled() = %q, want %q", got, want)
+	}
+}
+
+func TestWrapAndScrollback(t *testing.T) {
+	term := New(5, 3, 100)
+	feed(t, term, "abcdefg\n1\n2\n3")
+	if got, want := term.Plain(), rows("1", "2", "3"); got != want {
+		t.Errorf("Plain() = %q, want %q", got, want)
+	}
+	if got, want := term.Scrollback(false), rows("abcde", "fg", "1", "2", "3"); got != want {
+		t.Errorf("Scrollback(false) = %q, want %q", got, want)
+	}
+	if got, want := term.Scrollback(true), rows("abcdefg", "1", "2", "3"); got != want {
+		t.Errorf("Scrollback(true) = %q, want %q", got, want)
+	}
+
+	// A character in the last column leaves the cursor past it until the
+	// next character wraps.
+	term = New(5, 3, 100)
+	feed(t, term, "abcde")
+	if x, y := term.Cursor(); x != 5 || y != 0 {
+		t.Errorf("Cursor() = %d, %d, want 5, 0", x, y)
+	}
+}
+
+func TestClearScrollsIntoHistory(t *testing.T) {
+	term := New(20, 5, 100)
+	feed(t, term, "one\ntwo\nthree\n\x1b[2J\x1b[Hafter")
+	if got, want := term.Scrollback(false), rows("one", "two", "three", "after", "", "", "", ""); got != want {
+		t.Errorf("Scrollback(false) = %q, want %q", got, want)
+	}
+
+	// Not on the alternate screen, and ED 3 clears the history.
+	term = New(20, 5, 100)
+	feed(t, term, "1\n2\n3\n4\n5\n6\n7\x1b[3J\x1b[?1049hALT\x1b[2J\x1b[?1049l")
+	if got, want := term.Scrollback(false), rows("3", "4", "5", "6", "7"); got != want {
+		t.Errorf("Scrollback(false) = %q, want %q", got, want)
+	}
+}
+
+func TestAltScreen(t *testing.T) {
+	term := New(20, 5, 100)
+	feed(t, term, "prompt\x1b[?1049h\x1b[Hfull screen")
+	if !term.AltScreen() {
+		t.Fatal("AltScreen() = false after 1049h")
+	}
+	if got, want := term.Plain(), rows("full screen", "", "", "", ""); got != want {
+		t.Errorf("Plain() = %q, want %q", got, want)
+	}
+	if got, err := term.Primary(); err != nil || got != rows("prompt", "", "", "", "") {
+		t.Errorf("Primary() = %q, %v", got, err)
+	}
+	feed(t, term, "\x1b[?1049l")
+	if got, want := term.Plain(), rows("prompt", "", "", "", ""); got != want {
+		t.Errorf("Plain() after 1049l = %q, want %q", got, want)
+	}
+	if x, y := term.Cursor(); x != 6 || y != 0 {
+		t.Errorf("Cursor() = %d, %d, want 6, 0", x, y)
+	}
+	if _, err := term.Primary(); err != ErrNoAlternateScreen {
+		t.Errorf("Primary() error = %v, want ErrNoAlternateScreen", err)
+	}
+}
+
+func TestResize(t *testing.T) {
+	for _, tt := range []struct {
+		name          string
+		output        string
+		width, height int
+		scrollback    string
+		x, y          int
+	}{
+		{"narrower", "aaaaaaaaaaaaaaa\nb", 5, 5,
+			rows("aaaaa", "aaaaa", "aaaaa", "b", "", "", ""), 1, 1},
+		{"shorter", "aaaaaaaaaaaaaaa\nb", 20, 2,
+			rows("aaaaaaaaaaaaaaa", "b"), 1, 1},
+		{"shorter above cursor", "aaaaaaaaaaaaaaa\nb\n\n\n\nc\x1b[H", 20, 2,
+			rows("aaaaaaaaaaaaaaa", "b", ""), 0, 0},
+		{"taller", "1\n2\n3\n4\n5\n6\n7", 20, 8,
+			rows("1", "2", "3", "4", "5", "6", "7", ""), 1, 6},
+		{"narrower and shorter", "aaaaaaaaaaaaaaa\nb", 10, 3,
+			rows("aaaaaaaaaa", "aaaaa", "b", ""), 1, 1},
+		{"wider", "aaaaaaaaaaaaaaaaaaaaaaaaa\nb", 30, 5,
+			rows("aaaaaaaaaaaaaaaaaaaaaaaaa", "b", "", "", ""), 1, 1},
+	} {
+		t.Run(tt.name, func(t *testing.T) {
+			term := New(20, 5, 100)
+			feed(t, term, tt.output)
+			term.Resize(tt.width, tt.height)
+			if got := term.Scrollback(false); got != tt.scrollback {
+				t.Errorf("Scrollback(false) = %q, want %q", got, tt.scrollback)
+			}
+			if x, y := term.Cursor(); x != tt.x || y != tt.y {
+				t.Errorf("Cursor() = %d, %d, want %d, %d", x, y, tt.x, tt.y)
+			}
+		})
+	}
+}
+
+func TestScrollRegion(t *testing.T) {
+	term := New(20, 5, 100)
+	feed(t, term, "h1\nh2\x1b[3;4r\x1b[3Ha\nb\nc\nd\x1b[r\x1b[5Hfooter")
+	if got, want := term.Plain(), rows("h1", "h2", "c", "d", "footer"); got != want {
+		t.Errorf("Plain() = %q, want %q", got, want)
+	}
+	// The lines scrolled out of the region go to the history.
+	if got, want := term.Scrollback(false), rows("a", "b", "h1", "h2", "c", "d", "footer"); got != want {
+		t.Errorf("Scrollback(false) = %q, want %q", got, want)
+	}
+}
+
+func TestWideCharacters(t *testing.T) {
+	term := New(5, 3, 100)
+	feed(t, term, "ab世界\x1b[1;2Hx\x1b[2;1Hé́")
+	// Overwriting half of 界 erases all of it.
+	if got, want := term.Plain(), rows("ax世", "é́", ""); got != want {
+		t.Errorf("Plain() = %q, want %q", got, want)
+	}
+	if x, y := term.Cursor(); x != 1 || y != 1 {
+		t.Errorf("Cursor() = %d, %d, want 1, 1", x, y)
+	}
+}
+
+func TestReplies(t *testing.T) {
+	term := New(20, 5, 100)
+	feed(t, term, "abc\x1b[6n\x1b[c\x1b[>c")
+	if got, want := string(term.TakeReplies()), "\x1b[1;4R\x1b[?1;2c\x1b[>84;0;0c"; got != want {
+		t.Errorf("TakeReplies() = %q, want %q", got, want)
+	}
+	if r := term.TakeReplies(); len(r) != 0 {
+		t.Errorf("TakeReplies() again = %q, want nothing", r)
+	}
+}
+
+func TestModes(t *testing.T) {
+	term := New(20, 5, 100)
+	feed(t, term, "\x1b[?1h\x1b=\x1b[?1002h\x1b[?1006h\x1b[?2004h")
+	want := Modes{CursorKeys: true, Keypad: true, BracketedPaste: true, Mouse: true, MouseSGR: true}
+	if got := term.Modes(); got != want {
+		t.Errorf("Modes() = %+v, want %+v", got, want)
+	}
+	feed(t, term, "\x1b[?1l\x1b>\x1b[?1002l")
+	if got := term.Modes(); got.CursorKeys || got.Keypad || got.Mouse {
+		t.Errorf("Modes() after resetting = %+v", got)
+	}
+}
+
+func TestSplitSequences(t *testing.T) {
+	// Output arrives in arbitrary chunks.
+	term := New(20, 5, 100)
+	for _, b := range []byte("\x1b[31mré\x1b]0;title\x07d\x1b[0m") {
+		term.Write([]byte{b})
+	}
+	if got, want := term.Styled(), rows("\x1b[31mréd", "", "", "", ""); got != want {
+		t.Errorf("Styled() = %q, want %q", got, want)
+	}
+	if term.Title() != "title" {
+		t.Errorf("Title() = %q, want %q", term.Title(), "title")
+	}
+}
@@ -1,6 +1,10 @@
 package strider
 
-import "fmt"
+import (
+	"fmt"
+	"strings"
+	"unicode/utf8"
+)
 
 // Key represents a tmux key sequence.
 type Key string
@@ -21,6 +25,8 @@ const (
 	PageDown  Key = "PageDown"
 	Space     Key = "Space"
 	Delete    Key = "DC"
+	Insert    Key = "IC"
+	BTab      Key = "BTab" // Shift+Tab
 
 	F1  Key = "F1"
 	F2  Key = "F2"
@@ -34,8 +40,53 @@ const (
 	F10 Key = "F10"
 	F11 Key = "F11"
 	F12 Key = "F12"
+
+	// Shifted function keys.
+	ShiftF1  Key = "S-F1"
+	ShiftF2  Key = "S-F2"
+	ShiftF3  Key = "S-F3"
+	ShiftF4  Key = "S-F4"
+	ShiftF5  Key = "S-F5"
+	ShiftF6  Key = "S-F6"
+	ShiftF7  Key = "S-F7"
+	ShiftF8  Key = "S-F8"
+	ShiftF9  Key = "S-F9"
+	ShiftF10 Key = "S-F10"
+	ShiftF11 Key = "S-F11"
+	ShiftF12 Key = "S-F12"
+
+	// Numeric keypad keys. Programs see them as the plain characters unless
+	// they enable the terminal's application keypad mode.
+	KP0      Key = "KP0"
+	KP1      Key = "KP1"
+	KP2      Key = "KP2"
+	KP3      Key = "KP3"
+	KP4      Key = "KP4"
+	KP5      Key = "KP5"
+	KP6      Key = "KP6"
+	KP7      Key = "KP7"
+	KP8      Key = "KP8"
+	KP9      Key = "KP9"
+	KPEnter  Key = "KPEnter"
+	KPPlus   Key = "KP+"
+	KPMinus  Key = "KP-"
+	KPStar   Key = "KP*"
+	KPSlash  Key = "KP/"
+	KPPeriod Key = "KP."
 )
 
+// Keymap maps the names of application actions to the keys that trigger
+// them, so tests can express intent and a changed binding is updated in one
+// place:
+//
+//	km := strider.Keymap{
+//		"save": strider.Ctrl('s'),
+//		"quit": strider.Key("q"),
+//	}
+//	term := strider.Open(t, "./my-app", strider.WithKeymap(km))
+//	term.Do("save")
+type Keymap map[string]Key
+
 // Ctrl returns the key sequence for Ctrl+<char>.
 func Ctrl(c byte) Key {
 	return Key(fmt.Sprintf("C-%c", c))
@@ -45,3 +96,111 @@ func Ctrl(c byte) Key {
 func Alt(c byte) Key {
 	return Key(fmt.Sprintf("M-%c", c))
 }
+
+// CtrlKey returns k with the Ctrl modifier, for named keys such as
+// CtrlKey(Right), which tmux sends as C-Right. Modifiers compose:
+// CtrlKey(ShiftKey(Up)) is C-S-Up.
+func CtrlKey(k Key) Key {
+	return "C-" + k
+}
+
+// AltKey returns k with the Alt (Meta) modifier, for named keys such as
+// AltKey(Left), the word-wise movement of many editors and shells.
+func AltKey(k Key) Key {
+	return "M-" + k
+}
+
+// ShiftKey returns k with the Shift modifier, for named keys such as
+// ShiftKey(Up), which selects text in many editors.
+func ShiftKey(k Key) Key {
+	return "S-" + k
+}
+
+// keyNames are the key names tmux's send-keys recognizes, in addition to
+// single characters. tmux matches them case-insensitively.
+var keyNames = []string{
+	"Enter", "Escape", "Tab", "BTab", "BSpace", "Space",
+	"Up", "Down", "Left", "Right",
+	"Home", "End", "IC", "Insert", "DC", "Delete",
+	"PPage", "PageUp", "PgUp", "NPage", "PageDown", "PgDn",
+	"F1", "F2", "F3", "F4", "F5", "F6", "F7", "F8", "F9", "F10", "F11", "F12",
+	"KP0", "KP1", "KP2", "KP3", "KP4", "KP5", "KP6", "KP7", "KP8", "KP9",
+	"KP/", "KP*", "KP-", "KP+", "KP.", "KPEnter",
+}
+
+// ValidateKey reports whether tmux recognizes k as a key: a single
+// character or a key name such as "Enter" or "F5", optionally with C-, M-,
+// S-, or ^ modifier prefixes. tmux types anything else as literal text
+// without an error, so a misspelled key name would send its letters instead.
+// The error lists the valid key names and suggests the closest one.
+func ValidateKey(k Key) error {
+	if k == "" {
+		return fmt.Errorf("empty key")
+	}
+	base := stripKeyModifiers(string(k))
+	if utf8.RuneCountInString(base) == 1 {
+		return nil
+	}
+	for _, name := range keyNames {
+		if strings.EqualFold(base, name) {
+			return nil
+		}
+	}
+
+	msg := fmt.Sprintf("unknown key %q (tmux would type it as text)", string(k))
+	if s := suggestKeyName(base); s != "" {
+		msg += fmt.Sprintf("; did you mean %q?", string(k[:len(k)-len(base)])+s)
+	}
+	return fmt.Errorf("%s\n    valid key names: %s, or any single character, with optional C-, M-, S- prefixes; use Type to send text",
+		msg, strings.Join(keyNames, ", "))
+}
+
+// stripKeyModifiers returns s without its modifier prefixes (C-, M-, S-,
+// and ^), as tmux parses them. A prefix is only stripped if something
+// follows it, so "C-" alone is two characters, not a key.
+func stripKeyModifiers(s string) string {
+	for {
+		switch {
+		case len(s) > 2 && s[1] == '-' && strings.ContainsRune("CMScms", rune(s[0])):
+			s = s[2:]
+		case len(s) > 1 && s[0] == '^':
+			s = s[1:]
+		default:
+			return s
+		}
+	}
+}
+
+// suggestKeyName returns the key name closest to s, ignoring case, or "" if
+// none is close enough to be a likely typo.
+func suggestKeyName(s string) string {
+	best, bestDist := "", 3 // suggest at most two edits away
+	for _, name := range keyNames {
+		if d := editDistance(strings.ToLower(s), strings.ToLower(name)); d < bestDist {
+			best, bestDist = name, d
+		}
+	}
+	return best
+}
+
+// editDistance returns the Levenshtein distance between a and b, in runes.
+func editDistance(a, b string) int {
+	ra, rb := []rune(a), []rune(b)
+	prev := make([]int, len(rb)+1)
+	cur := make([]int, len(rb)+1)
+	for j := range prev {
+		prev[j] = j
+	}
+	for i := 1; i <= len(ra); i++ {
+		cur[0] = i
+		for j := 1; j <= len(rb); j++ {
+			cost := 1
+			if ra[i-1] == rb[j-1] {
+				cost = 0
+			}
+			cur[j] = min(prev[j]+1, cur[j-1]+1, prev[j-1]+cost)
+		}
+		prev, cur = cur, prev
+	}
+	return prev[len(rb)]
+}
@@ -0,0 +1,112 @@
+package strider
+
+import (
+	"fmt"
+	"os"
+	"strconv"
+	"sync"
+	"time"
+)
+
+// serverLimit bounds how many tmux servers run at once across the process.
+var serverLimit = struct {
+	mu       sync.Mutex
+	cond     *sync.Cond
+	max      int // 0 means unlimited
+	active   int
+	held     map[host]int // slots held by the terminals of each test
+	explicit bool         // set by SetMaxConcurrent; STRIDER_MAX_CONCURRENT is ignored
+	envOnce  sync.Once
+}{}
+
+func init() {
+	serverLimit.cond = sync.NewCond(&serverLimit.mu)
+}
+
+// SetMaxConcurrent bounds the number of tmux servers (one per open Terminal)
+// that may run at the same time. Open blocks until a slot is free, and the
+// slot is released when the Terminal is cleaned up. A value of 0 or less
+// removes the limit. It returns the previous limit.
+//
+// Every Terminal holds a slot until its test ends, including a second
+// Terminal of the same test and one started with Reopen, so a test needs as
+// many slots as it opens terminals. Open fails at once if the test's own
+// terminals hold every slot, and fails if no slot frees up within the
+// Terminal's timeout (see WithTimeout), rather than wait for a slot that
+// only its own cleanup, or that of a parent test, would free.
+//
+// The limit can also be set with the STRIDER_MAX_CONCURRENT environment
+// variable; SetMaxConcurrent takes precedence.
+func SetMaxConcurrent(n int) (previous int) {
+	loadMaxConcurrentEnv()
+
+	serverLimit.mu.Lock()
+	defer serverLimit.mu.Unlock()
+
+	previous = serverLimit.max
+	if n < 0 {
+		n = 0
+	}
+	serverLimit.max = n
+	serverLimit.explicit = true
+	serverLimit.cond.Broadcast()
+	return previous
+}
+
+// loadMaxConcurrentEnv applies STRIDER_MAX_CONCURRENT once, unless
+// SetMaxConcurrent was called first. Invalid values are ignored.
+func loadMaxConcurrentEnv() {
+	serverLimit.envOnce.Do(func() {
+		n, err := strconv.Atoi(os.Getenv("STRIDER_MAX_CONCURRENT"))
+		if err != nil || n <= 0 {
+			return
+		}
+		serverLimit.mu.Lock()
+		if !serverLimit.explicit {
+			serverLimit.max = n
+		}
+		serverLimit.mu.Unlock()
+	})
+}
+
+// acquireServerSlot waits until a tmux server may be started for a
+// Terminal whose cleanup runs with owner, for at most timeout.
+func acquireServerSlot(owner host, timeout time.Duration) error {
+	loadMaxConcurrentEnv()
+
+	serverLimit.mu.Lock()
+	defer serverLimit.mu.Unlock()
+	deadline := time.Now().Add(timeout)
+	timer := time.AfterFunc(timeout, func() {
+		serverLimit.mu.Lock()
+		serverLimit.cond.Broadcast()
+		serverLimit.mu.Unlock()
+	})
+	defer timer.Stop()
+	for serverLimit.max > 0 && serverLimit.active >= serverLimit.max {
+		if serverLimit.held[owner] >= serverLimit.max {
+			return fmt.Errorf("the test's terminals already hold all %d tmux server slots (see SetMaxConcurrent), which are freed only when it ends", serverLimit.max)
+		}
+		if !time.Now().Before(deadline) {
+			return fmt.Errorf("no tmux server slot freed up within %v; all %d slots are taken (see SetMaxConcurrent)", timeout, serverLimit.max)
+		}
+		serverLimit.cond.Wait()
+	}
+	serverLimit.active++
+	if serverLimit.held == nil {
+		serverLimit.held = make(map[host]int)
+	}
+	serverLimit.held[owner]++
+	return nil
+}
+
+// releaseServerSlot frees a slot taken by acquireServerSlot.
+func releaseServerSlot(owner host) {
+	serverLimit.mu.Lock()
+	defer serverLimit.mu.Unlock()
+	serverLimit.active--
+	if serverLimit.held[owner]--; serverLimit.held[owner] <= 0 {
+		delete(serverLimit.held, owner)
+	}
+	serverLimit.cond.Broadcast()
+}
@@ -0,0 +1,123 @@
+package strider
+
+import (
+	"fmt"
+	"os"
+	"strings"
+	"sync"
+)
+
+// logLevel is how much strider logs about its own work during a test.
+type logLevel int
+
+const (
+	logNormal logLevel = iota // informational lines as they happen
+	logQuiet                  // informational lines only if the test fails
+	logDebug                  // also every input action and wait poll
+)
+
+// envTrue reports whether the environment variable name is set to a truthy
+// value, as STRIDER_UPDATE is.
+func envTrue(name string) bool {
+	v := os.Getenv(name)
+	return v == "1" || v == "true" || v == "yes"
+}
+
+// resolveLogLevel returns the log level for opts. STRIDER_DEBUG takes
+// precedence over WithQuiet and STRIDER_QUIET, so a quiet suite can be
+// debugged without editing it.
+func resolveLogLevel(opts options) logLevel {
+	switch {
+	case envTrue("STRIDER_DEBUG"):
+		return logDebug
+	case opts.quiet || envTrue("STRIDER_QUIET"):
+		return logQuiet
+	}
+	return logNormal
+}
+
+// logger writes strider's informational and debug lines to the test log.
+// In quiet mode it holds the informational lines of each test until the test
+// ends, and logs them then only if the test failed.
+type logger struct {
+	level logLevel
+
+	mu   sync.Mutex
+	held map[host][]string
+}
+
+func newLogger(level logLevel) *logger {
+	return &logger{level: level}
+}
+
+// infof logs an informational line, such as the seed or a step name.
+func (l *logger) infof(t host, format string, args ...any) {
+	t.Helper()
+	if l.level != logQuiet {
+		t.Logf(format, args...)
+		return
+	}
+
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	if l.held == nil {
+		l.held = make(map[host][]string)
+	}
+	if _, ok := l.held[t]; !ok {
+		t.Cleanup(func() { l.flush(t) })
+	}
+	l.held[t] = append(l.held[t], fmt.Sprintf(format, args...))
+}
+
+// debugf logs a line only when STRIDER_DEBUG is set.
+func (l *logger) debugf(t host, format string, args ...any) {
+	t.Helper()
+	if l.level == logDebug {
+		t.Logf(format, args...)
+	}
+}
+
+// flush logs the lines held for t if t failed, and forgets them.
+func (l *logger) flush(t host) {
+	l.mu.Lock()
+	lines := l.held[t]
+	delete(l.held, t)
+	l.mu.Unlock()
+	if t.Failed() && len(lines) > 0 {
+		t.Logf("strider: quiet: log held until the failure:\n    %s", strings.Join(lines, "\n    "))
+	}
+}
+
+// infof logs an informational line to the Terminal's test.
+func (term *Terminal) infof(format string, args ...any) {
+	term.host.Helper()
+	term.log.infof(term.host, "%s", term.redact(fmt.Sprintf(format, args...)))
+}
+
+// debugf logs a line to the Terminal's test when STRIDER_DEBUG is set.
+func (term *Terminal) debugf(format string, args ...any) {
+	term.host.Helper()
+	if term.log.level == logDebug {
+		term.log.debugf(term.host, "%s", term.redact(fmt.Sprintf(format, args...)))
+	}
+}
+
+// logf logs a line to the Terminal's test, redacted (see WithRedact).
+func (term *Terminal) logf(format string, args ...any) {
+	term.host.Helper()
+	term.host.Logf("%s", term.redact(fmt.Sprintf(format, args...)))
+}
+
+// errorf reports a failure to the Terminal's test, redacted (see
+// WithRedact), through its Reporter.
+func (term *Terminal) errorf(format string, args ...any) {
+	term.t.Helper()
+	term.failWith(Failure{}, format, args...)
+}
+
+// fatalf reports a failure to the Terminal's test, redacted (see
+// WithRedact), through its Reporter, and stops the test.
+func (term *Terminal) fatalf(format string, args ...any) {
+	term.t.Helper()
+	term.failWith(Failure{Fatal: true}, format, args...)
+}
@@ -0,0 +1,210 @@
+package strider
+
+import (
+	"bufio"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+)
+
+// A Macro is a named list of input steps, for setup sequences many tests
+// repeat: logging in, opening a project, navigating to a screen. Play it
+// with Terminal.Play, and keep it in a file with SaveMacro and LoadMacro:
+//
+//	login := strider.Macro{Name: "log in", Steps: []strider.MacroStep{
+//		{WaitFor: "Username:"},
+//		{Type: "alice"},
+//		{Press: []strider.Key{strider.Enter}},
+//		{WaitFor: "Welcome, alice"},
+//	}}
+//	term.Play(login)
+type Macro struct {
+	Name  string
+	Steps []MacroStep
+}
+
+// A MacroStep is one step of a Macro. Exactly one of its fields is set.
+type MacroStep struct {
+	// Type is text sent as with Terminal.Type.
+	Type string
+	// Press is keys sent as with Terminal.Press.
+	Press []Key
+	// WaitFor is text to wait for, as with WaitFor(Text(...)).
+	WaitFor string
+}
+
+// String returns the step as a line of a macro file: type "text",
+// press Key..., or wait "text".
+func (s MacroStep) String() string {
+	switch {
+	case s.Type != "":
+		return "type " + strconv.Quote(s.Type)
+	case len(s.Press) > 0:
+		keys := make([]string, len(s.Press))
+		for i, k := range s.Press {
+			keys[i] = string(k)
+		}
+		return "press " + strings.Join(keys, " ")
+	case s.WaitFor != "":
+		return "wait " + strconv.Quote(s.WaitFor)
+	}
+	return "(empty step)"
+}
+
+// validate reports a step that does not set exactly one field, or presses a
+// key tmux does not recognize.
+func (s MacroStep) validate() error {
+	set := 0
+	for _, ok := range []bool{s.Type != "", len(s.Press) > 0, s.WaitFor != ""} {
+		if ok {
+			set++
+		}
+	}
+	if set != 1 {
+		return fmt.Errorf("step sets %d of Type, Press, and WaitFor, want exactly one", set)
+	}
+	for _, k := range s.Press {
+		if err := ValidateKey(k); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// Play runs the steps of m in order, as a Step named after the macro, so a
+// failure names the macro that broke. It fails the test before sending any
+// input if a step is invalid.
+func (term *Terminal) Play(m Macro) {
+	term.t.Helper()
+	term.check(term.checkMacro(m))
+	term.Step(m.Name, func() {
+		term.check(term.playSteps(m))
+	})
+}
+
+// play is Play for a Session, which has no test to run a Step in: it logs
+// the macro's name as Step does, and returns the first failure.
+func (term *Terminal) play(m Macro) error {
+	term.host.Helper()
+	if err := term.checkMacro(m); err != nil {
+		return err
+	}
+	term.infof("strider: step %q", m.Name)
+	if err := term.playSteps(m); err != nil {
+		term.logf("strider: step %q failed", m.Name)
+		return err
+	}
+	return nil
+}
+
+// checkMacro returns the failure for the first invalid step of m, if any.
+func (term *Terminal) checkMacro(m Macro) error {
+	for i, s := range m.Steps {
+		if err := s.validate(); err != nil {
+			return term.failf("strider: play: macro %q: step %d: %v", m.Name, i+1, err)
+		}
+	}
+	return nil
+}
+
+// playSteps runs the steps of m, stopping at the first failure.
+func (term *Terminal) playSteps(m Macro) error {
+	term.host.Helper()
+	for _, s := range m.Steps {
+		var err error
+		switch {
+		case s.Type != "":
+			err = term.typeText(s.Type)
+		case len(s.Press) > 0:
+			err = term.press(s.Press)
+		default:
+			_, err = term.waitFor("wait-for", Text(s.WaitFor))
+		}
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// macroExt is the extension of macro files, dropped from the file name to
+// name a loaded macro.
+const macroExt = ".macro"
+
+// LoadMacro reads a macro file, as written by SaveMacro. Each line is one
+// step, in the form MacroStep.String returns; blank lines and lines starting
+// with # are ignored. The macro is named after the file, without its .macro
+// extension:
+//
+//	# testdata/login.macro
+//	wait "Username:"
+//	type "alice"
+//	press Enter
+//	wait "Welcome, alice"
+func LoadMacro(path string) (Macro, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return Macro{}, fmt.Errorf("strider: load-macro: %w", err)
+	}
+	m := Macro{Name: strings.TrimSuffix(filepath.Base(path), macroExt)}
+	sc := bufio.NewScanner(strings.NewReader(string(data)))
+	for n := 1; sc.Scan(); n++ {
+		line := strings.TrimSpace(sc.Text())
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+		s, err := parseMacroStep(line)
+		if err == nil {
+			err = s.validate()
+		}
+		if err != nil {
+			return Macro{}, fmt.Errorf("strider: load-macro: %s:%d: %v", path, n, err)
+		}
+		m.Steps = append(m.Steps, s)
+	}
+	return m, nil
+}
+
+// parseMacroStep parses a line of a macro file.
+func parseMacroStep(line string) (MacroStep, error) {
+	action, arg, _ := strings.Cut(line, " ")
+	arg = strings.TrimSpace(arg)
+	switch action {
+	case "type", "wait":
+		text, err := strconv.Unquote(arg)
+		if err != nil {
+			return MacroStep{}, fmt.Errorf("%s wants a quoted string, got %s", action, arg)
+		}
+		if action == "type" {
+			return MacroStep{Type: text}, nil
+		}
+		return MacroStep{WaitFor: text}, nil
+	case "press":
+		var s MacroStep
+		for _, k := range strings.Fields(arg) {
+			s.Press = append(s.Press, Key(k))
+		}
+		return s, nil
+	}
+	return MacroStep{}, fmt.Errorf("unknown action %q (want type, press, or wait)", action)
+}
+
+// SaveMacro writes m to path in the format LoadMacro reads, with a comment
+// line naming the macro. It returns an error, writing nothing, if a step is
+// invalid.
+func SaveMacro(path string, m Macro) error {
+	var b strings.Builder
+	fmt.Fprintf(&b, "# %s\n", m.Name)
+	for i, s := range m.Steps {
+		if err := s.validate(); err != nil {
+			return fmt.Errorf("strider: save-macro: step %d: %v", i+1, err)
+		}
+		b.WriteString(s.String() + "\n")
+	}
+	if err := writeFileAtomic(path, b.String()); err != nil {
+		return fmt.Errorf("strider: save-macro: %w", err)
+	}
+	return nil
+}
@@ -3,11 +3,22 @@ package strider
 import (
 	"fmt"
 	"regexp"
+	"strconv"
 	"strings"
+	"sync"
+
+	"github.com/cboone/strider/internal/textdiff"
 )
 
 // A Matcher reports whether a Screen satisfies a condition.
 // The string return is a human-readable description for error messages.
+//
+// A Matcher must depend only on the Screen it is given: while waiting,
+// strider does not run it again on a capture identical to one it already
+// rejected (see Screen.Hash). InOrder is the exception: it tracks what
+// earlier screens showed, which unchanged captures would not add to.
+// Built-in matchers on state outside the screen, such as FileExists, are
+// run on every poll.
 type Matcher func(s *Screen) (ok bool, description string)
 
 // Text matches if the screen contains the given substring anywhere.
@@ -17,6 +28,62 @@ func Text(s string) Matcher {
 	}
 }
 
+// TextWrapped matches if the screen contains the given substring after
+// joining soft-wrapped rows (see Screen.ContainsWrapped). Use it when text
+// may wrap across rows at narrow terminal sizes.
+func TextWrapped(s string) Matcher {
+	return func(scr *Screen) (bool, string) {
+		return scr.ContainsWrapped(s), fmt.Sprintf("screen to contain %q (soft-wrap aware)", s)
+	}
+}
+
+// Textf is like Text with the substring built by fmt.Sprintf.
+func Textf(format string, args ...any) Matcher {
+	return Text(fmt.Sprintf(format, args...))
+}
+
+// TextAll matches if the screen contains every given substring. On failure
+// the description lists the substrings that were missing.
+func TextAll(strs ...string) Matcher {
+	return func(scr *Screen) (bool, string) {
+		desc := "screen to contain all of " + quoteList(strs)
+		var missing []string
+		for _, s := range strs {
+			if !scr.Contains(s) {
+				missing = append(missing, s)
+			}
+		}
+		if len(missing) > 0 {
+			return false, desc + " (missing: " + quoteList(missing) + ")"
+		}
+		return true, desc
+	}
+}
+
+// TextAny matches if the screen contains at least one of the given
+// substrings. On failure the description lists the substrings that were
+// missing.
+func TextAny(strs ...string) Matcher {
+	return func(scr *Screen) (bool, string) {
+		desc := "screen to contain any of " + quoteList(strs)
+		for _, s := range strs {
+			if scr.Contains(s) {
+				return true, desc
+			}
+		}
+		return false, desc + " (missing: " + quoteList(strs) + ")"
+	}
+}
+
+// quoteList formats strs as a comma-separated list of quoted strings.
+func quoteList(strs []string) string {
+	quoted := make([]string, len(strs))
+	for i, s := range strs {
+		quoted[i] = fmt.Sprintf("%q", s)
+	}
+	return strings.Join(quoted, ", ")
+}
+
 // Regexp matches if the screen content matches the regular expression.
 // The pattern is compiled once; an invalid pattern causes a panic.
 func Regexp(pattern string) Matcher {
@@ -27,34 +94,178 @@ func Regexp(pattern string) Matcher {
 }
 
 // Line matches if the given line (0-indexed) equals s after trimming
-// trailing spaces from the screen line.
+// trailing spaces from the screen line. It does not match, rather than
+// panic, if the line is out of range.
 func Line(n int, s string) Matcher {
 	return func(scr *Screen) (bool, string) {
 		desc := fmt.Sprintf("line %d to equal %q", n, s)
 		lines := scr.Lines()
 		if n < 0 || n >= len(lines) {
+			scr.addNote(lineRangeNote(n, len(lines)))
+			return false, desc
+		}
+		actual := strings.TrimRight(lines[n], " ")
+		if actual != s {
+			scr.addNote(lineDiffNote(n, s, actual))
 			return false, desc
 		}
-		return strings.TrimRight(lines[n], " ") == s, desc
+		return true, desc
 	}
 }
 
+// Linef is like Line with the expected content built by fmt.Sprintf.
+func Linef(n int, format string, args ...any) Matcher {
+	return Line(n, fmt.Sprintf(format, args...))
+}
+
 // LineContains matches if the given line (0-indexed) contains the substring.
+// It does not match, rather than panic, if the line is out of range.
 func LineContains(n int, substr string) Matcher {
 	return func(scr *Screen) (bool, string) {
 		desc := fmt.Sprintf("line %d to contain %q", n, substr)
 		lines := scr.Lines()
 		if n < 0 || n >= len(lines) {
+			scr.addNote(lineRangeNote(n, len(lines)))
+			return false, desc
+		}
+		if !strings.Contains(lines[n], substr) {
+			scr.addNote(lineContainsNote(n, substr, strings.TrimRight(lines[n], " ")))
+			return false, desc
+		}
+		return true, desc
+	}
+}
+
+// addNote records an explanation of why a matcher did not match s, shown
+// below the description when a wait fails. Notes are kept with the screen
+// because a description must not depend on the screen's content: composite
+// matchers join descriptions into one line. They belong to one evaluation
+// (see evaluate), not to the capture.
+func (s *Screen) addNote(note string) {
+	s.notesMu.Lock()
+	defer s.notesMu.Unlock()
+	s.notes = append(s.notes, note)
+}
+
+// evaluate runs m on scr and returns its result with the notes m recorded.
+// Notes left on scr by matchers run on it directly, outside evaluate, are
+// dropped first, so they do not explain an unrelated failure.
+func evaluate(m Matcher, scr *Screen) (ok bool, desc string, notes []string) {
+	scr.takeNotes()
+	ok, desc = m(scr)
+	return ok, desc, scr.takeNotes()
+}
+
+// takeNotes returns and clears the notes recorded on s.
+func (s *Screen) takeNotes() []string {
+	s.notesMu.Lock()
+	defer s.notesMu.Unlock()
+	notes := s.notes
+	s.notes = nil
+	return notes
+}
+
+// formatNotes formats notes for a wait failure message, or returns "" when
+// there are none.
+func formatNotes(notes []string) string {
+	var b strings.Builder
+	for _, note := range notes {
+		b.WriteString("\n    ")
+		b.WriteString(strings.ReplaceAll(note, "\n", "\n    "))
+	}
+	return b.String()
+}
+
+// lineRangeNote explains that row n does not exist on a screen of count
+// rows.
+func lineRangeNote(n, count int) string {
+	return fmt.Sprintf("line %d is out of range (the screen has %d lines)", n, count)
+}
+
+// lineDiffNote shows the expected and actual content of row n, with a caret
+// under the first column where they differ.
+func lineDiffNote(n int, expected, actual string) string {
+	e, a := []rune(expected), []rune(actual)
+	i := 0
+	for i < len(e) && i < len(a) && e[i] == a[i] {
+		i++
+	}
+	prefix := string(a[:i])
+	return fmt.Sprintf("line %d differs at column %d:\n    expected: %q\n    actual:   %q\n    %s^",
+		n, displayWidth(prefix), expected, actual, caretPad(prefix))
+}
+
+// lineContainsNote shows the content of row n with substr aligned under the
+// longest prefix of substr the row contains, and a caret under the column
+// where the two part. With no common prefix, it shows the row alone.
+func lineContainsNote(n int, substr, actual string) string {
+	sub := []rune(substr)
+	for k := len(sub) - 1; k > 0; k-- {
+		at := strings.Index(actual, string(sub[:k]))
+		if at < 0 {
+			continue
+		}
+		// Both quoted strings are laid out alike up to the opening quote of
+		// substr, which sits under the last character before the match.
+		indent := strings.Repeat(" ", quotedWidth(actual[:at])-2)
+		return fmt.Sprintf("line %d, closest match at column %d:\n    actual:   %q\n    expected: %s%q\n    %s^",
+			n, displayWidth(actual[:at]), actual, indent, substr, caretPad(actual[:at+len(string(sub[:k]))]))
+	}
+	return lineNote(n, actual)
+}
+
+// lineNote shows the content of row n.
+func lineNote(n int, actual string) string {
+	return fmt.Sprintf("line %d:\n    actual:   %q", n, actual)
+}
+
+// caretPad returns the spaces that put a caret, on the line below a
+// "label:   " and a quoted string, under the character following prefix.
+func caretPad(prefix string) string {
+	return strings.Repeat(" ", len("expected: ")+quotedWidth(prefix)-1)
+}
+
+// quotedWidth returns the display width of s quoted with %q.
+func quotedWidth(s string) int {
+	return displayWidth(strconv.Quote(s))
+}
+
+// LineContainsf is like LineContains with the substring built by
+// fmt.Sprintf.
+func LineContainsf(n int, format string, args ...any) Matcher {
+	return LineContains(n, fmt.Sprintf(format, args...))
+}
+
+// LineMatches matches if the given line (0-indexed) matches the regular
+// expression. It does not match, rather than panic, if the line is out of
+// range. The pattern is compiled once; an invalid pattern causes a panic.
+func LineMatches(n int, pattern string) Matcher {
+	re := regexp.MustCompile(pattern)
+	return func(scr *Screen) (bool, string) {
+		desc := fmt.Sprintf("line %d to match regexp %q", n, pattern)
+		lines := scr.Lines()
+		if n < 0 || n >= len(lines) {
+			scr.addNote(lineRangeNote(n, len(lines)))
 			return false, desc
 		}
-		return strings.Contains(lines[n], substr), desc
+		if !re.MatchString(lines[n]) {
+			scr.addNote(lineNote(n, strings.TrimRight(lines[n], " ")))
+			return false, desc
+		}
+		return true, desc
 	}
 }
 
 // Not inverts a matcher.
 func Not(m Matcher) Matcher {
 	return func(scr *Screen) (bool, string) {
+		// Notes explain why m did not match, which is when Not does.
+		before := scr.takeNotes()
 		ok, desc := m(scr)
+		scr.takeNotes()
+		for _, note := range before {
+			scr.addNote(note)
+		}
 		return !ok, "NOT(" + desc + ")"
 	}
 }
@@ -89,6 +300,123 @@ func Any(matchers ...Matcher) Matcher {
 	}
 }
 
+// Memoize returns a matcher that runs m only when the screen differs from
+// the last one it was given (see Screen.Hash), and otherwise repeats m's
+// previous result. It is a hint for expensive matchers (large regular
+// expressions, layout analysis) shared between several waits or composite
+// matchers; within a single wait, unchanged screens are already skipped
+// (see Matcher). m must
+// depend only on the screen; results that involve built-in matchers on
+// other state, such as FileExists, are not cached. The returned matcher is
+// safe for concurrent use.
+func Memoize(m Matcher) Matcher {
+	var (
+		mu        sync.Mutex
+		last      *Screen
+		lastHash  uint64
+		lastOK    bool
+		lastDesc  string
+		lastNotes []string
+	)
+	return func(scr *Screen) (bool, string) {
+		hash := scr.Hash()
+		mu.Lock()
+		if last != nil && hash == lastHash && sameCapture(last, scr) {
+			ok, desc, notes := lastOK, lastDesc, lastNotes
+			mu.Unlock()
+			if scr != last {
+				for _, note := range notes {
+					scr.addNote(note)
+				}
+			}
+			return ok, desc
+		}
+		mu.Unlock()
+
+		before := scr.takeNotes()
+		ok, desc := m(scr)
+		notes := scr.takeNotes()
+		for _, note := range append(before, notes...) {
+			scr.addNote(note)
+		}
+		if scr.external.Load() {
+			return ok, desc // depends on more than the screen
+		}
+		mu.Lock()
+		last, lastHash, lastOK, lastDesc, lastNotes = scr, hash, ok, desc, notes
+		mu.Unlock()
+		return ok, desc
+	}
+}
+
+// sameCapture reports whether a and b hold the same content, size, and
+// cursor position, guarding Memoize against hash collisions.
+func sameCapture(a, b *Screen) bool {
+	return a.Equal(b) && a.cursorRow == b.cursorRow && a.cursorCol == b.cursorCol &&
+		a.visibleStart == b.visibleStart
+}
+
+// InOrder matches once the given strings have all appeared, in order, for
+// status messages that must follow a sequence such as "Connecting",
+// "Authenticated", "Ready". A string counts as seen when a screen shows it,
+// in a later poll or below the previous string on the same screen, so both
+// a status line that changes in place and a log that grows down the screen
+// work.
+//
+// A string appearing before its predecessors is an ordering error: from then
+// on the matcher never matches, and its description names the two strings,
+// so the wait fails when it times out. The strings should not appear on the
+// screen except as the messages being checked.
+//
+// Unlike other matchers, InOrder keeps state between calls, so use a new
+// one for each wait. Transitions faster than the poll interval can be
+// missed; for messages that stay in the scrollback, check a single capture
+// instead:
+//
+//	ok, desc := strider.InOrder("Connecting", "Ready")(term.Scrollback())
+func InOrder(strs ...string) Matcher {
+	desc := "screen to show " + quoteList(strs) + " in order"
+	var (
+		mu       sync.Mutex
+		next     int    // index of the first string not yet seen
+		outOfSeq string // the ordering error, once one is found
+	)
+	return func(scr *Screen) (bool, string) {
+		mu.Lock()
+		defer mu.Unlock()
+		if outOfSeq != "" {
+			return false, desc + " (out of order: " + outOfSeq + ")"
+		}
+
+		text := scr.String()
+		pos := 0
+		for next < len(strs) {
+			i := strings.Index(text[pos:], strs[next])
+			if i < 0 {
+				break
+			}
+			pos += i + len(strs[next])
+			next++
+		}
+		if next == len(strs) {
+			return true, desc
+		}
+
+		if next > 0 && strings.Contains(text[:pos], strs[next]) {
+			outOfSeq = fmt.Sprintf("%q appeared before %q", strs[next], strs[next-1])
+		}
+		for j := next + 1; j < len(strs) && outOfSeq == ""; j++ {
+			if strings.Contains(text, strs[j]) {
+				outOfSeq = fmt.Sprintf("%q appeared before %q", strs[j], strs[next])
+			}
+		}
+		if outOfSeq != "" {
+			return false, desc + " (out of order: " + outOfSeq + ")"
+		}
+		return false, fmt.Sprintf("%s (waiting for %q)", desc, strs[next])
+	}
+}
+
 // Empty matches when the screen has no visible content.
 func Empty() Matcher {
 	return func(scr *Screen) (bool, string) {
@@ -112,3 +440,161 @@ func Cursor(row, col int) Matcher {
 		return false, desc + fmt.Sprintf(" (actual: row=%d, col=%d)", scr.cursorRow, scr.cursorCol)
 	}
 }
+
+// SameAs matches if the screen equals ref after snapshot normalization
+// (see Screen.EqualNormalized). On mismatch the description includes a line
+// diff from ref to the current screen.
+func SameAs(ref *Screen) Matcher {
+	return func(scr *Screen) (bool, string) {
+		desc := "screen to equal reference screen"
+		if scr.EqualNormalized(ref) {
+			return true, desc
+		}
+		want := strings.Split(strings.TrimSuffix(NormalizeScreen(ref.String()), "\n"), "\n")
+		got := strings.Split(strings.TrimSuffix(NormalizeScreen(scr.String()), "\n"), "\n")
+		return false, desc + " (diff, - reference + actual):\n      " + strings.Join(textdiff.Lines(want, got), "\n      ")
+	}
+}
+
+// CursorWithin matches if the cursor lies inside the rectangle spanning rows
+// top through bottom and columns left through right (all 0-indexed and
+// inclusive). Use it instead of Cursor when the exact column shifts with
+// prompt or label lengths.
+func CursorWithin(top, left, bottom, right int) Matcher {
+	return func(scr *Screen) (bool, string) {
+		desc := fmt.Sprintf("cursor within rows %d-%d, cols %d-%d", top, bottom, left, right)
+		if scr.cursorRow < 0 || scr.cursorCol < 0 {
+			return false, desc + " (cursor position unavailable)"
+		}
+		if scr.cursorRow >= top && scr.cursorRow <= bottom && scr.cursorCol >= left && scr.cursorCol <= right {
+			return true, desc
+		}
+		return false, desc + fmt.Sprintf(" (actual: row=%d, col=%d)", scr.cursorRow, scr.cursorCol)
+	}
+}
+
+// SizeIs matches if the pane size at capture time is width x height.
+// Waiting on SizeIs after Resize avoids racing the pane's adoption of the
+// new dimensions.
+func SizeIs(width, height int) Matcher {
+	return func(scr *Screen) (bool, string) {
+		desc := fmt.Sprintf("screen size to be %dx%d", width, height)
+		w, h := scr.Size()
+		if w == width && h == height {
+			return true, desc
+		}
+		return false, desc + fmt.Sprintf(" (actual: %dx%d)", w, h)
+	}
+}
+
+// RightAlignedOn matches if text is the last content on the given row
+// (0-indexed) and ends in the screen's last column. Columns are measured in
+// display cells, so wide characters count as two.
+func RightAlignedOn(row int, text string) Matcher {
+	return func(scr *Screen) (bool, string) {
+		width, _ := scr.Size()
+		desc := fmt.Sprintf("line %d to have %q right-aligned at width %d", row, text, width)
+		lines := scr.Lines()
+		if row < 0 || row >= len(lines) {
+			return false, desc
+		}
+		trimmed := strings.TrimRight(lines[row], " ")
+		if !strings.HasSuffix(trimmed, text) {
+			return false, desc + " (text is not the last content on the line)"
+		}
+		if end := displayWidth(trimmed); end != width {
+			return false, desc + fmt.Sprintf(" (actual: ends at column %d)", end)
+		}
+		return true, desc
+	}
+}
+
+// CenteredOn matches if text appears on the given row (0-indexed) with the
+// blank margins to its left and right differing by at most one column,
+// relative to the screen width. Columns are measured in display cells.
+func CenteredOn(row int, text string) Matcher {
+	return func(scr *Screen) (bool, string) {
+		width, _ := scr.Size()
+		desc := fmt.Sprintf("line %d to have %q centered at width %d", row, text, width)
+		lines := scr.Lines()
+		if row < 0 || row >= len(lines) {
+			return false, desc
+		}
+		idx := strings.Index(lines[row], text)
+		if idx < 0 {
+			return false, desc + " (text not on line)"
+		}
+		left := displayWidth(lines[row][:idx])
+		right := width - left - displayWidth(text)
+		if diff := left - right; diff < -1 || diff > 1 {
+			return false, desc + fmt.Sprintf(" (actual: left margin %d, right margin %d)", left, right)
+		}
+		return true, desc
+	}
+}
+
+// AlignedColumns matches if each of the given rows (0-indexed) has a field
+// boundary at display column col: one of the cells on either side of the
+// line between columns col-1 and col is blank and the other is not. A
+// left-aligned field starting at col and a right-aligned field ending just
+// before it both count, so the matcher catches a table column that shifts
+// when the width of a value changes, which substring checks miss:
+//
+//	// Names start at column 0, right-aligned amounts end before column 20.
+//	term.WaitFor(strider.AlignedColumns([]int{2, 3, 4}, 20))
+//
+// Column 0 counts as a boundary when its cell is not blank.
+func AlignedColumns(rows []int, col int) Matcher {
+	return func(scr *Screen) (bool, string) {
+		desc := fmt.Sprintf("rows %v to have a field boundary at column %d", rows, col)
+		lines := scr.Lines()
+		var misaligned []string
+		for _, row := range rows {
+			if row < 0 || row >= len(lines) {
+				misaligned = append(misaligned, fmt.Sprintf("row %d: off screen", row))
+				continue
+			}
+			line := lines[row]
+			left, right := ' ', cellAt(line, col)
+			if col > 0 {
+				left = cellAt(line, col-1)
+			}
+			if (left == ' ') != (right == ' ') {
+				continue
+			}
+			from := max(col-boundaryContext, 0)
+			misaligned = append(misaligned, fmt.Sprintf("row %d: %q", row,
+				cellSlice(line, from, col-from)+"|"+cellSlice(line, col, boundaryContext)))
+		}
+		if len(misaligned) > 0 {
+			return false, desc + " (no boundary: " + strings.Join(misaligned, ", ") + ")"
+		}
+		return true, desc
+	}
+}
+
+// boundaryContext is the number of cells AlignedColumns shows on each side
+// of a missing field boundary.
+const boundaryContext = 4
+
+// RegionEquals matches if the block of cells starting at row top and display
+// column left equals lines exactly: row top+i, starting at column left, must
+// equal lines[i] over the display width of lines[i]. Cells past the end of a
+// screen line compare as spaces. It sits between single-line matchers and
+// full-screen snapshots for widget-level assertions.
+func RegionEquals(top, left int, lines []string) Matcher {
+	return func(scr *Screen) (bool, string) {
+		desc := fmt.Sprintf("region at row %d, col %d to equal %s", top, left, quoteList(lines))
+		screenLines := scr.Lines()
+		for i, want := range lines {
+			row := top + i
+			if row < 0 || row >= len(screenLines) {
+				return false, desc + fmt.Sprintf(" (row %d is off screen)", row)
+			}
+			if got := cellSlice(screenLines[row], left, displayWidth(want)); got != want {
+				return false, desc + fmt.Sprintf(" (row %d: actual %q)", row, got)
+			}
+		}
+		return true, desc
+	}
+}
@@ -0,0 +1,80 @@
+package strider
+
+import "time"
+
+// Metrics receives measurements from Terminals, for export to a monitoring
+// system such as Prometheus or OpenTelemetry. It is useful when strider
+// drives scheduled smoke checks rather than tests. Set it with WithMetrics.
+//
+// Methods are called synchronously from the goroutine running the Terminal,
+// and from several goroutines at once when Terminals sharing a Metrics run
+// in parallel, so implementations must be safe for concurrent use and
+// return quickly.
+type Metrics interface {
+	// ObserveWait is called when a wait finishes, before a failed wait
+	// fails the test.
+	ObserveWait(WaitMetric)
+}
+
+// WaitMetric describes a finished wait.
+type WaitMetric struct {
+	// Op names the operation as in failure messages: "wait-for" for
+	// WaitFor, WaitForScreen, and WaitForResult, "wait-exit" for WaitExit,
+	// and for example "open: ready-when" for the WithReadyWhen wait in Open.
+	Op string
+	// Description is the matcher description, or "process to exit".
+	Description string
+	// Elapsed is the time the wait took.
+	Elapsed time.Duration
+	// Polls is the number of times the screen or pane state was checked.
+	Polls int
+	// Outcome is how the wait finished.
+	Outcome WaitOutcome
+}
+
+// WaitOutcome is how a wait finished.
+type WaitOutcome int
+
+const (
+	// WaitSucceeded means the condition held before the timeout.
+	WaitSucceeded WaitOutcome = iota
+	// WaitTimedOut means the timeout expired first.
+	WaitTimedOut
+	// WaitProgramExited means the program exited before the condition held.
+	WaitProgramExited
+	// WaitAborted means a check set on the wait, such as NoClears or
+	// WithAbortWhen, stopped it before the condition held.
+	WaitAborted
+)
+
+// String returns a lowercase name for o, suitable as a metric label value.
+func (o WaitOutcome) String() string {
+	switch o {
+	case WaitSucceeded:
+		return "succeeded"
+	case WaitTimedOut:
+		return "timed_out"
+	case WaitProgramExited:
+		return "program_exited"
+	case WaitAborted:
+		return "aborted"
+	}
+	return "unknown"
+}
+
+// recordWait reports a finished wait to the suite summary and to the
+// Metrics set with WithMetrics, and returns its WaitMetric.
+func (term *Terminal) recordWait(op, desc string, elapsed time.Duration, polls int, outcome WaitOutcome) *WaitMetric {
+	term.recordWaitStats(elapsed, polls, outcome != WaitSucceeded)
+	m := WaitMetric{
+		Op:          op,
+		Description: term.redact(desc),
+		Elapsed:     elapsed,
+		Polls:       polls,
+		Outcome:     outcome,
+	}
+	if term.opts.metrics != nil {
+		term.opts.metrics.ObserveWait(m)
+	}
+	return &m
+}
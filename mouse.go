@@ -0,0 +1,119 @@
+package strider
+
+import (
+	"fmt"
+	"unicode/utf8"
+)
+
+// MouseButton is a mouse button for the click methods of Terminal.
+type MouseButton int
+
+// Mouse buttons.
+const (
+	MouseLeft MouseButton = iota
+	MouseMiddle
+	MouseRight
+)
+
+// String returns the name of b, such as "left".
+func (b MouseButton) String() string {
+	switch b {
+	case MouseLeft:
+		return "left"
+	case MouseMiddle:
+		return "middle"
+	case MouseRight:
+		return "right"
+	}
+	return fmt.Sprintf("MouseButton(%d)", int(b))
+}
+
+// legacyMouseLimit is the largest 1-based coordinate the legacy X10 mouse
+// encoding can carry in one byte.
+const legacyMouseLimit = 223
+
+// Click clicks the left mouse button on the cell at row and col (0-indexed),
+// sending the press and release reports an xterm would. The program must have
+// enabled mouse reporting, as Bubble Tea and other TUI frameworks do for
+// mouse support; Click fails the test otherwise, or if the cell is outside
+// the pane. The report uses the encoding the program asked for: SGR (1006),
+// UTF-8 (1005), or the legacy X10 encoding.
+func (term *Terminal) Click(row, col int) {
+	term.t.Helper()
+	term.ClickButton(MouseLeft, row, col)
+}
+
+// RightClick clicks the right mouse button on the cell at row and col, like
+// Click.
+func (term *Terminal) RightClick(row, col int) {
+	term.t.Helper()
+	term.ClickButton(MouseRight, row, col)
+}
+
+// MiddleClick clicks the middle mouse button on the cell at row and col,
+// like Click.
+func (term *Terminal) MiddleClick(row, col int) {
+	term.t.Helper()
+	term.ClickButton(MouseMiddle, row, col)
+}
+
+// ClickButton clicks button on the cell at row and col, like Click.
+func (term *Terminal) ClickButton(button MouseButton, row, col int) {
+	term.t.Helper()
+	term.record("click %s %d,%d", button, row, col)
+	term.requireAlive("click")
+
+	if button < MouseLeft || button > MouseRight {
+		term.fatalf("strider: click: unknown mouse button %v", button)
+	}
+	if g, err := term.backend.geometry(); err == nil && (row < 0 || row >= g.height || col < 0 || col >= g.width) {
+		term.fatalf("strider: click: cell %d,%d is outside the %dx%d pane", row, col, g.width, g.height)
+	}
+	mode, err := term.backend.mouseMode()
+	if err != nil {
+		term.fatalf("strider: click: %v", err)
+	}
+	if !mode.enabled {
+		term.fatalf("strider: click: the program has not enabled mouse reporting (for example with ESC [ ? 1000 h)")
+	}
+
+	report, err := mouseClickReport(mode, button, row, col)
+	if err != nil {
+		term.fatalf("strider: click: %v", err)
+	}
+	if term.opts.maxInputRate > 0 {
+		term.paceInput()
+	}
+	if err := term.backend.sendBytes(report); err != nil {
+		term.fatalf("strider: click: %v", err)
+	}
+}
+
+// mouseClickReport returns the press and release reports for a click of
+// button on the cell at row and col (0-indexed), in the encoding of mode.
+func mouseClickReport(mode mouseMode, button MouseButton, row, col int) ([]byte, error) {
+	b, x, y := int(button), col+1, row+1
+	if mode.sgr {
+		return fmt.Appendf(nil, "\x1b[<%d;%d;%dM\x1b[<%d;%d;%dm", b, x, y, b, x, y), nil
+	}
+
+	// The legacy encodings offset every value by 32 and report a release
+	// as button 3, without saying which button was released.
+	var out []byte
+	for _, v := range []int{b, 3} {
+		out = append(out, "\x1b[M"...)
+		out = append(out, byte(32+v))
+		for _, c := range []int{x, y} {
+			switch {
+			case mode.utf8:
+				out = utf8.AppendRune(out, rune(32+c))
+			case c > legacyMouseLimit:
+				return nil, fmt.Errorf("cell %d,%d is beyond what the legacy mouse encoding can report (column and row %d at most); enable SGR mouse mode (1006)",
+					row, col, legacyMouseLimit-1)
+			default:
+				out = append(out, byte(32+c))
+			}
+		}
+	}
+	return out, nil
+}
@@ -0,0 +1,94 @@
+package strider
+
+import (
+	"fmt"
+	"time"
+)
+
+// A Navigator moves the program's cursor or focus to the screen position at
+// row and col (0-indexed), using the Terminal's public methods, and returns
+// an error if it cannot. TypeAt uses it before typing; set it with
+// WithNavigator.
+//
+// Navigators for programs with their own focus model, such as forms that
+// move between fields with Tab, can press keys until Screen.CursorPosition
+// reports the target.
+type Navigator func(term *Terminal, row, col int) error
+
+// ArrowKeys is the default Navigator. It presses the arrow keys that lead
+// from the current cursor position to the target, waits for the cursor to
+// settle, and repeats until the cursor is at the target. It returns an error
+// if the cursor stops moving before reaching it.
+func ArrowKeys(term *Terminal, row, col int) error {
+	for {
+		scr := term.Screen()
+		r, c, ok := scr.CursorPosition()
+		if !ok {
+			return fmt.Errorf("cursor position unavailable")
+		}
+		if r == row && c == col {
+			return nil
+		}
+
+		var keys []Key
+		for i := r; i < row; i++ {
+			keys = append(keys, Down)
+		}
+		for i := r; i > row; i-- {
+			keys = append(keys, Up)
+		}
+		for i := c; i < col; i++ {
+			keys = append(keys, Right)
+		}
+		for i := c; i > col; i-- {
+			keys = append(keys, Left)
+		}
+		term.Press(keys...)
+
+		if !term.waitCursorSettled(r, c, row, col) {
+			return fmt.Errorf("cursor stuck at row %d, col %d", r, c)
+		}
+	}
+}
+
+// waitCursorSettled waits, up to the Terminal's timeout, for the cursor to
+// leave (fromRow, fromCol) and then either reach (toRow, toCol) or stop
+// moving, and reports whether it left at all.
+func (term *Terminal) waitCursorSettled(fromRow, fromCol, toRow, toCol int) bool {
+	deadline := time.Now().Add(term.opts.timeout)
+	lastRow, lastCol := fromRow, fromCol
+	for time.Now().Before(deadline) {
+		time.Sleep(term.opts.pollInterval)
+		scr := term.captureScreenRaw()
+		if scr == nil {
+			return false
+		}
+		r, c, ok := scr.CursorPosition()
+		if !ok {
+			return false
+		}
+		moved := r != fromRow || c != fromCol
+		if r == toRow && c == toCol || moved && r == lastRow && c == lastCol {
+			return true
+		}
+		lastRow, lastCol = r, c
+	}
+	return lastRow != fromRow || lastCol != fromCol
+}
+
+// TypeAt moves the cursor to row and col (0-indexed) with the Navigator set
+// with WithNavigator (ArrowKeys by default), then types s. It calls t.Fatal
+// if the cursor cannot be moved there. Use it for "put this value in that
+// cell" steps in forms and grids.
+func (term *Terminal) TypeAt(row, col int, s string) {
+	term.t.Helper()
+	nav := term.opts.navigator
+	if nav == nil {
+		nav = ArrowKeys
+	}
+	if err := nav(term, row, col); err != nil {
+		term.fatalf("strider: type-at: cannot move the cursor to row %d, col %d: %v\n%s",
+			row, col, err, formatScreenBox(term.captureScreenRaw()))
+	}
+	term.Type(s)
+}
@@ -0,0 +1,45 @@
+package strider
+
+import (
+	"os"
+	"os/exec"
+	"strings"
+)
+
+// unshareArgs returns the unshare(1) arguments that create a network
+// namespace. Without root, an unprivileged user namespace that maps the
+// current user to itself is needed to create it.
+func unshareArgs() []string {
+	if os.Geteuid() == 0 {
+		return []string{"--net", "--"}
+	}
+	return []string{"--user", "--map-current-user", "--net", "--"}
+}
+
+// requireNetworkNamespace returns the path to unshare, or an error that
+// skips the test (or fails it when strict) unless unshare can create a
+// network namespace.
+func requireNetworkNamespace(strict bool) (string, error) {
+	path, err := exec.LookPath("unshare")
+	if err != nil {
+		return "", environmentError(strict, "strider: open: WithNoNetwork: unshare not found")
+	}
+	out, err := exec.Command(path, append(unshareArgs(), "true")...).CombinedOutput()
+	if err != nil {
+		msg := strings.TrimSpace(string(out))
+		if msg == "" {
+			msg = err.Error()
+		}
+		return "", environmentError(strict, "strider: open: WithNoNetwork: network namespaces are not available: %s", msg)
+	}
+	return path, nil
+}
+
+// networkCommand returns the command that runs binary with args in a new
+// network namespace, using the unshare at unsharePath.
+func networkCommand(unsharePath, binary string, args []string) (string, []string) {
+	wrapped := unshareArgs()
+	wrapped = append(wrapped, binary)
+	wrapped = append(wrapped, args...)
+	return unsharePath, wrapped
+}
@@ -0,0 +1,64 @@
+package strider
+
+import (
+	"strings"
+
+	"github.com/cboone/strider/ansi"
+)
+
+// NormalizeScreen normalizes screen content the way snapshots do, for
+// stable comparisons in custom matchers and external tools:
+//
+//   - "\r\n" line endings become "\n"
+//   - trailing spaces are trimmed from each line
+//   - trailing blank lines are removed
+//   - the result ends with a single newline
+//
+// Options apply further normalization before these steps.
+func NormalizeScreen(s string, nopts ...NormalizeOption) string {
+	no := normalizeOptions{}
+	for _, o := range nopts {
+		o(&no)
+	}
+
+	s = strings.ReplaceAll(s, "\r\n", "\n")
+	if no.stripANSI {
+		s = ansi.Strip(s)
+	}
+	lines := strings.Split(s, "\n")
+
+	for i, l := range lines {
+		if no.collapseSpaces {
+			l = collapseSpaces(l)
+		}
+		// Trim trailing spaces on each line.
+		lines[i] = strings.TrimRight(l, " ")
+	}
+
+	// Remove trailing blank lines.
+	for len(lines) > 0 && lines[len(lines)-1] == "" {
+		lines = lines[:len(lines)-1]
+	}
+
+	// End with a single newline.
+	return strings.Join(lines, "\n") + "\n"
+}
+
+// collapseSpaces replaces every run of spaces and tabs in line with a single
+// space.
+func collapseSpaces(line string) string {
+	var b strings.Builder
+	inRun := false
+	for _, r := range line {
+		if r == ' ' || r == '\t' {
+			if !inRun {
+				b.WriteByte(' ')
+			}
+			inRun = true
+			continue
+		}
+		inRun = false
+		b.WriteRune(r)
+	}
+	return b.String()
+}
@@ -0,0 +1,92 @@
+package strider
+
+import (
+	"testing"
+	"time"
+)
+
+// Observe captures the screen every interval for duration and returns the
+// captures (frames), oldest first. The first frame is captured immediately.
+// It keeps capturing if the program exits, so the last frames show the
+// final screen.
+//
+// Some properties are about a sequence of frames rather than any single
+// one: check them with AssertEveryFrame and AssertBefore. Transitions
+// shorter than interval can fall between two frames, so keep interval short
+// (it cannot go below 10ms) when checking what must never be shown.
+func (term *Terminal) Observe(duration, interval time.Duration) []*Screen {
+	term.t.Helper()
+	if duration < 0 {
+		term.fatalf("strider: observe: negative duration: %v", duration)
+	}
+	if interval <= 0 {
+		term.fatalf("strider: observe: interval must be positive (got %v)", interval)
+	}
+	interval = max(interval, minPollInterval)
+
+	var frames []*Screen
+	deadline := time.Now().Add(duration)
+	for {
+		scr := term.captureScreenRaw()
+		if scr == nil {
+			term.fatalf("strider: observe: capture failed")
+		}
+		frames = append(frames, scr)
+		if !time.Now().Add(interval).Before(deadline) {
+			return frames
+		}
+		time.Sleep(interval)
+	}
+}
+
+// AssertEveryFrame checks that m matches every frame, for invariants that
+// must hold throughout a sequence of captures such as the result of Observe.
+// To check that no frame shows both A and B:
+//
+//	strider.AssertEveryFrame(t, frames, strider.Not(strider.TextAll("A", "B")))
+//
+// On failure it reports the first frame that does not match.
+func AssertEveryFrame(t testing.TB, frames []*Screen, m Matcher) {
+	t.Helper()
+	for i, scr := range frames {
+		if ok, desc, notes := evaluate(m, scr); !ok {
+			t.Fatalf("strider: assert-every-frame: frame %d of %d does not match\n    expected: %s%s\n%s",
+				i+1, len(frames), desc, formatNotes(notes), formatScreenBox(scr))
+		}
+	}
+}
+
+// AssertBefore checks that first matches a frame strictly earlier than the
+// first frame second matches, for example that a "Saving..." message is
+// shown before "Saved". Both must match some frame.
+//
+// On failure it reports the frames where each first matched and shows the
+// frame where second did.
+func AssertBefore(t testing.TB, frames []*Screen, first, second Matcher) {
+	t.Helper()
+	i, firstDesc := firstMatch(frames, first)
+	j, secondDesc := firstMatch(frames, second)
+	switch {
+	case i < 0:
+		t.Fatalf("strider: assert-before: no frame of %d matches %s", len(frames), firstDesc)
+	case j < 0:
+		t.Fatalf("strider: assert-before: no frame of %d matches %s", len(frames), secondDesc)
+	case i >= j:
+		t.Fatalf("strider: assert-before: expected %s before %s\n    first matches frame %d, second matches frame %d (of %d)\n    frame %d:\n%s",
+			firstDesc, secondDesc, i+1, j+1, len(frames), j+1, formatScreenBox(frames[j]))
+	}
+}
+
+// firstMatch returns the index of the first frame m matches, or -1, and the
+// description m gave for that frame (or the last one).
+func firstMatch(frames []*Screen, m Matcher) (int, string) {
+	desc := "matcher condition"
+	for i, scr := range frames {
+		ok, d, _ := evaluate(m, scr)
+		desc = d
+		if ok {
+			return i, desc
+		}
+	}
+	return -1, desc
+}
@@ -1,6 +1,16 @@
 package strider
 
-import "time"
+import (
+	"fmt"
+	"maps"
+	"os"
+	"os/user"
+	"path/filepath"
+	"regexp"
+	"slices"
+	"strings"
+	"time"
+)
 
 type options struct {
 	args         []string
@@ -12,6 +22,66 @@ type options struct {
 	pollInterval time.Duration
 	tmuxPath     string
 	historyLimit int
+
+	scrollbackTail int
+	slowWait       time.Duration
+	metrics        Metrics
+	reporter       Reporter
+	abortWhen      []func() (bool, string)
+
+	tempWorkdir      bool
+	tempWorkdirFiles map[string]string
+
+	sizeEnv   bool // set by WithSize
+	noSizeEnv bool // set by WithoutSizeEnv
+
+	noOutputEvents bool
+	noControlMode  bool
+	sharedServer   bool
+
+	backend    Backend
+	backendErr string // set by applyEnvBackend for an unknown STRIDER_BACKEND
+
+	pinnedTerminfo bool
+	terminfoDir    string // where Open installed the pinned entry
+
+	frozenClock *time.Time
+
+	seed       *int64
+	randomSeed bool
+
+	readyWhen Matcher
+
+	keymap Keymap
+
+	navigator Navigator
+
+	maxInputRate int
+
+	transcript string
+	recording  string
+	redact     []string
+
+	docCaptures string
+
+	styles bool
+
+	quiet bool
+
+	exitClassifier func(ExitState, *Screen) error
+
+	profileErr string // set by applyEnvProfile for an unknown STRIDER_PROFILE
+
+	cpuLimit    time.Duration
+	memoryLimit int64
+
+	noNetwork bool
+
+	sandbox *SandboxPolicy
+
+	strictEnvironment bool
+
+	user string
 }
 
 // Option configures a Terminal created by Open.
@@ -25,10 +95,108 @@ func WithArgs(args ...string) Option {
 }
 
 // WithSize sets the terminal dimensions (columns x rows).
+//
+// It also exports COLUMNS and LINES with the same dimensions to the program,
+// for programs that consult those variables instead of the terminal size.
+// Entries passed to WithEnv take precedence, and WithoutSizeEnv turns the
+// export off.
 func WithSize(width, height int) Option {
 	return func(o *options) {
 		o.width = width
 		o.height = height
+		o.sizeEnv = true
+	}
+}
+
+// WithSizePreset sets the terminal dimensions to a named Size, such as
+// SizeVT100 or Size132x43. It is equivalent to WithSize(s.Width, s.Height).
+func WithSizePreset(s Size) Option {
+	return WithSize(s.Width, s.Height)
+}
+
+// WithoutSizeEnv stops WithSize from exporting COLUMNS and LINES. Use it for
+// programs (such as some curses applications) that would otherwise prefer
+// the variables over the actual terminal size after a Resize.
+func WithoutSizeEnv() Option {
+	return func(o *options) {
+		o.noSizeEnv = true
+	}
+}
+
+// WithoutOutputEvents makes waits capture the screen on every poll, as
+// earlier versions of strider did. By default a wait watches the program's
+// output between polls, captures as soon as new output arrives, and skips
+// the captures of polls that find no new output, since the screen cannot
+// have changed. Use it if a program changes the screen in a way that does
+// not reach the output tmux copies.
+func WithoutOutputEvents() Option {
+	return func(o *options) {
+		o.noOutputEvents = true
+	}
+}
+
+// WithoutControlMode makes strider start a tmux process for each command it
+// sends, as earlier versions did. By default Open attaches one control-mode
+// client (tmux -C) to the session and sends commands through it, which
+// saves starting a process for every poll. Control mode is not used with
+// tmux older than 3.2.
+func WithoutControlMode() Option {
+	return func(o *options) {
+		o.noControlMode = true
+	}
+}
+
+// WithSharedServer opens the Terminal as a session of a tmux server shared
+// with the other Terminals of the test binary that use it, instead of
+// starting a server of its own. Starting and stopping servers is the largest
+// fixed cost of Open, so suites that open many Terminals run faster.
+// Sessions keep their own program, size, history limit, and output, but
+// share the server's global state, such as paste buffers. The shared server
+// stops soon after the test binary exits. Set STRIDER_SHARED_SERVER=1 to
+// use it for every Terminal.
+func WithSharedServer() Option {
+	return func(o *options) {
+		o.sharedServer = true
+	}
+}
+
+// WithBackend sets what runs the program: a tmux session (Tmux, the
+// default), or a pseudo-terminal with a terminal emulator in the test process
+// (PTY), for machines without tmux. Auto picks tmux when it is installed and
+// the PTY backend otherwise. Set STRIDER_BACKEND to tmux, pty, or auto to
+// choose for every Terminal that does not use WithBackend.
+//
+// The PTY backend renders screens as tmux does, so snapshots match on both,
+// and always runs the program with the pinned terminfo entry (see
+// WithPinnedTerminfo). It has no server, so WithSharedServer and WithTmuxPath
+// cannot be combined with it, and SendKeys does not accept send-keys flags.
+func WithBackend(b Backend) Option {
+	return func(o *options) {
+		o.backend = b
+	}
+}
+
+// WithStrictEnvironment makes Open fail the test, rather than skip it, when
+// the environment lacks something strider needs: tmux, a recent enough tmux,
+// what WithNoNetwork, WithSandbox, and WithUser rely on (such as Landlock and
+// seccomp for WithSandbox), or pseudo-terminals for WithBackend(PTY). Use
+// SetStrictEnvironment or STRIDER_STRICT=1 to make every terminal strict, as
+// CI usually should.
+func WithStrictEnvironment() Option {
+	return func(o *options) {
+		o.strictEnvironment = true
+	}
+}
+
+// WithPinnedTerminfo runs the program with a terminfo entry shipped with
+// strider rather than the host's: TERM=tmux-256color, with TERMINFO pointing
+// at a copy of the entry in a temporary directory. Programs that draw through
+// curses or read terminfo then write the same escape sequences on every host,
+// so snapshots recorded on one distribution match on another whose ncurses
+// has a different tmux-256color entry. WithEnv can still set TERM or TERMINFO.
+func WithPinnedTerminfo() Option {
+	return func(o *options) {
+		o.pinnedTerminfo = true
 	}
 }
 
@@ -40,14 +208,261 @@ func WithEnv(env ...string) Option {
 	}
 }
 
-// WithDir sets the working directory for the binary.
+// WithDir sets the working directory for the binary. It overrides
+// WithTempWorkdir given before it (see WithTempWorkdir).
 func WithDir(dir string) Option {
 	return func(o *options) {
 		o.dir = dir
+		o.tempWorkdir = false
+		o.tempWorkdirFiles = nil
+	}
+}
+
+// WithTempWorkdir runs the binary in a fresh temporary directory populated
+// with files, a map from slash-separated relative path to file content.
+// Parent directories are created as needed. The directory is removed when
+// the test finishes, and Terminal.Dir returns its path. Of WithDir and
+// WithTempWorkdir, the one given last applies, so that Reopen can switch a
+// Terminal from one to the other.
+func WithTempWorkdir(files map[string]string) Option {
+	return func(o *options) {
+		o.dir = ""
+		o.tempWorkdir = true
+		o.tempWorkdirFiles = files
+	}
+}
+
+// WithFrozenClock makes clock-displaying programs render deterministically
+// by pinning the time the program sees to t0. It exports:
+//
+//   - STRIDER_NOW: t0 in RFC 3339 format, for programs that read their
+//     notion of "now" from the environment
+//   - FAKETIME: t0 in libfaketime's frozen format, plus LD_PRELOAD (or
+//     DYLD_INSERT_LIBRARIES on macOS) when libfaketime is installed; set
+//     STRIDER_LIBFAKETIME to its path if it is not found automatically
+//   - TZ=UTC, so formatted local times do not depend on the host
+//
+// Entries passed to WithEnv take precedence.
+func WithFrozenClock(t0 time.Time) Option {
+	return func(o *options) {
+		o.frozenClock = &t0
+	}
+}
+
+// WithSeed exports STRIDER_SEED=n to the program, for programs that seed
+// their random number generator from the environment. The seed is logged
+// when the terminal opens, so it appears in the output of a failing test.
+// Entries passed to WithEnv take precedence.
+func WithSeed(n int64) Option {
+	return func(o *options) {
+		o.seed = &n
+		o.randomSeed = false
+	}
+}
+
+// WithRandomSeed is like WithSeed with a seed chosen by Open: the value of
+// STRIDER_SEED in the test process's environment if set, so a failure can be
+// reproduced with STRIDER_SEED=<seed> go test, or a random seed otherwise.
+// Terminal.Seed returns the seed in use.
+func WithRandomSeed() Option {
+	return func(o *options) {
+		o.seed = nil
+		o.randomSeed = true
+	}
+}
+
+// WithReadyWhen makes Open block until m matches, so tests start from the
+// program's initial ready state. If m does not match within the terminal's
+// default timeout, or the program exits first, Open fails with the usual
+// wait diagnostics under an "open: ready-when" prefix. Reset and Pool.Get
+// wait for m again after restarting the program.
+func WithReadyWhen(m Matcher) Option {
+	return func(o *options) {
+		o.readyWhen = m
+	}
+}
+
+// WithKeymap sets the Keymap used by Terminal.Do to translate action names
+// into keys.
+func WithKeymap(km Keymap) Option {
+	return func(o *options) {
+		o.keymap = km
+	}
+}
+
+// WithNavigator sets how TypeAt moves the cursor to a screen position.
+// The default is ArrowKeys.
+func WithNavigator(n Navigator) Option {
+	return func(o *options) {
+		o.navigator = n
+	}
+}
+
+// WithMaxInputRate paces input to at most keysPerSecond keys per second,
+// across Type, Press, SendKeys, and everything built on them, so scripted
+// bulk input does not overflow a slow program's input buffer. Type counts
+// each character as a key, and SendKeys each argument. A value of 0 (the
+// default) sends input as fast as possible.
+func WithMaxInputRate(keysPerSecond int) Option {
+	return func(o *options) {
+		o.maxInputRate = keysPerSecond
+	}
+}
+
+// WithTranscript records the whole session as a transcript, the input sent
+// to the program interleaved with the screen each wait matched, and compares
+// it to a golden file when the test ends: testdata/<test>/<name>.transcript.txt.
+// Golden transcripts are created and updated like snapshots (see
+// MatchSnapshot). The comparison is skipped if the test has already failed.
+//
+// Transcripts catch regressions in intermediate frames that a snapshot of
+// the final screen misses.
+func WithTranscript(name string) Option {
+	return func(o *options) {
+		o.transcript = name
+	}
+}
+
+// WithRecording records the whole session as an asciinema v2 cast file at
+// path, with the program's output and resizes at the times they happened.
+// The file is written when the test ends, whether or not it passes, and the
+// failure output names it. Play it with asciinema play, or embed it in docs
+// with the asciinema player. Output is redacted as captures are (see
+// TypeSecret and WithRedact).
+//
+// Setting STRIDER_RECORD to a directory records every terminal that does not
+// use WithRecording to <dir>/<sanitized-test-name>.cast, with a numbered
+// suffix for the second and later terminals of a test.
+func WithRecording(path string) Option {
+	return func(o *options) {
+		o.recording = path
+	}
+}
+
+// WithRedact masks the text matching any of patterns, regular expressions
+// in the syntax of package regexp, as ●●● in everything the Terminal reports:
+// screen captures (and so snapshots and doc captures), failure and log
+// output, transcripts, recordings, OutputSince, and the wait descriptions
+// sent to WithMetrics. Use it for tokens and fixture data that must not
+// appear in CI logs:
+//
+//	strider.WithRedact(`ghp_[A-Za-z0-9]{36}`, `alice@example\.com`)
+//
+// Captures are redacted before matchers see them, so a matcher cannot find
+// redacted text on the screen. Calls add to the patterns of earlier ones.
+func WithRedact(patterns ...string) Option {
+	return func(o *options) {
+		o.redact = append(o.redact, patterns...)
+	}
+}
+
+// WithDocCaptures saves a rendered copy of the screen to dir at every
+// MatchSnapshot and MatchSnapshotAt call on the Terminal, and at every
+// Capture call, even when the test passes: <dir>/<sanitized-name>.txt, the
+// normalized screen text, and <dir>/<sanitized-name>.svg, an image of the
+// terminal for user documentation. The directory is created if needed.
+//
+// Point dir at the directory the README or docs embed images from, and
+// rerun the tests to keep screenshots in sync with the real UI.
+func WithDocCaptures(dir string) Option {
+	return func(o *options) {
+		o.docCaptures = dir
+	}
+}
+
+// WithStyles captures the screen with its colors and text attributes
+// (capture-pane -e), so Screen.StyleAt and the TextStyled matcher can check
+// them. The text of each Screen is the same as without the option.
+func WithStyles() Option {
+	return func(o *options) {
+		o.styles = true
+	}
+}
+
+// WithExitClassifier sets a function called when the program exits while
+// the test expects it to be running, such as during WaitFor, with the exit
+// and the final screen. It gives exits the program's own meaning: returning
+// an error wrapping ErrSkip skips the test, any other error is added to the
+// failure message, and nil keeps the default failure.
+//
+//	strider.WithExitClassifier(func(e strider.ExitState, scr *strider.Screen) error {
+//		switch e.Code {
+//		case 77:
+//			return fmt.Errorf("%w: feature not compiled in", strider.ErrSkip)
+//		case 78:
+//			return errors.New("configuration error")
+//		}
+//		return nil
+//	})
+//
+// WaitExit expects the exit, so it does not call the classifier.
+func WithExitClassifier(classify func(ExitState, *Screen) error) Option {
+	return func(o *options) {
+		o.exitClassifier = classify
+	}
+}
+
+// WithQuiet holds strider's informational log lines, such as the seed and
+// Step names, until the test ends, and logs them only if the test failed.
+// Failure messages are unchanged. Setting STRIDER_QUIET=1 does the same for
+// every Terminal, and STRIDER_DEBUG=1 overrides both, logging every input
+// action and wait poll as well.
+func WithQuiet() Option {
+	return func(o *options) {
+		o.quiet = true
+	}
+}
+
+// WithServerLimits limits the CPU time (rounded up to whole seconds) and
+// virtual memory (in bytes) of the program, so a runaway program cannot take
+// down the machine running the tests. A zero value leaves that resource
+// unlimited. The limits are applied with ulimit in a /bin/sh wrapper and are
+// inherited by the program's children.
+//
+// When the program is killed for exceeding its CPU time, the failure output
+// says so; a program that exits after running out of memory is reported as
+// possibly having hit the memory limit. On macOS, the memory limit may not
+// be supported; the program then fails to start with status 125.
+func WithServerLimits(cpu time.Duration, memory int64) Option {
+	return func(o *options) {
+		o.cpuLimit = cpu
+		o.memoryLimit = memory
+	}
+}
+
+// WithNoNetwork runs the program in a new network namespace with no
+// network interfaces except a loopback device that is down, so any attempt
+// to reach the network fails. Use it to prove a program works offline.
+//
+// The namespace is created with unshare(1) from util-linux, as an
+// unprivileged user namespace when the tests do not run as root. The test
+// is skipped when network namespaces are not available (for example on
+// macOS, or where unprivileged user namespaces are disabled).
+func WithNoNetwork() Option {
+	return func(o *options) {
+		o.noNetwork = true
+	}
+}
+
+// WithUser runs the program as another, usually unprivileged, user, for
+// example to test how it handles files it is not allowed to read. When the
+// tests run as root, the program is started with su(1); otherwise with
+// sudo(8), which must allow running commands as username without a password.
+// The test is skipped when neither is possible.
+//
+// The program must be able to reach its binary and working directory as
+// that user. Directories from WithTempWorkdir live under the test's
+// temporary directory, which other users cannot enter, so use WithDir with
+// a directory they can access instead. Combining WithUser with
+// WithNoNetwork requires running the tests as root.
+func WithUser(username string) Option {
+	return func(o *options) {
+		o.user = username
 	}
 }
 
 // WithTimeout sets the default timeout for WaitFor and WaitForScreen.
+// It takes precedence over the -strider.timeout flag.
 func WithTimeout(d time.Duration) Option {
 	return func(o *options) {
 		o.timeout = d
@@ -55,6 +470,9 @@ func WithTimeout(d time.Duration) Option {
 }
 
 // WithPollInterval sets the default polling interval for WaitFor and WaitForScreen.
+// Waits also capture as soon as the program writes output, so the interval
+// mostly bounds how quickly a wait notices changes without output, such as
+// the program exiting (see WithoutOutputEvents).
 func WithPollInterval(d time.Duration) Option {
 	return func(o *options) {
 		o.pollInterval = d
@@ -70,6 +488,43 @@ func WithTmuxPath(path string) Option {
 	}
 }
 
+// WithSlowWaitWarning logs a warning, with the matcher description and the
+// time taken, whenever a wait succeeds after more than threshold. Slowness
+// hidden behind a generous timeout shows up in the test log before it turns
+// into a flaky timeout. It applies to WaitFor, WaitForScreen,
+// WaitForResult, and WaitExit.
+func WithSlowWaitWarning(threshold time.Duration) Option {
+	return func(o *options) {
+		o.slowWait = threshold
+	}
+}
+
+// WithMetrics sends a WaitMetric to m whenever a wait finishes, with its
+// duration and outcome, so scheduled smoke checks can export wait latencies
+// and failure counts. Failed waits are reported before they fail the test.
+func WithMetrics(m Metrics) Option {
+	return func(o *options) {
+		o.metrics = m
+	}
+}
+
+// WithReporter sends the Terminal's failures to r, with their details
+// (the failed wait, recent screens, exit state, and transcript) as a
+// Failure, instead of reporting them with t.Error and t.Fatal. A nil r
+// restores DefaultReporter. A Session, which has no test to report to,
+// returns the Failure as its error instead.
+//
+//	strider.WithReporter(strider.ReporterFunc(func(t testing.TB, f strider.Failure) {
+//		t.Helper()
+//		saveFailure(t.Name(), f) // the team's own tooling
+//		strider.DefaultReporter.Report(t, f)
+//	}))
+func WithReporter(r Reporter) Option {
+	return func(o *options) {
+		o.reporter = r
+	}
+}
+
 // WithHistoryLimit sets the tmux scrollback history limit for the test session.
 // A value of 0 uses the default set by Open (10000).
 func WithHistoryLimit(limit int) Option {
@@ -78,12 +533,50 @@ func WithHistoryLimit(limit int) Option {
 	}
 }
 
+// WithScrollbackTail appends the last n lines of the scrollback buffer to
+// wait failure output, in addition to the recent screen captures. This is
+// useful when the interesting error message scrolled off the visible screen
+// just before the process died. A value of 0 (the default) disables it.
+func WithScrollbackTail(n int) Option {
+	return func(o *options) {
+		o.scrollbackTail = n
+	}
+}
+
+// WithAbortWhen checks cond on every poll of every wait, and fails the wait
+// as soon as cond reports true, with the reason it returns. Use it for
+// fixtures the program depends on, such as a backend server or a container,
+// so that a crashed fixture fails with its own reason rather than as a
+// screen timeout:
+//
+//	strider.WithAbortWhen(func() (bool, string) {
+//		if err := backend.Err(); err != nil {
+//			return true, "backend crashed: " + err.Error()
+//		}
+//		return false, ""
+//	})
+//
+// cond is checked after each screen that does not satisfy the wait, so a
+// wait that succeeds is never aborted. It is called from the test's
+// goroutine and should return quickly. The option may be
+// given more than once; each condition is checked in order.
+func WithAbortWhen(cond func() (abort bool, reason string)) Option {
+	return func(o *options) {
+		o.abortWhen = append(o.abortWhen, cond)
+	}
+}
+
 // WaitOption configures a single WaitFor, WaitForScreen, or WaitExit call.
 type WaitOption func(*waitOptions)
 
 type waitOptions struct {
 	timeout      time.Duration
 	pollInterval time.Duration
+	noClears     bool
+
+	// soft reports a failed wait with t.Error rather than t.Fatal (see
+	// ExpectFor).
+	soft bool
 }
 
 // WithinTimeout overrides the call timeout for a single wait call.
@@ -103,6 +596,75 @@ func WithWaitPollInterval(d time.Duration) WaitOption {
 	}
 }
 
+// NoClears fails the wait as soon as the program clears the whole screen
+// while it waits (see ClearCount), instead of letting the clear go unnoticed
+// because the next redraw restores the screen. Use it around waits on
+// screens that should update in place, such as a ticking progress view.
+func NoClears() WaitOption {
+	return func(o *waitOptions) {
+		o.noClears = true
+	}
+}
+
+// SnapshotOption configures a single MatchSnapshot call.
+type SnapshotOption func(*snapshotOptions)
+
+type snapshotOptions struct {
+	escaped    bool
+	tmuxCompat bool
+}
+
+// WithEscapedFormat stores the snapshot in the escaped golden format: every
+// row as a Go-quoted string, without the normalization pass, so trailing
+// spaces, trailing blank rows, and control characters are pinned exactly.
+// The golden file is named <name>.escaped.txt.
+//
+// Terminal.MatchSnapshot captures trailing spaces for the escaped format.
+// Screen.MatchSnapshot uses the screen as captured, and tmux drops trailing
+// spaces from ordinary captures.
+func WithEscapedFormat() SnapshotOption {
+	return func(o *snapshotOptions) {
+		o.escaped = true
+	}
+}
+
+// WithTmuxCompat compares the snapshot with its golden file after smoothing
+// over known differences in how tmux versions capture the same output, such
+// as variation selectors after emoji, so goldens recorded with one tmux
+// version pass with another. Golden files are still written exactly as
+// captured.
+func WithTmuxCompat() SnapshotOption {
+	return func(o *snapshotOptions) {
+		o.tmuxCompat = true
+	}
+}
+
+// NormalizeOption configures NormalizeScreen.
+type NormalizeOption func(*normalizeOptions)
+
+type normalizeOptions struct {
+	stripANSI      bool
+	collapseSpaces bool
+}
+
+// WithStripANSI makes NormalizeScreen remove ANSI escape sequences (colors,
+// cursor movement, OSC titles and links) before normalizing, for content
+// read from a program's raw output or a styled capture.
+func WithStripANSI() NormalizeOption {
+	return func(o *normalizeOptions) {
+		o.stripANSI = true
+	}
+}
+
+// WithCollapseSpaces makes NormalizeScreen replace every run of spaces and
+// tabs with a single space, for comparisons that should not depend on
+// column alignment.
+func WithCollapseSpaces() NormalizeOption {
+	return func(o *normalizeOptions) {
+		o.collapseSpaces = true
+	}
+}
+
 const (
 	defaultWidth        = 80
 	defaultHeight       = 24
@@ -110,14 +672,123 @@ const (
 	defaultPollInterval = 50 * time.Millisecond
 	defaultHistoryLimit = 10000
 	minPollInterval     = 10 * time.Millisecond
+
+	// outputCheckInterval is how often a wait checks for new output between
+	// polls, and outputIdleRecapture how long it goes without capturing
+	// while there is none (see WithoutOutputEvents). Recapturing now and
+	// then guards against output that failed to reach the file.
+	outputCheckInterval = 5 * time.Millisecond
+	outputIdleRecapture = time.Second
+
+	// maxHistoryLimit bounds WithHistoryLimit. tmux allocates scrollback
+	// lazily, but a test that fills it would exhaust memory long before.
+	maxHistoryLimit = 10_000_000
 )
 
 func defaultOptions() options {
+	timeout := defaultTimeout
+	if flagConfig.timeout > 0 {
+		timeout = flagConfig.timeout
+	}
+
 	return options{
 		width:        defaultWidth,
 		height:       defaultHeight,
-		timeout:      defaultTimeout,
+		timeout:      timeout,
 		pollInterval: defaultPollInterval,
 		historyLimit: defaultHistoryLimit,
 	}
 }
+
+// validate checks the options for problems that would otherwise surface as
+// cryptic tmux errors or a dead pane, and returns an error listing all of
+// them, or nil.
+func (o options) validate() error {
+	var problems []string
+	if o.profileErr != "" {
+		problems = append(problems, o.profileErr)
+	}
+	if o.backendErr != "" {
+		problems = append(problems, o.backendErr)
+	}
+	if o.backend < 0 || o.backend > Auto {
+		problems = append(problems, fmt.Sprintf("WithBackend: unknown backend %v", o.backend))
+	}
+	if o.backend == PTY && o.sharedServer {
+		problems = append(problems, "WithSharedServer: the PTY backend has no tmux server to share")
+	}
+	if o.backend == PTY && o.tmuxPath != "" {
+		problems = append(problems, "WithTmuxPath: the PTY backend does not run tmux")
+	}
+	if o.width <= 0 || o.height <= 0 {
+		problems = append(problems, fmt.Sprintf("WithSize: width and height must be positive (got %dx%d)", o.width, o.height))
+	}
+	if o.historyLimit < 0 || o.historyLimit > maxHistoryLimit {
+		problems = append(problems, fmt.Sprintf("WithHistoryLimit: limit must be between 0 and %d (got %d)", maxHistoryLimit, o.historyLimit))
+	}
+	if o.timeout < 0 {
+		problems = append(problems, fmt.Sprintf("WithTimeout: timeout must not be negative (got %v)", o.timeout))
+	}
+	if o.pollInterval < 0 {
+		problems = append(problems, fmt.Sprintf("WithPollInterval: interval must not be negative (got %v)", o.pollInterval))
+	}
+	if o.slowWait < 0 {
+		problems = append(problems, fmt.Sprintf("WithSlowWaitWarning: threshold must not be negative (got %v)", o.slowWait))
+	}
+	if o.maxInputRate < 0 {
+		problems = append(problems, fmt.Sprintf("WithMaxInputRate: rate must not be negative (got %d)", o.maxInputRate))
+	}
+	if o.scrollbackTail < 0 {
+		problems = append(problems, fmt.Sprintf("WithScrollbackTail: line count must not be negative (got %d)", o.scrollbackTail))
+	}
+	for _, cond := range o.abortWhen {
+		if cond == nil {
+			problems = append(problems, "WithAbortWhen: condition must not be nil")
+			break
+		}
+	}
+	for _, p := range o.redact {
+		if _, err := regexp.Compile(p); err != nil {
+			problems = append(problems, fmt.Sprintf("WithRedact: invalid pattern %q: %v", p, err))
+		}
+	}
+	for _, e := range o.env {
+		if key, _, ok := strings.Cut(e, "="); !ok || key == "" {
+			problems = append(problems, fmt.Sprintf("WithEnv: entry %q is not in KEY=VALUE format", e))
+		}
+	}
+	if o.cpuLimit < 0 || o.memoryLimit < 0 {
+		problems = append(problems, fmt.Sprintf("WithServerLimits: limits must not be negative (got %v, %d)", o.cpuLimit, o.memoryLimit))
+	}
+	if o.user != "" && o.sandbox != nil {
+		problems = append(problems, "WithSandbox and WithUser: the other user cannot run the test binary that applies the sandbox; use one")
+	}
+	if o.user != "" {
+		if _, err := user.Lookup(o.user); err != nil {
+			problems = append(problems, fmt.Sprintf("WithUser: %v", err))
+		}
+	}
+	if o.dir != "" {
+		if info, err := os.Stat(o.dir); err != nil {
+			problems = append(problems, fmt.Sprintf("WithDir: %v", err))
+		} else if !info.IsDir() {
+			problems = append(problems, fmt.Sprintf("WithDir: %s is not a directory", o.dir))
+		}
+	}
+	for _, action := range slices.Sorted(maps.Keys(o.keymap)) {
+		if err := ValidateKey(o.keymap[action]); err != nil {
+			msg, _, _ := strings.Cut(err.Error(), "\n") // without the list of key names
+			problems = append(problems, fmt.Sprintf("WithKeymap: action %q: %s", action, msg))
+		}
+	}
+	for _, name := range slices.Sorted(maps.Keys(o.tempWorkdirFiles)) {
+		if !filepath.IsLocal(filepath.FromSlash(name)) {
+			problems = append(problems, fmt.Sprintf("WithTempWorkdir: file path %q must be relative and stay inside the directory", name))
+		}
+	}
+
+	if len(problems) == 0 {
+		return nil
+	}
+	return fmt.Errorf("invalid options:\n    - %s", strings.Join(problems, "\n    - "))
+}
@@ -0,0 +1,180 @@
+package strider
+
+import (
+	"io"
+	"os"
+	"strings"
+
+	"github.com/cboone/strider/ansi"
+)
+
+// outputSuffix is appended to the socket path to name the file tmux copies
+// the program's output to (see writeConfig).
+const outputSuffix = ".out"
+
+// outputLog follows the program's output, which tmux copies to a file with
+// pipe-pane, and counts the full-screen clears in it.
+type outputLog struct {
+	path string
+
+	// scanned is the file offset up to which the output has been read, and
+	// pending the incomplete escape sequence at the end of what was read.
+	scanned int64
+	pending string
+
+	// clears counts the clears seen so far, and clearsBase the count when
+	// the program was last (re)started.
+	clears     int
+	clearsBase int
+}
+
+// update reads the output written since the last call and counts the clears
+// in it.
+func (l *outputLog) update() error {
+	data, err := l.readFrom(l.scanned)
+	if err != nil {
+		return err
+	}
+	l.scanned += int64(len(data))
+
+	s := l.pending + string(data)
+	l.pending = ""
+	for seg := range ansi.Parse(s) {
+		if seg.Kind != ansi.Text && !complete(seg) {
+			// Only the last segment can be incomplete; finish it next time.
+			l.pending = seg.Raw
+			break
+		}
+		if isClear(seg) {
+			l.clears++
+		}
+	}
+	return nil
+}
+
+// size returns the number of bytes of output written so far.
+func (l *outputLog) size() (int64, error) {
+	info, err := os.Stat(l.path)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return 0, nil
+		}
+		return 0, err
+	}
+	return info.Size(), nil
+}
+
+// readFrom returns the output written after offset, or nothing if the
+// program has not written anything yet.
+func (l *outputLog) readFrom(offset int64) ([]byte, error) {
+	f, err := os.Open(l.path)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return nil, nil
+		}
+		return nil, err
+	}
+	defer f.Close()
+
+	if _, err := f.Seek(offset, io.SeekStart); err != nil {
+		return nil, err
+	}
+	return io.ReadAll(f)
+}
+
+// complete reports whether the escape sequence seg is terminated, rather
+// than cut off at the end of the output read so far.
+func complete(seg ansi.Segment) bool {
+	switch seg.Kind {
+	case ansi.CSI, ansi.Escape:
+		return seg.Final != 0
+	case ansi.OSC:
+		return strings.HasSuffix(seg.Raw, "\a") || strings.HasSuffix(seg.Raw, "\x1b\\")
+	case ansi.ControlString:
+		return strings.HasSuffix(seg.Raw, "\x1b\\")
+	}
+	return true
+}
+
+// isClear reports whether seg clears the whole screen: ED 2 (erase display),
+// ED 3 (erase display and scrollback), or RIS (full reset).
+func isClear(seg ansi.Segment) bool {
+	switch seg.Kind {
+	case ansi.CSI:
+		return seg.Final == 'J' && (seg.Params == "2" || seg.Params == "3")
+	case ansi.Escape:
+		return seg.Raw == "\x1bc"
+	}
+	return false
+}
+
+// ClearCount returns the number of times the program has cleared the whole
+// screen since Open or the last Reset: erase-display sequences (ESC [ 2 J,
+// ESC [ 3 J) and full resets (ESC c) in its output. Programs usually clear
+// once on startup; a count that grows with every redraw causes flicker that
+// screen captures do not show. See also NoClears.
+//
+// The output reaches strider shortly after the screen shows it, so a clear
+// written just before the call may not be counted yet.
+func (term *Terminal) ClearCount() int {
+	term.t.Helper()
+	return term.clearCount("clear-count")
+}
+
+// clearCount is ClearCount with failures reported for op.
+func (term *Terminal) clearCount(op string) int {
+	term.t.Helper()
+	n, err := term.clears(op)
+	term.check(err)
+	return n
+}
+
+// clears is clearCount, returning the failure.
+func (term *Terminal) clears(op string) (int, error) {
+	if err := term.output.update(); err != nil {
+		return 0, term.failf("strider: %s: reading program output: %v", op, err)
+	}
+	return term.output.clears - term.output.clearsBase, nil
+}
+
+// A Mark is a position in the program's output, taken with Terminal.Mark.
+type Mark struct {
+	offset int64
+}
+
+// Mark returns the current position in the program's output, for
+// OutputSince:
+//
+//	m := term.Mark()
+//	term.Press(strider.Enter)
+//	term.WaitFor(strider.Text("saved"))
+//	if out := term.OutputSince(m); strings.Contains(out, "warning") {
+//		t.Errorf("saving printed a warning: %q", out)
+//	}
+//
+// The output reaches strider shortly after the screen shows it, so wait for
+// the screen to show the last output before taking a mark: output written
+// just before the call may land after the mark.
+func (term *Terminal) Mark() Mark {
+	term.t.Helper()
+	n, err := term.output.size()
+	if err != nil {
+		term.fatalf("strider: mark: reading program output: %v", err)
+	}
+	return Mark{offset: n}
+}
+
+// OutputSince returns what the program wrote after m, with escape sequences
+// removed, line endings normalized to "\n", and values typed with TypeSecret
+// redacted. It answers whether an action produced output at all, which
+// comparing screens cannot tell when the output redraws the same content.
+// Like ClearCount, it may miss output written just before the call; wait for
+// the screen to show it first.
+func (term *Terminal) OutputSince(m Mark) string {
+	term.t.Helper()
+	data, err := term.output.readFrom(m.offset)
+	if err != nil {
+		term.fatalf("strider: output-since: reading program output: %v", err)
+	}
+	return term.redact(strings.ReplaceAll(ansi.Strip(string(data)), "\r\n", "\n"))
+}
@@ -0,0 +1,212 @@
+// Package page structures large strider suites with page objects: a Page
+// describes one screen of a program, with a matcher that tells when it is
+// displayed, named elements (regions of the screen), and named actions.
+// Tests bind a Page to a terminal and work in terms of its elements and
+// actions, and failures name the page and element involved.
+//
+//	var login = page.New("login", strider.Text("Username:")).
+//		Element("status", page.Line(22)).
+//		Action("submit", func(c strider.Console) { c.Press(strider.Enter) })
+//
+//	v := login.On(t, term)
+//	v.WaitReady()
+//	v.Do("submit")
+//	v.WaitFor("status", strider.Text("Signed in"))
+package page
+
+import (
+	"fmt"
+	"maps"
+	"slices"
+	"strings"
+	"testing"
+
+	"github.com/cboone/strider"
+)
+
+// Region is a rectangle of screen cells: Height rows from Row, and Width
+// display columns from Col (all 0-indexed). A Width of 0 extends to the
+// right edge of the screen.
+type Region struct {
+	Row, Col      int
+	Width, Height int
+}
+
+// Rect returns the Region of height rows and width columns whose top-left
+// cell is at row and col.
+func Rect(row, col, width, height int) Region {
+	return Region{Row: row, Col: col, Width: width, Height: height}
+}
+
+// Line returns the Region covering row n.
+func Line(n int) Region {
+	return Region{Row: n, Height: 1}
+}
+
+// String formats r for failure messages.
+func (r Region) String() string {
+	s := fmt.Sprintf("rows %d-%d", r.Row, r.Row+r.Height-1)
+	if r.Height == 1 {
+		s = fmt.Sprintf("row %d", r.Row)
+	}
+	switch {
+	case r.Width > 0:
+		s += fmt.Sprintf(", cols %d-%d", r.Col, r.Col+r.Width-1)
+	case r.Col > 0:
+		s += fmt.Sprintf(", from col %d", r.Col)
+	}
+	return s
+}
+
+// Crop returns the part of scr inside r, as a Screen of r's size, so any
+// matcher can be applied to it. Cells outside scr are blank (see
+// strider.Screen.Region).
+func (r Region) Crop(scr *strider.Screen) *strider.Screen {
+	width, _ := scr.Size()
+	w := r.Width
+	if w == 0 {
+		w = max(width-r.Col, 0)
+	}
+	return scr.Region(r.Col, r.Row, w, r.Height)
+}
+
+// An Action is a named sequence of interactions with a page, such as
+// filling a field or submitting a form.
+type Action func(c strider.Console)
+
+// Page describes one screen of a program. Build it with New and the
+// Element and Action methods, usually once in a package-level variable,
+// and bind it to a terminal with On.
+type Page struct {
+	name     string
+	ready    strider.Matcher
+	elements map[string]Region
+	actions  map[string]Action
+}
+
+// New returns a Page named name that is displayed when ready matches the
+// screen.
+func New(name string, ready strider.Matcher) *Page {
+	return &Page{
+		name:     name,
+		ready:    ready,
+		elements: map[string]Region{},
+		actions:  map[string]Action{},
+	}
+}
+
+// Element adds a named region of the screen to p and returns p.
+func (p *Page) Element(name string, r Region) *Page {
+	p.elements[name] = r
+	return p
+}
+
+// Action adds a named action to p and returns p.
+func (p *Page) Action(name string, a Action) *Page {
+	p.actions[name] = a
+	return p
+}
+
+// Name returns the name of p.
+func (p *Page) Name() string {
+	return p.name
+}
+
+// Ready returns a matcher for p being displayed, whose description names
+// the page.
+func (p *Page) Ready() strider.Matcher {
+	return func(scr *strider.Screen) (bool, string) {
+		ok, desc := p.ready(scr)
+		return ok, fmt.Sprintf("page %q to be displayed: %s", p.name, desc)
+	}
+}
+
+// Has returns a matcher that applies m to the named element of p, whose
+// description names the page and element. It panics if p has no such
+// element, since that is a mistake in the page definition.
+func (p *Page) Has(element string, m strider.Matcher) strider.Matcher {
+	r, ok := p.elements[element]
+	if !ok {
+		panic(fmt.Sprintf("page: %s: %s", p.name, unknown("element", element, p.elements)))
+	}
+	return func(scr *strider.Screen) (bool, string) {
+		ok, desc := m(r.Crop(scr))
+		return ok, fmt.Sprintf("page %q, element %q (%v): %s", p.name, element, r, desc)
+	}
+}
+
+// unknown describes a missing element or action, listing the known ones.
+func unknown[V any](kind, name string, known map[string]V) string {
+	return fmt.Sprintf("no %s %q (known: %s)", kind, name, strings.Join(slices.Sorted(maps.Keys(known)), ", "))
+}
+
+// View is a Page bound to a test and a terminal.
+type View struct {
+	t    testing.TB
+	c    strider.Console
+	page *Page
+}
+
+// On binds p to the terminal c, reporting failures to t.
+func (p *Page) On(t testing.TB, c strider.Console) *View {
+	return &View{t: t, c: c, page: p}
+}
+
+// WaitReady waits until the page is displayed.
+func (v *View) WaitReady(wopts ...strider.WaitOption) {
+	v.t.Helper()
+	v.c.WaitFor(v.page.Ready(), wopts...)
+}
+
+// WaitFor waits until m matches the named element.
+func (v *View) WaitFor(element string, m strider.Matcher, wopts ...strider.WaitOption) {
+	v.t.Helper()
+	v.c.WaitFor(v.has(element, m), wopts...)
+}
+
+// Text returns the text of the named element on the current screen: its
+// rows, with trailing spaces removed, joined by newlines.
+func (v *View) Text(element string) string {
+	v.t.Helper()
+	r, ok := v.page.elements[element]
+	if !ok {
+		v.t.Fatalf("page: %s: %s", v.page.name, unknown("element", element, v.page.elements))
+	}
+	return strings.Join(r.Crop(v.c.Screen()).Lines(), "\n")
+}
+
+// Do runs the named actions in order. If the test fails during an action,
+// the page and action are logged.
+func (v *View) Do(actions ...string) {
+	v.t.Helper()
+	for _, name := range actions {
+		a, ok := v.page.actions[name]
+		if !ok {
+			v.t.Fatalf("page: %s: %s", v.page.name, unknown("action", name, v.page.actions))
+		}
+		v.run(name, a)
+	}
+}
+
+// run runs the action a, logging its name if the test fails during it.
+func (v *View) run(name string, a Action) {
+	v.t.Helper()
+	failedBefore := v.t.Failed()
+	finished := false
+	defer func() {
+		if !finished || (!failedBefore && v.t.Failed()) {
+			v.t.Logf("page: %s: action %q failed", v.page.name, name)
+		}
+	}()
+	a(v.c)
+	finished = true
+}
+
+// has is Page.Has with unknown elements reported to the test.
+func (v *View) has(element string, m strider.Matcher) strider.Matcher {
+	v.t.Helper()
+	if _, ok := v.page.elements[element]; !ok {
+		v.t.Fatalf("page: %s: %s", v.page.name, unknown("element", element, v.page.elements))
+	}
+	return v.page.Has(element, m)
+}
@@ -0,0 +1,157 @@
+package page_test
+
+import (
+	"fmt"
+	"runtime"
+	"strings"
+	"testing"
+
+	"github.com/cboone/strider"
+	"github.com/cboone/strider/page"
+	"github.com/cboone/strider/striderfake"
+)
+
+var login = page.New("login", strider.Text("Username:")).
+	Element("username", page.Rect(0, 10, 12, 1)).
+	Element("status", page.Line(2)).
+	Action("submit", func(c strider.Console) { c.Press(strider.Enter) })
+
+// newLoginFake returns a fake program showing the login page, which signs
+// in on Enter.
+func newLoginFake(t testing.TB) *striderfake.Terminal {
+	fake := striderfake.New(t, striderfake.WithSize(40, 3),
+		striderfake.WithProgram(func(term *striderfake.Terminal, in striderfake.Input) {
+			if len(in.Keys) > 0 && in.Keys[0] == strider.Enter {
+				term.SetScreen("Username: alice       (ignored)", "", "Status: signed in")
+			}
+		}))
+	fake.SetScreen("Username: alice       (ignored)", "", "Status: idle")
+	return fake
+}
+
+func TestView(t *testing.T) {
+	v := login.On(t, newLoginFake(t))
+	v.WaitReady()
+
+	if got := v.Text("username"); got != "alice" {
+		t.Errorf(`Text("username") = %q, want "alice"`, got)
+	}
+	v.Do("submit")
+	v.WaitFor("status", strider.Line(0, "Status: signed in"))
+}
+
+func TestRegionCrop(t *testing.T) {
+	scr := strider.NewScreen(20, 3, "abcdefghij", "0123456789", "short")
+	got := page.Rect(1, 2, 4, 3).Crop(scr)
+	if w, h := got.Size(); w != 4 || h != 3 {
+		t.Errorf("Size() = %dx%d, want 4x3", w, h)
+	}
+	if lines := got.Lines(); strings.Join(lines, "|") != "2345|ort|" {
+		t.Errorf("Lines() = %q", lines)
+	}
+	if got := page.Line(0).Crop(scr).Lines(); len(got) != 1 || got[0] != "abcdefghij" {
+		t.Errorf("Line(0).Crop = %q", got)
+	}
+}
+
+func TestRegionString(t *testing.T) {
+	for _, tt := range []struct {
+		r    page.Region
+		want string
+	}{
+		{page.Line(3), "row 3"},
+		{page.Rect(1, 2, 4, 3), "rows 1-3, cols 2-5"},
+		{page.Region{Row: 5, Col: 10, Height: 2}, "rows 5-6, from col 10"},
+	} {
+		if got := tt.r.String(); got != tt.want {
+			t.Errorf("%+v.String() = %q, want %q", tt.r, got, tt.want)
+		}
+	}
+}
+
+func TestHasDescription(t *testing.T) {
+	scr := strider.NewScreen(40, 3, "Username: alice", "", "Status: idle")
+	ok, desc := login.Has("status", strider.Text("signed in"))(scr)
+	if ok {
+		t.Fatal("expected no match")
+	}
+	if want := `page "login", element "status" (row 2): screen to contain "signed in"`; desc != want {
+		t.Errorf("description = %q, want %q", desc, want)
+	}
+	if _, desc := login.Ready()(scr); desc != `page "login" to be displayed: screen to contain "Username:"` {
+		t.Errorf("Ready description = %q", desc)
+	}
+}
+
+// recordingTB records failures and logs instead of reporting them.
+type recordingTB struct {
+	testing.TB
+	failed bool
+	out    []string
+}
+
+func (r *recordingTB) Helper()      {}
+func (r *recordingTB) Failed() bool { return r.failed }
+
+func (r *recordingTB) Logf(format string, args ...any) {
+	r.out = append(r.out, fmt.Sprintf(format, args...))
+}
+
+func (r *recordingTB) Fatalf(format string, args ...any) {
+	r.failed = true
+	r.out = append(r.out, fmt.Sprintf(format, args...))
+	runtime.Goexit()
+}
+
+// output runs fn with a recordingTB and returns everything it reported.
+func output(t *testing.T, fn func(tb testing.TB)) string {
+	rec := &recordingTB{TB: t}
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		fn(rec)
+	}()
+	<-done
+	return strings.Join(rec.out, "\n")
+}
+
+func TestFailureContext(t *testing.T) {
+	got := output(t, func(tb testing.TB) {
+		login.On(tb, newLoginFake(tb)).Text("password")
+	})
+	if want := `page: login: no element "password" (known: status, username)`; got != want {
+		t.Errorf("unknown element: got %q, want %q", got, want)
+	}
+
+	got = output(t, func(tb testing.TB) {
+		login.On(tb, newLoginFake(tb)).Do("cancel")
+	})
+	if want := `page: login: no action "cancel" (known: submit)`; got != want {
+		t.Errorf("unknown action: got %q, want %q", got, want)
+	}
+
+	failing := page.New("menu", strider.Text("Menu")).
+		Action("open", func(c strider.Console) { c.WaitFor(strider.Text("never")) })
+	got = output(t, func(tb testing.TB) {
+		fake := striderfake.New(tb, striderfake.WithTimeout(0))
+		failing.On(tb, fake).Do("open")
+	})
+	if !strings.Contains(got, "striderfake: wait-for: timed out") ||
+		!strings.HasSuffix(got, `page: menu: action "open" failed`) {
+		t.Errorf("expected the failing action to be named, got:\n%s", got)
+	}
+}
+
+func Example() {
+	inbox := page.New("inbox", strider.Regexp(`^Inbox \(\d+\)`)).
+		Element("selected", page.Line(2)).
+		Action("open", func(c strider.Console) { c.Press(strider.Enter) })
+
+	_ = func(t *testing.T) {
+		term := strider.Open(t, "./mail")
+		v := inbox.On(t, term)
+		v.WaitReady()
+		v.WaitFor("selected", strider.Text("Welcome"))
+		v.Do("open")
+	}
+}
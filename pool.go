@@ -0,0 +1,101 @@
+package strider
+
+import (
+	"sync"
+	"testing"
+)
+
+// Pool hands out Terminals running the same binary and reuses their tmux
+// sessions between borrowers instead of starting a new server for every
+// test. It is created with NewPool.
+type Pool struct {
+	owner    testing.TB
+	binary   string
+	userOpts []Option
+	size     int
+
+	mu      sync.Mutex
+	cond    *sync.Cond
+	idle    []*Terminal
+	created int
+}
+
+// NewPool creates a pool of at most size Terminals running binary with the
+// given options. Terminals are started lazily by Get, and their tmux servers
+// are killed when t (typically the parent of the borrowing subtests) is
+// cleaned up. A size of 0 or less means one Terminal.
+func NewPool(t testing.TB, binary string, size int, opts ...Option) *Pool {
+	t.Helper()
+	if size <= 0 {
+		size = 1
+	}
+	p := &Pool{
+		owner:    t,
+		binary:   binary,
+		userOpts: opts,
+		size:     size,
+	}
+	p.cond = sync.NewCond(&p.mu)
+	return p
+}
+
+// Get borrows a Terminal for t, blocking until one is free. Failures are
+// reported to t. When t is cleaned up, the Terminal is Reset (its program
+// restarted, history cleared, size restored) and returned to the pool.
+func (p *Pool) Get(t testing.TB) *Terminal {
+	t.Helper()
+
+	p.mu.Lock()
+	for len(p.idle) == 0 && p.created >= p.size {
+		p.cond.Wait()
+	}
+
+	var base *Terminal
+	reused := false
+	if n := len(p.idle); n > 0 {
+		base = p.idle[n-1]
+		p.idle = p.idle[:n-1]
+		reused = true
+		p.mu.Unlock()
+	} else {
+		p.created++
+		p.mu.Unlock()
+
+		// Give the slot back if open fails before a Terminal exists.
+		opened := false
+		defer func() {
+			if !opened {
+				p.mu.Lock()
+				p.created--
+				p.cond.Signal()
+				p.mu.Unlock()
+			}
+		}()
+		base = open(t, p.owner, p.binary, p.userOpts)
+		opened = true
+	}
+
+	term := *base
+	term.t, term.host = t, t
+	t.Cleanup(func() { p.put(&term) })
+	if reused {
+		term.check(term.waitReady("pool: get"))
+	}
+	return &term
+}
+
+// put resets a borrowed Terminal and makes it available again. A Terminal
+// that cannot be reset is dropped, and a fresh one is started on demand.
+func (p *Pool) put(term *Terminal) {
+	err := term.reset()
+
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	if err != nil {
+		_ = term.backend.stop()
+		p.created--
+	} else {
+		p.idle = append(p.idle, term)
+	}
+	p.cond.Signal()
+}
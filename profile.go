@@ -0,0 +1,102 @@
+package strider
+
+import (
+	"fmt"
+	"maps"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+	"sync"
+	"time"
+)
+
+// A Profile is a named bundle of options, for the settings a team would
+// otherwise repeat in every Open call: timeouts, polling, and what is kept
+// for debugging and documentation. Apply one with WithProfile, or select a
+// registered profile for every terminal with the STRIDER_PROFILE environment
+// variable.
+type Profile struct {
+	Name    string
+	Options []Option
+}
+
+// Built-in profiles, registered under their names.
+var (
+	// FastLocal suits a developer machine: a 2s wait timeout and a 20ms
+	// poll interval, so failures surface quickly.
+	FastLocal = Profile{Name: "fast-local", Options: []Option{
+		WithTimeout(2 * time.Second),
+		WithPollInterval(20 * time.Millisecond),
+	}}
+
+	// SlowCI suits shared CI runners: a 30s wait timeout, a 100ms poll
+	// interval, warnings for waits slower than 10s, and the last 50
+	// scrollback lines in wait failures.
+	SlowCI = Profile{Name: "slow-ci", Options: []Option{
+		WithTimeout(30 * time.Second),
+		WithPollInterval(100 * time.Millisecond),
+		WithSlowWaitWarning(10 * time.Second),
+		WithScrollbackTail(50),
+	}}
+
+	// Recording saves every snapshot and Capture call under
+	// testdata/captures (see WithDocCaptures), for regenerating the
+	// screenshots in user docs.
+	Recording = Profile{Name: "recording", Options: []Option{
+		WithDocCaptures(filepath.Join("testdata", "captures")),
+	}}
+)
+
+var (
+	profilesMu sync.Mutex
+	profiles   = map[string]Profile{
+		FastLocal.Name: FastLocal,
+		SlowCI.Name:    SlowCI,
+		Recording.Name: Recording,
+	}
+)
+
+// RegisterProfile makes p selectable by name with STRIDER_PROFILE. Call it
+// from TestMain or an init function. It panics if p has no name or a
+// profile with the same name is already registered.
+func RegisterProfile(p Profile) {
+	profilesMu.Lock()
+	defer profilesMu.Unlock()
+	if p.Name == "" {
+		panic("strider: RegisterProfile: profile has no name")
+	}
+	if _, ok := profiles[p.Name]; ok {
+		panic(fmt.Sprintf("strider: RegisterProfile: profile %q is already registered", p.Name))
+	}
+	profiles[p.Name] = p
+}
+
+// WithProfile applies the options of p, in order. Options after it in the
+// same Open call override the profile's settings.
+func WithProfile(p Profile) Option {
+	return func(o *options) {
+		for _, opt := range p.Options {
+			opt(o)
+		}
+	}
+}
+
+// applyEnvProfile applies the profile named by STRIDER_PROFILE, if set,
+// before the options passed to Open. An unknown name is reported by
+// validate.
+func applyEnvProfile(o *options) {
+	name := os.Getenv("STRIDER_PROFILE")
+	if name == "" {
+		return
+	}
+	profilesMu.Lock()
+	p, ok := profiles[name]
+	known := slices.Sorted(maps.Keys(profiles))
+	profilesMu.Unlock()
+	if !ok {
+		o.profileErr = fmt.Sprintf("STRIDER_PROFILE: unknown profile %q (known: %s)", name, strings.Join(known, ", "))
+		return
+	}
+	WithProfile(p)(o)
+}
@@ -0,0 +1,436 @@
+package strider
+
+import (
+	"fmt"
+	"os"
+	"os/exec"
+	"strconv"
+	"strings"
+	"sync"
+	"syscall"
+	"time"
+	"unicode/utf8"
+
+	"github.com/cboone/strider/internal/pty"
+	"github.com/cboone/strider/internal/vt"
+)
+
+// ptyDrainTimeout bounds how long a program's exit waits for the rest of
+// its output. The terminal stays open while a child the program left
+// running in the background holds it.
+const ptyDrainTimeout = 100 * time.Millisecond
+
+// ptyStopTimeout bounds how long stopping a program waits for it to exit
+// after the hangup before killing it.
+const ptyStopTimeout = time.Second
+
+// ptyBackend runs the program on a pseudo-terminal and feeds its output to
+// a terminal emulator (see WithBackend). The emulator's state is guarded by
+// mu, since a goroutine updates it as output arrives.
+type ptyBackend struct {
+	env    []string
+	output *os.File // the copy of the output, see outputLog
+
+	mu     sync.Mutex
+	term   *vt.Terminal
+	ptmx   *os.File
+	cmd    *exec.Cmd
+	exited chan struct{} // closed once the program's state is dead
+	dead   bool
+	status int
+}
+
+// startPTY starts command in dir, with the environment env, on a
+// pseudo-terminal of the given size, copying its output to outputPath.
+func startPTY(dir string, command, env []string, width, height, historyLimit int, outputPath string) (*ptyBackend, error) {
+	output, err := os.OpenFile(outputPath, os.O_WRONLY|os.O_CREATE|os.O_APPEND, 0o644)
+	if err != nil {
+		return nil, err
+	}
+	b := &ptyBackend{
+		env:    env,
+		output: output,
+		term:   vt.New(width, height, historyLimit),
+	}
+	if err := b.start(dir, command); err != nil {
+		output.Close()
+		return nil, err
+	}
+	return b, nil
+}
+
+// start starts command and the goroutines that follow it. The caller holds
+// mu, or has not shared b yet.
+func (b *ptyBackend) start(dir string, command []string) error {
+	cmd := exec.Command(command[0], command[1:]...)
+	cmd.Dir = dir
+	cmd.Env = b.env
+	width, height := b.term.Size()
+	ptmx, err := pty.Start(cmd, width, height)
+	if err != nil {
+		return err
+	}
+	exited := make(chan struct{})
+	b.ptmx, b.cmd, b.exited = ptmx, cmd, exited
+	b.dead, b.status = false, 0
+
+	read := make(chan struct{})
+	go b.read(ptmx, read)
+	go func() {
+		_ = cmd.Wait()
+		select {
+		case <-read:
+		case <-time.After(ptyDrainTimeout):
+		}
+		b.mu.Lock()
+		if b.cmd == cmd {
+			b.dead, b.status = true, exitStatus(cmd.ProcessState)
+		}
+		b.mu.Unlock()
+		close(exited)
+	}()
+	return nil
+}
+
+// read feeds the output on ptmx to the emulator, then appends it to the
+// copy of the output, so that a wait that sees the copy grow captures the
+// new screen. It writes the emulator's replies, such as cursor position
+// reports, back to the program. It closes done at the end of the output.
+func (b *ptyBackend) read(ptmx *os.File, done chan<- struct{}) {
+	defer close(done)
+	buf := make([]byte, 32*1024)
+	for {
+		n, err := ptmx.Read(buf)
+		if n > 0 {
+			b.mu.Lock()
+			current := b.ptmx == ptmx
+			var replies []byte
+			if current {
+				b.term.Write(buf[:n])
+				replies = b.term.TakeReplies()
+			}
+			b.mu.Unlock()
+			if !current {
+				return
+			}
+			_, _ = b.output.Write(buf[:n])
+			if len(replies) > 0 {
+				_, _ = ptmx.Write(replies)
+			}
+		}
+		if err != nil {
+			return // io.EOF, or EIO once the terminal is closed
+		}
+	}
+}
+
+// ptyEnviron returns the test process's environment for the program,
+// without the variables that would point it at a tmux the tests run in.
+func ptyEnviron() []string {
+	var env []string
+	for _, e := range os.Environ() {
+		if !strings.HasPrefix(e, "TMUX=") && !strings.HasPrefix(e, "TMUX_PANE=") && !strings.HasPrefix(e, "TERM=") {
+			env = append(env, e)
+		}
+	}
+	return env
+}
+
+// exitStatus returns the status of an exited process, with a process
+// killed by a signal reported as 128 plus the signal number, like tmux's
+// pane state.
+func exitStatus(ps *os.ProcessState) int {
+	if ps == nil {
+		return -1
+	}
+	if ws, ok := ps.Sys().(syscall.WaitStatus); ok && ws.Signaled() {
+		return 128 + int(ws.Signal())
+	}
+	return ps.ExitCode()
+}
+
+// kill hangs up the program's process group, as closing a terminal does,
+// and closes the terminal. A program still running after ptyStopTimeout is
+// killed.
+func (b *ptyBackend) kill() {
+	b.mu.Lock()
+	cmd, ptmx, exited := b.cmd, b.ptmx, b.exited
+	b.mu.Unlock()
+	if cmd == nil {
+		return
+	}
+	pid := cmd.Process.Pid
+	_ = syscall.Kill(-pid, syscall.SIGHUP)
+	ptmx.Close()
+	select {
+	case <-exited:
+	case <-time.After(ptyStopTimeout):
+		_ = syscall.Kill(-pid, syscall.SIGKILL)
+		<-exited
+	}
+}
+
+func (b *ptyBackend) capture(styled bool) (string, paneState, paneGeometry, error) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	raw := b.term.Plain()
+	if styled {
+		raw = b.term.Styled()
+	}
+	return raw, paneState{dead: b.dead, exitStatus: b.status}, b.geometryLocked(), nil
+}
+
+func (b *ptyBackend) captureExact() (string, error) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	return b.term.Exact(), nil
+}
+
+func (b *ptyBackend) captureSaved() (string, error) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	return b.term.Primary()
+}
+
+func (b *ptyBackend) captureScrollback(joined bool) (string, error) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	return b.term.Scrollback(joined), nil
+}
+
+// captureScrolled takes the rows tmux's copy mode shows after scrolling up
+// by lines, stopping at the top of the history.
+func (b *ptyBackend) captureScrolled(lines, height int) (string, error) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	rows := strings.SplitAfter(b.term.Scrollback(false), "\n")
+	history := b.term.HistorySize()
+	start := history - min(lines, history)
+	return strings.Join(rows[start:min(start+height, len(rows))], ""), nil
+}
+
+func (b *ptyBackend) alternateScreen() (bool, error) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	return b.term.AltScreen(), nil
+}
+
+func (b *ptyBackend) geometry() (paneGeometry, error) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	return b.geometryLocked(), nil
+}
+
+func (b *ptyBackend) geometryLocked() paneGeometry {
+	x, y := b.term.Cursor()
+	width, height := b.term.Size()
+	return paneGeometry{cursorRow: y, cursorCol: x, width: width, height: height}
+}
+
+func (b *ptyBackend) state() (paneState, error) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	return paneState{dead: b.dead, exitStatus: b.status}, nil
+}
+
+func (b *ptyBackend) pid() (int, error) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	return b.cmd.Process.Pid, nil
+}
+
+func (b *ptyBackend) mouseMode() (mouseMode, error) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	m := b.term.Modes()
+	return mouseMode{enabled: m.Mouse, sgr: m.MouseSGR, utf8: m.MouseUTF8}, nil
+}
+
+// sendKeys sends keys as send-keys does: key names as the bytes a terminal
+// sends for them, and anything else as text.
+func (b *ptyBackend) sendKeys(keys []string) error {
+	b.mu.Lock()
+	modes := b.term.Modes()
+	b.mu.Unlock()
+	var input []byte
+	for _, k := range keys {
+		if isSendKeysFlag(k) {
+			return fmt.Errorf("send-keys flag %s needs the tmux backend", k)
+		}
+		input = append(input, encodeKey(k, modes)...)
+	}
+	return b.sendBytes(input)
+}
+
+func (b *ptyBackend) sendLiteral(s string) error {
+	return b.sendBytes([]byte(s))
+}
+
+func (b *ptyBackend) sendBytes(p []byte) error {
+	b.mu.Lock()
+	ptmx := b.ptmx
+	b.mu.Unlock()
+	_, err := ptmx.Write(p)
+	return err
+}
+
+// resize resizes the emulator's screen and the terminal, which sends the
+// program SIGWINCH.
+func (b *ptyBackend) resize(width, height int) error {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	b.term.Resize(width, height)
+	return pty.Setsize(b.ptmx, width, height)
+}
+
+// respawn stops the program and starts command on a new terminal, with
+// the emulator reset, like respawn-pane -k.
+func (b *ptyBackend) respawn(dir string, command []string) error {
+	b.kill()
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	b.term.Reset()
+	return b.start(dir, command)
+}
+
+func (b *ptyBackend) clearHistory() error {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	b.term.ClearHistory()
+	return nil
+}
+
+func (b *ptyBackend) stop() error {
+	b.kill()
+	return b.output.Close()
+}
+
+// encodeKey returns the bytes a terminal sends for the tmux key name k, in
+// the cursor key and keypad modes of modes. Like send-keys, it sends a
+// string that is not a key name as text.
+func encodeKey(k string, modes vt.Modes) []byte {
+	base := stripKeyModifiers(k)
+	var ctrl, meta, shift bool
+	for _, m := range k[:len(k)-len(base)] {
+		switch m {
+		case 'C', 'c', '^':
+			ctrl = true
+		case 'M', 'm':
+			meta = true
+		case 'S', 's':
+			shift = true
+		}
+	}
+	// The xterm modifier parameter of keys sent as sequences.
+	mod := 1
+	if shift {
+		mod++
+	}
+	if meta {
+		mod += 2
+	}
+	if ctrl {
+		mod += 4
+	}
+
+	if r, size := utf8.DecodeRuneInString(base); size == len(base) && r != utf8.RuneError {
+		if shift && r >= 'a' && r <= 'z' {
+			r -= 'a' - 'A'
+		}
+		seq := []byte(string(r))
+		if ctrl {
+			switch {
+			case r >= 'a' && r <= 'z', r >= '@' && r <= '_':
+				seq = []byte{byte(r) & 0x1f}
+			case r == ' ' || r == '2':
+				seq = []byte{0}
+			case r == '?':
+				seq = []byte{0x7f}
+			}
+		}
+		return withMeta(seq, meta)
+	}
+
+	name := strings.ToLower(base)
+	if seq, ok := plainKeys[name]; ok {
+		return withMeta([]byte(seq), meta)
+	}
+	if strings.HasPrefix(name, "kp") {
+		if seq, ok := keypadKeys[name]; ok {
+			if modes.Keypad {
+				return []byte("\x1bO" + seq[1:])
+			}
+			return []byte(seq[:1])
+		}
+	}
+	if final, ok := cursorKeys[name]; ok {
+		switch {
+		case mod > 1:
+			return []byte("\x1b[1;" + strconv.Itoa(mod) + final)
+		case modes.CursorKeys:
+			return []byte("\x1bO" + final)
+		}
+		return []byte("\x1b[" + final)
+	}
+	if final, ok := functionKeys[name]; ok {
+		switch {
+		case mod > 1 && strings.HasPrefix(final, "O"):
+			return []byte("\x1b[1;" + strconv.Itoa(mod) + final[1:])
+		case mod > 1:
+			return []byte("\x1b[" + strings.TrimSuffix(final, "~") + ";" + strconv.Itoa(mod) + "~")
+		case strings.HasPrefix(final, "O"):
+			return []byte("\x1b" + final)
+		}
+		return []byte("\x1b[" + final)
+	}
+	return []byte(k)
+}
+
+// withMeta prefixes seq with ESC if meta, as terminals send Meta (Alt).
+func withMeta(seq []byte, meta bool) []byte {
+	if meta {
+		return append([]byte{0x1b}, seq...)
+	}
+	return seq
+}
+
+// plainKeys are the keys, by lowercase name, sent as fixed bytes.
+var plainKeys = map[string]string{
+	"enter":  "\r",
+	"escape": "\x1b",
+	"tab":    "\t",
+	"btab":   "\x1b[Z",
+	"bspace": "\x7f",
+	"space":  " ",
+}
+
+// cursorKeys are the final characters of the cursor keys, sent as
+// ESC [ x, or ESC O x in the cursor key mode.
+var cursorKeys = map[string]string{
+	"up":    "A",
+	"down":  "B",
+	"right": "C",
+	"left":  "D",
+}
+
+// functionKeys are the sequences, after ESC (and [ unless they start with
+// O), of the editing and function keys.
+var functionKeys = map[string]string{
+	"home": "1~", "end": "4~",
+	"ic": "2~", "insert": "2~", "dc": "3~", "delete": "3~",
+	"ppage": "5~", "pageup": "5~", "pgup": "5~",
+	"npage": "6~", "pagedown": "6~", "pgdn": "6~",
+	"f1": "OP", "f2": "OQ", "f3": "OR", "f4": "OS",
+	"f5": "15~", "f6": "17~", "f7": "18~", "f8": "19~",
+	"f9": "20~", "f10": "21~", "f11": "23~", "f12": "24~",
+}
+
+// keypadKeys are the keypad keys: the character they send, followed by the
+// final character of ESC O x they send in the application keypad mode.
+// Keypad Enter sends a carriage return, as Enter does in xterm, or ESC O M.
+var keypadKeys = map[string]string{
+	"kp0": "0p", "kp1": "1q", "kp2": "2r", "kp3": "3s", "kp4": "4t",
+	"kp5": "5u", "kp6": "6v", "kp7": "7w", "kp8": "8x", "kp9": "9y",
+	"kp/": "/o", "kp*": "*j", "kp-": "-m", "kp+": "+k", "kp.": ".n",
+	"kpenter": "\rM",
+}
@@ -0,0 +1,213 @@
+package strider
+
+import (
+	"encoding/json"
+	"fmt"
+	"math"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"time"
+	"unicode/utf8"
+)
+
+// recordPollInterval is how often a recording reads the program's output.
+// Output read in one poll shares one timestamp.
+const recordPollInterval = 10 * time.Millisecond
+
+// recording follows the program's output while the Terminal is open and
+// writes it, with timing, as an asciinema v2 cast file (see WithRecording).
+type recording struct {
+	path   string // the .cast file
+	title  string
+	width  int
+	height int
+	start  time.Time
+
+	output outputLog // read with its own offset, apart from Terminal.output
+
+	mu      sync.Mutex
+	offset  int64
+	pending []byte // an incomplete UTF-8 sequence at the end of the output
+	events  []castEvent
+
+	stop chan struct{}
+	done chan struct{}
+}
+
+// castEvent is an event of a cast file: output ("o") or a resize ("r").
+type castEvent struct {
+	at   time.Duration
+	kind string
+	data string
+}
+
+// recordings counts the cast files written for STRIDER_RECORD by path
+// without extension, so that several terminals in one test do not overwrite
+// each other's files.
+var recordings struct {
+	mu    sync.Mutex
+	names map[string]int
+}
+
+// recordingPath returns the cast file for a Terminal of the test (or
+// Session) called name: the path set with WithRecording, or a file named
+// after the test in the STRIDER_RECORD directory, or "" to not record.
+func recordingPath(name string, opts options) string {
+	if opts.recording != "" {
+		return opts.recording
+	}
+	dir := os.Getenv("STRIDER_RECORD")
+	if dir == "" {
+		return ""
+	}
+
+	recordings.mu.Lock()
+	defer recordings.mu.Unlock()
+	if recordings.names == nil {
+		recordings.names = make(map[string]int)
+	}
+	base := filepath.Join(dir, sanitizeName(name))
+	recordings.names[base]++
+	if n := recordings.names[base]; n > 1 {
+		base += fmt.Sprintf("-%d", n)
+	}
+	return base + ".cast"
+}
+
+// startRecording starts following the program's output for a cast file at
+// path. The recording is written when owner's cleanup runs.
+func (term *Terminal) startRecording(owner host, path string) {
+	rec := &recording{
+		path:   path,
+		title:  term.host.Name(),
+		width:  term.opts.width,
+		height: term.opts.height,
+		start:  time.Now(),
+		output: outputLog{path: term.output.path},
+		stop:   make(chan struct{}),
+		done:   make(chan struct{}),
+	}
+	term.recording = rec
+
+	go func() {
+		defer close(rec.done)
+		tick := time.NewTicker(recordPollInterval)
+		defer tick.Stop()
+		for {
+			select {
+			case <-rec.stop:
+				return
+			case <-tick.C:
+				rec.poll()
+			}
+		}
+	}()
+
+	owner.Cleanup(func() {
+		close(rec.stop)
+		<-rec.done
+		rec.poll()
+		if err := rec.write(term); err != nil {
+			owner.Errorf("strider: recording: %v", err)
+			return
+		}
+		if owner.Failed() {
+			owner.Logf("strider: recording: session saved to %s (play with: asciinema play %s)", path, shellQuote(path))
+		}
+	})
+}
+
+// poll records the output written since the last poll as one event.
+// Errors are ignored: the next poll reads the same output again.
+func (rec *recording) poll() {
+	rec.mu.Lock()
+	defer rec.mu.Unlock()
+	data, err := rec.output.readFrom(rec.offset)
+	if err != nil || len(data) == 0 {
+		return
+	}
+	rec.offset += int64(len(data))
+
+	data = append(rec.pending, data...)
+	complete := len(data)
+	// Hold back a UTF-8 sequence cut off at the end until the rest arrives.
+	for i := len(data) - 1; i >= 0 && i >= len(data)-utf8.UTFMax; i-- {
+		if utf8.RuneStart(data[i]) {
+			if !utf8.FullRune(data[i:]) {
+				complete = i
+			}
+			break
+		}
+	}
+	rec.pending = append([]byte(nil), data[complete:]...)
+	if complete > 0 {
+		rec.events = append(rec.events, castEvent{time.Since(rec.start), "o", string(data[:complete])})
+	}
+}
+
+// resize records a resize of the terminal.
+func (rec *recording) resize(width, height int) {
+	rec.poll() // output before the resize keeps its place
+	rec.mu.Lock()
+	defer rec.mu.Unlock()
+	rec.events = append(rec.events, castEvent{time.Since(rec.start), "r", fmt.Sprintf("%dx%d", width, height)})
+}
+
+// castHeader is the first line of an asciinema v2 cast file.
+type castHeader struct {
+	Version   int    `json:"version"`
+	Width     int    `json:"width"`
+	Height    int    `json:"height"`
+	Timestamp int64  `json:"timestamp"`
+	Title     string `json:"title,omitempty"`
+}
+
+// write writes the cast file, with the output redacted (see TypeSecret and
+// WithRedact).
+func (rec *recording) write(term *Terminal) error {
+	rec.mu.Lock()
+	defer rec.mu.Unlock()
+
+	// Redact the output events as one stream, so a value split between two
+	// polls is still found.
+	var outputs []string
+	for _, e := range rec.events {
+		if e.kind == "o" {
+			outputs = append(outputs, e.data)
+		}
+	}
+	outputs = term.redactChunks(outputs)
+
+	var b strings.Builder
+	header, err := json.Marshal(castHeader{
+		Version:   2,
+		Width:     rec.width,
+		Height:    rec.height,
+		Timestamp: rec.start.Unix(),
+		Title:     rec.title,
+	})
+	if err != nil {
+		return err
+	}
+	b.Write(header)
+	b.WriteByte('\n')
+	for _, e := range rec.events {
+		data := e.data
+		if e.kind == "o" {
+			data, outputs = outputs[0], outputs[1:]
+		}
+		line, err := json.Marshal([]any{math.Round(e.at.Seconds()*1e6) / 1e6, e.kind, data})
+		if err != nil {
+			return err
+		}
+		b.Write(line)
+		b.WriteByte('\n')
+	}
+
+	if err := os.MkdirAll(filepath.Dir(rec.path), 0o755); err != nil {
+		return err
+	}
+	return writeFileAtomic(rec.path, b.String())
+}
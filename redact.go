@@ -0,0 +1,180 @@
+package strider
+
+import (
+	"slices"
+	"strings"
+
+	"github.com/cboone/strider/ansi"
+)
+
+// redactMask replaces redacted text: values typed with TypeSecret and
+// matches of the WithRedact patterns.
+const redactMask = "●●●"
+
+// secretSet holds the values typed with TypeSecret.
+type secretSet struct {
+	values []string
+}
+
+// TypeSecret types s like Type, for passwords, tokens, and other values
+// that must not appear in test output. The transcript and debug log record
+// the input as type "●●●", and from then on every capture of the Terminal
+// shows ●●● wherever the program displays s: screens given to matchers,
+// failure output, snapshots, scrollback, and OutputSince. Recordings (see
+// WithRecording) are redacted too. Matchers cannot find s itself on the
+// screen.
+//
+//	term.WaitFor(strider.Text("Password:"))
+//	term.TypeSecret(os.Getenv("TEST_PASSWORD"))
+//	term.Press(strider.Enter)
+//
+// The value is redacted for the rest of the Terminal's life, including after
+// Reset. A value typed in a Terminal borrowed from a Pool stays redacted for
+// later borrowers and in the pooled Terminal's recording. Redaction replaces
+// the exact text, even where the program styles part of it, so a program
+// that wraps s across rows, or shows only part of it, can still reveal that
+// part.
+func (term *Terminal) TypeSecret(s string) {
+	term.t.Helper()
+	term.check(term.typeSecret(s))
+}
+
+// typeSecret is TypeSecret, returning its failure.
+func (term *Terminal) typeSecret(s string) error {
+	term.host.Helper()
+	if s != "" && !slices.Contains(term.secrets.values, s) {
+		term.secrets.values = append(term.secrets.values, s)
+	}
+	term.record("type %q", redactMask)
+	return term.typeLiteral(s)
+}
+
+// redact replaces the values typed with TypeSecret, and the matches of the
+// WithRedact patterns, in s.
+func (term *Terminal) redact(s string) string {
+	if len(term.secrets.values) == 0 && len(term.redactPatterns) == 0 {
+		return s // the common case, on every capture
+	}
+	return term.redactChunks([]string{s})[0]
+}
+
+// redactions returns the byte ranges of s to redact, sorted and without
+// overlaps: every occurrence of a value typed with TypeSecret and every
+// non-empty match of a WithRedact pattern.
+func (term *Terminal) redactions(s string) [][2]int {
+	var ranges [][2]int
+	for _, secret := range term.secrets.values {
+		for off := 0; ; {
+			i := strings.Index(s[off:], secret)
+			if i < 0 {
+				break
+			}
+			ranges = append(ranges, [2]int{off + i, off + i + len(secret)})
+			off += i + len(secret)
+		}
+	}
+	for _, re := range term.redactPatterns {
+		for _, m := range re.FindAllStringIndex(s, -1) {
+			if m[1] > m[0] {
+				ranges = append(ranges, [2]int{m[0], m[1]})
+			}
+		}
+	}
+	slices.SortFunc(ranges, func(a, b [2]int) int { return a[0] - b[0] })
+
+	merged := ranges[:0]
+	for _, r := range ranges {
+		if n := len(merged); n > 0 && r[0] <= merged[n-1][1] {
+			merged[n-1][1] = max(merged[n-1][1], r[1])
+			continue
+		}
+		merged = append(merged, r)
+	}
+	return merged
+}
+
+// A redaction is a byte range of text to remove. mask is set on the first
+// range of each redacted value, where redactMask replaces it.
+type redaction struct {
+	from, to int
+	mask     bool
+}
+
+// styledRedactions returns the ranges of s to remove for the redactions of
+// its text, with escape sequences left out: a value the program styles in
+// the middle, such as "pa\x1b[1mss", is still found, and the sequences
+// within it are kept so that the styles that follow stay the same.
+func (term *Terminal) styledRedactions(s string) []redaction {
+	// span maps a run of text in s to its offset in the text alone.
+	type span struct{ at, textAt, n int }
+	var spans []span
+	var text strings.Builder
+	off := 0
+	for seg := range ansi.Parse(s) {
+		if seg.Kind == ansi.Text {
+			spans = append(spans, span{off, text.Len(), len(seg.Raw)})
+			text.WriteString(seg.Raw)
+		}
+		off += len(seg.Raw)
+	}
+
+	var out []redaction
+	i := 0 // index of the first span not entirely before the range
+	for _, r := range term.redactions(text.String()) {
+		mask := true
+		for ; i < len(spans); i++ {
+			sp := spans[i]
+			if sp.textAt >= r[1] {
+				break
+			}
+			if from, to := max(r[0], sp.textAt), min(r[1], sp.textAt+sp.n); from < to {
+				out = append(out, redaction{sp.at + from - sp.textAt, sp.at + to - sp.textAt, mask})
+				mask = false
+			}
+			if sp.textAt+sp.n > r[1] {
+				break // the next range may start in this span
+			}
+		}
+	}
+	return out
+}
+
+// redactChunks redacts consecutive pieces of a stream, such as the output a
+// recording reads in chunks, as one text: a value split across chunks, or
+// by escape sequences, is still found. Each redacted value is masked in the
+// chunk where it starts, and the rest of its text is removed.
+func (term *Terminal) redactChunks(chunks []string) []string {
+	if len(term.secrets.values) == 0 && len(term.redactPatterns) == 0 {
+		return chunks
+	}
+	ranges := term.styledRedactions(strings.Join(chunks, ""))
+	if len(ranges) == 0 {
+		return chunks
+	}
+
+	out := make([]string, len(chunks))
+	start := 0 // offset of the current chunk in the whole text
+	r := 0     // index of the first range not entirely before the chunk
+	for i, c := range chunks {
+		end := start + len(c)
+		var b strings.Builder
+		pos := start
+		for ; r < len(ranges) && ranges[r].from < end; r++ {
+			from, to := ranges[r].from, ranges[r].to
+			if from >= pos {
+				b.WriteString(c[pos-start : from-start])
+				if ranges[r].mask {
+					b.WriteString(redactMask)
+				}
+			}
+			pos = max(pos, min(to, end))
+			if to > end {
+				break // continues into the next chunk
+			}
+		}
+		b.WriteString(c[pos-start:])
+		out[i] = b.String()
+		start = end
+	}
+	return out
+}
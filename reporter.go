@@ -0,0 +1,115 @@
+package strider
+
+import (
+	"errors"
+	"fmt"
+	"testing"
+)
+
+// Reporter reports the failures of Terminals, for routing them into a
+// team's own tooling, such as a CI annotation format or a failure database,
+// without parsing strider's messages. Set it with WithReporter.
+//
+// Report must report f to t, usually with t.Error, and may save or format
+// its details in any way. strider stops the test after Report returns if
+// f.Fatal is set, and marks it failed otherwise, so a Reporter that only
+// logs still fails the test.
+type Reporter interface {
+	Report(t testing.TB, f Failure)
+}
+
+// ReporterFunc adapts a function to a Reporter.
+type ReporterFunc func(t testing.TB, f Failure)
+
+// Report calls fn(t, f).
+func (fn ReporterFunc) Report(t testing.TB, f Failure) {
+	t.Helper()
+	fn(t, f)
+}
+
+// DefaultReporter reports failures as strider does without WithReporter:
+// t.Error with the failure's Message. Reporters that add to the default
+// report can call it.
+var DefaultReporter Reporter = ReporterFunc(func(t testing.TB, f Failure) {
+	t.Helper()
+	t.Error(f.Message)
+})
+
+// Failure describes a failure a Terminal reports. Its text, screens, and
+// transcript are redacted (see WithRedact).
+type Failure struct {
+	// Message is the failure as DefaultReporter reports it, including the
+	// details below formatted for reading.
+	Message string
+	// Fatal reports whether the test stops after the failure. Soft
+	// failures, such as those of ExpectFor, leave it false.
+	Fatal bool
+	// Wait describes the wait that failed, with the matcher description
+	// and the outcome, or is nil for failures outside a wait.
+	Wait *WaitMetric
+	// Screens are the last screens captured before the failure, oldest
+	// first. Failed waits report several; other failures at most the final
+	// screen.
+	Screens []*Screen
+	// Exit describes the program's exit, if the failure is that it exited
+	// unexpectedly or with the wrong status, or is nil.
+	Exit *ExitState
+	// Transcript is the session recorded so far for WithTranscript, or "".
+	Transcript string
+}
+
+// Error returns f.Message, so that a Session can return f as its error.
+func (f *Failure) Error() string {
+	return f.Message
+}
+
+// failure returns a failure with the details in f, and the message formatted
+// from format and args, for an operation to return.
+func (term *Terminal) failure(f Failure, format string, args ...any) *Failure {
+	f.Message = term.redact(fmt.Sprintf(format, args...))
+	if term.transcript != nil {
+		f.Transcript = term.transcript.String()
+	}
+	return &f
+}
+
+// failf returns a fatal failure with the message formatted from format and
+// args.
+func (term *Terminal) failf(format string, args ...any) error {
+	return term.failure(Failure{Fatal: true}, format, args...)
+}
+
+// failWith reports a failure with the details in f, and the message
+// formatted from format and args, to the Terminal's Reporter.
+func (term *Terminal) failWith(f Failure, format string, args ...any) {
+	term.t.Helper()
+	term.check(term.failure(f, format, args...))
+}
+
+// check reports err, returned by one of the Terminal's operations, to its
+// test. A *Failure goes to the Terminal's Reporter, and stops the test if it
+// is fatal; a skipError skips the test. Other errors are reported as fatal
+// failures.
+func (term *Terminal) check(err error) {
+	term.t.Helper()
+	if err == nil {
+		return
+	}
+	var skip *skipError
+	if errors.As(err, &skip) {
+		term.t.Skip(skip.msg)
+	}
+	f, ok := err.(*Failure)
+	if !ok {
+		f = term.failure(Failure{Fatal: true}, "%v", err)
+	}
+	r := term.opts.reporter
+	if r == nil {
+		r = DefaultReporter
+	}
+	r.Report(term.t, *f)
+	if f.Fatal {
+		term.t.FailNow()
+	}
+	term.t.Fail()
+}
@@ -0,0 +1,183 @@
+package strider
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"testing"
+
+	"github.com/cboone/strider/internal/tmuxcli"
+)
+
+// CheckRequirements verifies that a usable tmux is available: it resolves
+// tmux from STRIDER_TMUX or $PATH and checks that its version is at least
+// 3.0. The returned error explains what is missing and how to fix it.
+//
+// Use it (or MustRequirements) in TestMain to check the environment once per
+// package instead of reporting the same skip from every test.
+func CheckRequirements() error {
+	_, err := checkRequirements()
+	return err
+}
+
+// checkRequirements is CheckRequirements that also reports whether tmux was
+// explicitly configured through STRIDER_TMUX.
+func checkRequirements() (explicit bool, err error) {
+	path, explicit, err := findTmux("")
+	if err != nil {
+		return false, fmt.Errorf("strider: requirements: tmux not found in PATH\n"+
+			"    install tmux %s or newer (apt-get install tmux, brew install tmux),\n"+
+			"    or set STRIDER_TMUX to the path of a tmux binary", minTmuxVersion)
+	}
+
+	version, err := tmuxcli.Version(path)
+	if err != nil {
+		return explicit, fmt.Errorf("strider: requirements: cannot run %s: %v\n"+
+			"    check that it is an executable tmux binary", path, err)
+	}
+
+	if !versionAtLeast(version, minTmuxVersion) {
+		return explicit, fmt.Errorf("strider: requirements: %s is tmux %s, below minimum %s\n"+
+			"    upgrade tmux, or set STRIDER_TMUX to a newer tmux binary", path, version, minTmuxVersion)
+	}
+
+	return explicit, nil
+}
+
+// MustRequirements checks requirements from TestMain, before m.Run, so a
+// suite fails fast or skips as a whole instead of per test:
+//
+//	func TestMain(m *testing.M) {
+//		strider.MustRequirements(m)
+//		os.Exit(m.Run())
+//	}
+//
+// It follows the same policy as Open. If tmux was auto-detected (or not
+// found), unmet requirements print the guidance and exit with status 0,
+// skipping the package. If tmux was explicitly configured with STRIDER_TMUX,
+// or the environment is strict (see SetStrictEnvironment), they exit with
+// status 1. When requirements are met it returns and the caller runs the
+// tests.
+func MustRequirements(m *testing.M) {
+	explicit, err := checkRequirements()
+	if err == nil {
+		return
+	}
+
+	if explicit || strictByDefault() {
+		fmt.Fprintf(os.Stderr, "%v\nFAIL\n", err)
+		os.Exit(1)
+	}
+	fmt.Fprintf(os.Stderr, "%v\nskipping all tests in this package\n", err)
+	os.Exit(0)
+}
+
+// strictSetting is the package-wide policy set with SetStrictEnvironment.
+var strictSetting struct {
+	mu     sync.Mutex
+	set    bool
+	strict bool
+}
+
+// SetStrictEnvironment sets whether, by default, a test fails rather than
+// skips when the environment lacks something strider needs: tmux, a recent
+// enough tmux, what WithNoNetwork, WithSandbox, and WithUser rely on, or
+// pseudo-terminals for WithBackend(PTY). Skipping suits
+// developer machines; failing suits CI, where a skip can hide that a suite
+// has not run for weeks. It returns the previous setting.
+//
+// The default can also be set with the STRIDER_STRICT environment variable;
+// SetStrictEnvironment takes precedence. WithStrictEnvironment makes a single
+// terminal strict, and a tmux configured through STRIDER_TMUX or WithTmuxPath
+// is always required.
+func SetStrictEnvironment(strict bool) (previous bool) {
+	strictSetting.mu.Lock()
+	defer strictSetting.mu.Unlock()
+	previous = strictSetting.strict
+	if !strictSetting.set {
+		previous = envTrue("STRIDER_STRICT")
+	}
+	strictSetting.set, strictSetting.strict = true, strict
+	return previous
+}
+
+// strictByDefault reports the package-wide policy: SetStrictEnvironment if
+// it was called, else STRIDER_STRICT.
+func strictByDefault() bool {
+	strictSetting.mu.Lock()
+	defer strictSetting.mu.Unlock()
+	if strictSetting.set {
+		return strictSetting.strict
+	}
+	return envTrue("STRIDER_STRICT")
+}
+
+// missingEnvironment ends the test because the environment lacks something
+// strider needs: with a failure when strict, and a skip otherwise.
+func missingEnvironment(t testing.TB, strict bool, format string, args ...any) {
+	t.Helper()
+	if strict {
+		t.Fatalf(format, args...)
+	}
+	t.Skipf(format, args...)
+}
+
+// environmentError is missingEnvironment for operations that return their
+// failures: a skipError, or a plain error when strict.
+func environmentError(strict bool, format string, args ...any) error {
+	if strict {
+		return fmt.Errorf(format, args...)
+	}
+	return &skipError{msg: fmt.Sprintf(format, args...)}
+}
+
+// skipError is returned by an operation that would skip the test rather
+// than fail it, such as Open without tmux. A Session returns it as any
+// other error.
+type skipError struct {
+	msg string
+}
+
+func (e *skipError) Error() string {
+	return e.msg
+}
+
+// checkBinary verifies that binary, when given as a path rather than a name
+// to look up in $PATH, exists and is an executable file. Relative paths are
+// resolved against dir (the program's working directory) when it is set.
+func checkBinary(binary, dir string) error {
+	if !strings.Contains(binary, "/") {
+		return nil
+	}
+
+	path := binary
+	if dir != "" && !filepath.IsAbs(path) {
+		path = filepath.Join(dir, path)
+	}
+
+	info, err := os.Stat(path)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return fmt.Errorf("binary %s does not exist", describeBinary(binary, path))
+		}
+		return fmt.Errorf("binary %s: %w", describeBinary(binary, path), err)
+	}
+	if info.IsDir() {
+		return fmt.Errorf("binary %s is a directory", describeBinary(binary, path))
+	}
+	if info.Mode().Perm()&0o111 == 0 {
+		return fmt.Errorf("binary %s is not executable (mode %v)", describeBinary(binary, path), info.Mode().Perm())
+	}
+	return nil
+}
+
+// describeBinary names binary in checkBinary errors, including the resolved
+// path when it differs.
+func describeBinary(binary, path string) string {
+	if path == binary {
+		return fmt.Sprintf("%q", binary)
+	}
+	return fmt.Sprintf("%q (resolved to %s)", binary, path)
+}
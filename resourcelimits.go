@@ -0,0 +1,76 @@
+package strider
+
+import (
+	"fmt"
+	"os"
+	"strconv"
+	"strings"
+	"syscall"
+	"time"
+)
+
+// limitScript runs the program under the resource limits given as ulimit
+// commands, and records its exit status in the file named by its first
+// argument. The status file is reliable where tmux's pane_dead_status is
+// not, for programs killed by a signal. INT and QUIT are caught (not
+// ignored, which the program would inherit) so Ctrl+C and Ctrl+\ reach
+// only the program.
+const limitScript = `%s || exit 125; trap : INT QUIT; f=$1; shift; "$@"; s=$?; echo $s > "$f"; exit $s`
+
+// hasLimits reports whether WithServerLimits set any limit.
+func (o options) hasLimits() bool {
+	return o.cpuLimit > 0 || o.memoryLimit > 0
+}
+
+// limitCommand returns the command that runs binary with args under the
+// limits set with WithServerLimits, writing the exit status to statusPath.
+func limitCommand(binary string, args []string, opts options, statusPath string) (string, []string) {
+	var ulimits []string
+	if opts.cpuLimit > 0 {
+		secs := int64((opts.cpuLimit + time.Second - 1) / time.Second)
+		ulimits = append(ulimits, fmt.Sprintf("ulimit -t %d", secs))
+	}
+	if opts.memoryLimit > 0 {
+		ulimits = append(ulimits, fmt.Sprintf("ulimit -v %d", (opts.memoryLimit+1023)/1024))
+	}
+
+	script := fmt.Sprintf(limitScript, strings.Join(ulimits, " && "))
+	shArgs := make([]string, 0, 4+len(args))
+	shArgs = append(shArgs, "-c", script, "strider", statusPath, binary)
+	shArgs = append(shArgs, args...)
+	return "/bin/sh", shArgs
+}
+
+// formatLimitDiagnostics explains an exit caused by a WithServerLimits
+// limit, based on the status recorded by limitScript. Best-effort: returns
+// "" when no limits are set or the status was not recorded.
+func (term *Terminal) formatLimitDiagnostics() string {
+	if !term.openOpts.hasLimits() {
+		return ""
+	}
+	data, err := os.ReadFile(term.statusPath())
+	if err != nil {
+		return ""
+	}
+	status, err := strconv.Atoi(strings.TrimSpace(string(data)))
+	if err != nil {
+		return ""
+	}
+
+	switch {
+	case status == 125:
+		return "\n    limit: the resource limits could not be applied (WithServerLimits)"
+	case term.openOpts.cpuLimit > 0 &&
+		(status == 128+int(syscall.SIGXCPU) || status == 128+int(syscall.SIGKILL)):
+		return fmt.Sprintf("\n    limit: CPU time limit of %v exceeded (WithServerLimits)", term.openOpts.cpuLimit)
+	case term.openOpts.memoryLimit > 0 && status != 0:
+		return fmt.Sprintf("\n    limit: status %d; the program may have run out of memory under the %d-byte limit (WithServerLimits)",
+			status, term.openOpts.memoryLimit)
+	}
+	return ""
+}
+
+// statusPath returns the file limitScript writes the exit status to.
+func (term *Terminal) statusPath() string {
+	return term.files + ".status"
+}
@@ -0,0 +1,135 @@
+package strider
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// sandboxExecEnv carries the sandbox rules to the process that applies them
+// (see sandboxCommand).
+const sandboxExecEnv = "STRIDER_SANDBOX_EXEC"
+
+// SandboxPolicy configures WithSandbox. The zero value allows writes only in
+// the working directory and /dev, and no network access.
+type SandboxPolicy struct {
+	// ReadWrite lists more files and directories, with everything beneath
+	// them, that the program may write, such as a cache directory.
+	ReadWrite []string
+	// AllowNetwork keeps network access.
+	AllowNetwork bool
+}
+
+// sandboxRules are the rules the sandboxed process applies, resolved when
+// the Terminal is opened.
+type sandboxRules struct {
+	Writable []string `json:"writable"`
+	Network  bool     `json:"network"`
+}
+
+// WithSandbox runs the program in a sandbox on Linux, for acceptance tests
+// of programs that must not change the machine they run on. The program
+// can read and run files anywhere, but write only in its working directory
+// (see WithDir and WithTempWorkdir), in /dev, and in policy.ReadWrite, and
+// cannot open IPv4 or IPv6 sockets unless policy.AllowNetwork is set.
+// Attempts fail with a permission error (EACCES) that the program sees and
+// usually reports; when it exits, the failure output lists the sandbox
+// rules.
+//
+// The rules are applied with Landlock and seccomp, which need Linux 5.13 or
+// newer on amd64 or arm64, and no privileges. strider applies them by
+// running the program through the test binary itself, which checks for its
+// sandbox settings as package strider is initialized and then executes the
+// program. The test is skipped when the sandbox is not available.
+func WithSandbox(policy SandboxPolicy) Option {
+	return func(o *options) {
+		o.sandbox = &policy
+	}
+}
+
+// sandboxRulesFor resolves policy for a program running in dir, or in the
+// current directory if dir is "".
+func sandboxRulesFor(policy SandboxPolicy, dir string) (sandboxRules, error) {
+	rules := sandboxRules{Network: policy.AllowNetwork}
+	for _, p := range append([]string{dir, "/dev"}, policy.ReadWrite...) {
+		abs, err := filepath.Abs(p)
+		if err != nil {
+			return sandboxRules{}, err
+		}
+		rules.Writable = append(rules.Writable, abs)
+	}
+	return rules, nil
+}
+
+// requireSandbox returns the path of the test binary that applies the
+// sandbox, or an error that skips the test (or fails it when strict) unless
+// the sandbox is available.
+func requireSandbox(strict bool) (string, error) {
+	if err := sandboxAvailable(); err != nil {
+		return "", environmentError(strict, "strider: open: WithSandbox: %v", err)
+	}
+	self, err := os.Executable()
+	if err != nil {
+		return "", environmentError(strict, "strider: open: WithSandbox: cannot find the test binary: %v", err)
+	}
+	return self, nil
+}
+
+// sandboxCommand returns the command that runs binary with args under rules:
+// the test binary at self, which applies them before executing binary (see
+// sandboxExec).
+func sandboxCommand(self string, rules sandboxRules, binary string, args []string) (string, []string, error) {
+	encoded, err := json.Marshal(rules)
+	if err != nil {
+		return "", nil, err
+	}
+	wrapped := make([]string, 0, 3+len(args))
+	wrapped = append(wrapped, sandboxExecEnv+"="+string(encoded), self, binary)
+	wrapped = append(wrapped, args...)
+	return "/usr/bin/env", wrapped, nil
+}
+
+func init() {
+	if encoded, ok := os.LookupEnv(sandboxExecEnv); ok {
+		sandboxExec(encoded)
+	}
+}
+
+// sandboxExec runs in the test binary started by sandboxCommand, before any
+// test code: it applies the rules and executes the program named by its
+// arguments, or exits with status 126 if it cannot.
+func sandboxExec(encoded string) {
+	err := func() error {
+		os.Unsetenv(sandboxExecEnv)
+		var rules sandboxRules
+		if err := json.Unmarshal([]byte(encoded), &rules); err != nil {
+			return err
+		}
+		if len(os.Args) < 2 {
+			return fmt.Errorf("no program to run")
+		}
+		return applySandboxAndExec(rules, os.Args[1], os.Args[1:])
+	}()
+	fmt.Fprintf(os.Stderr, "strider: sandbox: %v\n", err)
+	os.Exit(126)
+}
+
+// formatSandboxDiagnostics lists the sandbox rules for the failure output of
+// a program that exited, or returns "" without WithSandbox.
+func (term *Terminal) formatSandboxDiagnostics() string {
+	if term.openOpts.sandbox == nil {
+		return ""
+	}
+	rules, err := sandboxRulesFor(*term.openOpts.sandbox, term.openOpts.dir)
+	if err != nil {
+		return ""
+	}
+	network := "none"
+	if rules.Network {
+		network = "allowed"
+	}
+	return fmt.Sprintf("\n    sandbox: writes only in %s, network %s (WithSandbox); permission errors may come from it",
+		strings.Join(rules.Writable, ", "), network)
+}
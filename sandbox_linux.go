@@ -0,0 +1,214 @@
+package strider
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"os/exec"
+	"runtime"
+	"syscall"
+	"unsafe"
+)
+
+// Landlock system calls, numbered alike on all architectures Go supports
+// on Linux.
+const (
+	sysLandlockCreateRuleset = 444
+	sysLandlockAddRule       = 445
+	sysLandlockRestrictSelf  = 446
+)
+
+const (
+	landlockCreateRulesetVersion = 1 << 0
+	landlockRulePathBeneath      = 1
+)
+
+// Landlock filesystem access rights that change the filesystem. Rights to
+// read and execute files are left unhandled, and so allowed everywhere.
+const (
+	landlockAccessWriteFile  = 1 << 1
+	landlockAccessRemoveDir  = 1 << 4
+	landlockAccessRemoveFile = 1 << 5
+	landlockAccessMakeChar   = 1 << 6
+	landlockAccessMakeDir    = 1 << 7
+	landlockAccessMakeReg    = 1 << 8
+	landlockAccessMakeSock   = 1 << 9
+	landlockAccessMakeFifo   = 1 << 10
+	landlockAccessMakeBlock  = 1 << 11
+	landlockAccessMakeSym    = 1 << 12
+	landlockAccessRefer      = 1 << 13 // ABI 2
+	landlockAccessTruncate   = 1 << 14 // ABI 3
+)
+
+// landlockPathBeneathAttr is struct landlock_path_beneath_attr, which the
+// kernel reads as 12 packed bytes: the same offsets as this struct.
+type landlockPathBeneathAttr struct {
+	allowedAccess uint64
+	parentFd      int32
+}
+
+// seccompArch describes an architecture the network filter supports: its
+// AUDIT_ARCH value and the number of socket(2).
+type seccompArch struct {
+	audit  uint32
+	socket uint32
+}
+
+var seccompArchs = map[string]seccompArch{
+	"amd64": {audit: 0xc000003e, socket: 41},
+	"arm64": {audit: 0xc00000b7, socket: 198},
+}
+
+// landlockABI returns the Landlock ABI version of the kernel.
+func landlockABI() (int, error) {
+	v, _, errno := syscall.Syscall(sysLandlockCreateRuleset, 0, 0, landlockCreateRulesetVersion)
+	if errno != 0 {
+		return 0, errno
+	}
+	return int(v), nil
+}
+
+// sandboxAvailable reports why WithSandbox cannot be used, or nil.
+func sandboxAvailable() error {
+	if _, ok := seccompArchs[runtime.GOARCH]; !ok {
+		return fmt.Errorf("not supported on %s", runtime.GOARCH)
+	}
+	if _, err := landlockABI(); err != nil {
+		return fmt.Errorf("Landlock is not available: %v", err)
+	}
+	return nil
+}
+
+// applySandboxAndExec restricts the current thread to rules and executes
+// name with argv in it. Landlock and seccomp apply to the calling thread and
+// are inherited across execve, so the thread is locked until the exec.
+func applySandboxAndExec(rules sandboxRules, name string, argv []string) error {
+	path, err := exec.LookPath(name)
+	if err != nil {
+		return err
+	}
+	runtime.LockOSThread()
+
+	if _, _, errno := syscall.RawSyscall6(syscall.SYS_PRCTL, prSetNoNewPrivs, 1, 0, 0, 0, 0); errno != 0 {
+		return fmt.Errorf("prctl(PR_SET_NO_NEW_PRIVS): %v", errno)
+	}
+	if err := restrictWrites(rules.Writable); err != nil {
+		return err
+	}
+	if !rules.Network {
+		if err := denyInetSockets(); err != nil {
+			return err
+		}
+	}
+	return syscall.Exec(path, argv, os.Environ())
+}
+
+// restrictWrites allows changes to the filesystem only beneath writable.
+func restrictWrites(writable []string) error {
+	abi, err := landlockABI()
+	if err != nil {
+		return fmt.Errorf("landlock: %v", err)
+	}
+	handled := uint64(landlockAccessWriteFile | landlockAccessRemoveDir | landlockAccessRemoveFile |
+		landlockAccessMakeChar | landlockAccessMakeDir | landlockAccessMakeReg | landlockAccessMakeSock |
+		landlockAccessMakeFifo | landlockAccessMakeBlock | landlockAccessMakeSym)
+	fileAccess := uint64(landlockAccessWriteFile)
+	if abi >= 2 {
+		handled |= landlockAccessRefer
+	}
+	if abi >= 3 {
+		handled |= landlockAccessTruncate
+		fileAccess |= landlockAccessTruncate
+	}
+
+	// struct landlock_ruleset_attr; its later fields are left out, which
+	// leaves network access to the seccomp filter.
+	attr := struct{ handledAccessFs uint64 }{handled}
+	fd, _, errno := syscall.Syscall(sysLandlockCreateRuleset, uintptr(unsafe.Pointer(&attr)), unsafe.Sizeof(attr), 0)
+	if errno != 0 {
+		return fmt.Errorf("landlock_create_ruleset: %v", errno)
+	}
+	defer syscall.Close(int(fd))
+
+	for _, p := range writable {
+		dir, err := os.Open(p)
+		if errors.Is(err, os.ErrNotExist) {
+			continue
+		}
+		if err != nil {
+			return err
+		}
+		access := handled
+		if info, err := dir.Stat(); err == nil && !info.IsDir() {
+			access = fileAccess
+		}
+		rule := landlockPathBeneathAttr{allowedAccess: access, parentFd: int32(dir.Fd())}
+		_, _, errno := syscall.Syscall6(sysLandlockAddRule, fd, landlockRulePathBeneath, uintptr(unsafe.Pointer(&rule)), 0, 0, 0)
+		dir.Close()
+		if errno != 0 {
+			return fmt.Errorf("landlock_add_rule %s: %v", p, errno)
+		}
+	}
+
+	if _, _, errno := syscall.Syscall(sysLandlockRestrictSelf, fd, 0, 0); errno != 0 {
+		return fmt.Errorf("landlock_restrict_self: %v", errno)
+	}
+	return nil
+}
+
+// Classic BPF instructions, seccomp return values, and prctl(2) options for
+// the sandbox.
+const (
+	bpfLdWAbs       = 0x20 // BPF_LD | BPF_W | BPF_ABS
+	bpfJeqK         = 0x15 // BPF_JMP | BPF_JEQ | BPF_K
+	bpfJgeK         = 0x35 // BPF_JMP | BPF_JGE | BPF_K
+	bpfRetK         = 0x06 // BPF_RET | BPF_K
+	seccompRetAllow = 0x7fff0000
+	seccompRetErrno = 0x00050000
+
+	prSetSeccomp      = 22
+	prSetNoNewPrivs   = 38
+	seccompModeFilter = 2
+	x32SyscallBit     = 0x40000000
+)
+
+// sockFilter is struct sock_filter.
+type sockFilter struct {
+	code uint16
+	jt   uint8
+	jf   uint8
+	k    uint32
+}
+
+// sockFprog is struct sock_fprog.
+type sockFprog struct {
+	len    uint16
+	filter *sockFilter
+}
+
+// denyInetSockets installs a seccomp filter that fails socket(2) for IPv4
+// and IPv6 with EACCES. System calls of other ABIs, such as 32-bit calls on
+// amd64, fail too, so that they cannot bypass the filter.
+func denyInetSockets() error {
+	arch := seccompArchs[runtime.GOARCH]
+	deny := uint32(seccompRetErrno | uint32(syscall.EACCES))
+	filter := []sockFilter{
+		{code: bpfLdWAbs, k: 4},                            // 0: arch
+		{code: bpfJeqK, jt: 0, jf: 7, k: arch.audit},       // 1: other ABI: deny
+		{code: bpfLdWAbs, k: 0},                            // 2: syscall number
+		{code: bpfJgeK, jt: 5, jf: 0, k: x32SyscallBit},    // 3: x32: deny
+		{code: bpfJeqK, jt: 0, jf: 3, k: arch.socket},      // 4: not socket: allow
+		{code: bpfLdWAbs, k: 16},                           // 5: domain
+		{code: bpfJeqK, jt: 2, jf: 0, k: syscall.AF_INET},  // 6
+		{code: bpfJeqK, jt: 1, jf: 0, k: syscall.AF_INET6}, // 7
+		{code: bpfRetK, k: seccompRetAllow},                // 8
+		{code: bpfRetK, k: deny},                           // 9
+	}
+	prog := sockFprog{len: uint16(len(filter)), filter: &filter[0]}
+	_, _, errno := syscall.RawSyscall6(syscall.SYS_PRCTL, prSetSeccomp, seccompModeFilter, uintptr(unsafe.Pointer(&prog)), 0, 0, 0)
+	runtime.KeepAlive(filter)
+	if errno != 0 {
+		return fmt.Errorf("seccomp: %v", errno)
+	}
+	return nil
+}
@@ -0,0 +1,19 @@
+//go:build !linux
+
+package strider
+
+import (
+	"errors"
+	"runtime"
+)
+
+// sandboxAvailable reports why WithSandbox cannot be used: it needs Linux.
+func sandboxAvailable() error {
+	return errors.New("not supported on " + runtime.GOOS)
+}
+
+// applySandboxAndExec is never called without Linux, where sandboxAvailable
+// skips the test first.
+func applySandboxAndExec(rules sandboxRules, name string, argv []string) error {
+	return sandboxAvailable()
+}
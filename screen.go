@@ -1,7 +1,12 @@
 package strider
 
 import (
+	"encoding/binary"
+	"hash/fnv"
+	"slices"
 	"strings"
+	"sync"
+	"sync/atomic"
 )
 
 // Screen is an immutable capture of terminal content.
@@ -12,6 +17,28 @@ type Screen struct {
 	height    int
 	cursorRow int
 	cursorCol int
+
+	// visibleStart is the index in lines of the first row that was visible
+	// in the pane at capture time. It is 0 for visible-screen captures and
+	// the number of history rows for scrollback captures.
+	visibleStart int
+
+	// styled is the capture with its SGR escape sequences, for screens
+	// captured with WithStyles (hasStyles). styles is parsed from it on
+	// first use (see StyleAt).
+	hasStyles  bool
+	styled     string
+	stylesOnce sync.Once
+	styles     [][]Style
+
+	// external is set by matchers that check state outside the screen, such
+	// as FileExists (see markExternal).
+	external atomic.Bool
+
+	// notes explain why the matcher being evaluated on the screen did not
+	// match (see addNote). They are not part of the capture.
+	notesMu sync.Mutex
+	notes   []string
 }
 
 // newScreen creates a Screen from raw capture-pane output.
@@ -36,6 +63,34 @@ func newScreen(raw string, width, height int) *Screen {
 	}
 }
 
+// NewScreen returns a Screen of the given size that shows lines, padded with
+// blank rows to height, as if captured from a program. It has no cursor
+// position (see WithCursor). Use it to unit-test matchers and helpers that
+// take a Screen without running tmux.
+func NewScreen(width, height int, lines ...string) *Screen {
+	rows := slices.Clone(lines)
+	for len(rows) < height {
+		rows = append(rows, "")
+	}
+	return newScreen(strings.Join(rows, "\n")+"\n", width, height)
+}
+
+// WithCursor returns a copy of s with the cursor at row and col (0-indexed),
+// for Screens built with NewScreen.
+func (s *Screen) WithCursor(row, col int) *Screen {
+	return &Screen{
+		lines:        s.lines,
+		raw:          s.raw,
+		width:        s.width,
+		height:       s.height,
+		cursorRow:    row,
+		cursorCol:    col,
+		visibleStart: s.visibleStart,
+		hasStyles:    s.hasStyles,
+		styled:       s.styled,
+	}
+}
+
 // String returns the full screen content as a string.
 func (s *Screen) String() string {
 	return s.raw
@@ -56,12 +111,150 @@ func (s *Screen) Line(n int) string {
 	return s.lines[n]
 }
 
+// Column returns the vertical slice of the screen at display column col
+// (0-indexed): one rune per line, top to bottom. Columns are measured in
+// display cells, so a wide character is returned for both columns it covers,
+// and cells past the end of a line are returned as ' '.
+func (s *Screen) Column(col int) []rune {
+	out := make([]rune, len(s.lines))
+	for i, line := range s.lines {
+		if col < 0 {
+			out[i] = ' '
+			continue
+		}
+		out[i] = cellAt(line, col)
+	}
+	return out
+}
+
+// Region returns the rectangle of h rows from row y and w display columns
+// from column x (all 0-indexed) as a Screen of size w x h, so matchers and
+// snapshots can target part of the screen, such as a sidebar or a dialog:
+//
+//	term.Screen().Region(0, 0, 20, 24).MatchSnapshot(t, "sidebar")
+//
+// Cells outside s are blank, as are the halves of wide characters cut by the
+// edges, and trailing spaces are trimmed from each row as in a capture. The
+// cursor position is kept, relative to the region, when it lies inside it.
+// Styles are not kept. Negative sizes are treated as 0.
+func (s *Screen) Region(x, y, w, h int) *Screen {
+	w, h = max(w, 0), max(h, 0)
+	rows := make([]string, h)
+	for i := range rows {
+		if row := y + i; row >= 0 && row < len(s.lines) {
+			rows[i] = strings.TrimRight(cellSlice(s.lines[row], x, w), " ")
+		}
+	}
+	sub := NewScreen(w, h, rows...)
+	if row, col, ok := s.CursorPosition(); ok && row >= y && row < y+h && col >= x && col < x+w {
+		sub.cursorRow, sub.cursorCol = row-y, col-x
+	}
+	return sub
+}
+
 // Contains reports whether the screen contains the substring.
 func (s *Screen) Contains(substr string) bool {
 	return strings.Contains(s.raw, substr)
 }
 
-// Size returns the width and height.
+// Equal reports whether s and other have identical content and size, and
+// identical styles if both were captured with styles. Cursor position is not
+// compared.
+func (s *Screen) Equal(other *Screen) bool {
+	if s == nil || other == nil {
+		return s == other
+	}
+	if s.Styled() && other.Styled() && s.styled != other.styled {
+		return false
+	}
+	return s.width == other.width && s.height == other.height && s.raw == other.raw
+}
+
+// EqualNormalized reports whether s and other have the same content after
+// snapshot normalization (trailing spaces and trailing blank lines removed).
+// Size and cursor position are not compared.
+func (s *Screen) EqualNormalized(other *Screen) bool {
+	if s == nil || other == nil {
+		return s == other
+	}
+	return NormalizeScreen(s.raw) == NormalizeScreen(other.raw)
+}
+
+// Hash returns a hash of the screen content, size, cursor position, and
+// styles, if captured with them.
+// Screens captured the same way, both with WithStyles or both without, that
+// are Equal and have the same cursor position have the same hash, in every
+// process and on every platform, so the hash can be used to detect cheaply
+// whether a screen changed, or stored to compare later. A styled and a plain
+// capture of the same text are Equal but hash differently. Different screens
+// may collide, though it is unlikely.
+func (s *Screen) Hash() uint64 {
+	h := fnv.New64a()
+	var buf [8]byte
+	for _, n := range []int{s.width, s.height, s.cursorRow, s.cursorCol, s.visibleStart} {
+		binary.LittleEndian.PutUint64(buf[:], uint64(int64(n)))
+		h.Write(buf[:])
+	}
+	h.Write([]byte(s.raw))
+	h.Write([]byte(s.styled))
+	return h.Sum64()
+}
+
+// ContainsWrapped reports whether the screen contains the substring after
+// joining soft-wrapped rows. A row whose content fills the full screen width
+// is treated as continuing onto the next row, so text the terminal wrapped
+// across rows still matches. Rows are otherwise separated by newlines.
+func (s *Screen) ContainsWrapped(substr string) bool {
+	return strings.Contains(s.unwrapped(), substr)
+}
+
+// unwrapped returns the screen content with soft-wrapped rows joined.
+func (s *Screen) unwrapped() string {
+	var b strings.Builder
+	for i, line := range s.lines {
+		b.WriteString(line)
+		if i == len(s.lines)-1 {
+			break
+		}
+		if s.width > 0 && displayWidth(line) >= s.width {
+			continue
+		}
+		b.WriteByte('\n')
+	}
+	return b.String()
+}
+
+// CursorPosition returns the cursor position (0-indexed) at capture time.
+// ok is false if the position could not be queried.
+func (s *Screen) CursorPosition() (row, col int, ok bool) {
+	if s.cursorRow < 0 || s.cursorCol < 0 {
+		return -1, -1, false
+	}
+	return s.cursorRow, s.cursorCol, true
+}
+
+// Size returns the width and height of the pane at capture time.
+// For scrollback captures this is still the visible pane size; use
+// TotalLines for the number of captured lines.
 func (s *Screen) Size() (width, height int) {
 	return s.width, s.height
 }
+
+// TotalLines returns the number of captured lines. For visible-screen
+// captures this equals the pane height; for scrollback captures it includes
+// every history row as well.
+func (s *Screen) TotalLines() int {
+	return len(s.lines)
+}
+
+// VisibleRange returns the half-open range [start, end) of indexes into
+// Lines that were visible in the pane at capture time. For visible-screen
+// captures the range covers every line; for scrollback captures it covers
+// the last rows, after the history.
+func (s *Screen) VisibleRange() (start, end int) {
+	end = s.visibleStart + s.height
+	if end > len(s.lines) {
+		end = len(s.lines)
+	}
+	return s.visibleStart, end
+}
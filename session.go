@@ -0,0 +1,210 @@
+package strider
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"strings"
+)
+
+// SessionConfig configures OpenSession.
+type SessionConfig struct {
+	// Name names the session in log lines, recordings, and the tmux socket,
+	// as a test's name does for a Terminal. It defaults to "session".
+	Name string
+	// Binary is the program to run, as for Open.
+	Binary string
+	// Options are the options to run it with, as for Open.
+	Options []Option
+	// Log receives strider's log lines, one per line. If nil they are
+	// discarded.
+	Log io.Writer
+}
+
+// A Session drives a TUI program outside go test, for tools such as demo
+// recorders and smoke-test daemons: its methods return errors where a
+// Terminal's fail the test. Create it with OpenSession and end it with Close.
+//
+// A Session runs the program as a Terminal opened with the same options
+// would, and its methods do what the Terminal methods of the same name do.
+// A failure is returned as a *Failure, with the details a Reporter would
+// get; what would skip a test, such as tmux not being found, is an error
+// too. Methods are not safe for concurrent use.
+type Session struct {
+	host   *sessionHost
+	term   *Terminal
+	closed bool
+}
+
+// OpenSession starts cfg.Binary in a new tmux session. It returns an error,
+// leaving nothing running, if the options are invalid or the program cannot
+// be started.
+func OpenSession(cfg SessionConfig) (*Session, error) {
+	if cfg.Name == "" {
+		cfg.Name = "session"
+	}
+	if cfg.Log == nil {
+		cfg.Log = io.Discard
+	}
+	h := &sessionHost{name: cfg.Name, log: cfg.Log}
+	term, err := openTerminal(h, h, cfg.Binary, cfg.Options)
+	if err != nil {
+		h.failed = true
+		_ = h.cleanup()
+		return nil, err
+	}
+	return &Session{host: h, term: term}, nil
+}
+
+// Type sends text literally, as Terminal.Type does.
+func (s *Session) Type(text string) error {
+	return s.do(func() error { return s.term.typeText(text) })
+}
+
+// TypeSecret sends text as Terminal.TypeSecret does, keeping it out of
+// captures and log lines.
+func (s *Session) TypeSecret(text string) error {
+	return s.do(func() error { return s.term.typeSecret(text) })
+}
+
+// Press sends keys, as Terminal.Press does.
+func (s *Session) Press(keys ...Key) error {
+	return s.do(func() error { return s.term.press(keys) })
+}
+
+// Play runs the steps of m, as Terminal.Play does.
+func (s *Session) Play(m Macro) error {
+	return s.do(func() error { return s.term.play(m) })
+}
+
+// Resize changes the terminal dimensions, as Terminal.Resize does.
+func (s *Session) Resize(width, height int) error {
+	return s.do(func() error { return s.term.resize(width, height) })
+}
+
+// Reset restarts the program, as Terminal.Reset does.
+func (s *Session) Reset() error {
+	return s.do(s.term.restart)
+}
+
+// Screen captures the current visible screen, as Terminal.Screen does.
+func (s *Session) Screen() (*Screen, error) {
+	var scr *Screen
+	err := s.do(func() (err error) {
+		scr, err = s.term.screen("capture")
+		return err
+	})
+	return scr, err
+}
+
+// Scrollback captures the full scrollback history, as Terminal.Scrollback
+// does.
+func (s *Session) Scrollback() (*Screen, error) {
+	var scr *Screen
+	err := s.do(func() (err error) {
+		scr, err = s.term.scrollback()
+		return err
+	})
+	return scr, err
+}
+
+// WaitFor waits until the screen satisfies m, as Terminal.WaitFor does. The
+// error describes the timeout or early exit as a test failure would.
+func (s *Session) WaitFor(m Matcher, wopts ...WaitOption) error {
+	_, err := s.WaitForScreen(m, wopts...)
+	return err
+}
+
+// WaitForScreen waits until the screen satisfies m and returns the matching
+// screen, as Terminal.WaitForScreen does.
+func (s *Session) WaitForScreen(m Matcher, wopts ...WaitOption) (*Screen, error) {
+	var scr *Screen
+	err := s.do(func() error {
+		r, err := s.term.waitFor("wait-for", m, wopts...)
+		scr = r.Screen
+		return err
+	})
+	return scr, err
+}
+
+// WaitExit waits for the program to exit and returns its exit status, as
+// Terminal.WaitExit does.
+func (s *Session) WaitExit(wopts ...WaitOption) (int, error) {
+	var code int
+	err := s.do(func() (err error) {
+		code, err = s.term.waitExit("wait-exit", wopts...)
+		return err
+	})
+	return code, err
+}
+
+// Close stops the program and the tmux server and writes the session's
+// artifacts, such as a recording, doing what a Terminal's cleanup does at
+// the end of a test. It returns the errors reported along the way. Calling
+// Close again does nothing.
+func (s *Session) Close() error {
+	if s.closed {
+		return nil
+	}
+	s.closed = true
+	return s.host.cleanup()
+}
+
+// do runs op, unless the session is closed, and returns its failure. A
+// failure also counts for the log lines held by WithQuiet and the recording
+// notice, which are written on Close as at the end of a failed test.
+func (s *Session) do(op func() error) error {
+	if s.closed {
+		return errors.New("strider: session: closed")
+	}
+	err := op()
+	if err != nil {
+		s.host.failed = true
+	}
+	return err
+}
+
+// sessionHost is the host of a Session's Terminal: log lines go to the
+// configured writer, and cleanups run on Close.
+type sessionHost struct {
+	name     string
+	log      io.Writer
+	failed   bool
+	errs     []error // reported by cleanups, for Close
+	cleanups []func()
+}
+
+// cleanup runs the registered cleanups, last registered first, and returns
+// the errors they reported.
+func (h *sessionHost) cleanup() error {
+	for len(h.cleanups) > 0 {
+		n := len(h.cleanups)
+		fn := h.cleanups[n-1]
+		h.cleanups = h.cleanups[:n-1]
+		fn()
+	}
+	return errors.Join(h.errs...)
+}
+
+// Helper does nothing: log lines written to a Session's log have no call
+// site.
+func (h *sessionHost) Helper() {}
+
+func (h *sessionHost) Name() string { return h.name }
+
+func (h *sessionHost) Logf(format string, args ...any) {
+	msg := fmt.Sprintf(format, args...)
+	if !strings.HasSuffix(msg, "\n") {
+		msg += "\n"
+	}
+	_, _ = io.WriteString(h.log, msg)
+}
+
+func (h *sessionHost) Errorf(format string, args ...any) {
+	h.failed = true
+	h.errs = append(h.errs, fmt.Errorf(format, args...))
+}
+
+func (h *sessionHost) Cleanup(fn func()) { h.cleanups = append(h.cleanups, fn) }
+
+func (h *sessionHost) Failed() bool { return h.failed }
@@ -0,0 +1,102 @@
+package strider
+
+import (
+	"crypto/rand"
+	"encoding/hex"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"sync"
+
+	"github.com/cboone/strider/internal/tmuxcli"
+)
+
+// sharedServer is a tmux server that hosts a session for each Terminal
+// opened WithSharedServer, one per tmux binary for the test binary.
+type sharedServer struct {
+	socketPath string
+	configPath string
+}
+
+// sharedServers holds the shared servers started so far, by tmux binary.
+var sharedServers struct {
+	mu      sync.Mutex
+	servers map[string]*sharedServer
+}
+
+// sharedServersByDefault reports whether Terminals use a shared server
+// without WithSharedServer, because STRIDER_SHARED_SERVER is set.
+func sharedServersByDefault() bool {
+	return envTrue("STRIDER_SHARED_SERVER")
+}
+
+// keepaliveScript runs in the shared server's first session. It stops the
+// server once the test binary (%[1]d) exits, so no server outlives the tests
+// even when they are killed, and then removes the config file and socket. It
+// ignores the hangup the server's exit sends it.
+const keepaliveScript = `trap '' HUP; while kill -0 %[1]d 2>/dev/null; do sleep 1; done; %[3]s -S %[4]s kill-server; rm -f %[2]s %[4]s`
+
+// getSharedServer returns the shared server for tmuxPath, starting it if
+// needed.
+func getSharedServer(tmuxPath string) (*sharedServer, error) {
+	sharedServers.mu.Lock()
+	defer sharedServers.mu.Unlock()
+	if s := sharedServers.servers[tmuxPath]; s != nil {
+		return s, nil
+	}
+
+	b := make([]byte, 4)
+	if _, err := rand.Read(b); err != nil {
+		return nil, err
+	}
+	socketPath := filepath.Join(os.TempDir(), fmt.Sprintf("strider-shared-%d-%s.sock", os.Getpid(), hex.EncodeToString(b)))
+	s := &sharedServer{socketPath: socketPath, configPath: socketPath + ".conf"}
+
+	// Options that differ between Terminals are set as each session starts
+	// (see startSharedSession).
+	config := "set-option -g remain-on-exit on\nset-option -g status off\n"
+	if err := os.WriteFile(s.configPath, []byte(config), 0o644); err != nil {
+		return nil, fmt.Errorf("failed to write tmux config: %w", err)
+	}
+	runner := tmuxcli.New(tmuxPath, s.socketPath)
+	runner.SetConfigPath(s.configPath)
+	script := fmt.Sprintf(keepaliveScript, os.Getpid(), shellQuote(s.configPath), shellQuote(tmuxPath), shellQuote(s.socketPath))
+	if _, err := runner.Run("new-session", "-d", "-s", "strider-keepalive", "-x", "10", "-y", "2", "--", "/bin/sh", "-c", script); err != nil {
+		os.Remove(s.configPath)
+		return nil, fmt.Errorf("failed to start the shared tmux server: %w", err)
+	}
+
+	if sharedServers.servers == nil {
+		sharedServers.servers = make(map[string]*sharedServer)
+	}
+	sharedServers.servers[tmuxPath] = s
+	return s, nil
+}
+
+// sharedSessionName returns the session name for a Terminal whose files
+// start with files: tmux replaces dots and colons in session names.
+func sharedSessionName(files string) string {
+	return strings.ReplaceAll(strings.TrimSuffix(filepath.Base(files), ".sock"), ".", "_")
+}
+
+// startSharedSession starts session on a shared server. The server's
+// history limit applies to panes as they are created, and its
+// after-new-session hook cannot know each Terminal's output file, so the
+// limit is set and the output copied in the same command sequence: tmux runs
+// it before reading any output of the new pane or running another client's
+// commands.
+func startSharedSession(runner *tmuxcli.Runner, session, binary, outputPath string, opts options) error {
+	histLimit := opts.historyLimit
+	if histLimit == 0 {
+		histLimit = defaultHistoryLimit
+	}
+	args := []string{"set-option", "-g", "history-limit", strconv.Itoa(histLimit), ";"}
+	args = append(args, newSessionArgs(session, binary, opts)...)
+	args = append(args, ";", "pipe-pane", "-O", "-t", "="+session+":", "exec cat >> "+shellQuote(outputPath))
+	if _, err := runner.Run(args...); err != nil {
+		return fmt.Errorf("strider: open: failed to start tmux session: %w", err)
+	}
+	return nil
+}
@@ -0,0 +1,162 @@
+package strider
+
+import (
+	"crypto/rand"
+	"encoding/hex"
+	"fmt"
+	"regexp"
+	"strings"
+)
+
+// ShellConfig configures a ShellSession for a shell or REPL. The zero value
+// suits POSIX shells such as sh, bash, and zsh.
+type ShellConfig struct {
+	// Prompt is a regular expression matching the last non-blank line of
+	// the screen when the shell waits for input. It defaults to a line
+	// ending in $, #, %, or >.
+	Prompt string
+	// Print returns a statement that prints marker on a line of its own.
+	// The statement must not contain marker as it is, so that the echo of
+	// the typed command cannot be mistaken for its output: split it, as
+	// the default does with printf '%s\n' 'STRIDER-BEGIN-''1f2e'. For
+	// Python:
+	//
+	//	func(m string) string { return fmt.Sprintf("print(%q %q)", m[:4], m[4:]) }
+	Print func(marker string) string
+	// Separator joins statements on one line. It defaults to "; ".
+	Separator string
+}
+
+// defaultShellPrompt matches the usual prompts of shells and REPLs.
+const defaultShellPrompt = `[$#%>]\s*$`
+
+// printShellMarker is the default ShellConfig.Print: printf for POSIX
+// shells, with the marker split by an empty quoted string.
+func printShellMarker(marker string) string {
+	half := len(marker) / 2
+	return "printf '%s\\n' '" + marker[:half] + "''" + marker[half:] + "'"
+}
+
+// A ShellSession runs commands in a shell or REPL running in a Terminal and
+// returns the output of each. It types every command between two statements
+// that print unique markers, waits for the end marker and the next prompt,
+// and returns what the command printed between the markers.
+//
+//	term := strider.Open(t, "/bin/sh")
+//	sh := strider.NewShellSession(term, strider.ShellConfig{})
+//	if out := sh.RunCommand("echo hello"); out != "hello" {
+//		t.Errorf("echo printed %q", out)
+//	}
+type ShellSession struct {
+	term   *Terminal
+	cfg    ShellConfig
+	prompt *regexp.Regexp
+	id     string // distinguishes this session's markers
+	count  int
+}
+
+// NewShellSession returns a ShellSession for the shell running in term,
+// after waiting for its first prompt. It calls t.Fatal if cfg.Prompt is not
+// a valid regular expression.
+func NewShellSession(term *Terminal, cfg ShellConfig) *ShellSession {
+	term.t.Helper()
+	if cfg.Prompt == "" {
+		cfg.Prompt = defaultShellPrompt
+	}
+	if cfg.Print == nil {
+		cfg.Print = printShellMarker
+	}
+	if cfg.Separator == "" {
+		cfg.Separator = "; "
+	}
+	prompt, err := regexp.Compile(cfg.Prompt)
+	if err != nil {
+		term.fatalf("strider: shell: invalid prompt: %v", err)
+	}
+	b := make([]byte, 4)
+	if _, err := rand.Read(b); err != nil {
+		term.fatalf("strider: shell: %v", err)
+	}
+
+	s := &ShellSession{term: term, cfg: cfg, prompt: prompt, id: hex.EncodeToString(b)}
+	term.WaitFor(s.promptAfter(""))
+	return s
+}
+
+// Terminal returns the Terminal the shell runs in, for checks on its
+// screen.
+func (s *ShellSession) Terminal() *Terminal {
+	return s.term
+}
+
+// RunCommand types cmd, waits for it to finish and the prompt to return,
+// and returns what cmd printed, with trailing spaces and the final newline
+// removed. Lines the terminal wrapped are joined again. It fails the test
+// if the prompt does not return within the Terminal's timeout.
+func (s *ShellSession) RunCommand(cmd string) string {
+	s.term.t.Helper()
+	s.count++
+	begin := fmt.Sprintf("STRIDER-BEGIN-%s-%d", s.id, s.count)
+	end := fmt.Sprintf("STRIDER-END-%s-%d", s.id, s.count)
+
+	sep := s.cfg.Separator
+	s.term.Type(s.cfg.Print(begin) + sep + cmd + sep + s.cfg.Print(end))
+	s.term.Press(Enter)
+	s.term.WaitFor(s.promptAfter(end))
+
+	raw, err := s.term.backend.captureScrollback(true)
+	if err != nil {
+		s.term.fatalf("strider: shell: capture: %v", err)
+	}
+	lines := strings.Split(strings.TrimSuffix(s.term.redact(raw), "\n"), "\n")
+	last := -1
+	for i := len(lines) - 1; i >= 0; i-- {
+		if strings.TrimRight(lines[i], " ") == end {
+			last = i
+			break
+		}
+	}
+	first := -1
+	for i := last - 1; i >= 0; i-- {
+		if strings.TrimRight(lines[i], " ") == begin {
+			first = i
+			break
+		}
+	}
+	if last < 0 || first < 0 {
+		s.term.fatalf("strider: shell: output of %q not found in the scrollback; the history limit may be too small (see WithHistoryLimit)", cmd)
+	}
+	out := lines[first+1 : last]
+	for i, line := range out {
+		out[i] = strings.TrimRight(line, " ")
+	}
+	return strings.Join(out, "\n")
+}
+
+// promptAfter matches a screen showing the prompt on its last non-blank
+// line, below a line equal to marker if marker is not "".
+func (s *ShellSession) promptAfter(marker string) Matcher {
+	desc := "shell prompt matching " + s.prompt.String()
+	if marker != "" {
+		desc = "shell prompt after " + marker
+	}
+	return func(scr *Screen) (bool, string) {
+		lines := scr.Lines()
+		last := len(lines) - 1
+		for last >= 0 && strings.TrimSpace(lines[last]) == "" {
+			last--
+		}
+		if last < 0 || !s.prompt.MatchString(strings.TrimRight(lines[last], " ")) {
+			return false, desc
+		}
+		if marker == "" {
+			return true, desc
+		}
+		for _, line := range lines[:last] {
+			if strings.TrimRight(line, " ") == marker {
+				return true, desc
+			}
+		}
+		return false, desc
+	}
+}
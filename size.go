@@ -0,0 +1,57 @@
+package strider
+
+import (
+	"fmt"
+	"testing"
+)
+
+// Size is a terminal geometry in columns and rows.
+type Size struct {
+	Width  int
+	Height int
+}
+
+// Common terminal geometries, for use with WithSizePreset and ForEachSize.
+var (
+	// SizeVT100 is the DEC VT100's 80 columns by 24 rows.
+	SizeVT100 = Size{Width: 80, Height: 24}
+
+	// Size80x24 is the traditional default terminal size (the same as
+	// SizeVT100, and Open's default).
+	Size80x24 = Size{Width: 80, Height: 24}
+
+	// Size132x43 is the VT220-era wide mode with a 43-row display.
+	Size132x43 = Size{Width: 132, Height: 43}
+
+	// SizeiTermDefault is the default window size of iTerm2.
+	SizeiTermDefault = Size{Width: 80, Height: 25}
+)
+
+// String returns the size as "<width>x<height>", for example "80x24".
+func (s Size) String() string {
+	return fmt.Sprintf("%dx%d", s.Width, s.Height)
+}
+
+// ForEachSize runs fn once per size, each time in a subtest named after the
+// size (for example "80x24") with a fresh Terminal running binary at that
+// size. Snapshots taken in fn are stored per subtest, so each size gets its
+// own golden files. opts are applied before the size.
+//
+//	sizes := []strider.Size{
+//		{Width: 40, Height: 12},
+//		{Width: 80, Height: 24},
+//		{Width: 200, Height: 60},
+//	}
+//	strider.ForEachSize(t, sizes, "./my-app", func(t *testing.T, term *strider.Terminal) {
+//		term.WaitFor(strider.Text("Dashboard"))
+//		term.MatchSnapshot("dashboard")
+//	})
+func ForEachSize(t *testing.T, sizes []Size, binary string, fn func(t *testing.T, term *Terminal), opts ...Option) {
+	t.Helper()
+	for _, size := range sizes {
+		t.Run(size.String(), func(t *testing.T) {
+			sizeOpts := append(opts[:len(opts):len(opts)], WithSize(size.Width, size.Height))
+			fn(t, Open(t, binary, sizeOpts...))
+		})
+	}
+}
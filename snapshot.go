@@ -3,61 +3,337 @@ package strider
 import (
 	"crypto/sha256"
 	"encoding/hex"
+	"fmt"
 	"os"
 	"path/filepath"
+	"strconv"
 	"strings"
+	"sync"
 	"testing"
 )
 
 // MatchSnapshot compares the current screen against a golden file
 // stored in testdata/<sanitized-test-name>/<sanitized-name>.txt.
 //
-// Set STRIDER_UPDATE=1 to create or update golden files.
-func (term *Terminal) MatchSnapshot(name string) {
+// Set STRIDER_UPDATE=1 (or pass -strider.update, see RegisterFlags) to
+// create or update golden files. With WithDocCaptures, the screen is also
+// saved for documentation under name (see Capture).
+func (term *Terminal) MatchSnapshot(name string, sopts ...SnapshotOption) {
 	term.t.Helper()
-	scr := term.Screen()
-	scr.MatchSnapshot(term.t, name)
+	scr := term.snapshotScreen(sopts)
+	term.docCapture(name, scr)
+	scr.matchSnapshot(term.t, term, name, snapshotDir(term.t), false, false, sopts)
+}
+
+// ExpectSnapshot compares the current screen against its golden file like
+// MatchSnapshot, but reports a mismatch or a missing golden file with t.Error
+// rather than t.Fatal and returns whether the screen matched, so a test can
+// compare several screens before stopping.
+func (term *Terminal) ExpectSnapshot(name string, sopts ...SnapshotOption) bool {
+	term.t.Helper()
+	scr := term.snapshotScreen(sopts)
+	term.docCapture(name, scr)
+	return scr.matchSnapshot(term.t, term, name, snapshotDir(term.t), false, true, sopts)
 }
 
 // MatchSnapshot on Screen allows snapshotting a previously captured screen.
-func (s *Screen) MatchSnapshot(t testing.TB, name string) {
+func (s *Screen) MatchSnapshot(t testing.TB, name string, sopts ...SnapshotOption) {
+	t.Helper()
+	s.matchSnapshot(t, nil, name, snapshotDir(t), false, false, sopts)
+}
+
+// MatchSnapshotAt compares the current screen against a golden file whose
+// path depends only on key: testdata/snapshots/<sanitized-key>.txt. Unlike
+// MatchSnapshot, several tests may share the golden file, for example
+// table-driven subtests that render identically.
+func (term *Terminal) MatchSnapshotAt(key string, sopts ...SnapshotOption) {
+	term.t.Helper()
+	scr := term.snapshotScreen(sopts)
+	term.docCapture(key, scr)
+	scr.matchSnapshot(term.t, term, key, sharedSnapshotDir, true, false, sopts)
+}
+
+// ExpectSnapshotAt is MatchSnapshotAt with the non-fatal reporting of
+// ExpectSnapshot.
+func (term *Terminal) ExpectSnapshotAt(key string, sopts ...SnapshotOption) bool {
+	term.t.Helper()
+	scr := term.snapshotScreen(sopts)
+	term.docCapture(key, scr)
+	return scr.matchSnapshot(term.t, term, key, sharedSnapshotDir, true, true, sopts)
+}
+
+// MatchSnapshotAt on Screen allows snapshotting a previously captured screen
+// under a key shared between tests.
+func (s *Screen) MatchSnapshotAt(t testing.TB, key string, sopts ...SnapshotOption) {
+	t.Helper()
+	s.matchSnapshot(t, nil, key, sharedSnapshotDir, true, false, sopts)
+}
+
+// sharedSnapshotDir holds the golden files of MatchSnapshotAt.
+var sharedSnapshotDir = filepath.Join("testdata", "snapshots")
+
+// snapshotScreen captures the screen for a snapshot with the given options.
+func (term *Terminal) snapshotScreen(sopts []SnapshotOption) *Screen {
+	term.t.Helper()
+	if newSnapshotOptions(sopts).escaped {
+		return term.captureExact()
+	}
+	return term.Screen()
+}
+
+// newSnapshotOptions applies sopts to the default snapshot options.
+func newSnapshotOptions(sopts []SnapshotOption) snapshotOptions {
+	so := snapshotOptions{}
+	for _, o := range sopts {
+		o(&so)
+	}
+	return so
+}
+
+// matchSnapshot compares s against the golden file for name in dir and
+// reports whether it matched. soft reports a mismatch with t.Error rather
+// than t.Fatal. Failures go through term's Reporter, if term is not nil.
+func (s *Screen) matchSnapshot(t testing.TB, term *Terminal, name, dir string, shared, soft bool, sopts []SnapshotOption) bool {
 	t.Helper()
+	so := newSnapshotOptions(sopts)
+	g := goldenFile{
+		op:     "snapshot",
+		what:   "screen",
+		name:   name,
+		dir:    dir,
+		shared: shared,
+		soft:   soft,
+		canon:  so.canonical(),
+		term:   term,
+		screen: s,
+	}
 
-	// Build snapshot path.
-	dir := snapshotDir(t)
-	sanitized := sanitizeName(name)
-	path := filepath.Join(dir, sanitized+".txt")
+	if so.escaped {
+		g.file = sanitizeName(name) + ".escaped.txt"
+		return matchGolden(t, g, escapeForSnapshot(s))
+	}
 
 	// Normalize screen content for stable diffs:
 	// - Trim trailing spaces on each line
 	// - Remove trailing blank lines
 	// - End with a single newline
-	content := normalizeForSnapshot(s.String())
+	g.file = sanitizeName(name) + ".txt"
+	return matchGolden(t, g, NormalizeScreen(s.String()))
+}
+
+// goldenFile describes a golden file comparison.
+type goldenFile struct {
+	op   string // prefixes failure messages
+	what string // names the content in failure messages
+	name string // snapshot name or key given by the caller
+	dir  string
+	file string
+
+	// shared marks a golden file several tests may use (MatchSnapshotAt).
+	shared bool
+
+	// soft reports a missing golden file or a mismatch with t.Error rather
+	// than t.Fatal (ExpectSnapshot).
+	soft bool
+
+	// canon, when not nil, is applied to both the golden file and the
+	// content before comparing them. Golden files are still written as is.
+	canon func(string) string
+
+	// term, when not nil, reports failures through its Reporter, with
+	// screen, if not nil, as the failure's screen.
+	term   *Terminal
+	screen *Screen
+}
+
+// fail reports a failure of the comparison, stopping the test if fatal.
+func (g goldenFile) fail(t testing.TB, fatal bool, format string, args ...any) {
+	t.Helper()
+	if g.term != nil {
+		f := Failure{Fatal: fatal}
+		if g.screen != nil {
+			f.Screens = []*Screen{g.screen}
+		}
+		g.term.failWith(f, format, args...)
+		return
+	}
+	if fatal {
+		t.Fatalf(format, args...)
+	}
+	t.Errorf(format, args...)
+}
+
+// matchGolden compares content against the golden file described by g,
+// creating or updating it when updates are enabled, and reports whether it
+// matched.
+func matchGolden(t testing.TB, g goldenFile, content string) bool {
+	t.Helper()
+	fail := func(format string, args ...any) {
+		t.Helper()
+		g.fail(t, !g.soft, format, args...)
+	}
 
-	if shouldUpdate() {
+	op, what, name, dir := g.op, g.what, g.name, g.dir
+	path := filepath.Join(dir, g.file)
+	if err := claimGolden(t, path, g, content); err != nil {
+		g.fail(t, true, "strider: %s: %v", op, err)
+	}
+
+	if shouldUpdate(t) {
 		// Create/update golden file.
 		if err := os.MkdirAll(dir, 0o755); err != nil {
-			t.Fatalf("strider: snapshot: failed to create directory: %v", err)
+			g.fail(t, true, "strider: %s: failed to create directory: %v", op, err)
 		}
-		if err := os.WriteFile(path, []byte(content), 0o644); err != nil {
-			t.Fatalf("strider: snapshot: failed to write golden file: %v", err)
+		if err := writeFileAtomic(path, content); err != nil {
+			g.fail(t, true, "strider: %s: failed to write golden file: %v", op, err)
 		}
-		return
+		os.Remove(path + pendingSuffix)
+		return true
 	}
 
 	// Read and compare.
 	golden, err := os.ReadFile(path)
 	if err != nil {
 		if os.IsNotExist(err) {
-			t.Fatalf("strider: snapshot: golden file not found: %s\nRun with STRIDER_UPDATE=1 to create it.\n\nActual screen:\n%s", path, content)
+			pending := writePending(dir, path, content)
+			fail("strider: %s: golden file not found: %s\nRun with STRIDER_UPDATE=1 to create it.%s\n\nActual %s:\n%s", op, path, pending, what, content)
+			return false
 		}
-		t.Fatalf("strider: snapshot: failed to read golden file: %v", err)
+		g.fail(t, true, "strider: %s: failed to read golden file: %v", op, err)
 	}
 
-	if string(golden) != content {
-		t.Fatalf("strider: snapshot: mismatch for %q\nGolden file: %s\nRun with STRIDER_UPDATE=1 to update.\n\n--- golden ---\n%s\n--- actual ---\n%s",
-			name, path, string(golden), content)
+	if !goldenEqual(string(golden), content, g.canon) {
+		pending := writePending(dir, path, content)
+		fail("strider: %s: mismatch for %q\nGolden file: %s\nRun with STRIDER_UPDATE=1 to update.%s\n\n--- golden ---\n%s\n--- actual ---\n%s",
+			op, name, path, pending, string(golden), content)
+		return false
 	}
+
+	// A stale pending file from an earlier failing run no longer applies.
+	os.Remove(path + pendingSuffix)
+	return true
+}
+
+// goldenEqual reports whether golden and content match, after canon when it
+// is not nil.
+func goldenEqual(golden, content string, canon func(string) string) bool {
+	if canon == nil {
+		return golden == content
+	}
+	return golden == content || canon(golden) == canon(content)
+}
+
+// pendingSuffix is appended to a golden file path to name the file holding
+// the actual content of a failed comparison, for review with
+// "strider snapshots".
+const pendingSuffix = ".new"
+
+// writePending records the actual content of a failed comparison next to
+// the golden file so it can be reviewed and accepted later. Best-effort:
+// returns the text to add to the failure message, or "" if the pending file
+// could not be written.
+func writePending(dir, path, content string) string {
+	if err := os.MkdirAll(dir, 0o755); err != nil {
+		return ""
+	}
+	if err := writeFileAtomic(path+pendingSuffix, content); err != nil {
+		return ""
+	}
+	return "\nPending snapshot: " + path + pendingSuffix +
+		"\nReview with: go run github.com/cboone/strider/cmd/strider snapshots"
+}
+
+// goldenClaim records which test uses a golden file, under which snapshot
+// name or key, and, for shared golden files, with which content.
+type goldenClaim struct {
+	t       testing.TB
+	name    string
+	shared  bool
+	content string
+}
+
+var (
+	goldenClaimsMu sync.Mutex
+	goldenClaims   = map[string]goldenClaim{} // keyed by absolute path
+)
+
+// claimGolden records that t uses the golden file at path for g, and
+// returns an error if the comparison would clash with another one:
+//
+//   - a different test still running, or a different snapshot of the same
+//     test, uses the file: with parallel updates, the two would overwrite
+//     each other's golden file. The claim is released when t finishes, so
+//     reruns with -count are not collisions.
+//   - for shared golden files (MatchSnapshotAt), a different key maps to the
+//     same file, or another test rendered the same key differently. Shared
+//     claims last for the whole test binary run.
+func claimGolden(t testing.TB, path string, g goldenFile, content string) error {
+	abs, err := filepath.Abs(path)
+	if err != nil {
+		return err
+	}
+
+	goldenClaimsMu.Lock()
+	defer goldenClaimsMu.Unlock()
+	if c, ok := goldenClaims[abs]; ok {
+		switch {
+		case c.name != g.name && c.t == t:
+			return fmt.Errorf("golden file %s is used by two snapshots of %s: %q and %q; use names that differ after sanitization",
+				path, t.Name(), c.name, g.name)
+		case c.name != g.name && c.shared:
+			return fmt.Errorf("golden file %s is used by two snapshot keys: %q (in %s) and %q (in %s); use keys that differ after sanitization",
+				path, c.name, c.t.Name(), g.name, t.Name())
+		case c.shared && c.content != content:
+			return fmt.Errorf("snapshot key %q is rendered differently by %s and %s\n\n--- %s ---\n%s\n--- %s ---\n%s",
+				g.name, c.t.Name(), t.Name(), c.t.Name(), c.content, t.Name(), content)
+		case !c.shared && c.t != t:
+			return fmt.Errorf("golden file %s is used by two tests: %s (snapshot %q) and %s (snapshot %q)",
+				path, c.t.Name(), c.name, t.Name(), g.name)
+		}
+		return nil
+	}
+
+	goldenClaims[abs] = goldenClaim{t: t, name: g.name, shared: g.shared, content: content}
+	if g.shared {
+		return nil
+	}
+	t.Cleanup(func() {
+		goldenClaimsMu.Lock()
+		defer goldenClaimsMu.Unlock()
+		if goldenClaims[abs].t == t {
+			delete(goldenClaims, abs)
+		}
+	})
+	return nil
+}
+
+// writeFileAtomic writes content to path through a temporary file in the
+// same directory and a rename, so readers and concurrent writers never see
+// a partially written file.
+func writeFileAtomic(path, content string) error {
+	f, err := os.CreateTemp(filepath.Dir(path), "."+filepath.Base(path)+".tmp-*")
+	if err != nil {
+		return err
+	}
+	tmp := f.Name()
+	if _, err := f.WriteString(content); err != nil {
+		f.Close()
+		os.Remove(tmp)
+		return err
+	}
+	if err := f.Close(); err != nil {
+		os.Remove(tmp)
+		return err
+	}
+	if err := os.Chmod(tmp, 0o644); err != nil {
+		os.Remove(tmp)
+		return err
+	}
+	if err := os.Rename(tmp, path); err != nil {
+		os.Remove(tmp)
+		return err
+	}
+	return nil
 }
 
 // snapshotDir returns the directory for golden files for the current test.
@@ -75,27 +351,31 @@ func snapshotDir(t testing.TB) string {
 	return filepath.Join("testdata", sanitized+"-"+hash)
 }
 
-// normalizeForSnapshot normalizes screen content for stable golden file diffs.
-func normalizeForSnapshot(raw string) string {
-	lines := strings.Split(raw, "\n")
-
-	// Trim trailing spaces on each line.
-	for i, l := range lines {
-		lines[i] = strings.TrimRight(l, " ")
-	}
+// escapedHeader starts every golden file in the escaped format.
+const escapedHeader = "strider escaped snapshot"
 
-	// Remove trailing blank lines.
-	for len(lines) > 0 && lines[len(lines)-1] == "" {
-		lines = lines[:len(lines)-1]
+// escapeForSnapshot renders s in the escaped golden format: a header with
+// the screen size, then every row, including trailing blank rows, as a
+// Go-quoted string on its own line.
+func escapeForSnapshot(s *Screen) string {
+	var b strings.Builder
+	fmt.Fprintf(&b, "%s %dx%d\n", escapedHeader, s.width, s.height)
+	for _, line := range s.lines {
+		b.WriteString(strconv.Quote(line))
+		b.WriteByte('\n')
 	}
-
-	// End with a single newline.
-	return strings.Join(lines, "\n") + "\n"
+	return b.String()
 }
 
-// shouldUpdate returns true if STRIDER_UPDATE is set to a truthy value.
-func shouldUpdate() bool {
-	v := os.Getenv("STRIDER_UPDATE")
-	return v == "1" || v == "true" || v == "yes"
+// shouldUpdate returns true if golden files should be written for the
+// current test: STRIDER_UPDATE is set to a truthy value, -strider.update was
+// passed, or the test name matches -strider.run-pattern-update.
+func shouldUpdate(t testing.TB) bool {
+	if flagConfig.update {
+		return true
+	}
+	if flagConfig.updatePattern != nil && flagConfig.updatePattern.MatchString(t.Name()) {
+		return true
+	}
+	return envTrue("STRIDER_UPDATE")
 }
-
@@ -1,130 +1,695 @@
 package strider
 
 import (
+	"errors"
 	"fmt"
+	"maps"
 	"os"
+	"path/filepath"
+	"regexp"
+	"slices"
 	"strings"
+	"syscall"
 	"testing"
 	"time"
 
+	"github.com/cboone/strider/ansi"
+	"github.com/cboone/strider/internal/pty"
+	"github.com/cboone/strider/internal/terminfo"
 	"github.com/cboone/strider/internal/tmuxcli"
 )
 
 // Terminal is a handle to a TUI program running inside a tmux session.
 // It is created with Open and cleaned up automatically via t.Cleanup.
 type Terminal struct {
-	t          testing.TB
-	runner     *tmuxcli.Runner
-	socketPath string
-	pane       string
-	opts       options
+	// t is the test failures are reported to, or nil for a Session's
+	// Terminal, whose operations return them. host is the test or Session
+	// the Terminal logs to.
+	t       testing.TB
+	host    host
+	backend backend
+	opts    options
+
+	// files is the path prefix of the Terminal's own files, such as the
+	// copy of its output.
+	files string
+
+	// binary is the program as passed to Open, command the argv started in
+	// the pane (after any /usr/bin/env wrapping), and openOpts the options
+	// as resolved by Open. Reset uses them to restart the program in its
+	// original configuration.
+	binary   string
+	command  []string
+	openOpts options
+
+	// userOpts are the options passed to Open, for Reopen.
+	userOpts []Option
+
+	// steps is the stack of names of the Step calls in progress.
+	steps []string
+
+	// transcript records the session for WithTranscript, or is nil.
+	transcript *strings.Builder
+
+	// output follows the program's output (see ClearCount).
+	output outputLog
+
+	// nextInput is when WithMaxInputRate next allows a key to be sent.
+	nextInput time.Time
+
+	// log writes strider's own log lines (see WithQuiet).
+	log *logger
+
+	// secrets are the values typed with TypeSecret, and redactPatterns the
+	// patterns of WithRedact, redacted from captures and failure output.
+	// Terminals borrowed from a Pool share the pooled Terminal's secrets,
+	// which its recording and transcript are redacted with.
+	secrets        *secretSet
+	redactPatterns []*regexp.Regexp
+
+	// recording follows the output for WithRecording, or is nil.
+	recording *recording
+}
+
+// host is what a Terminal needs from the test it runs for, or from its
+// Session: a name, a log, and cleanups to run when it ends. testing.TB
+// implements it.
+type host interface {
+	Helper()
+	Name() string
+	Logf(format string, args ...any)
+	Errorf(format string, args ...any)
+	Cleanup(fn func())
+	Failed() bool
+}
+
+// tempDir returns a new directory, removed by h's cleanup: t.TempDir for a
+// test.
+func tempDir(h host) (string, error) {
+	if t, ok := h.(testing.TB); ok {
+		return t.TempDir(), nil
+	}
+	dir, err := os.MkdirTemp("", "strider-"+sanitizeName(h.Name())+"-*")
+	if err != nil {
+		return "", err
+	}
+	h.Cleanup(func() { os.RemoveAll(dir) })
+	return dir, nil
 }
 
 const failureCaptureHistory = 3
 
+// exitScrollbackTail is the minimum number of scrollback lines included when
+// the program exits unexpectedly.
+const exitScrollbackTail = 20
+
 // Open starts the binary in a new tmux session.
 // Cleanup is automatic via t.Cleanup — no defer needed.
+//
+// When binary is a path (it contains a slash) rather than a name looked up
+// in $PATH, Open first checks that it exists and is executable, resolving
+// relative paths against the working directory set by WithDir or
+// WithTempWorkdir.
 func Open(t testing.TB, binary string, userOpts ...Option) *Terminal {
 	t.Helper()
+	return open(t, t, binary, userOpts)
+}
+
+// open starts the binary like Open. Failures are reported to t, while the
+// server's lifetime (cleanup) is tied to owner. They differ for pooled
+// terminals, which outlive the test that first borrows them.
+func open(t, owner testing.TB, binary string, userOpts []Option) *Terminal {
+	t.Helper()
+	term, err := openTerminal(t, owner, binary, userOpts)
+	if term == nil {
+		var skip *skipError
+		if errors.As(err, &skip) {
+			t.Skip(skip.msg)
+		}
+		t.Fatal(err)
+	}
+	term.t = t
+	if name := term.opts.transcript; name != "" {
+		t.Cleanup(func() { term.matchTranscript(name) })
+	}
+	term.check(err)
+	return term
+}
+
+// openTerminal starts the binary for open and OpenSession, logging to h.
+// It returns the Terminal along with the error if the program started but
+// did not become ready (see WithReadyWhen).
+func openTerminal(h, owner host, binary string, userOpts []Option) (*Terminal, error) {
+	h.Helper()
 
 	opts := defaultOptions()
+	applyEnvProfile(&opts)
 	for _, o := range userOpts {
 		o(&opts)
 	}
+	applyEnvBackend(&opts)
+	if err := opts.validate(); err != nil {
+		return nil, fmt.Errorf("strider: open: %v", err)
+	}
 
-	// Resolve and verify tmux.
-	tmuxPath, explicit := resolveTmuxPath(t, opts.tmuxPath)
-	checkTmuxVersion(t, tmuxPath, explicit)
+	// Resolve and verify tmux, unless the PTY backend runs the program.
+	strict := opts.strictEnvironment || strictByDefault()
+	kind, tmuxPath, err := resolveBackend(opts, strict)
+	if err != nil {
+		return nil, err
+	}
 
-	// Generate socket path.
-	socketPath := generateSocketPath(t)
+	// Log the seed so it shows up in the output of a failing test.
+	log := newLogger(resolveLogLevel(opts))
+	if opts.randomSeed {
+		seed, err := chooseSeed()
+		if err != nil {
+			return nil, fmt.Errorf("strider: open: %v", err)
+		}
+		opts.seed = &seed
+		log.infof(h, "strider: seed %d (reproduce with STRIDER_SEED=%d)", seed, seed)
+	} else if opts.seed != nil {
+		log.infof(h, "strider: seed %d", *opts.seed)
+	}
 
-	// Create runner.
-	runner := tmuxcli.New(tmuxPath, socketPath)
+	if opts.tempWorkdir {
+		dir, err := tempDir(owner)
+		if err == nil {
+			err = writeWorkdirFiles(dir, opts.tempWorkdirFiles)
+		}
+		if err != nil {
+			return nil, fmt.Errorf("strider: open: %v", err)
+		}
+		opts.dir = dir
+	}
+
+	if opts.pinnedTerminfo {
+		dir, err := installTerminfo(owner)
+		if err != nil {
+			return nil, err
+		}
+		opts.terminfoDir = dir
+	}
+
+	if err := checkBinary(binary, opts.dir); err != nil {
+		return nil, fmt.Errorf("strider: open: %v", err)
+	}
 
-	// For environment variables, wrap the binary in /usr/bin/env.
+	var sandboxSelf string
+	if opts.sandbox != nil {
+		if sandboxSelf, err = requireSandbox(strict); err != nil {
+			return nil, err
+		}
+	}
+	var unsharePath string
+	if opts.noNetwork {
+		if unsharePath, err = requireNetworkNamespace(strict); err != nil {
+			return nil, err
+		}
+	}
+	var suPath string
+	var suPrefix []string
+	if opts.user != "" {
+		if suPath, suPrefix, err = requireUserSwitch(opts.user, strict); err != nil {
+			return nil, err
+		}
+	}
+
+	// Wait for a free server slot (see SetMaxConcurrent). The release is
+	// registered first so it runs after the server is killed.
+	if err := acquireServerSlot(owner, opts.timeout); err != nil {
+		return nil, fmt.Errorf("strider: open: %v", err)
+	}
+	owner.Cleanup(func() { releaseServerSlot(owner) })
+
+	// Generate socket path. It also names the Terminal's files, and on a
+	// shared server its session.
+	files, err := generateSocketPath(h.Name())
+	if err != nil {
+		return nil, err
+	}
+	suiteStats.terminals.Add(1)
+
+	// For a sandbox, run the binary through the test binary, which applies
+	// it. The resource limit shell still writes its status file outside.
 	actualBinary := binary
 	actualArgs := opts.args
-	if len(opts.env) > 0 {
-		actualArgs = make([]string, 0, len(opts.env)+1+len(opts.args))
-		actualArgs = append(actualArgs, opts.env...)
-		actualArgs = append(actualArgs, binary)
-		actualArgs = append(actualArgs, opts.args...)
-		actualBinary = "/usr/bin/env"
+	if opts.sandbox != nil {
+		rules, err := sandboxRulesFor(*opts.sandbox, opts.dir)
+		if err == nil {
+			actualBinary, actualArgs, err = sandboxCommand(sandboxSelf, rules, actualBinary, actualArgs)
+		}
+		if err != nil {
+			return nil, fmt.Errorf("strider: open: WithSandbox: %v", err)
+		}
 	}
 
-	optsForSession := opts
-	optsForSession.args = actualArgs
+	// For resource limits, wrap the binary in a shell that applies them.
+	if opts.hasLimits() {
+		actualBinary, actualArgs = limitCommand(actualBinary, actualArgs, opts, files+".status")
+	}
+
+	// For environment variables, wrap the command in /usr/bin/env.
+	env := childEnv(opts)
+	if len(env) > 0 {
+		wrapped := make([]string, 0, len(env)+1+len(actualArgs))
+		wrapped = append(wrapped, env...)
+		wrapped = append(wrapped, actualBinary)
+		wrapped = append(wrapped, actualArgs...)
+		actualBinary, actualArgs = "/usr/bin/env", wrapped
+	}
+
+	// To run as another user, switch users inside the network namespace.
+	if opts.user != "" {
+		actualBinary, actualArgs = userCommand(suPath, suPrefix, actualBinary, actualArgs)
+	}
+
+	// For network isolation, run everything in a new network namespace.
+	if opts.noNetwork {
+		actualBinary, actualArgs = networkCommand(unsharePath, actualBinary, actualArgs)
+	}
 
-	// Write tmux config file and set it on the runner.
-	configPath := socketPath + ".conf"
-	if err := writeConfig(configPath, opts); err != nil {
-		t.Fatalf("%v", err)
+	var b backend
+	if kind == PTY {
+		b, err = openPTY(owner, files, append([]string{actualBinary}, actualArgs...), opts, strict)
+	} else {
+		b, err = openTmux(h, owner, tmuxPath, files, actualBinary, actualArgs, opts, log)
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	term := &Terminal{
+		host:     h,
+		backend:  b,
+		files:    files,
+		opts:     opts,
+		binary:   binary,
+		command:  append([]string{actualBinary}, actualArgs...),
+		openOpts: opts,
+		userOpts: userOpts,
+		output:   outputLog{path: files + outputSuffix},
+		log:      log,
+		secrets:  &secretSet{},
+	}
+	for _, p := range opts.redact {
+		term.redactPatterns = append(term.redactPatterns, regexp.MustCompile(p)) // checked by validate
 	}
-	runner.SetConfigPath(configPath)
 
-	if err := startSession(runner, actualBinary, optsForSession); err != nil {
-		t.Fatalf("%v", err)
+	if path := recordingPath(h.Name(), opts); path != "" {
+		term.startRecording(owner, path)
 	}
 
-	// Wait for the session to be ready.
-	if err := runner.WaitForSession(5 * time.Second); err != nil {
-		t.Fatalf("strider: open: %v", err)
+	if opts.transcript != "" {
+		term.transcript = &strings.Builder{}
+	}
+
+	return term, term.waitReady("open")
+}
+
+// installTerminfo installs the pinned terminfo entry in a new directory
+// removed by owner's cleanup, and returns the directory.
+func installTerminfo(owner host) (string, error) {
+	dir, err := tempDir(owner)
+	if err == nil {
+		dir = filepath.Join(dir, "terminfo")
+		err = terminfo.Install(dir)
+	}
+	if err != nil {
+		return "", fmt.Errorf("strider: open: installing the pinned terminfo entry: %v", err)
+	}
+	return dir, nil
+}
+
+// openTmux starts the command in a new tmux session, on the Terminal's own
+// server or the shared one, and registers the cleanup that stops it.
+func openTmux(h, owner host, tmuxPath, files, binary string, args []string, opts options, log *logger) (*tmuxBackend, error) {
+	h.Helper()
+
+	socketPath := files
+	var session string
+	if opts.sharedServer || sharedServersByDefault() {
+		server, err := getSharedServer(tmuxPath)
+		if err != nil {
+			return nil, fmt.Errorf("strider: open: %v", err)
+		}
+		socketPath = server.socketPath
+		session = sharedSessionName(files)
+	}
+
+	// Create runner.
+	runner := tmuxcli.New(tmuxPath, socketPath)
+
+	optsForSession := opts
+	optsForSession.args = args
+
+	// Write tmux config file and set it on the runner. A shared server
+	// was started with its own.
+	configPath := files + ".conf"
+	outputPath := files + outputSuffix
+	if session == "" {
+		if err := writeConfig(configPath, outputPath, opts); err != nil {
+			return nil, err
+		}
+		runner.SetConfigPath(configPath)
+
+		if err := startSession(runner, binary, optsForSession); err != nil {
+			return nil, err
+		}
+
+		// Wait for the session to be ready.
+		if err := runner.WaitForSession(5 * time.Second); err != nil {
+			return nil, fmt.Errorf("strider: open: %v", err)
+		}
+	} else if err := startSharedSession(runner, session, binary, outputPath, optsForSession); err != nil {
+		return nil, err
 	}
 
 	// Get the pane ID.
-	output, err := runner.Run("list-panes", "-F", "#{pane_id}")
+	listArgs := []string{"list-panes", "-F", "#{pane_id}"}
+	if session != "" {
+		listArgs = append(listArgs, "-t", "="+session)
+	}
+	output, err := runner.Run(listArgs...)
 	if err != nil {
-		t.Fatalf("strider: open: failed to get pane ID: %v", err)
+		return nil, fmt.Errorf("strider: open: failed to get pane ID: %v", err)
 	}
 	pane := strings.TrimSpace(output)
 
-	term := &Terminal{
-		t:          t,
-		runner:     runner,
-		socketPath: socketPath,
-		pane:       pane,
-		opts:       opts,
+	// Send later commands through one control-mode client. Without it,
+	// each command starts a tmux process.
+	if !opts.noControlMode {
+		if err := runner.StartControl(session); err != nil {
+			log.debugf(h, "strider: open: control mode unavailable, starting tmux for each command: %v", err)
+		}
 	}
 
 	// Register cleanup.
-	t.Cleanup(func() {
-		_ = killServer(runner)
+	owner.Cleanup(func() {
+		if flagConfig.keep {
+			runner.Close()
+			if session != "" {
+				owner.Logf("strider: keep: session left running on the shared tmux server until the test binary exits; attach with: %s -S %s attach -t %s", tmuxPath, socketPath, session)
+				return
+			}
+			owner.Logf("strider: keep: tmux server left running; attach with: %s -S %s attach", tmuxPath, socketPath)
+			return
+		}
+		_ = stopSession(runner, session)
 		os.Remove(configPath)
+		os.Remove(files + ".status")
+		os.Remove(outputPath)
 	})
 
-	return term
+	return &tmuxBackend{runner: runner, pane: pane, session: session}, nil
+}
+
+// openPTY starts command on a pseudo-terminal and registers the cleanup that
+// stops it. Where the PTY backend is not supported, the error skips the
+// test, or fails it when strict.
+//
+// The program sees TERM=tmux-256color, as in tmux, with the pinned terminfo
+// entry (see WithPinnedTerminfo), since a host without tmux may not have
+// one. Entries from childEnv take precedence.
+func openPTY(owner host, files string, command []string, opts options, strict bool) (*ptyBackend, error) {
+	terminfoDir := opts.terminfoDir
+	if terminfoDir == "" {
+		dir, err := installTerminfo(owner)
+		if err != nil {
+			return nil, err
+		}
+		terminfoDir = dir
+	}
+	env := append(ptyEnviron(), "TERM="+terminfo.Name, "TERMINFO="+terminfoDir)
+
+	historyLimit := opts.historyLimit
+	if historyLimit == 0 {
+		historyLimit = defaultHistoryLimit
+	}
+	outputPath := files + outputSuffix
+	b, err := startPTY(opts.dir, command, env, opts.width, opts.height, historyLimit, outputPath)
+	if errors.Is(err, pty.ErrUnsupported) {
+		return nil, environmentError(strict, "strider: open: WithBackend(PTY): %v", err)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("strider: open: starting %s on a pseudo-terminal: %v", command[0], err)
+	}
+
+	owner.Cleanup(func() {
+		_ = b.stop()
+		os.Remove(files + ".status")
+		os.Remove(outputPath)
+	})
+	return b, nil
+}
+
+// waitReady blocks until the WithReadyWhen matcher succeeds, if one is set,
+// and returns the failure if it does not. op names the operation that
+// (re)started the program.
+func (term *Terminal) waitReady(op string) error {
+	term.host.Helper()
+	if term.opts.readyWhen == nil {
+		return nil
+	}
+	_, err := term.waitFor(op+": ready-when", term.opts.readyWhen)
+	return err
+}
+
+// Dir returns the working directory of the program: the directory created by
+// WithTempWorkdir, the directory set with WithDir, or "" if neither was used
+// (the program inherits the tmux server's working directory).
+func (term *Terminal) Dir() string {
+	return term.opts.dir
+}
+
+// Seed returns the seed exported to the program as STRIDER_SEED, and
+// whether WithSeed or WithRandomSeed was used.
+func (term *Terminal) Seed() (int64, bool) {
+	if term.opts.seed == nil {
+		return 0, false
+	}
+	return *term.opts.seed, true
+}
+
+// Reset restarts the program in the existing tmux session instead of
+// starting a new server: the pane's process is killed and respawned with the
+// original command, the scrollback history is cleared, and the terminal is
+// resized back to the size it was opened with. Like Open, it then waits for
+// the WithReadyWhen matcher, if set.
+func (term *Terminal) Reset() {
+	term.t.Helper()
+	term.check(term.restart())
+}
+
+// restart is Reset, returning its failure.
+func (term *Terminal) restart() error {
+	term.host.Helper()
+	term.record("reset")
+	if err := term.reset(); err != nil {
+		return term.failf("strider: reset: %v", err)
+	}
+	return term.waitReady("reset")
+}
+
+// Reopen starts the program again in a new tmux session, configured with
+// the options term was opened with followed by opts, and returns the new
+// Terminal. term keeps running. Use it for before/after comparisons, such as
+// the same screen at another size or with an environment variable changed,
+// without repeating the option list:
+//
+//	narrow := term.Reopen(strider.WithSize(40, 24))
+//
+// The new session uses the seed term uses, if any, so that WithRandomSeed
+// picks the same one. Options that create resources are applied again:
+// WithTempWorkdir creates a new directory with the same files. To record
+// WithTranscript only for the first session, pass WithTranscript("").
+func (term *Terminal) Reopen(opts ...Option) *Terminal {
+	term.t.Helper()
+	reopenOpts := slices.Clone(term.userOpts)
+	if term.opts.seed != nil {
+		reopenOpts = append(reopenOpts, WithSeed(*term.opts.seed))
+	}
+	return open(term.t, term.t, term.binary, append(reopenOpts, opts...))
+}
+
+func (term *Terminal) reset() error {
+	os.Remove(term.statusPath())
+	if err := term.output.update(); err != nil {
+		return err
+	}
+	term.output.clearsBase = term.output.clears
+	if err := term.backend.respawn(term.openOpts.dir, term.command); err != nil {
+		return err
+	}
+	if err := term.backend.clearHistory(); err != nil {
+		return err
+	}
+	if term.opts.width != term.openOpts.width || term.opts.height != term.openOpts.height {
+		if err := term.backend.resize(term.openOpts.width, term.openOpts.height); err != nil {
+			return err
+		}
+		term.opts.width = term.openOpts.width
+		term.opts.height = term.openOpts.height
+	}
+	return nil
 }
 
 // SendKeys sends raw tmux key sequences. Escape hatch for advanced use.
+// Like Press, it fails the test if a key is not one tmux recognizes (see
+// ValidateKey), unless the arguments include send-keys flags such as -l.
 func (term *Terminal) SendKeys(keys ...string) {
 	term.t.Helper()
-	term.requireAlive("send-keys")
-	if err := sendKeys(term.runner, term.pane, keys); err != nil {
-		term.t.Fatalf("strider: send-keys: %v", err)
+	if !slices.ContainsFunc(keys, isSendKeysFlag) {
+		for _, k := range keys {
+			if err := ValidateKey(Key(k)); err != nil {
+				term.fatalf("strider: send-keys: %v", err)
+			}
+		}
 	}
+	term.record("send-keys %s", strings.Join(keys, " "))
+	term.check(term.sendKeys(keys))
+}
+
+// isSendKeysFlag reports whether arg passed to SendKeys is a send-keys
+// flag, which changes how the keys after it are read.
+func isSendKeysFlag(arg string) bool {
+	return len(arg) > 1 && arg[0] == '-'
+}
+
+// sendKeys sends keys to the running program, returning the failure if it
+// has exited or the keys cannot be sent.
+func (term *Terminal) sendKeys(keys []string) error {
+	if err := term.checkAlive("send-keys"); err != nil {
+		return err
+	}
+	if term.opts.maxInputRate == 0 {
+		if err := term.backend.sendKeys(keys); err != nil {
+			return term.failf("strider: send-keys: %v", err)
+		}
+		return nil
+	}
+	for _, k := range keys {
+		term.paceInput()
+		if err := term.backend.sendKeys([]string{k}); err != nil {
+			return term.failf("strider: send-keys: %v", err)
+		}
+	}
+	return nil
 }
 
 // Type sends a string as sequential keypresses.
 func (term *Terminal) Type(s string) {
 	term.t.Helper()
-	term.requireAlive("send-keys")
+	term.check(term.typeText(s))
+}
+
+// typeText is Type, returning its failure.
+func (term *Terminal) typeText(s string) error {
+	term.host.Helper()
+	term.record("type %q", s)
+	return term.typeLiteral(s)
+}
 
-	// Send the string literally via tmux send-keys -l (literal mode).
-	args := []string{"send-keys", "-t", term.pane, "-l", s}
-	if _, err := term.runner.Run(args...); err != nil {
-		term.t.Fatalf("strider: send-keys: %v", err)
+// typeLiteral sends s as sequential keypresses, without recording it.
+func (term *Terminal) typeLiteral(s string) error {
+	if err := term.checkAlive("send-keys"); err != nil {
+		return err
 	}
+
+	if term.opts.maxInputRate == 0 {
+		if err := term.backend.sendLiteral(s); err != nil {
+			return term.failf("strider: send-keys: %v", err)
+		}
+		return nil
+	}
+	for _, r := range s {
+		term.paceInput()
+		if err := term.backend.sendLiteral(string(r)); err != nil {
+			return term.failf("strider: send-keys: %v", err)
+		}
+	}
+	return nil
 }
 
-// Press sends one or more special keys.
+// paceInput blocks until the next key may be sent under WithMaxInputRate.
+// The pace carries over between calls, so consecutive calls are paced as
+// one stream of keys.
+func (term *Terminal) paceInput() {
+	now := time.Now()
+	if wait := term.nextInput.Sub(now); wait > 0 {
+		time.Sleep(wait)
+		now = term.nextInput
+	}
+	term.nextInput = now.Add(time.Second / time.Duration(term.opts.maxInputRate))
+}
+
+// Press sends one or more special keys. It fails the test, suggesting the
+// closest key name, if a key is not one tmux recognizes (see ValidateKey).
 func (term *Terminal) Press(keys ...Key) {
 	term.t.Helper()
+	term.check(term.press(keys))
+}
+
+// press is Press, returning its failure.
+func (term *Terminal) press(keys []Key) error {
+	term.host.Helper()
 	strs := make([]string, len(keys))
 	for i, k := range keys {
+		if err := ValidateKey(k); err != nil {
+			return term.failf("strider: press: %v", err)
+		}
 		strs[i] = string(k)
 	}
-	term.SendKeys(strs...)
+	term.record("press %s", strings.Join(strs, " "))
+	return term.sendKeys(strs)
+}
+
+// Do presses the keys bound to the named actions in the Keymap set with
+// WithKeymap. It calls t.Fatal, listing the known actions, if an action is
+// not in the keymap.
+func (term *Terminal) Do(actions ...string) {
+	term.t.Helper()
+	keys := make([]Key, len(actions))
+	for i, action := range actions {
+		k, ok := term.opts.keymap[action]
+		if !ok {
+			term.fatalf("strider: do: no key bound to action %q (known actions: %s)",
+				action, strings.Join(slices.Sorted(maps.Keys(term.opts.keymap)), ", "))
+		}
+		keys[i] = k
+	}
+	term.Press(keys...)
+}
+
+// Step runs fn as a named step of a longer scenario. The step is logged when
+// it starts, and if the test fails during fn (through strider or through
+// the test's own assertions), a line naming the failed step follows the
+// failure. Steps can be nested; nested names are joined with " > ".
+//
+//	term.Step("log in", func() {
+//		term.Type("alice")
+//		term.Press(strider.Enter)
+//		term.WaitFor(strider.Text("Welcome, alice"))
+//	})
+func (term *Terminal) Step(name string, fn func()) {
+	term.t.Helper()
+
+	term.steps = append(term.steps, name)
+	path := strings.Join(term.steps, " > ")
+	failedBefore := term.t.Failed()
+	term.infof("strider: step %q", path)
+
+	// fn may end in t.FailNow, which unwinds through this deferred call.
+	finished := false
+	defer func() {
+		term.steps = term.steps[:len(term.steps)-1]
+		if !finished || (!failedBefore && term.t.Failed()) {
+			term.logf("strider: step %q failed", path)
+		}
+	}()
+
+	fn()
+	finished = true
 }
 
 // Screen captures the current terminal content and returns it.
@@ -136,47 +701,165 @@ func (term *Terminal) Screen() *Screen {
 // captureScreen captures the current screen content and cursor position.
 func (term *Terminal) captureScreen(op string) *Screen {
 	term.t.Helper()
-	term.requireAlive(op)
+	scr, err := term.screen(op)
+	term.check(err)
+	return scr
+}
+
+// screen is captureScreen, returning its failure.
+func (term *Terminal) screen(op string) (*Screen, error) {
+	scr, state, err := term.capturePaneState()
+	if err != nil {
+		return nil, term.failf("strider: %s: %v", op, err)
+	}
+	if state.dead {
+		return nil, term.exitedError(op, state.exitStatus)
+	}
+	return scr, nil
+}
+
+// capturePane captures the visible screen, with styles if the Terminal was
+// opened WithStyles, and records the pane geometry on it.
+func (term *Terminal) capturePane() (*Screen, error) {
+	scr, _, err := term.capturePaneState()
+	return scr, err
+}
+
+// capturePaneState is capturePane, also returning the pane state queried
+// with the capture.
+func (term *Terminal) capturePaneState() (*Screen, paneState, error) {
+	raw, state, g, err := term.backend.capture(term.opts.styles)
+	if err != nil {
+		return nil, paneState{}, err
+	}
+
+	var scr *Screen
+	if !term.opts.styles {
+		scr = newScreen(term.redact(raw), term.opts.width, term.opts.height)
+	} else {
+		styled := term.redact(raw)
+		scr = newScreen(term.redact(ansi.Strip(styled)), term.opts.width, term.opts.height)
+		scr.hasStyles = true
+		scr.styled = strings.TrimSuffix(strings.ReplaceAll(styled, "\r\n", "\n"), "\n")
+	}
+	scr.setPaneGeometry(g)
+	return scr, state, nil
+}
+
+// InAlternateScreen reports whether the program is displaying the alternate
+// screen, as full-screen programs do while they run.
+func (term *Terminal) InAlternateScreen() bool {
+	term.t.Helper()
+	on, err := term.backend.alternateScreen()
+	if err != nil {
+		term.fatalf("strider: capture: %v", err)
+	}
+	return on
+}
 
-	raw, err := capturePaneContent(term.runner, term.pane)
+// ScreenPrimary captures the primary screen, the one shells and line-based
+// programs draw on, even while the program is displaying the alternate
+// screen. Use it, for example, to check that the shell prompt is intact after
+// a full-screen program exits. Unlike Screen, it also works after the program
+// has exited. The cursor position is unavailable while the alternate screen
+// is displayed.
+func (term *Terminal) ScreenPrimary() *Screen {
+	term.t.Helper()
+	on, err := term.backend.alternateScreen()
 	if err != nil {
-		term.t.Fatalf("strider: %s: %v", op, err)
+		term.fatalf("strider: capture: %v", err)
+	}
+	if !on {
+		return term.captureVisible()
 	}
 
-	scr := newScreen(raw, term.opts.width, term.opts.height)
+	raw, err := term.backend.captureSaved()
+	if err != nil {
+		term.fatalf("strider: capture: primary screen: %v", err)
+	}
+	scr := newScreen(term.redact(raw), term.opts.width, term.opts.height)
+	if g, err := term.backend.geometry(); err == nil {
+		scr.width = g.width
+		scr.height = g.height
+	}
+	return scr
+}
 
-	// Fetch cursor position (best-effort; don't fail if unavailable).
-	row, col, cursorErr := getCursorPosition(term.runner, term.pane)
-	if cursorErr == nil {
-		scr.cursorRow = row
-		scr.cursorCol = col
+// ScreenAlternate captures the alternate screen, the one full-screen programs
+// draw on. It calls t.Fatal if the alternate screen is not being displayed:
+// terminals discard its content when a program switches back to the primary
+// screen. Unlike Screen, it also works after the program has exited.
+func (term *Terminal) ScreenAlternate() *Screen {
+	term.t.Helper()
+	on, err := term.backend.alternateScreen()
+	if err != nil {
+		term.fatalf("strider: capture: %v", err)
+	}
+	if !on {
+		term.fatalf("strider: capture: alternate screen is not active")
 	}
+	return term.captureVisible()
+}
 
+// captureVisible captures the displayed screen like Screen, without
+// requiring the pane to be alive.
+func (term *Terminal) captureVisible() *Screen {
+	term.t.Helper()
+	scr := term.captureScreenRaw()
+	if scr == nil {
+		term.fatalf("strider: capture: capture failed")
+	}
 	return scr
 }
 
 // captureScreenRaw captures screen content without requiring the pane to be alive.
 // Used in error reporting paths where the pane may have died.
 func (term *Terminal) captureScreenRaw() *Screen {
-	raw, err := capturePaneContent(term.runner, term.pane)
+	scr, err := term.capturePane()
 	if err != nil {
 		return nil
 	}
-	scr := newScreen(raw, term.opts.width, term.opts.height)
-	row, col, cursorErr := getCursorPosition(term.runner, term.pane)
-	if cursorErr == nil {
-		scr.cursorRow = row
-		scr.cursorCol = col
+	return scr
+}
+
+// captureExact captures the visible screen with trailing spaces kept, for
+// the escaped snapshot format. Fails the test if the capture fails.
+func (term *Terminal) captureExact() *Screen {
+	term.t.Helper()
+	raw, err := term.backend.captureExact()
+	if err != nil {
+		term.fatalf("strider: snapshot: capture failed: %v", err)
 	}
+	scr := newScreen(term.redact(raw), term.opts.width, term.opts.height)
+	term.applyPaneGeometry(scr)
 	return scr
 }
 
+// applyPaneGeometry records the cursor position and the pane's actual size
+// on scr. Best-effort: if the query fails, the cursor stays unavailable and
+// the size stays at the configured dimensions.
+func (term *Terminal) applyPaneGeometry(scr *Screen) {
+	g, err := term.backend.geometry()
+	if err != nil {
+		return
+	}
+	scr.setPaneGeometry(g)
+}
+
+// setPaneGeometry records the cursor position and the pane's actual size.
+func (scr *Screen) setPaneGeometry(g paneGeometry) {
+	scr.cursorRow = g.cursorRow
+	scr.cursorCol = g.cursorCol
+	scr.width = g.width
+	scr.height = g.height
+}
+
 // WaitFor polls the screen until the matcher succeeds or the timeout expires.
 // On timeout it calls t.Fatal with a description of what was expected
 // and the last screen content.
 func (term *Terminal) WaitFor(m Matcher, wopts ...WaitOption) {
 	term.t.Helper()
-	_ = term.waitForInternal(m, wopts...)
+	_ = term.waitForInternal("wait-for", m, wopts...)
 }
 
 // WaitForScreen has the same timeout behavior as WaitFor: it polls until the
@@ -184,11 +867,59 @@ func (term *Terminal) WaitFor(m Matcher, wopts ...WaitOption) {
 // success it returns the matching Screen.
 func (term *Terminal) WaitForScreen(m Matcher, wopts ...WaitOption) *Screen {
 	term.t.Helper()
-	return term.waitForInternal(m, wopts...)
+	return term.waitForInternal("wait-for", m, wopts...).Screen
+}
+
+// WaitResult describes a successful wait.
+type WaitResult struct {
+	// Screen is the screen that satisfied the matcher.
+	Screen *Screen
+	// Elapsed is how long the wait took.
+	Elapsed time.Duration
+	// Polls is the number of screens captured, including the matching one.
+	Polls int
+}
+
+// WaitForResult has the same timeout behavior as WaitFor. On success it
+// returns the matching Screen with how long the wait took and how many
+// captures it made, for tracking how long transitions take over time.
+func (term *Terminal) WaitForResult(m Matcher, wopts ...WaitOption) WaitResult {
+	term.t.Helper()
+	return term.waitForInternal("wait-for", m, wopts...)
 }
 
-func (term *Terminal) waitForInternal(m Matcher, wopts ...WaitOption) *Screen {
+// ExpectFor waits like WaitFor, but reports a timeout with t.Error rather
+// than t.Fatal and returns whether the matcher succeeded, so a test can check
+// several things before stopping:
+//
+//	ok := term.ExpectFor(strider.Text("3 files"))
+//	ok = term.ExpectFor(strider.Text("0 errors")) && ok
+//	if !ok {
+//		t.FailNow()
+//	}
+//
+// The failure message is the one WaitFor would give. A program that exits or
+// a screen clear under NoClears also fails the wait without stopping the test.
+// Misuse, such as a negative timeout, still calls t.Fatal.
+func (term *Terminal) ExpectFor(m Matcher, wopts ...WaitOption) bool {
 	term.t.Helper()
+	wopts = append(wopts, func(o *waitOptions) { o.soft = true })
+	return term.waitForInternal("expect-for", m, wopts...).Screen != nil
+}
+
+// waitForInternal implements WaitFor, WaitForScreen, WaitForResult, and
+// ExpectFor, reporting a failed wait to the test. op prefixes failure
+// messages.
+func (term *Terminal) waitForInternal(op string, m Matcher, wopts ...WaitOption) WaitResult {
+	term.t.Helper()
+	r, err := term.waitFor(op, m, wopts...)
+	term.check(err)
+	return r
+}
+
+// waitFor is waitForInternal, returning the failure of a failed wait.
+func (term *Terminal) waitFor(op string, m Matcher, wopts ...WaitOption) (WaitResult, error) {
+	term.host.Helper()
 
 	wo := waitOptions{}
 	for _, o := range wopts {
@@ -199,7 +930,7 @@ func (term *Terminal) waitForInternal(m Matcher, wopts ...WaitOption) *Screen {
 	if wo.timeout > 0 {
 		timeout = wo.timeout
 	} else if wo.timeout < 0 {
-		term.t.Fatalf("strider: wait-for: negative timeout: %v", wo.timeout)
+		return WaitResult{}, term.failf("strider: %s: negative timeout: %v", op, wo.timeout)
 	}
 
 	pollInterval := term.opts.pollInterval
@@ -209,45 +940,190 @@ func (term *Terminal) waitForInternal(m Matcher, wopts ...WaitOption) *Screen {
 			pollInterval = minPollInterval
 		}
 	} else if wo.pollInterval < 0 {
-		term.t.Fatalf("strider: wait-for: negative poll interval: %v", wo.pollInterval)
+		return WaitResult{}, term.failf("strider: %s: negative poll interval: %v", op, wo.pollInterval)
 	}
 
-	deadline := time.Now().Add(timeout)
+	start := time.Now()
+	deadline := start.Add(timeout)
+	polls := 0
 	var lastScreen *Screen
 	lastDesc := "matcher condition"
+	var lastNotes []string // explain lastDesc (see Screen.addNote)
 	recentScreens := make([]*Screen, 0, failureCaptureHistory)
 
+	// The hash of the last screen the matcher rejected. Matchers depend only
+	// on the screen, so an unchanged capture is not matched again, unless
+	// the matcher checked state outside it (see markExternal).
+	var rejectedHash uint64
+	rejected := false
+
+	// fail returns the failure of the wait, which is not fatal under
+	// ExpectFor.
+	fail := func(f Failure, format string, args ...any) (WaitResult, error) {
+		f.Fatal = !wo.soft
+		f.Screens = recentScreens
+		return WaitResult{}, term.failure(f, format, args...)
+	}
+
+	clearsBase := 0
+	if wo.noClears {
+		var err error
+		if clearsBase, err = term.clears(op); err != nil {
+			return WaitResult{}, err
+		}
+	}
+
+	// With output events, seen is the output size before lastScreen was
+	// captured, or -1. The program's output reaches the file after tmux
+	// draws it, so while the size stays at seen the screen is unchanged.
+	events := !term.opts.noOutputEvents
+	seen := int64(-1)
+	var capturedAt time.Time
+
 	for {
-		// Check if pane is dead.
-		state, err := getPaneState(term.runner, term.pane)
+		size := int64(-1)
+		if events {
+			size = term.outputSize()
+		}
+
+		// A poll makes one tmux call: the capture, with the pane state
+		// queried alongside, or the state alone when the screen cannot
+		// have changed.
+		idle := lastScreen != nil && size >= 0 && size == seen && time.Since(capturedAt) < outputIdleRecapture
+		var state paneState
+		var scr *Screen
+		var err error
+		if idle {
+			state, err = term.backend.state()
+		} else {
+			scr, state, err = term.capturePaneState()
+		}
 		if err == nil && state.dead {
-			lastScreen = term.captureScreenRaw()
+			lastScreen = scr
+			if lastScreen == nil {
+				lastScreen = term.captureScreenRaw()
+			}
 			recentScreens = appendRecentScreens(recentScreens, lastScreen, failureCaptureHistory)
 			if lastScreen != nil {
-				_, lastDesc = m(lastScreen)
+				_, lastDesc, lastNotes = evaluate(m, lastScreen)
+			}
+			wait := term.recordWait(op, lastDesc, time.Since(start), polls, WaitProgramExited)
+			exit, err := term.classifyExit(op, state.exitStatus, lastScreen)
+			if err != nil {
+				return WaitResult{}, err
+			}
+			return fail(Failure{Wait: wait, Exit: &ExitState{Op: op, Code: state.exitStatus}}, "strider: %s: process exited unexpectedly (status %d)%s\n    waiting for: %s%s\n    recent screen captures (oldest to newest):\n%s%s",
+				op, state.exitStatus, exit, lastDesc, formatNotes(lastNotes), formatRecentScreens(recentScreens), term.formatExitDiagnostics(state.exitStatus))
+		}
+
+		if idle {
+			term.debugf("strider: %s: no new output", op)
+		} else {
+			seen, capturedAt = size, time.Now()
+			lastScreen = scr
+			if lastScreen == nil {
+				return WaitResult{}, term.failf("strider: %s: capture failed", op)
+			}
+			polls++
+			recentScreens = appendRecentScreens(recentScreens, lastScreen, failureCaptureHistory)
+
+			if wo.noClears {
+				clears, err := term.clears(op)
+				if err != nil {
+					return WaitResult{}, err
+				}
+				if n := clears - clearsBase; n > 0 {
+					wait := term.recordWait(op, lastDesc, time.Since(start), polls, WaitAborted)
+					return fail(Failure{Wait: wait}, "strider: %s: the program cleared the screen during the wait (%d times; NoClears)\n    waiting for: %s%s\n    recent screen captures (oldest to newest):\n%s",
+						op, n, lastDesc, formatNotes(lastNotes), formatRecentScreens(recentScreens))
+				}
 			}
-			term.t.Fatalf("strider: wait-for: process exited unexpectedly (status %d)\n    waiting for: %s\n    recent screen captures (oldest to newest):\n%s",
-				state.exitStatus, lastDesc, formatRecentScreens(recentScreens))
 		}
 
-		lastScreen = term.captureScreenRaw()
-		if lastScreen == nil {
-			term.t.Fatalf("strider: wait-for: capture failed")
+		if hash := lastScreen.Hash(); !rejected || hash != rejectedHash {
+			ok, desc, notes := evaluate(m, lastScreen)
+			lastDesc, lastNotes = desc, notes
+			if ok {
+				term.recordScreen(op+": "+desc, lastScreen)
+				elapsed := time.Since(start)
+				term.debugf("strider: %s: poll %d: matched after %v: %s", op, polls, elapsed.Round(time.Millisecond), desc)
+				term.recordWait(op, desc, elapsed, polls, WaitSucceeded)
+				term.warnIfSlow(op, desc, elapsed)
+				return WaitResult{Screen: lastScreen, Elapsed: elapsed, Polls: polls}, nil
+			}
+			term.debugf("strider: %s: poll %d: no match: %s", op, polls, desc)
+			rejectedHash, rejected = hash, !lastScreen.external.Load()
+		} else {
+			term.debugf("strider: %s: poll %d: screen unchanged", op, polls)
 		}
-		recentScreens = appendRecentScreens(recentScreens, lastScreen, failureCaptureHistory)
 
-		ok, desc := m(lastScreen)
-		lastDesc = desc
-		if ok {
-			return lastScreen
+		if abort, reason := term.checkAbort(); abort {
+			wait := term.recordWait(op, lastDesc, time.Since(start), polls, WaitAborted)
+			return fail(Failure{Wait: wait}, "strider: %s: aborted: %s\n    waiting for: %s%s\n    recent screen captures (oldest to newest):\n%s%s",
+				op, reason, lastDesc, formatNotes(lastNotes), formatRecentScreens(recentScreens), term.formatScrollbackTail())
 		}
 
 		if time.Now().After(deadline) {
-			term.t.Fatalf("strider: wait-for: timed out after %v\n    waiting for: %s\n    recent screen captures (oldest to newest):\n%s",
-				timeout, lastDesc, formatRecentScreens(recentScreens))
+			wait := term.recordWait(op, lastDesc, time.Since(start), polls, WaitTimedOut)
+			return fail(Failure{Wait: wait}, "strider: %s: timed out after %v\n    waiting for: %s%s\n    recent screen captures (oldest to newest):\n%s%s",
+				op, timeout, lastDesc, formatNotes(lastNotes), formatRecentScreens(recentScreens), term.formatScrollbackTail())
 		}
 
-		time.Sleep(pollInterval)
+		term.sleepUntilOutput(seen, pollInterval)
+	}
+}
+
+// checkAbort runs the conditions set with WithAbortWhen and returns the
+// first that reports true, with its reason.
+func (term *Terminal) checkAbort() (bool, string) {
+	for _, cond := range term.opts.abortWhen {
+		if abort, reason := cond(); abort {
+			if reason == "" {
+				reason = "WithAbortWhen condition reported true"
+			}
+			return true, reason
+		}
+	}
+	return false, ""
+}
+
+// outputSize returns the size of the program's output so far, or -1 if it
+// cannot be read.
+func (term *Terminal) outputSize() int64 {
+	n, err := term.output.size()
+	if err != nil {
+		return -1
+	}
+	return n
+}
+
+// sleepUntilOutput sleeps for d, or until the output grows past size, if
+// size is known.
+func (term *Terminal) sleepUntilOutput(size int64, d time.Duration) {
+	if size < 0 {
+		time.Sleep(d)
+		return
+	}
+	deadline := time.Now().Add(d)
+	for {
+		step := min(outputCheckInterval, time.Until(deadline))
+		if step <= 0 {
+			return
+		}
+		time.Sleep(step)
+		if term.outputSize() != size {
+			return
+		}
+	}
+}
+
+// warnIfSlow logs a warning when a successful wait took longer than the
+// threshold set with WithSlowWaitWarning.
+func (term *Terminal) warnIfSlow(op, desc string, elapsed time.Duration) {
+	term.host.Helper()
+	if term.opts.slowWait > 0 && elapsed > term.opts.slowWait {
+		term.logf("strider: %s: slow wait: took %v (threshold %v)\n    waiting for: %s",
+			op, elapsed.Round(time.Millisecond), term.opts.slowWait, desc)
 	}
 }
 
@@ -255,6 +1131,48 @@ func (term *Terminal) waitForInternal(m Matcher, wopts ...WaitOption) *Screen {
 // Useful for testing that a program terminates cleanly.
 func (term *Terminal) WaitExit(wopts ...WaitOption) int {
 	term.t.Helper()
+	return term.waitExitInternal("wait-exit", wopts...)
+}
+
+// ExpectExit waits up to within for the program to exit and fails the test
+// unless it exits with status code. A zero within uses the Terminal's
+// timeout. On a mismatch the failure shows both codes, the final screen, how
+// the program was started, and the end of its output.
+//
+//	term.Press(strider.Ctrl('c'))
+//	term.ExpectExit(0, 2*time.Second)
+func (term *Terminal) ExpectExit(code int, within time.Duration) {
+	term.t.Helper()
+	var wopts []WaitOption
+	if within != 0 {
+		wopts = append(wopts, WithinTimeout(within))
+	}
+	got := term.waitExitInternal("expect-exit", wopts...)
+	if got == code {
+		return
+	}
+	f := Failure{Fatal: true, Exit: &ExitState{Op: "expect-exit", Code: got}}
+	final := "    (no screen captured)"
+	if scr := term.captureScreenRaw(); scr != nil {
+		final = formatScreenBox(scr)
+		f.Screens = []*Screen{scr}
+	}
+	term.failWith(f, "strider: expect-exit: exited with status %d, want %d\n    final screen:\n%s%s",
+		got, code, final, term.formatExitDiagnostics(got))
+}
+
+// waitExitInternal implements WaitExit and ExpectExit, reporting a failed
+// wait to the test. op prefixes failure messages.
+func (term *Terminal) waitExitInternal(op string, wopts ...WaitOption) int {
+	term.t.Helper()
+	code, err := term.waitExit(op, wopts...)
+	term.check(err)
+	return code
+}
+
+// waitExit is waitExitInternal, returning the failure of a failed wait.
+func (term *Terminal) waitExit(op string, wopts ...WaitOption) (int, error) {
+	term.host.Helper()
 
 	wo := waitOptions{}
 	for _, o := range wopts {
@@ -265,7 +1183,7 @@ func (term *Terminal) WaitExit(wopts ...WaitOption) int {
 	if wo.timeout > 0 {
 		timeout = wo.timeout
 	} else if wo.timeout < 0 {
-		term.t.Fatalf("strider: wait-exit: negative timeout: %v", wo.timeout)
+		return 0, term.failf("strider: %s: negative timeout: %v", op, wo.timeout)
 	}
 
 	pollInterval := term.opts.pollInterval
@@ -275,80 +1193,195 @@ func (term *Terminal) WaitExit(wopts ...WaitOption) int {
 			pollInterval = minPollInterval
 		}
 	} else if wo.pollInterval < 0 {
-		term.t.Fatalf("strider: wait-exit: negative poll interval: %v", wo.pollInterval)
+		return 0, term.failf("strider: %s: negative poll interval: %v", op, wo.pollInterval)
 	}
 
-	deadline := time.Now().Add(timeout)
+	start := time.Now()
+	deadline := start.Add(timeout)
+	polls := 0
 	recentScreens := make([]*Screen, 0, failureCaptureHistory)
 	for {
-		state, err := getPaneState(term.runner, term.pane)
+		state, err := term.backend.state()
 		if err != nil {
-			term.t.Fatalf("strider: wait-exit: %v", err)
+			return 0, term.failf("strider: %s: %v", op, err)
 		}
+		polls++
 		if state.dead {
-			return state.exitStatus
+			term.record("exit %d", state.exitStatus)
+			elapsed := time.Since(start)
+			term.recordWait(op, "process to exit", elapsed, polls, WaitSucceeded)
+			term.warnIfSlow(op, "process to exit", elapsed)
+			return state.exitStatus, nil
 		}
 		recentScreens = appendRecentScreens(recentScreens, term.captureScreenRaw(), failureCaptureHistory)
+		if abort, reason := term.checkAbort(); abort {
+			wait := term.recordWait(op, "process to exit", time.Since(start), polls, WaitAborted)
+			return 0, term.failure(Failure{Fatal: true, Wait: wait, Screens: recentScreens}, "strider: %s: aborted: %s\n    pane still alive\n    recent screen captures (oldest to newest):\n%s%s",
+				op, reason, formatRecentScreens(recentScreens), term.formatScrollbackTail())
+		}
 		if time.Now().After(deadline) {
-			term.t.Fatalf("strider: wait-exit: timed out after %v\n    pane still alive\n    recent screen captures (oldest to newest):\n%s",
-				timeout, formatRecentScreens(recentScreens))
+			wait := term.recordWait(op, "process to exit", time.Since(start), polls, WaitTimedOut)
+			return 0, term.failure(Failure{Fatal: true, Wait: wait, Screens: recentScreens}, "strider: %s: timed out after %v\n    pane still alive\n    recent screen captures (oldest to newest):\n%s%s",
+				op, timeout, formatRecentScreens(recentScreens), term.formatScrollbackTail())
 		}
 		time.Sleep(pollInterval)
 	}
 }
 
+// Signal sends sig, such as syscall.SIGTERM or syscall.SIGHUP, to the
+// pane's process group: the program and the processes it started, unless
+// they moved to a group of their own. Use it for shutdown and reload paths
+// that keys cannot trigger; Press(Ctrl('c')) covers SIGINT. It calls
+// t.Fatal if the program has exited or sig cannot be sent.
+//
+//	term.Signal(syscall.SIGHUP)
+//	term.WaitFor(strider.Text("config reloaded"))
+//
+// WaitExit reports a program killed by a signal as exiting with status 128
+// plus the signal number, as shells do (with tmux 3.3 or newer, which still
+// occasionally loses the status and reports 0).
+func (term *Terminal) Signal(sig os.Signal) {
+	term.t.Helper()
+	term.record("signal %v", sig)
+	term.requireAlive("signal")
+	num, ok := sig.(syscall.Signal)
+	if !ok {
+		term.fatalf("strider: signal: unsupported signal %v", sig)
+	}
+	pid, err := term.backend.pid()
+	if err != nil {
+		term.fatalf("strider: signal: %v", err)
+	}
+	if err := syscall.Kill(-pid, num); err != nil {
+		term.fatalf("strider: signal: %v to process group %d: %v", sig, pid, err)
+	}
+}
+
 // Resize changes the terminal dimensions.
-// This sends a SIGWINCH to the running program.
+// This sends a SIGWINCH to the running program. COLUMNS and LINES exported
+// by WithSize keep their original values: a running process cannot observe
+// changes to its environment.
 func (term *Terminal) Resize(width, height int) {
 	term.t.Helper()
-	term.requireAlive("resize")
-	if err := resizeWindow(term.runner, term.pane, width, height); err != nil {
-		term.t.Fatalf("strider: resize: %v", err)
+	term.check(term.resize(width, height))
+}
+
+// resize is Resize, returning its failure.
+func (term *Terminal) resize(width, height int) error {
+	term.host.Helper()
+	term.record("resize %dx%d", width, height)
+	if err := term.checkAlive("resize"); err != nil {
+		return err
+	}
+	if err := term.backend.resize(width, height); err != nil {
+		return term.failf("strider: resize: %v", err)
 	}
 	term.opts.width = width
 	term.opts.height = height
+	if term.recording != nil {
+		term.recording.resize(width, height)
+	}
+	return nil
+}
+
+// ResizeSteps resizes the terminal gradually, emulating a user dragging the
+// window corner: it resizes to fromW x fromH, then through steps evenly
+// spaced sizes ending at toW x toH, pausing for delay after each resize so
+// the program can handle SIGWINCH. It calls t.Fatal if steps is less than 1.
+// As with Resize, WaitFor the expected final content afterwards.
+//
+// tmux may merge resizes that follow each other quickly into one, so the
+// program only sees every intermediate size when delay is a few hundred
+// milliseconds.
+func (term *Terminal) ResizeSteps(fromW, fromH, toW, toH, steps int, delay time.Duration) {
+	term.t.Helper()
+	if steps < 1 {
+		term.fatalf("strider: resize: steps must be at least 1, got %d", steps)
+	}
+
+	term.Resize(fromW, fromH)
+	for i := 1; i <= steps; i++ {
+		time.Sleep(delay)
+		term.Resize(fromW+(toW-fromW)*i/steps, fromH+(toH-fromH)*i/steps)
+	}
 }
 
 // Scrollback captures the full scrollback buffer, not just the visible screen.
 //
-// The returned Screen has one line per scrollback row (oldest to newest).
-// Its height (and len(Lines())) reflects the total number of captured lines,
-// which is typically larger than the pane's visible height. Width is the
-// maximum line width across all captured lines. Callers should use
-// len(s.Lines()) to reason about scrollback length, rather than relying on
-// the visible height returned by s.Size().
+// The returned Screen has one line per captured row (oldest to newest): the
+// history rows followed by the rows visible in the pane. Size reports the
+// visible pane size, TotalLines reports the number of captured rows, and
+// VisibleRange reports which of those rows were on screen at capture time.
 func (term *Terminal) Scrollback() *Screen {
 	term.t.Helper()
-	term.requireAlive("capture")
+	scr, err := term.scrollback()
+	term.check(err)
+	return scr
+}
 
-	raw, err := capturePaneScrollback(term.runner, term.pane)
+// scrollback is Scrollback, returning its failure.
+func (term *Terminal) scrollback() (*Screen, error) {
+	if err := term.checkAlive("capture"); err != nil {
+		return nil, err
+	}
+
+	raw, err := term.backend.captureScrollback(false)
 	if err != nil {
-		term.t.Fatalf("strider: capture: scrollback: %v", err)
+		return nil, term.failf("strider: capture: scrollback: %v", err)
 	}
 
-	lines := strings.Split(strings.TrimSuffix(raw, "\n"), "\n")
-	maxWidth := 0
-	for _, l := range lines {
-		if len(l) > maxWidth {
-			maxWidth = len(l)
-		}
+	scr := newScreen(term.redact(raw), term.opts.width, term.opts.height)
+	if start := len(scr.lines) - term.opts.height; start > 0 {
+		scr.visibleStart = start
+	}
+	return scr, nil
+}
+
+// ScrollView returns what the user would see after scrolling the terminal
+// itself up by lines: it enters tmux copy mode, scrolls, captures the view,
+// and leaves copy mode again. Scrolling stops at the top of the scrollback
+// history. The copy-mode position indicator is not part of the capture.
+// Use it for programs that rely on terminal scrollback rather than paging
+// their own output.
+func (term *Terminal) ScrollView(lines int) *Screen {
+	term.t.Helper()
+	if lines < 0 {
+		term.fatalf("strider: scroll-view: negative line count: %d", lines)
 	}
+	term.requireAlive("scroll-view")
 
-	return newScreen(raw, maxWidth, len(lines))
+	raw, err := term.backend.captureScrolled(lines, term.opts.height)
+	if err != nil {
+		term.fatalf("strider: scroll-view: %v", err)
+	}
+	return newScreen(term.redact(raw), term.opts.width, term.opts.height)
 }
 
 // requireAlive checks that the pane process is still running and calls t.Fatal
 // if it has exited.
 func (term *Terminal) requireAlive(op string) {
 	term.t.Helper()
+	term.check(term.checkAlive(op))
+}
 
-	state, err := getPaneState(term.runner, term.pane)
-	if err != nil {
-		return
+// checkAlive is requireAlive, returning the failure.
+func (term *Terminal) checkAlive(op string) error {
+	state, err := term.backend.state()
+	if err != nil || !state.dead {
+		return nil
 	}
-	if state.dead {
-		term.t.Fatalf("strider: %s: process exited unexpectedly (status %d)", op, state.exitStatus)
+	return term.exitedError(op, state.exitStatus)
+}
+
+// exitedError returns the failure of an operation that found the program
+// exited with status.
+func (term *Terminal) exitedError(op string, status int) error {
+	exit, err := term.classifyExit(op, status, nil)
+	if err != nil {
+		return err
 	}
+	return term.failure(Failure{Fatal: true, Exit: &ExitState{Op: op, Code: status}}, "strider: %s: process exited unexpectedly (status %d)%s%s",
+		op, status, exit, term.formatExitDiagnostics(status))
 }
 
 func appendRecentScreens(screens []*Screen, scr *Screen, max int) []*Screen {
@@ -377,6 +1410,88 @@ func formatRecentScreens(screens []*Screen) string {
 	return b.String()
 }
 
+// formatScrollbackTail returns the last lines of the scrollback buffer,
+// formatted for failure output, when WithScrollbackTail is set.
+func (term *Terminal) formatScrollbackTail() string {
+	return term.formatScrollback(term.opts.scrollbackTail)
+}
+
+// formatExitDiagnostics returns the failure output details for a program
+// that exited unexpectedly: how it was started and the end of its output,
+// which often holds the error message that explains the exit.
+func (term *Terminal) formatExitDiagnostics(status int) string {
+	var b strings.Builder
+	switch status {
+	case 126:
+		b.WriteString("\n    hint: status 126 usually means the binary is not executable")
+	case 127:
+		b.WriteString("\n    hint: status 127 usually means the binary or its interpreter was not found")
+	}
+	b.WriteString(term.formatLimitDiagnostics())
+
+	args := make([]string, 0, 1+len(term.openOpts.args))
+	args = append(args, term.binary)
+	args = append(args, term.openOpts.args...)
+	for i, a := range args {
+		args[i] = shellQuote(a)
+	}
+	fmt.Fprintf(&b, "\n    command: %s", strings.Join(args, " "))
+	if env := childEnv(term.openOpts); len(env) > 0 {
+		for i, e := range env {
+			env[i] = shellQuote(e)
+		}
+		fmt.Fprintf(&b, "\n    env: %s", strings.Join(env, " "))
+	}
+	if term.openOpts.dir != "" {
+		fmt.Fprintf(&b, "\n    dir: %s", term.openOpts.dir)
+	}
+	if term.openOpts.user != "" {
+		fmt.Fprintf(&b, "\n    user: %s (WithUser)", term.openOpts.user)
+	}
+	if term.openOpts.noNetwork {
+		b.WriteString("\n    network: none (WithNoNetwork)")
+	}
+	b.WriteString(term.formatSandboxDiagnostics())
+
+	b.WriteString(term.formatScrollback(max(term.opts.scrollbackTail, exitScrollbackTail)))
+	return b.String()
+}
+
+// formatScrollback returns the last n lines of the scrollback buffer,
+// formatted for failure output. Trailing blank rows of the visible screen,
+// and tmux's "Pane is dead" notice, are dropped before taking the tail.
+// Best-effort: returns "" when n is not positive or when the capture fails.
+func (term *Terminal) formatScrollback(n int) string {
+	if n <= 0 {
+		return ""
+	}
+
+	raw, err := term.backend.captureScrollback(false)
+	if err != nil {
+		return ""
+	}
+
+	lines := trimTrailingBlank(strings.Split(strings.TrimSuffix(term.redact(raw), "\n"), "\n"))
+	// Skip the notice tmux prints below a dead pane's output.
+	if len(lines) > 0 && strings.HasPrefix(lines[len(lines)-1], "Pane is dead") {
+		lines = trimTrailingBlank(lines[:len(lines)-1])
+	}
+	if len(lines) > n {
+		lines = lines[len(lines)-n:]
+	}
+
+	scr := newScreen(strings.Join(lines, "\n"), term.opts.width, len(lines))
+	return fmt.Sprintf("\n    scrollback tail (last %d lines):\n%s", len(lines), formatScreenBox(scr))
+}
+
+// trimTrailingBlank returns lines without its trailing blank lines.
+func trimTrailingBlank(lines []string) []string {
+	for len(lines) > 0 && strings.TrimRight(lines[len(lines)-1], " ") == "" {
+		lines = lines[:len(lines)-1]
+	}
+	return lines
+}
+
 // formatScreenBox formats a screen capture with a box border for error messages.
 func formatScreenBox(scr *Screen) string {
 	if scr == nil {
@@ -1,16 +1,29 @@
 package strider_test
 
 import (
+	"encoding/json"
+	"errors"
+	"flag"
 	"fmt"
+	"net"
+	"net/http"
+	"net/http/httptest"
 	"os"
 	"os/exec"
+	"path"
 	"path/filepath"
+	"reflect"
 	"regexp"
+	"slices"
 	"strings"
+	"sync"
+	"sync/atomic"
+	"syscall"
 	"testing"
 	"time"
 
 	"github.com/cboone/strider"
+	"github.com/cboone/strider/internal/tmuxcli"
 )
 
 var testBinary string
@@ -18,6 +31,34 @@ var testBinary string
 const (
 	waitForTimeoutHelperEnv  = "STRIDER_WAITFOR_TIMEOUT_HELPER"
 	waitExitTimeoutHelperEnv = "STRIDER_WAITEXIT_TIMEOUT_HELPER"
+	scrollbackTailHelperEnv  = "STRIDER_SCROLLBACK_TAIL_HELPER"
+	flagUpdateHelperEnv      = "STRIDER_FLAG_UPDATE_HELPER"
+	flagTimeoutHelperEnv     = "STRIDER_FLAG_TIMEOUT_HELPER"
+	pendingSnapshotHelperEnv = "STRIDER_PENDING_SNAPSHOT_HELPER"
+	readyWhenHelperEnv       = "STRIDER_READY_WHEN_HELPER"
+	earlyExitHelperEnv       = "STRIDER_EARLY_EXIT_HELPER"
+	binaryCheckHelperEnv     = "STRIDER_BINARY_CHECK_HELPER"
+	invalidOptionsHelperEnv  = "STRIDER_INVALID_OPTIONS_HELPER"
+	stepHelperEnv            = "STRIDER_STEP_HELPER"
+	transcriptHelperEnv      = "STRIDER_TRANSCRIPT_HELPER"
+	serverLimitsHelperEnv    = "STRIDER_SERVER_LIMITS_HELPER"
+	snapshotClashHelperEnv   = "STRIDER_SNAPSHOT_CLASH_HELPER"
+	sharedSnapshotHelperEnv  = "STRIDER_SHARED_SNAPSHOT_HELPER"
+	slowWaitHelperEnv        = "STRIDER_SLOW_WAIT_HELPER"
+	metricsHelperEnv         = "STRIDER_METRICS_HELPER"
+	observeHelperEnv         = "STRIDER_OBSERVE_HELPER"
+	noClearsHelperEnv        = "STRIDER_NO_CLEARS_HELPER"
+	lineDiffHelperEnv        = "STRIDER_LINE_DIFF_HELPER"
+	exitClassifierHelperEnv  = "STRIDER_EXIT_CLASSIFIER_HELPER"
+	expectExitHelperEnv      = "STRIDER_EXPECT_EXIT_HELPER"
+	quietHelperEnv           = "STRIDER_QUIET_HELPER"
+	typeSecretHelperEnv      = "STRIDER_TYPE_SECRET_HELPER"
+	redactHelperEnv          = "STRIDER_REDACT_HELPER"
+	expectHelperEnv          = "STRIDER_EXPECT_HELPER"
+	strictHelperEnv          = "STRIDER_STRICT_HELPER"
+	reporterHelperEnv        = "STRIDER_REPORTER_HELPER"
+	maxConcurrentHelperEnv   = "STRIDER_MAX_CONCURRENT_HELPER"
+	sandboxHelperEnv         = "STRIDER_SANDBOX_HELPER"
 )
 
 func TestMain(m *testing.M) {
@@ -39,7 +80,8 @@ func TestMain(m *testing.M) {
 	}
 
 	testBinary = binPath
-	os.Exit(m.Run())
+	strider.RegisterFlags(flag.CommandLine)
+	os.Exit(strider.EnableSummary(m))
 }
 
 func TestOpenAndCleanup(t *testing.T) {
@@ -56,6 +98,38 @@ func TestTypeAndEcho(t *testing.T) {
 	term.WaitFor(strider.Text("echo: hello world"))
 }
 
+func TestWithMaxInputRate(t *testing.T) {
+	term := strider.Open(t, testBinary, strider.WithMaxInputRate(50))
+	term.WaitFor(strider.Text("ready>"))
+
+	// 18 keys at 50 per second: the last is sent 17 intervals (340ms) after
+	// the first.
+	start := time.Now()
+	term.Type("paced input ")
+	term.Type("12345")
+	term.Press(strider.Enter)
+	if elapsed := time.Since(start); elapsed < 340*time.Millisecond {
+		t.Errorf("expected input to take at least 340ms, took %v", elapsed)
+	}
+	term.WaitFor(strider.Text("echo: paced input 12345"))
+}
+
+// submit is a helper written against the strider interfaces.
+func submit(in strider.Inputter, w strider.Waiter, line string) *strider.Screen {
+	in.Type(line)
+	in.Press(strider.Enter)
+	return w.WaitForScreen(strider.Text("echo: " + line))
+}
+
+func TestConsoleInterfaces(t *testing.T) {
+	var c strider.Console = strider.Open(t, testBinary)
+	c.WaitFor(strider.Text("ready>"))
+	scr := submit(c, c, "via interfaces")
+	if !scr.Contains("echo: via interfaces") || !c.Screen().Contains("echo: via interfaces") {
+		t.Errorf("unexpected screen:\n%s", scr)
+	}
+}
+
 func TestPressKeys(t *testing.T) {
 	term := strider.Open(t, testBinary)
 	term.WaitFor(strider.Text("ready>"))
@@ -110,6 +184,168 @@ func TestWaitForScreen(t *testing.T) {
 	}
 }
 
+func TestWaitForResult(t *testing.T) {
+	term := strider.Open(t, "/bin/sh",
+		strider.WithArgs("-c", "echo start; sleep 0.3; echo done; read y"),
+	)
+	res := term.WaitForResult(strider.Text("start"))
+	if !res.Screen.Contains("start") {
+		t.Errorf("expected the matching screen, got:\n%s", res.Screen)
+	}
+
+	res = term.WaitForResult(strider.Text("done"), strider.WithWaitPollInterval(20*time.Millisecond))
+	if res.Elapsed < 100*time.Millisecond || res.Polls < 2 {
+		t.Errorf("Elapsed = %v, Polls = %d; want a wait of several polls", res.Elapsed, res.Polls)
+	}
+
+	res = term.WaitForResult(strider.Text("done"))
+	if res.Polls != 1 {
+		t.Errorf("Polls = %d for an already matching screen, want 1", res.Polls)
+	}
+}
+
+func TestWithSlowWaitWarning(t *testing.T) {
+	if os.Getenv(slowWaitHelperEnv) == "1" {
+		term := strider.Open(t, "/bin/sh",
+			strider.WithArgs("-c", "echo start; sleep 0.5; echo done; read y"),
+			strider.WithSlowWaitWarning(200*time.Millisecond),
+		)
+		term.WaitFor(strider.Text("start"))
+		term.WaitFor(strider.Text("done"))
+		return
+	}
+
+	cmd := exec.Command(os.Args[0], "-test.run", "^TestWithSlowWaitWarning$", "-test.v")
+	cmd.Env = append(os.Environ(), slowWaitHelperEnv+"=1")
+	out, err := cmd.CombinedOutput()
+	if err != nil {
+		t.Fatalf("expected subprocess to pass, got %v:\n%s", err, string(out))
+	}
+	output := string(out)
+	if n := strings.Count(output, "strider: wait-for: slow wait: took"); n != 1 {
+		t.Fatalf("expected one slow wait warning, got %d:\n%s", n, output)
+	}
+	if !strings.Contains(output, "(threshold 200ms)") || !strings.Contains(output, `waiting for: screen to contain "done"`) {
+		t.Errorf("expected threshold and matcher in the warning, got:\n%s", output)
+	}
+}
+
+func TestObserve(t *testing.T) {
+	if os.Getenv(observeHelperEnv) == "1" {
+		term := strider.Open(t, "/bin/sh",
+			strider.WithArgs("-c", "echo Saving; sleep 0.3; echo Saved; read y"))
+		frames := term.Observe(time.Second, 20*time.Millisecond)
+		strider.AssertBefore(t, frames, strider.Text("Saved"), strider.Text("Saving"))
+		return
+	}
+
+	term := strider.Open(t, "/bin/sh",
+		strider.WithArgs("-c", "echo Saving; sleep 0.3; echo Saved; read y"))
+	frames := term.Observe(time.Second, 20*time.Millisecond)
+	if len(frames) < 10 {
+		t.Fatalf("expected a frame about every 20ms for 1s, got %d", len(frames))
+	}
+	strider.AssertBefore(t, frames, strider.Text("Saving"), strider.Text("Saved"))
+	strider.AssertEveryFrame(t, frames, strider.Not(strider.TextAll("Saving", "Error")))
+
+	cmd := exec.Command(os.Args[0], "-test.run", "^TestObserve$")
+	cmd.Env = append(os.Environ(), observeHelperEnv+"=1")
+	out, err := cmd.CombinedOutput()
+	if err == nil {
+		t.Fatalf("expected subprocess to fail, got success:\n%s", string(out))
+	}
+	if !strings.Contains(string(out), `strider: assert-before: expected screen to contain "Saved" before screen to contain "Saving"`) {
+		t.Errorf("expected the out-of-order frames to be reported, got:\n%s", string(out))
+	}
+}
+
+// waitRecorder is a strider.Metrics that keeps every WaitMetric.
+type waitRecorder struct {
+	mu    sync.Mutex
+	waits []strider.WaitMetric
+}
+
+func (r *waitRecorder) ObserveWait(w strider.WaitMetric) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.waits = append(r.waits, w)
+	if os.Getenv(metricsHelperEnv) == "1" {
+		fmt.Printf("metric: %s %s %q\n", w.Op, w.Outcome, w.Description)
+	}
+}
+
+func TestWithMetrics(t *testing.T) {
+	if os.Getenv(metricsHelperEnv) == "1" {
+		term := strider.Open(t, testBinary, strider.WithMetrics(&waitRecorder{}))
+		term.WaitFor(strider.Text("never shown"), strider.WithinTimeout(200*time.Millisecond))
+		return
+	}
+
+	rec := &waitRecorder{}
+	term := strider.Open(t, testBinary, strider.WithMetrics(rec))
+	term.WaitFor(strider.Text("ready>"))
+	term.Type("quit")
+	term.Press(strider.Enter)
+	term.WaitExit()
+
+	if len(rec.waits) != 2 {
+		t.Fatalf("expected 2 wait metrics, got %+v", rec.waits)
+	}
+	w := rec.waits[0]
+	if w.Op != "wait-for" || w.Description != `screen to contain "ready>"` ||
+		w.Outcome != strider.WaitSucceeded || w.Polls < 1 || w.Elapsed <= 0 {
+		t.Errorf("unexpected wait-for metric: %+v", w)
+	}
+	if w := rec.waits[1]; w.Op != "wait-exit" || w.Outcome != strider.WaitSucceeded {
+		t.Errorf("unexpected wait-exit metric: %+v", w)
+	}
+
+	cmd := exec.Command(os.Args[0], "-test.run", "^TestWithMetrics$")
+	cmd.Env = append(os.Environ(), metricsHelperEnv+"=1")
+	out, err := cmd.CombinedOutput()
+	if err == nil {
+		t.Fatalf("expected subprocess to fail, got success:\n%s", string(out))
+	}
+	if !strings.Contains(string(out), `metric: wait-for timed_out "screen to contain \"never shown\""`) {
+		t.Errorf("expected the timed-out wait to be reported, got:\n%s", string(out))
+	}
+}
+
+func TestEnableSummary(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "summary.json")
+	cmd := exec.Command(os.Args[0], "-test.run", "^TestOpenAndCleanup$")
+	cmd.Env = append(os.Environ(), "STRIDER_SUMMARY_JSON="+path)
+	if out, err := cmd.CombinedOutput(); err != nil {
+		t.Fatalf("expected subprocess to pass, got %v:\n%s", err, string(out))
+	}
+
+	data, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatalf("expected the summary to be written: %v", err)
+	}
+	var s strider.Summary
+	if err := json.Unmarshal(data, &s); err != nil {
+		t.Fatalf("invalid summary JSON: %v\n%s", err, data)
+	}
+	if s.Terminals != 1 || s.Waits != 1 || s.FailedWaits != 0 {
+		t.Errorf("expected 1 terminal and 1 successful wait, got %+v", s)
+	}
+	if s.TmuxInvocations < 3 || s.WaitTime <= 0 {
+		t.Errorf("expected tmux invocations and wait time to be counted, got %+v", s)
+	}
+
+	cmd = exec.Command(os.Args[0], "-test.run", "^TestOpenAndCleanup$")
+	cmd.Env = append(os.Environ(), "STRIDER_SUMMARY_JSON=")
+	out, err := cmd.CombinedOutput()
+	if err != nil {
+		t.Fatalf("expected subprocess to pass, got %v:\n%s", err, string(out))
+	}
+	if !strings.Contains(string(out), "strider: summary: 1 terminals,") ||
+		!strings.Contains(string(out), "    waits: 1 (") {
+		t.Errorf("expected the summary to be printed, got:\n%s", string(out))
+	}
+}
+
 func TestScreenContains(t *testing.T) {
 	term := strider.Open(t, testBinary)
 	term.WaitFor(strider.Text("ready>"))
@@ -168,6 +404,56 @@ func TestScreenLine(t *testing.T) {
 	}
 }
 
+func TestScreenColumn(t *testing.T) {
+	term := strider.Open(t, "/bin/sh",
+		strider.WithArgs("-c", "printf 'ab|cd\\nxyz|w\\nq\\n' && read line"),
+		strider.WithSize(20, 4),
+	)
+	screen := term.WaitForScreen(strider.Line(2, "q"))
+
+	if got, want := string(screen.Column(2)), "|z  "; got != want {
+		t.Errorf("Column(2) = %q, want %q", got, want)
+	}
+	if got, want := string(screen.Column(3)), "c|  "; got != want {
+		t.Errorf("Column(3) = %q, want %q", got, want)
+	}
+}
+
+func TestScreenRegion(t *testing.T) {
+	scr := strider.NewScreen(20, 4,
+		"menu  | title",
+		"open  | body \u4e16\u754c",
+		"quit  |",
+	).WithCursor(1, 9)
+
+	sub := scr.Region(8, 0, 6, 3)
+	if got, want := sub.Lines(), []string{"title", "body", ""}; !slices.Equal(got, want) {
+		t.Errorf("Region lines = %q, want %q", got, want)
+	}
+	if w, h := sub.Size(); w != 6 || h != 3 {
+		t.Errorf("Region size = %dx%d, want 6x3", w, h)
+	}
+	if row, col, ok := sub.CursorPosition(); !ok || row != 1 || col != 1 {
+		t.Errorf("Region cursor = %d, %d, %v, want 1, 1, true", row, col, ok)
+	}
+	if !sub.Contains("body") || sub.Contains("menu") {
+		t.Errorf("Region content:\n%s", sub)
+	}
+
+	// The region starts in the middle of the wide character at columns
+	// 13-14, and extends past the screen.
+	edge := scr.Region(14, 1, 10, 5)
+	if got, want := edge.Line(0), " \u754c"; got != want {
+		t.Errorf("edge region line 0 = %q, want %q", got, want)
+	}
+	if _, _, ok := edge.CursorPosition(); ok {
+		t.Error("edge region has the cursor, which lies outside it")
+	}
+	if got := edge.TotalLines(); got != 5 {
+		t.Errorf("edge region has %d lines, want 5", got)
+	}
+}
+
 func TestScreenSize(t *testing.T) {
 	term := strider.Open(t, testBinary, strider.WithSize(100, 30))
 	term.WaitFor(strider.Text("ready>"))
@@ -184,6 +470,47 @@ func TestTextMatcher(t *testing.T) {
 	term.WaitFor(strider.Text("ready>"))
 }
 
+func TestTextAllAndTextAnyMatchers(t *testing.T) {
+	term := strider.Open(t, testBinary)
+	term.WaitFor(strider.Text("ready>"))
+
+	term.Type("alpha beta")
+	term.Press(strider.Enter)
+	screen := term.WaitForScreen(strider.TextAll("echo:", "alpha", "beta"))
+	term.WaitFor(strider.TextAny("missing", "beta"))
+
+	ok, desc := strider.TextAll("alpha", "gamma", "delta")(screen)
+	if ok {
+		t.Fatal("expected TextAll to fail")
+	}
+	if !strings.Contains(desc, `(missing: "gamma", "delta")`) {
+		t.Errorf("expected missing substrings in description, got %q", desc)
+	}
+
+	if ok, _ := strider.TextAny("gamma", "delta")(screen); ok {
+		t.Error("expected TextAny to fail when no substring is present")
+	}
+}
+
+func TestTextWrappedMatcher(t *testing.T) {
+	term := strider.Open(t, testBinary, strider.WithSize(20, 6))
+	term.WaitFor(strider.Text("ready>"))
+
+	term.Type("the quick brown fox jumps")
+	term.Press(strider.Enter)
+	screen := term.WaitForScreen(strider.TextWrapped("echo: the quick brown fox jumps"))
+
+	if screen.Contains("brown fox") {
+		t.Fatalf("expected text to be wrapped across rows, got:\n%s", screen)
+	}
+	if !screen.ContainsWrapped("brown fox") {
+		t.Errorf("expected ContainsWrapped to join wrapped rows, got:\n%s", screen)
+	}
+	if screen.ContainsWrapped("ready>echo") {
+		t.Errorf("expected rows shorter than the width not to be joined")
+	}
+}
+
 func TestRegexpMatcher(t *testing.T) {
 	term := strider.Open(t, testBinary)
 	term.WaitFor(strider.Regexp(`ready>`))
@@ -209,6 +536,31 @@ func TestLineContainsMatcher(t *testing.T) {
 	term.WaitFor(strider.LineContains(1, "world"))
 }
 
+func TestLineMatchesMatcher(t *testing.T) {
+	term := strider.Open(t, testBinary)
+	term.WaitFor(strider.Text("ready>"))
+
+	term.Type("item 42")
+	term.Press(strider.Enter)
+	term.WaitFor(strider.LineMatches(1, `^echo: item \d+$`))
+	term.WaitFor(strider.Not(strider.LineMatches(0, `^echo:`)))
+}
+
+func TestFormattedMatchers(t *testing.T) {
+	term := strider.Open(t, testBinary)
+	term.WaitFor(strider.Text("ready>"))
+
+	term.Type("row 7")
+	term.Press(strider.Enter)
+	term.WaitFor(strider.Textf("echo: row %d", 7))
+	term.WaitFor(strider.Linef(1, "echo: %s %d", "row", 7))
+	term.WaitFor(strider.LineContainsf(1, "row %d", 7))
+
+	if _, desc := strider.Textf("row %d", 8)(term.Screen()); desc != `screen to contain "row 8"` {
+		t.Errorf("expected formatted value in description, got %q", desc)
+	}
+}
+
 func TestNotMatcher(t *testing.T) {
 	term := strider.Open(t, testBinary)
 	term.WaitFor(strider.Not(strider.Text("nonexistent string")))
@@ -237,141 +589,1767 @@ func TestEmptyMatcher(t *testing.T) {
 	term.WaitFor(strider.Not(strider.Empty()))
 }
 
-func TestWaitExit(t *testing.T) {
+func TestScreenEqual(t *testing.T) {
 	term := strider.Open(t, testBinary)
-	term.WaitFor(strider.Text("ready>"))
+	before := term.WaitForScreen(strider.Text("ready>"))
 
-	term.Type("quit")
+	again := term.Screen()
+	if !before.Equal(again) || !before.EqualNormalized(again) {
+		t.Fatalf("expected identical captures to be equal:\n%s\n---\n%s", before, again)
+	}
+
+	term.Type("changed")
 	term.Press(strider.Enter)
+	after := term.WaitForScreen(strider.Text("echo: changed"))
+	term.WaitFor(strider.Not(strider.SameAs(before)))
+	if after.Equal(before) || after.EqualNormalized(before) {
+		t.Fatalf("expected changed capture to differ from reference")
+	}
 
-	code := term.WaitExit(strider.WithinTimeout(10 * time.Second))
-	if code != 0 {
-		t.Errorf("expected exit code 0, got %d", code)
+	ok, desc := strider.SameAs(before)(after)
+	if ok {
+		t.Fatal("expected SameAs to fail against a changed screen")
+	}
+	if !strings.Contains(desc, "+ echo: changed") {
+		t.Errorf("expected diff in description, got:\n%s", desc)
 	}
 }
 
-func TestWaitExitNonZero(t *testing.T) {
-	term := strider.Open(t, testBinary)
-	term.WaitFor(strider.Text("ready>"))
+func TestNormalizeScreen(t *testing.T) {
+	tests := []struct {
+		name  string
+		in    string
+		nopts []strider.NormalizeOption
+		want  string
+	}{
+		{"trailing spaces and lines", "a  \r\nb \n\n  \n", nil, "a\nb\n"},
+		{"empty", "", nil, "\n"},
+		{"ansi kept by default", "\x1b[31mred\x1b[0m", nil, "\x1b[31mred\x1b[0m\n"},
+		{"strip csi", "\x1b[1;31mred\x1b[0m \x1b[2K", []strider.NormalizeOption{strider.WithStripANSI()}, "red\n"},
+		{"strip osc", "\x1b]0;title\x07\x1b]8;;http://x\x1b\\link\x1b]8;;\x1b\\", []strider.NormalizeOption{strider.WithStripANSI()}, "link\n"},
+		{"collapse", "a   b\t\tc  ", []strider.NormalizeOption{strider.WithCollapseSpaces()}, "a b c\n"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := strider.NormalizeScreen(tt.in, tt.nopts...); got != tt.want {
+				t.Errorf("NormalizeScreen(%q) = %q, want %q", tt.in, got, tt.want)
+			}
+		})
+	}
+}
 
-	term.Type("fail")
-	term.Press(strider.Enter)
+func TestNewScreen(t *testing.T) {
+	scr := strider.NewScreen(20, 4, "Name: alice", "Role: admin")
+	if w, h := scr.Size(); w != 20 || h != 4 {
+		t.Errorf("Size() = %dx%d, want 20x4", w, h)
+	}
+	if got := scr.Lines(); len(got) != 4 || got[1] != "Role: admin" || got[3] != "" {
+		t.Errorf("Lines() = %q", got)
+	}
+	if ok, desc := strider.LineContains(1, "admin")(scr); !ok {
+		t.Errorf("expected the matcher to match: %s", desc)
+	}
+	if _, _, ok := scr.CursorPosition(); ok {
+		t.Error("expected no cursor position")
+	}
 
-	code := term.WaitExit(strider.WithinTimeout(10 * time.Second))
-	if code != 1 {
-		t.Errorf("expected exit code 1, got %d", code)
+	moved := scr.WithCursor(0, 6)
+	if ok, desc := strider.Cursor(0, 6)(moved); !ok {
+		t.Errorf("expected the cursor matcher to match: %s", desc)
+	}
+	if _, _, ok := scr.CursorPosition(); ok {
+		t.Error("WithCursor modified the original screen")
 	}
 }
 
-func TestWaitExitTimeout(t *testing.T) {
-	if os.Getenv(waitExitTimeoutHelperEnv) == "1" {
-		term := strider.Open(t, testBinary)
-		term.WaitFor(strider.Text("ready>"))
-		_ = term.WaitExit(strider.WithinTimeout(150 * time.Millisecond))
-		return
+func TestScreenHash(t *testing.T) {
+	term := strider.Open(t, testBinary)
+	before := term.WaitForScreen(strider.Text("ready>"))
+
+	if again := term.Screen(); again.Hash() != before.Hash() {
+		t.Fatalf("expected identical captures to hash the same:\n%s\n---\n%s", before, again)
 	}
 
-	if _, err := exec.LookPath("tmux"); err != nil {
-		t.Skip("tmux not found in PATH")
+	term.Type("changed")
+	term.Press(strider.Enter)
+	after := term.WaitForScreen(strider.Text("echo: changed"))
+	if after.Hash() == before.Hash() {
+		t.Fatal("expected changed capture to hash differently")
 	}
+}
 
-	cmd := exec.Command(os.Args[0], "-test.run", "^TestWaitExitTimeout$")
-	cmd.Env = append(os.Environ(), waitExitTimeoutHelperEnv+"=1")
-	out, err := cmd.CombinedOutput()
-	if err == nil {
-		t.Fatalf("expected subprocess to fail, output:\n%s", string(out))
+func TestWaitForOutputEvents(t *testing.T) {
+	const script = "echo start; sleep 0.5; echo done; read y"
+	wait := func(opts ...strider.Option) strider.WaitResult {
+		term := strider.Open(t, "/bin/sh", append(opts, strider.WithArgs("-c", script))...)
+		term.WaitFor(strider.Text("start"))
+		return term.WaitForResult(strider.Text("done"), strider.WithWaitPollInterval(10*time.Millisecond))
 	}
 
-	output := string(out)
-	if !strings.Contains(output, "strider: wait-exit: timed out") {
-		t.Fatalf("expected wait-exit timeout message, got:\n%s", output)
+	// Polls without new output skip the capture.
+	if res := wait(); res.Polls > 5 {
+		t.Errorf("captured %d screens while the program was idle, want the idle polls skipped", res.Polls)
 	}
-	if !strings.Contains(output, "recent screen captures (oldest to newest):") {
-		t.Fatalf("expected recent captures header, got:\n%s", output)
+	// About 50 polls capture the same screen before "done" appears.
+	if res := wait(strider.WithoutOutputEvents()); res.Polls < 20 {
+		t.Errorf("WithoutOutputEvents: captured %d screens, want one per poll", res.Polls)
 	}
 }
 
-func TestResize(t *testing.T) {
-	term := strider.Open(t, testBinary, strider.WithSize(80, 24))
-	term.WaitFor(strider.Text("ready>"))
+func TestWaitForWakesOnOutput(t *testing.T) {
+	term := strider.Open(t, "/bin/sh", strider.WithArgs("-c", "echo start; sleep 0.3; echo later; read y"))
+	term.WaitFor(strider.Text("start"))
 
-	// Ask testbin to report size before resize.
-	term.Type("size")
-	term.Press(strider.Enter)
-	term.WaitFor(strider.Text("size: 80x24"))
+	// With a poll interval longer than the timeout, only the new output can
+	// trigger the capture that sees it.
+	term.WaitFor(strider.Text("later"), strider.WithWaitPollInterval(time.Minute), strider.WithinTimeout(5*time.Second))
+}
 
-	// Resize.
-	term.Resize(120, 40)
+func TestControlMode(t *testing.T) {
+	invocations := func(opts ...strider.Option) int64 {
+		term := strider.Open(t, testBinary, opts...)
+		term.WaitFor(strider.Text("ready>"))
+		before := tmuxcli.Invocations()
+		for range 10 {
+			term.Type("x")
+			term.Screen()
+		}
+		term.WaitFor(strider.Text("xxxxxxxxxx"))
+		return tmuxcli.Invocations() - before
+	}
 
-	// Ask for size again.
-	term.Type("size")
-	term.Press(strider.Enter)
-	term.WaitFor(strider.Text("size: 120x40"))
+	if n := invocations(); n != 0 {
+		t.Errorf("started %d tmux processes, want every command sent through the control client", n)
+	}
+	if n := invocations(strider.WithoutControlMode()); n < 20 {
+		t.Errorf("WithoutControlMode: started %d tmux processes, want one per command", n)
+	}
 }
 
-func TestScrollback(t *testing.T) {
-	term := strider.Open(t, testBinary, strider.WithSize(80, 10))
-	term.WaitFor(strider.Text("ready>"))
+func TestWaitForOneCallPerPoll(t *testing.T) {
+	term := strider.Open(t, "/bin/sh", strider.WithArgs("-c", "echo start; sleep 0.3; echo done; read y"),
+		strider.WithoutControlMode(), strider.WithoutOutputEvents())
+	term.WaitFor(strider.Text("start"))
 
-	// Generate enough lines to overflow the visible area.
-	term.Type("lines 20")
-	term.Press(strider.Enter)
-	term.WaitFor(strider.Text("ready>"))
+	before := tmuxcli.Invocations()
+	res := term.WaitForResult(strider.Text("done"), strider.WithWaitPollInterval(10*time.Millisecond))
+	if got := tmuxcli.Invocations() - before; got != int64(res.Polls) {
+		t.Errorf("started %d tmux processes in %d polls, want one per poll", got, res.Polls)
+	}
+}
 
-	// Poll until scrollback contains both early and late lines.
-	deadline := time.Now().Add(2 * time.Second)
-	for {
-		scrollback := term.Scrollback()
-		content := scrollback.String()
-		if strings.Contains(content, "line 1") && strings.Contains(content, "line 20") {
-			return
-		}
-		if time.Now().After(deadline) {
-			t.Fatalf("scrollback did not contain expected lines within timeout; last content:\n%s", content)
-		}
-		time.Sleep(50 * time.Millisecond)
+// benchmarkScreen returns a full 80x24 screen for the capture benchmarks.
+func benchmarkScreen() *strider.Screen {
+	lines := make([]string, 24)
+	for i := range lines {
+		lines[i] = fmt.Sprintf("%2d %s", i, strings.Repeat("lorem ipsum ", 6))
 	}
+	return strider.NewScreen(80, 24, lines...).WithCursor(23, 10)
 }
 
-func TestWithEnv(t *testing.T) {
-	// Use testbin with env var and verify it through command output.
-	term := strider.Open(t, "/bin/sh",
-		strider.WithArgs("-c", "echo $STRIDER_TEST_VAR && read line"),
-		strider.WithEnv("STRIDER_TEST_VAR=hello_from_env"),
-	)
-	term.WaitFor(strider.Text("hello_from_env"))
+func TestScreenHashAllocs(t *testing.T) {
+	scr := benchmarkScreen()
+	if n := testing.AllocsPerRun(100, func() { scr.Hash() }); n != 0 {
+		t.Errorf("Hash allocated %v times per call, want 0", n)
+	}
 }
 
-func TestWithDir(t *testing.T) {
-	// WithDir sets the working directory.
-	term := strider.Open(t, "/bin/sh",
-		strider.WithArgs("-c", "pwd && read line"),
-		strider.WithDir(os.TempDir()),
-	)
-	// The output should contain a path.
-	term.WaitFor(strider.Regexp(`/`))
+func BenchmarkScreenHash(b *testing.B) {
+	scr := benchmarkScreen()
+	b.ReportAllocs()
+	for b.Loop() {
+		scr.Hash()
+	}
 }
 
-func TestWithTimeout(t *testing.T) {
-	term := strider.Open(t, testBinary, strider.WithTimeout(10*time.Second))
+func BenchmarkScreen(b *testing.B) {
+	term := strider.Open(b, testBinary)
 	term.WaitFor(strider.Text("ready>"))
+	b.ReportAllocs()
+	for b.Loop() {
+		term.Screen()
+	}
 }
 
-func TestWithPollInterval(t *testing.T) {
-	term := strider.Open(t, testBinary, strider.WithPollInterval(100*time.Millisecond))
+func BenchmarkWaitFor(b *testing.B) {
+	term := strider.Open(b, testBinary)
 	term.WaitFor(strider.Text("ready>"))
+	b.ReportAllocs()
+	for b.Loop() {
+		term.WaitFor(strider.Text("ready>"))
+	}
 }
 
-func TestCtrlC(t *testing.T) {
-	term := strider.Open(t, testBinary)
-	term.WaitFor(strider.Text("ready>"))
+func TestWaitForSkipsUnchangedScreens(t *testing.T) {
+	term := strider.Open(t, "/bin/sh",
+		strider.WithArgs("-c", "echo start; sleep 0.5; echo done; read y"),
+	)
+	term.WaitFor(strider.Text("start"))
 
-	term.Press(strider.Ctrl('c'))
-	// Ctrl-C sends SIGINT; the process exits with a signal-based code.
-	code := term.WaitExit(strider.WithinTimeout(10 * time.Second))
-	// Accept any non-zero exit code (SIGINT typically yields 130 or 2).
-	_ = code
-}
+	var calls atomic.Int32
+	term.WaitFor(func(s *strider.Screen) (bool, string) {
+		calls.Add(1)
+		return s.Contains("done"), "screen to contain \"done\""
+	}, strider.WithWaitPollInterval(10*time.Millisecond))
+
+	// About 50 polls see the same screen before "done" appears.
+	if n := calls.Load(); n > 5 {
+		t.Errorf("matcher ran %d times, want it skipped on unchanged screens", n)
+	}
+
+	// A matcher on state outside the screen still runs on every poll.
+	path := filepath.Join(t.TempDir(), "flag")
+	time.AfterFunc(200*time.Millisecond, func() { os.WriteFile(path, nil, 0o644) })
+	term.WaitFor(strider.FileExists(path), strider.WithWaitPollInterval(10*time.Millisecond))
+}
+
+func TestInOrder(t *testing.T) {
+	// A status line that changes in place.
+	status := func(msgs ...string) string {
+		var b strings.Builder
+		for _, m := range msgs {
+			fmt.Fprintf(&b, `printf '\033[2J\033[H%s\n'; sleep 0.2; `, m)
+		}
+		return b.String() + "read y"
+	}
+	term := strider.Open(t, "/bin/sh",
+		strider.WithArgs("-c", status("Connecting", "Authenticated", "Ready")))
+	term.WaitFor(strider.InOrder("Connecting", "Authenticated", "Ready"))
+
+	// Skipping ahead is an ordering error, reported in the description.
+	term = strider.Open(t, "/bin/sh",
+		strider.WithArgs("-c", status("Connecting", "Ready", "Authenticated")))
+	m := strider.InOrder("Connecting", "Authenticated", "Ready")
+	var ok bool
+	var desc string
+	for _, scr := range term.Observe(time.Second, 20*time.Millisecond) {
+		ok, desc = m(scr)
+	}
+	if ok || !strings.Contains(desc, `out of order: "Ready" appeared before "Authenticated"`) {
+		t.Errorf("expected an ordering error, got %v, %q", ok, desc)
+	}
+
+	// A log in the scrollback, checked in a single capture.
+	term = strider.Open(t, "/bin/sh",
+		strider.WithArgs("-c", "echo Connecting; echo Ready; echo Authenticated; read y"))
+	term.WaitFor(strider.Text("Authenticated"))
+	ok, desc = strider.InOrder("Connecting", "Ready", "Authenticated")(term.Scrollback())
+	if !ok {
+		t.Errorf("expected the log to be in order: %s", desc)
+	}
+	ok, desc = strider.InOrder("Connecting", "Authenticated", "Ready")(term.Scrollback())
+	if ok || !strings.Contains(desc, `out of order: "Ready" appeared before "Authenticated"`) {
+		t.Errorf("expected an ordering error, got %v, %q", ok, desc)
+	}
+}
+
+// waitClearCount polls term.ClearCount until it reaches want, since the
+// output is counted shortly after the screen shows it.
+func waitClearCount(t *testing.T, term *strider.Terminal, want int) {
+	t.Helper()
+	deadline := time.Now().Add(2 * time.Second)
+	for {
+		got := term.ClearCount()
+		if got == want {
+			return
+		}
+		if got > want || time.Now().After(deadline) {
+			t.Fatalf("expected %d clears, got %d", want, got)
+		}
+		time.Sleep(20 * time.Millisecond)
+	}
+}
+
+func TestClearCount(t *testing.T) {
+	term := strider.Open(t, "/bin/sh", strider.WithArgs("-c",
+		`printf '\033[2J\033[Hone\n'; printf 'two\033[3J\n'; printf '\033cthree\033[J\n'; echo done; read y`))
+	term.WaitFor(strider.Text("done"))
+	waitClearCount(t, term, 3)
+
+	// Reset starts counting again.
+	term.Reset()
+	term.WaitFor(strider.Text("done"))
+	waitClearCount(t, term, 3)
+}
+
+func TestOutputSince(t *testing.T) {
+	term := strider.Open(t, testBinary)
+	term.WaitFor(strider.Text("ready>"))
+
+	// waitOutput waits for the output since m to contain want.
+	waitOutput := func(m strider.Mark, want string) string {
+		t.Helper()
+		var out string
+		for deadline := time.Now().Add(5 * time.Second); time.Now().Before(deadline); time.Sleep(10 * time.Millisecond) {
+			if out = term.OutputSince(m); strings.Contains(out, want) {
+				return out
+			}
+		}
+		t.Fatalf("OutputSince = %q, want it to contain %q", out, want)
+		return ""
+	}
+	waitOutput(strider.Mark{}, "ready>")
+
+	m := term.Mark()
+	if out := term.OutputSince(m); out != "" {
+		t.Errorf("OutputSince right after Mark = %q, want nothing", out)
+	}
+	term.Type("hi")
+	term.Press(strider.Enter)
+	term.WaitFor(strider.Text("echo: hi"))
+	if out := waitOutput(m, "echo: hi"); !strings.HasPrefix(out, "hi\necho: hi\n") {
+		t.Errorf("OutputSince = %q, want only the new output, without escape sequences or carriage returns", out)
+	}
+}
+
+func TestNoClears(t *testing.T) {
+	if os.Getenv(noClearsHelperEnv) == "1" {
+		term := strider.Open(t, "/bin/sh", strider.WithArgs("-c",
+			`i=0; while true; do i=$((i+1)); printf '\033[2J\033[Htick %d\n' $i; sleep 0.05; done`))
+		term.WaitFor(strider.Text("tick"))
+		term.WaitFor(strider.Text("tick 1000"), strider.NoClears())
+		return
+	}
+
+	term := strider.Open(t, testBinary)
+	term.WaitFor(strider.Text("ready>"), strider.NoClears())
+
+	cmd := exec.Command(os.Args[0], "-test.run", "^TestNoClears$")
+	cmd.Env = append(os.Environ(), noClearsHelperEnv+"=1")
+	start := time.Now()
+	out, err := cmd.CombinedOutput()
+	if err == nil {
+		t.Fatalf("expected subprocess to fail, got success:\n%s", string(out))
+	}
+	if !strings.Contains(string(out), "strider: wait-for: the program cleared the screen during the wait") {
+		t.Errorf("expected the clear to be reported, got:\n%s", string(out))
+	}
+	if elapsed := time.Since(start); elapsed > 4*time.Second {
+		t.Errorf("expected the wait to fail before its timeout, took %v", elapsed)
+	}
+}
+
+func TestFileMatchers(t *testing.T) {
+	// The program writes the file without changing the screen, so the wait
+	// must not skip unchanged captures.
+	term := strider.Open(t, "/bin/sh", strider.WithTempWorkdir(nil),
+		strider.WithArgs("-c", "echo started; sleep 0.3; echo saved-data > out.txt; read y"))
+	path := filepath.Join(term.Dir(), "out.txt")
+	term.WaitFor(strider.All(strider.Text("started"), strider.FileExists(path)))
+	term.WaitFor(strider.FileContains(path, "saved-data"))
+
+	scr := strider.NewScreen(20, 2)
+	missing := filepath.Join(t.TempDir(), "missing.txt")
+	if ok, desc := strider.FileExists(missing)(scr); ok || desc != "file "+missing+" to exist (not found)" {
+		t.Errorf("FileExists on a missing file: %v, %q", ok, desc)
+	}
+	if ok, desc := strider.FileContains(path, "other")(scr); ok || !strings.Contains(desc, `(actual content: "saved-data\n")`) {
+		t.Errorf("FileContains without the text: %v, %q", ok, desc)
+	}
+
+	// Memoize does not cache results that depend on files.
+	m := strider.Memoize(strider.FileExists(missing))
+	if ok, _ := m(scr); ok {
+		t.Fatal("expected no match before the file exists")
+	}
+	if err := os.WriteFile(missing, nil, 0o644); err != nil {
+		t.Fatal(err)
+	}
+	if ok, desc := m(scr); !ok {
+		t.Errorf("expected a match once the file exists: %s", desc)
+	}
+}
+
+func TestServiceMatchers(t *testing.T) {
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	addr := ln.Addr().String()
+
+	var healthy atomic.Bool
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if !healthy.Load() {
+			w.WriteHeader(http.StatusServiceUnavailable)
+		}
+	}))
+	defer srv.Close()
+
+	term := strider.Open(t, testBinary)
+	term.WaitFor(strider.All(strider.Text("ready>"), strider.PortOpen(addr)))
+
+	scr := term.Screen()
+	if ok, desc := strider.HTTPHealthy(srv.URL)(scr); ok || !strings.Contains(desc, "(status 503 Service Unavailable)") {
+		t.Errorf("HTTPHealthy on a failing service: %v, %q", ok, desc)
+	}
+	time.AfterFunc(200*time.Millisecond, func() { healthy.Store(true) })
+	term.WaitFor(strider.HTTPHealthy(srv.URL))
+
+	ln.Close()
+	if ok, desc := strider.PortOpen(addr)(scr); ok || !strings.HasPrefix(desc, "port "+addr+" to accept connections (") {
+		t.Errorf("PortOpen on a closed port: %v, %q", ok, desc)
+	}
+}
+
+func TestDocCaptures(t *testing.T) {
+	dir := filepath.Join(t.TempDir(), "docs", "images")
+	term := strider.Open(t, testBinary, strider.WithDocCaptures(dir))
+	term.WaitFor(strider.Text("ready>"))
+	term.Type("<b> & co")
+	term.Press(strider.Enter)
+	term.WaitFor(strider.Text("echo: <b> & co"))
+	term.Capture("echo screen")
+
+	text, err := os.ReadFile(filepath.Join(dir, "echo_screen.txt"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !strings.Contains(string(text), "echo: <b> & co\n") {
+		t.Errorf("text capture missing the echoed line:\n%s", text)
+	}
+	svg, err := os.ReadFile(filepath.Join(dir, "echo_screen.svg"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !strings.HasPrefix(string(svg), "<svg ") || !strings.Contains(string(svg), "echo: &lt;b&gt; &amp; co") {
+		t.Errorf("SVG capture missing the escaped echoed line:\n%s", svg)
+	}
+
+	// Without the option, Capture does nothing.
+	strider.Open(t, testBinary).Capture("ignored")
+	if _, err := os.Stat(filepath.Join(dir, "ignored.txt")); !os.IsNotExist(err) {
+		t.Errorf("Capture without WithDocCaptures wrote a file: %v", err)
+	}
+}
+
+func TestAlignedColumns(t *testing.T) {
+	scr := strider.NewScreen(30, 5,
+		"Item        Amount  Note",
+		"apples          12  ok",
+		"pears          150  ok",
+		"figs          1200   late",
+	)
+
+	// Amounts end before column 18; notes start at column 20 on rows 1-2.
+	for _, m := range []strider.Matcher{
+		strider.AlignedColumns([]int{1, 2, 3}, 18),
+		strider.AlignedColumns([]int{0, 1, 2, 3}, 0),
+		strider.AlignedColumns([]int{0, 1, 2}, 20),
+	} {
+		if ok, desc := m(scr); !ok {
+			t.Errorf("expected a match: %s", desc)
+		}
+	}
+
+	ok, desc := strider.AlignedColumns([]int{1, 3, 7}, 20)(scr)
+	want := `rows [1 3 7] to have a field boundary at column 20 (no boundary: row 3: "00  | lat", row 7: off screen)`
+	if ok || desc != want {
+		t.Errorf("misaligned rows: got %v, %q, want false, %q", ok, desc, want)
+	}
+}
+
+func TestTextLogical(t *testing.T) {
+	shalom := "\u05e9\u05dc\u05d5\u05dd" // Hebrew, in logical order
+	visual := "\u05dd\u05d5\u05dc\u05e9" // as drawn on the screen
+	scr := strider.NewScreen(40, 4,
+		"hello "+visual+" world",
+		"world "+visual,
+		"total: 42 "+visual,
+	)
+
+	for _, text := range []string{
+		"hello " + shalom + " world", // left-to-right line
+		shalom + " world",            // right-to-left line
+		shalom + " 42 :total",        // right-to-left line with a number
+	} {
+		if ok, desc := strider.TextLogical(text)(scr); !ok {
+			t.Errorf("expected a match: %s", desc)
+		}
+	}
+	if scr.Contains(shalom) {
+		t.Error("the capture should hold the visual order only")
+	}
+	if ok, desc := strider.TextLogical(visual)(scr); ok {
+		t.Errorf("expected visual-order text not to match: %s", desc)
+	}
+}
+
+func TestMemoize(t *testing.T) {
+	term := strider.Open(t, testBinary)
+	first := term.WaitForScreen(strider.Text("ready>"))
+
+	var calls int
+	m := strider.Memoize(func(s *strider.Screen) (bool, string) {
+		calls++
+		return s.Contains("echo: memo"), `screen to contain "echo: memo"`
+	})
+
+	// Shared between branches and waits, m runs once per distinct screen.
+	both := strider.Any(strider.All(strider.Text("ready>"), m), strider.All(strider.Text(">"), m))
+	if ok, _ := both(first); ok {
+		t.Fatal("expected no match before typing")
+	}
+	if ok, _ := m(term.Screen()); ok {
+		t.Fatal("expected no match on an identical capture")
+	}
+	if calls != 1 {
+		t.Errorf("matcher ran %d times on identical screens, want 1", calls)
+	}
+
+	term.Type("memo")
+	term.Press(strider.Enter)
+	term.WaitFor(m)
+	if calls < 2 {
+		t.Errorf("matcher ran %d times, want it run again after the screen changed", calls)
+	}
+}
+
+func TestKeymapDo(t *testing.T) {
+	km := strider.Keymap{"submit": strider.Enter}
+	term := strider.Open(t, testBinary, strider.WithKeymap(km))
+	term.WaitFor(strider.Text("ready>"))
+
+	term.Type("hello")
+	term.Do("submit")
+	term.WaitFor(strider.Text("echo: hello"))
+}
+
+func TestMacro(t *testing.T) {
+	echo := strider.Macro{Name: "echo", Steps: []strider.MacroStep{
+		{WaitFor: "ready>"},
+		{Type: "say \"hi\""},
+		{Press: []strider.Key{strider.Enter}},
+		{WaitFor: "echo: say"},
+	}}
+	path := filepath.Join(t.TempDir(), "echo.macro")
+	if err := strider.SaveMacro(path, echo); err != nil {
+		t.Fatal(err)
+	}
+	loaded, err := strider.LoadMacro(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(loaded, echo) {
+		t.Errorf("LoadMacro = %+v, want %+v", loaded, echo)
+	}
+
+	term := strider.Open(t, testBinary)
+	term.Play(loaded)
+	term.WaitFor(strider.Text(`echo: say "hi"`))
+
+	bad := filepath.Join(t.TempDir(), "bad.macro")
+	if err := os.WriteFile(bad, []byte("# comment\n\npress Enter\nclick 1 2\n"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := strider.LoadMacro(bad); err == nil || !strings.Contains(err.Error(), "bad.macro:4: unknown action \"click\"") {
+		t.Errorf("LoadMacro error = %v, want an unknown action on line 4", err)
+	}
+	if err := strider.SaveMacro(bad, strider.Macro{Steps: []strider.MacroStep{{Press: []strider.Key{"Entr"}}}}); err == nil {
+		t.Error("SaveMacro with an unknown key succeeded")
+	}
+}
+
+func TestTypeSecret(t *testing.T) {
+	if os.Getenv(typeSecretHelperEnv) == "1" {
+		term := strider.Open(t, testBinary)
+		term.WaitFor(strider.Text("ready>"))
+		term.TypeSecret("hunter2")
+		term.Press(strider.Enter)
+		term.WaitFor(strider.Text("never appears"), strider.WithinTimeout(150*time.Millisecond))
+		return
+	}
+
+	term := strider.Open(t, testBinary)
+	term.WaitFor(strider.Text("ready>"))
+	m := term.Mark()
+	term.TypeSecret("hunter2")
+	term.Press(strider.Enter)
+	term.WaitFor(strider.Text("echo: \u25cf\u25cf\u25cf"))
+	if scr := term.Screen(); scr.Contains("hunter2") {
+		t.Errorf("screen shows the secret:\n%s", scr)
+	}
+	if out := term.OutputSince(m); strings.Contains(out, "hunter2") {
+		t.Errorf("OutputSince shows the secret: %q", out)
+	}
+
+	cmd := exec.Command(os.Args[0], "-test.run", "^TestTypeSecret$", "-test.v")
+	cmd.Env = append(os.Environ(), typeSecretHelperEnv+"=1", "STRIDER_DEBUG=1")
+	out, err := cmd.CombinedOutput()
+	if err == nil {
+		t.Fatalf("expected subprocess to fail, output:\n%s", out)
+	}
+	output := string(out)
+	if strings.Contains(output, "hunter2") {
+		t.Errorf("failure output shows the secret:\n%s", output)
+	}
+	for _, want := range []string{"strider: input: type \"\u25cf\u25cf\u25cf\"", "echo: \u25cf\u25cf\u25cf"} {
+		if !strings.Contains(output, want) {
+			t.Errorf("expected output to contain %q, got:\n%s", want, output)
+		}
+	}
+}
+
+func TestRedact(t *testing.T) {
+	redact := strider.WithRedact(`tok_[a-z0-9]+`, `alice@example\.com`)
+	if os.Getenv(redactHelperEnv) == "1" {
+		term := strider.Open(t, testBinary, redact, strider.WithEnv("API_TOKEN=tok_abc123"))
+		term.WaitFor(strider.Text("ready>"))
+		term.Type("alice@example.com")
+		term.Press(strider.Enter)
+		term.WaitFor(strider.Text("echo: "))
+		term.Type("fail")
+		term.Press(strider.Enter)
+		term.WaitFor(strider.Text("tok_abc123"))
+		return
+	}
+
+	term := strider.Open(t, testBinary, redact)
+	term.WaitFor(strider.Text("ready>"))
+	term.Type("tok_abc123 and alice@example.com")
+	term.Press(strider.Enter)
+	term.WaitFor(strider.Text("echo: \u25cf\u25cf\u25cf and \u25cf\u25cf\u25cf"))
+
+	cmd := exec.Command(os.Args[0], "-test.run", "^TestRedact$", "-test.v")
+	cmd.Env = append(os.Environ(), redactHelperEnv+"=1", "STRIDER_DEBUG=1")
+	out, err := cmd.CombinedOutput()
+	if err == nil {
+		t.Fatalf("expected subprocess to fail, output:\n%s", out)
+	}
+	output := string(out)
+	for _, leaked := range []string{"tok_abc123", "alice@example.com"} {
+		if strings.Contains(output, leaked) {
+			t.Errorf("failure output contains %q:\n%s", leaked, output)
+		}
+	}
+	for _, want := range []string{
+		"strider: input: type \"\u25cf\u25cf\u25cf\"",
+		"waiting for: screen to contain \"\u25cf\u25cf\u25cf\"",
+		"env: API_TOKEN=\u25cf\u25cf\u25cf",
+	} {
+		if !strings.Contains(output, want) {
+			t.Errorf("expected output to contain %q, got:\n%s", want, output)
+		}
+	}
+}
+
+func TestRedactStyledText(t *testing.T) {
+	// The program styles part of the value, splitting it with SGR sequences.
+	path := filepath.Join(t.TempDir(), "session.cast")
+	t.Run("session", func(t *testing.T) {
+		term := strider.Open(t, "/bin/sh",
+			strider.WithArgs("-c", `printf 'token: tok_\033[1mabc\033[0m123\n'; read y`),
+			strider.WithRedact(`tok_[a-z0-9]+`), strider.WithStyles(), strider.WithRecording(path))
+		scr := term.WaitForScreen(strider.Text("token: \u25cf\u25cf\u25cf"))
+		if scr.Contains("abc") || scr.Contains("123") {
+			t.Errorf("screen shows part of the value:\n%s", scr)
+		}
+	})
+
+	data, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var output strings.Builder
+	for _, line := range strings.Split(strings.TrimSuffix(string(data), "\n"), "\n")[1:] {
+		var event []any
+		if err := json.Unmarshal([]byte(line), &event); err != nil || len(event) != 3 {
+			t.Fatalf("event %q: %v", line, err)
+		}
+		if event[1] == "o" {
+			output.WriteString(event[2].(string))
+		}
+	}
+	for _, leaked := range []string{"tok_", "abc", "123"} {
+		if strings.Contains(output.String(), leaked) {
+			t.Errorf("recording contains %q: %q", leaked, output.String())
+		}
+	}
+	if !strings.Contains(output.String(), "\u25cf\u25cf\u25cf") {
+		t.Errorf("recording does not contain the mask: %q", output.String())
+	}
+}
+
+func TestRecording(t *testing.T) {
+	dir := t.TempDir()
+	path := filepath.Join(dir, "session.cast")
+	t.Run("session", func(t *testing.T) {
+		term := strider.Open(t, testBinary, strider.WithRecording(path))
+		term.WaitFor(strider.Text("ready>"))
+		term.TypeSecret("hunter2")
+		term.Press(strider.Enter)
+		term.WaitFor(strider.Text("echo: "))
+		term.Resize(100, 30)
+	})
+
+	data, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	lines := strings.Split(strings.TrimSuffix(string(data), "\n"), "\n")
+	var header struct {
+		Version, Width, Height int
+		Title                  string
+	}
+	if err := json.Unmarshal([]byte(lines[0]), &header); err != nil {
+		t.Fatalf("header %q: %v", lines[0], err)
+	}
+	if header.Version != 2 || header.Width != 80 || header.Height != 24 || header.Title != "TestRecording/session" {
+		t.Errorf("header = %+v, want version 2, 80x24, titled after the test", header)
+	}
+
+	var output strings.Builder
+	var last float64
+	var resized bool
+	for _, line := range lines[1:] {
+		var event []any
+		if err := json.Unmarshal([]byte(line), &event); err != nil || len(event) != 3 {
+			t.Fatalf("event %q: %v", line, err)
+		}
+		at, kind, text := event[0].(float64), event[1].(string), event[2].(string)
+		if at < last {
+			t.Errorf("event %q is earlier than the one before it", line)
+		}
+		last = at
+		switch kind {
+		case "o":
+			output.WriteString(text)
+		case "r":
+			resized = text == "100x30"
+		}
+	}
+	if !strings.Contains(output.String(), "ready>") || !strings.Contains(output.String(), "echo: \u25cf\u25cf\u25cf") {
+		t.Errorf("recorded output = %q, want the prompt and the redacted echo", output.String())
+	}
+	if strings.Contains(string(data), "hunter2") {
+		t.Errorf("recording contains the secret:\n%s", data)
+	}
+	if !resized {
+		t.Errorf("recording has no 100x30 resize event:\n%s", data)
+	}
+
+	t.Run("env", func(t *testing.T) {
+		t.Setenv("STRIDER_RECORD", dir)
+		strider.Open(t, testBinary).WaitFor(strider.Text("ready>"))
+	})
+	if _, err := os.Stat(filepath.Join(dir, "TestRecording_env.cast")); err != nil {
+		t.Errorf("STRIDER_RECORD: %v", err)
+	}
+}
+
+func TestStep(t *testing.T) {
+	if os.Getenv(stepHelperEnv) == "1" {
+		term := strider.Open(t, testBinary)
+		term.Step("start", func() {
+			term.WaitFor(strider.Text("ready>"))
+		})
+		term.Step("checkout", func() {
+			term.Step("payment", func() {
+				term.WaitFor(strider.Text("never appears"), strider.WithinTimeout(150*time.Millisecond))
+			})
+		})
+		return
+	}
+
+	if _, err := exec.LookPath("tmux"); err != nil {
+		t.Skip("tmux not found in PATH")
+	}
+
+	cmd := exec.Command(os.Args[0], "-test.run", "^TestStep$")
+	cmd.Env = append(os.Environ(), stepHelperEnv+"=1")
+	out, err := cmd.CombinedOutput()
+	if err == nil {
+		t.Fatalf("expected subprocess to fail, output:\n%s", string(out))
+	}
+
+	output := string(out)
+	for _, want := range []string{
+		`strider: step "start"`,
+		`strider: step "checkout > payment"`,
+		"strider: wait-for: timed out",
+		`strider: step "checkout > payment" failed`,
+		`strider: step "checkout" failed`,
+	} {
+		if !strings.Contains(output, want) {
+			t.Errorf("expected output to contain %q, got:\n%s", want, output)
+		}
+	}
+	if strings.Contains(output, `step "start" failed`) {
+		t.Errorf("passing step reported as failed:\n%s", output)
+	}
+}
+
+func TestWaitExit(t *testing.T) {
+	term := strider.Open(t, testBinary)
+	term.WaitFor(strider.Text("ready>"))
+
+	term.Type("quit")
+	term.Press(strider.Enter)
+
+	code := term.WaitExit(strider.WithinTimeout(10 * time.Second))
+	if code != 0 {
+		t.Errorf("expected exit code 0, got %d", code)
+	}
+}
+
+func TestWaitExitNonZero(t *testing.T) {
+	term := strider.Open(t, testBinary)
+	term.WaitFor(strider.Text("ready>"))
+
+	term.Type("fail")
+	term.Press(strider.Enter)
+
+	code := term.WaitExit(strider.WithinTimeout(10 * time.Second))
+	if code != 1 {
+		t.Errorf("expected exit code 1, got %d", code)
+	}
+}
+
+func TestWaitExitTimeout(t *testing.T) {
+	if os.Getenv(waitExitTimeoutHelperEnv) == "1" {
+		term := strider.Open(t, testBinary)
+		term.WaitFor(strider.Text("ready>"))
+		_ = term.WaitExit(strider.WithinTimeout(150 * time.Millisecond))
+		return
+	}
+
+	if _, err := exec.LookPath("tmux"); err != nil {
+		t.Skip("tmux not found in PATH")
+	}
+
+	cmd := exec.Command(os.Args[0], "-test.run", "^TestWaitExitTimeout$")
+	cmd.Env = append(os.Environ(), waitExitTimeoutHelperEnv+"=1")
+	out, err := cmd.CombinedOutput()
+	if err == nil {
+		t.Fatalf("expected subprocess to fail, output:\n%s", string(out))
+	}
+
+	output := string(out)
+	if !strings.Contains(output, "strider: wait-exit: timed out") {
+		t.Fatalf("expected wait-exit timeout message, got:\n%s", output)
+	}
+	if !strings.Contains(output, "recent screen captures (oldest to newest):") {
+		t.Fatalf("expected recent captures header, got:\n%s", output)
+	}
+}
+
+func TestEarlyExitDiagnostics(t *testing.T) {
+	if os.Getenv(earlyExitHelperEnv) == "1" {
+		term := strider.Open(t, "/bin/sh",
+			strider.WithArgs("-c", "echo 'config error: missing --port'; exit 2"),
+			strider.WithEnv("APP_MODE=test"),
+			strider.WithDir(os.TempDir()),
+		)
+		term.WaitFor(strider.Text("never appears"))
+		return
+	}
+
+	if _, err := exec.LookPath("tmux"); err != nil {
+		t.Skip("tmux not found in PATH")
+	}
+
+	cmd := exec.Command(os.Args[0], "-test.run", "^TestEarlyExitDiagnostics$")
+	cmd.Env = append(os.Environ(), earlyExitHelperEnv+"=1")
+	out, err := cmd.CombinedOutput()
+	if err == nil {
+		t.Fatalf("expected subprocess to fail, output:\n%s", string(out))
+	}
+
+	output := string(out)
+	for _, want := range []string{
+		"strider: wait-for: process exited unexpectedly",
+		`command: /bin/sh -c 'echo '\''config error: missing --port'\''; exit 2'`,
+		"env: APP_MODE=test",
+		"dir: " + os.TempDir(),
+		"scrollback tail (last 1 lines):\n",
+		"config error: missing --port",
+	} {
+		if !strings.Contains(output, want) {
+			t.Errorf("expected output to contain %q, got:\n%s", want, output)
+		}
+	}
+}
+
+func TestBinaryPreflight(t *testing.T) {
+	switch os.Getenv(binaryCheckHelperEnv) {
+	case "missing":
+		strider.Open(t, "./does-not-exist")
+		return
+	case "not-executable":
+		strider.Open(t, "./app.sh", strider.WithTempWorkdir(map[string]string{
+			"app.sh": "#!/bin/sh\necho hi\n",
+		}))
+		return
+	case "directory":
+		strider.Open(t, os.TempDir())
+		return
+	}
+
+	if _, err := exec.LookPath("tmux"); err != nil {
+		t.Skip("tmux not found in PATH")
+	}
+
+	tests := []struct {
+		name string
+		want string
+	}{
+		{"missing", `strider: open: binary "./does-not-exist" does not exist`},
+		{"not-executable", `is not executable (mode -rw-r--r--)`},
+		{"directory", "is a directory"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			cmd := exec.Command(os.Args[0], "-test.run", "^TestBinaryPreflight$")
+			cmd.Env = append(os.Environ(), binaryCheckHelperEnv+"="+tt.name)
+			out, err := cmd.CombinedOutput()
+			if err == nil {
+				t.Fatalf("expected subprocess to fail, output:\n%s", string(out))
+			}
+			if !strings.Contains(string(out), tt.want) {
+				t.Fatalf("expected output to contain %q, got:\n%s", tt.want, string(out))
+			}
+		})
+	}
+}
+
+func TestStrictEnvironment(t *testing.T) {
+	switch os.Getenv(strictHelperEnv) {
+	case "default":
+		strider.Open(t, testBinary)
+		return
+	case "option":
+		strider.Open(t, testBinary, strider.WithStrictEnvironment())
+		return
+	case "set":
+		strider.SetStrictEnvironment(true)
+		strider.Open(t, testBinary)
+		return
+	}
+
+	// A tmux found in $PATH that is too old: a skip by default, a failure
+	// when strict.
+	bin := t.TempDir()
+	if err := os.WriteFile(filepath.Join(bin, "tmux"), []byte("#!/bin/sh\necho 'tmux 2.9'\n"), 0o755); err != nil {
+		t.Fatal(err)
+	}
+	var env []string
+	for _, kv := range os.Environ() {
+		if !strings.HasPrefix(kv, "STRIDER_TMUX=") && !strings.HasPrefix(kv, "STRIDER_STRICT=") &&
+			!strings.HasPrefix(kv, "PATH=") {
+			env = append(env, kv)
+		}
+	}
+	env = append(env, "PATH="+bin+string(os.PathListSeparator)+os.Getenv("PATH"))
+
+	const tooOld = "strider: open: tmux version 2.9 is below minimum 3.0"
+	tests := []struct {
+		name   string
+		helper string
+		env    string
+		fail   bool
+	}{
+		{"default", "default", "", false},
+		{"WithStrictEnvironment", "option", "", true},
+		{"SetStrictEnvironment", "set", "", true},
+		{"STRIDER_STRICT", "default", "STRIDER_STRICT=1", true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			cmd := exec.Command(os.Args[0], "-test.run", "^TestStrictEnvironment$", "-test.v")
+			cmd.Env = append(env, strictHelperEnv+"="+tt.helper)
+			if tt.env != "" {
+				cmd.Env = append(cmd.Env, tt.env)
+			}
+			out, err := cmd.CombinedOutput()
+			output := string(out)
+			if !strings.Contains(output, tooOld) {
+				t.Fatalf("expected output to contain %q, got:\n%s", tooOld, output)
+			}
+			if tt.fail {
+				if err == nil || !strings.Contains(output, "--- FAIL: TestStrictEnvironment") {
+					t.Fatalf("expected the test to fail, got:\n%s", output)
+				}
+			} else if err != nil || !strings.Contains(output, "--- SKIP: TestStrictEnvironment") {
+				t.Fatalf("expected the test to be skipped, got:\n%s", output)
+			}
+		})
+	}
+}
+
+func TestSession(t *testing.T) {
+	if _, err := exec.LookPath("tmux"); err != nil {
+		t.Skip("tmux not found in PATH")
+	}
+
+	if _, err := strider.OpenSession(strider.SessionConfig{
+		Binary:  testBinary,
+		Options: []strider.Option{strider.WithSize(0, 0)},
+	}); err == nil || !strings.Contains(err.Error(), "WithSize: width and height must be positive") {
+		t.Fatalf("expected an invalid options error, got %v", err)
+	}
+
+	var log strings.Builder
+	s, err := strider.OpenSession(strider.SessionConfig{
+		Name:    "demo",
+		Binary:  testBinary,
+		Options: []strider.Option{strider.WithRandomSeed()},
+		Log:     &log,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := s.WaitFor(strider.Text("ready>")); err != nil {
+		t.Fatal(err)
+	}
+	if err := s.Type("hello"); err != nil {
+		t.Fatal(err)
+	}
+	if err := s.Press(strider.Enter); err != nil {
+		t.Fatal(err)
+	}
+	scr, err := s.WaitForScreen(strider.Text("echo: hello"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !scr.Contains("echo: hello") {
+		t.Fatalf("expected the matching screen, got:\n%s", scr)
+	}
+
+	// A failed wait is an error, and the session stays usable.
+	err = s.WaitFor(strider.Text("never appears"), strider.WithinTimeout(200*time.Millisecond))
+	if err == nil || !strings.Contains(err.Error(), "strider: wait-for: timed out after 200ms") {
+		t.Fatalf("expected a timeout error, got %v", err)
+	}
+	if err := s.Play(strider.Macro{Name: "greet", Steps: []strider.MacroStep{
+		{Type: "hi"}, {Press: []strider.Key{strider.Enter}}, {WaitFor: "echo: hi"},
+	}}); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := s.Close(); err != nil {
+		t.Fatal(err)
+	}
+	if err := s.Type("late"); err == nil || err.Error() != "strider: session: closed" {
+		t.Fatalf("expected a closed session error, got %v", err)
+	}
+	if !strings.Contains(log.String(), "strider: seed ") {
+		t.Fatalf("expected the seed to be logged, got:\n%s", log.String())
+	}
+}
+
+func TestWithReporter(t *testing.T) {
+	if os.Getenv(reporterHelperEnv) == "1" {
+		// A Reporter that does not report to t still fails the test.
+		term := strider.Open(t, testBinary, strider.WithReporter(strider.ReporterFunc(func(t testing.TB, f strider.Failure) {
+			fmt.Printf("reported: %s %s fatal=%v\n", f.Wait.Op, f.Wait.Outcome, f.Fatal)
+		})))
+		term.WaitFor(strider.Text("never shown"), strider.WithinTimeout(200*time.Millisecond))
+		fmt.Println("still running")
+		return
+	}
+
+	// A Session returns the Failure a Reporter is given.
+	s, err := strider.OpenSession(strider.SessionConfig{
+		Binary:  testBinary,
+		Options: []strider.Option{strider.WithTranscript("reporter")},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer s.Close()
+
+	if err := s.WaitFor(strider.Text("ready>")); err != nil {
+		t.Fatal(err)
+	}
+	err = s.WaitFor(strider.Text("never shown"), strider.WithinTimeout(200*time.Millisecond))
+	var f *strider.Failure
+	if !errors.As(err, &f) {
+		t.Fatalf("expected a *strider.Failure, got %v", err)
+	}
+	if !strings.HasPrefix(f.Message, "strider: wait-for: timed out after 200ms") || !f.Fatal || f.Exit != nil {
+		t.Errorf("unexpected failure: %+v", f)
+	}
+	if f.Wait == nil || f.Wait.Outcome != strider.WaitTimedOut || f.Wait.Description != `screen to contain "never shown"` {
+		t.Errorf("unexpected failed wait: %+v", f.Wait)
+	}
+	if len(f.Screens) == 0 || !f.Screens[len(f.Screens)-1].Contains("ready>") {
+		t.Errorf("expected the recent screens, got %d", len(f.Screens))
+	}
+	if !strings.Contains(f.Transcript, `< wait-for: screen to contain "ready>"`) {
+		t.Errorf("expected the transcript so far, got:\n%s", f.Transcript)
+	}
+
+	if err := s.Type("quit"); err != nil {
+		t.Fatal(err)
+	}
+	if err := s.Press(strider.Enter); err != nil {
+		t.Fatal(err)
+	}
+	if err := s.WaitFor(strider.Text("never shown")); !errors.As(err, &f) {
+		t.Fatalf("expected the wait to fail when the program exits, got %v", err)
+	}
+	if f.Exit == nil || f.Exit.Op != "wait-for" || f.Wait.Outcome != strider.WaitProgramExited {
+		t.Errorf("expected the exit to be reported, got %+v", f)
+	}
+
+	cmd := exec.Command(os.Args[0], "-test.run", "^TestWithReporter$")
+	cmd.Env = append(os.Environ(), reporterHelperEnv+"=1")
+	out, err := cmd.CombinedOutput()
+	if err == nil {
+		t.Fatalf("expected subprocess to fail, got success:\n%s", out)
+	}
+	if !strings.Contains(string(out), "reported: wait-for timed_out fatal=true") ||
+		strings.Contains(string(out), "timed out after") || strings.Contains(string(out), "still running") {
+		t.Errorf("expected only the reporter's output and the test stopped, got:\n%s", out)
+	}
+}
+
+func TestAbortWhen(t *testing.T) {
+	if _, err := exec.LookPath("tmux"); err != nil {
+		t.Skip("tmux not found in PATH")
+	}
+
+	var crashed atomic.Bool
+	s, err := strider.OpenSession(strider.SessionConfig{
+		Binary: testBinary,
+		Options: []strider.Option{strider.WithAbortWhen(func() (bool, string) {
+			return crashed.Load(), "backend crashed"
+		})},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer s.Close()
+	if err := s.WaitFor(strider.Text("ready>")); err != nil {
+		t.Fatal(err)
+	}
+
+	crashed.Store(true)
+	start := time.Now()
+	err = s.WaitFor(strider.Text("never appears"), strider.WithinTimeout(10*time.Second))
+	if err == nil || !strings.Contains(err.Error(), "strider: wait-for: aborted: backend crashed\n    waiting for: screen to contain \"never appears\"") {
+		t.Fatalf("expected the wait to abort, got %v", err)
+	}
+	if elapsed := time.Since(start); elapsed > 5*time.Second {
+		t.Fatalf("expected the wait to abort early, took %v", elapsed)
+	}
+
+	_, err = s.WaitExit(strider.WithinTimeout(10 * time.Second))
+	if err == nil || !strings.Contains(err.Error(), "strider: wait-exit: aborted: backend crashed\n    pane still alive") {
+		t.Fatalf("expected the exit wait to abort, got %v", err)
+	}
+}
+
+var registerNarrowProfile = sync.OnceFunc(func() {
+	strider.RegisterProfile(strider.Profile{Name: "narrow", Options: []strider.Option{strider.WithSize(40, 10)}})
+})
+
+func TestProfiles(t *testing.T) {
+	wide := strider.Profile{Name: "wide", Options: []strider.Option{strider.WithSize(120, 30)}}
+	term := strider.Open(t, testBinary, strider.WithProfile(wide))
+	if w, h := term.Screen().Size(); w != 120 || h != 30 {
+		t.Errorf("WithProfile: got size %dx%d, want 120x30", w, h)
+	}
+
+	// Options after the profile override it.
+	term = strider.Open(t, testBinary, strider.WithProfile(wide), strider.WithSize(100, 20))
+	if w, h := term.Screen().Size(); w != 100 || h != 20 {
+		t.Errorf("option after WithProfile: got size %dx%d, want 100x20", w, h)
+	}
+
+	// STRIDER_PROFILE applies a registered profile before Open's options.
+	registerNarrowProfile()
+	t.Setenv("STRIDER_PROFILE", "narrow")
+	if w, h := strider.Open(t, testBinary).Screen().Size(); w != 40 || h != 10 {
+		t.Errorf("STRIDER_PROFILE: got size %dx%d, want 40x10", w, h)
+	}
+	if w, h := strider.Open(t, testBinary, strider.WithSize(50, 12)).Screen().Size(); w != 50 || h != 12 {
+		t.Errorf("option with STRIDER_PROFILE: got size %dx%d, want 50x12", w, h)
+	}
+}
+
+func TestReopen(t *testing.T) {
+	term := strider.Open(t, testBinary, strider.WithSize(60, 15), strider.WithRandomSeed(),
+		strider.WithReadyWhen(strider.Text("ready>")))
+	seed, _ := term.Seed()
+
+	narrow := term.Reopen(strider.WithSize(40, 10))
+	if w, h := narrow.Screen().Size(); w != 40 || h != 10 {
+		t.Errorf("reopened size: got %dx%d, want 40x10", w, h)
+	}
+	if !narrow.Screen().Contains("ready>") {
+		t.Error("Reopen did not wait for the original WithReadyWhen matcher")
+	}
+	if got, ok := narrow.Seed(); !ok || got != seed {
+		t.Errorf("reopened seed: got %d, %v, want %d", got, ok, seed)
+	}
+
+	// The original terminal keeps running with its own configuration.
+	term.Type("size")
+	term.Press(strider.Enter)
+	term.WaitFor(strider.Text("size: 60x15"))
+}
+
+func TestInvalidOptions(t *testing.T) {
+	if os.Getenv(invalidOptionsHelperEnv) == "1" {
+		strider.Open(t, testBinary,
+			strider.WithSize(0, -5),
+			strider.WithHistoryLimit(-1),
+			strider.WithEnv("NO_COLOR=1", "BROKEN"),
+			strider.WithTempWorkdir(map[string]string{"../escape.txt": ""}),
+			strider.WithUser("strider-no-such-user"),
+			strider.WithMaxInputRate(-10),
+			strider.WithKeymap(strider.Keymap{"quit": "Escap", "save": strider.Ctrl('s')}),
+			strider.WithRedact("tok_(["),
+			strider.WithAbortWhen(nil),
+		)
+		return
+	}
+
+	cmd := exec.Command(os.Args[0], "-test.run", "^TestInvalidOptions$")
+	cmd.Env = append(os.Environ(), invalidOptionsHelperEnv+"=1", "STRIDER_PROFILE=no-such-profile")
+	out, err := cmd.CombinedOutput()
+	if err == nil {
+		t.Fatalf("expected subprocess to fail, output:\n%s", string(out))
+	}
+
+	output := string(out)
+	for _, want := range []string{
+		"strider: open: invalid options:",
+		"- WithSize: width and height must be positive (got 0x-5)",
+		"- WithHistoryLimit: limit must be between 0 and",
+		`- WithEnv: entry "BROKEN" is not in KEY=VALUE format`,
+		`- WithTempWorkdir: file path "../escape.txt" must be relative`,
+		"- WithUser: user: unknown user strider-no-such-user",
+		"- WithMaxInputRate: rate must not be negative (got -10)",
+		`- WithKeymap: action "quit": unknown key "Escap" (tmux would type it as text); did you mean "Escape"?`,
+		`- WithRedact: invalid pattern "tok_([": error parsing regexp`,
+		"- WithAbortWhen: condition must not be nil",
+		`- STRIDER_PROFILE: unknown profile "no-such-profile" (known: fast-local, recording, slow-ci)`,
+	} {
+		if !strings.Contains(output, want) {
+			t.Errorf("expected output to contain %q, got:\n%s", want, output)
+		}
+	}
+	if strings.Contains(output, "NO_COLOR") {
+		t.Errorf("valid env entry reported as a problem:\n%s", output)
+	}
+}
+
+func TestScreenPrimaryAndAlternate(t *testing.T) {
+	term := strider.Open(t, "/bin/sh",
+		strider.WithArgs("-c", `echo primary-text; printf '\033[?1049h'; echo alt-text; read x; printf '\033[?1049l'; echo restored; read y`),
+	)
+	term.WaitFor(strider.Text("alt-text"))
+
+	if !term.InAlternateScreen() {
+		t.Fatal("expected the alternate screen to be active")
+	}
+	if scr := term.ScreenPrimary(); !scr.Contains("primary-text") || scr.Contains("alt-text") {
+		t.Errorf("primary screen while alternate is displayed:\n%s", scr)
+	}
+	if scr := term.ScreenAlternate(); !scr.Contains("alt-text") {
+		t.Errorf("alternate screen:\n%s", scr)
+	}
+
+	term.Press(strider.Enter)
+	term.WaitFor(strider.Text("restored"))
+
+	if term.InAlternateScreen() {
+		t.Fatal("expected the primary screen to be restored")
+	}
+	if scr := term.ScreenPrimary(); !scr.Contains("primary-text") || scr.Contains("alt-text") {
+		t.Errorf("primary screen after restore:\n%s", scr)
+	}
+}
+
+func TestScrollView(t *testing.T) {
+	term := strider.Open(t, "/bin/sh",
+		strider.WithArgs("-c", `i=1; while [ $i -le 10 ]; do echo line$i; i=$((i+1)); done; read x`),
+		strider.WithSize(40, 5),
+	)
+	term.WaitFor(strider.Line(3, "line10"))
+
+	// line1-line6 are in the history; the view scrolled up by 3 starts at line4.
+	scr := term.ScrollView(3)
+	want := []string{"line4", "line5", "line6", "line7", "line8"}
+	if got := scr.Lines(); !slices.Equal(got, want) {
+		t.Errorf("ScrollView(3) = %q, want %q", got, want)
+	}
+
+	// Scrolling stops at the top of the history.
+	if got := term.ScrollView(100).Line(0); got != "line1" {
+		t.Errorf("ScrollView(100) first line = %q, want %q", got, "line1")
+	}
+
+	// Copy mode is left again, so input still reaches the program.
+	term.Press(strider.Enter)
+	term.WaitExit()
+}
+
+func TestAssertRestoresScreen(t *testing.T) {
+	term := strider.Open(t, testBinary)
+	term.WaitFor(strider.Text("ready>"))
+	term.Type("hello")
+	term.Press(strider.Enter)
+	term.WaitFor(strider.Text("echo: hello"))
+
+	strider.AssertRestoresScreen(t, term, func() {
+		term.Resize(60, 20)
+		term.WaitFor(strider.SizeIs(60, 20))
+		term.Resize(80, 24)
+	})
+}
+
+func TestResizeSteps(t *testing.T) {
+	term := strider.Open(t, "/bin/sh",
+		strider.WithArgs("-c", `trap 'echo "winch $(stty size)"' WINCH; while :; do sleep 0.01; done`),
+	)
+
+	term.ResizeSteps(40, 10, 80, 20, 4, 300*time.Millisecond)
+	term.WaitFor(strider.All(
+		strider.SizeIs(80, 20),
+		strider.Text("winch 15 60"),
+		strider.Text("winch 20 80"),
+	))
+}
+
+func TestWithSizePreset(t *testing.T) {
+	term := strider.Open(t, testBinary, strider.WithSizePreset(strider.Size132x43))
+	term.WaitFor(strider.All(strider.Text("ready>"), strider.SizeIs(132, 43)))
+}
+
+func TestForEachSize(t *testing.T) {
+	sizes := []strider.Size{{Width: 40, Height: 10}, {Width: 100, Height: 30}}
+	var ran []string
+	strider.ForEachSize(t, sizes, testBinary, func(t *testing.T, term *strider.Terminal) {
+		ran = append(ran, t.Name())
+		term.WaitFor(strider.Text("ready>"))
+		w, h := term.Screen().Size()
+		if want := path.Base(t.Name()); fmt.Sprintf("%dx%d", w, h) != want {
+			t.Errorf("screen size %dx%d, want %s", w, h, want)
+		}
+	})
+
+	want := []string{"TestForEachSize/40x10", "TestForEachSize/100x30"}
+	if !slices.Equal(ran, want) {
+		t.Errorf("ran subtests %q, want %q", ran, want)
+	}
+}
+
+func TestWithServerLimits(t *testing.T) {
+	if os.Getenv(serverLimitsHelperEnv) == "1" {
+		term := strider.Open(t, "/bin/sh",
+			strider.WithArgs("-c", "echo spinning; while :; do :; done"),
+			strider.WithServerLimits(time.Second, 0),
+		)
+		term.WaitFor(strider.Text("never appears"), strider.WithinTimeout(10*time.Second))
+		return
+	}
+
+	// Limits generous enough for the program leave it working normally.
+	term := strider.Open(t, testBinary, strider.WithServerLimits(time.Minute, 4<<30))
+	term.WaitFor(strider.Text("ready>"))
+	term.Type("hello")
+	term.Press(strider.Enter)
+	term.WaitFor(strider.Text("echo: hello"))
+
+	cmd := exec.Command(os.Args[0], "-test.run", "^TestWithServerLimits$")
+	cmd.Env = append(os.Environ(), serverLimitsHelperEnv+"=1")
+	out, err := cmd.CombinedOutput()
+	if err == nil {
+		t.Fatalf("expected subprocess to fail, output:\n%s", string(out))
+	}
+	if !strings.Contains(string(out), "limit: CPU time limit of 1s exceeded (WithServerLimits)") {
+		t.Fatalf("expected CPU limit message, got:\n%s", string(out))
+	}
+}
+
+func TestWithNoNetwork(t *testing.T) {
+	// /proc/net/dev lists the interfaces of the process's network namespace:
+	// in a new one, only the loopback device.
+	term := strider.Open(t, "/bin/sh",
+		strider.WithArgs("-c", "echo interfaces: $(grep -c : /proc/net/dev) $GREETING; read y"),
+		strider.WithEnv("GREETING=offline"),
+		strider.WithNoNetwork(),
+	)
+	term.WaitFor(strider.Text("interfaces: 1 offline"))
+
+	term.Press(strider.Enter)
+	if code := term.WaitExit(); code != 0 {
+		t.Errorf("exit code = %d, want 0", code)
+	}
+}
+
+func TestWithSandbox(t *testing.T) {
+	outside := filepath.Join(os.TempDir(), fmt.Sprintf("strider-sandbox-%d", os.Getpid()))
+	t.Cleanup(func() { os.Remove(outside) })
+
+	if os.Getenv(sandboxHelperEnv) == "1" {
+		term := strider.Open(t, "/bin/sh",
+			strider.WithArgs("-c", `echo data > "$OUTSIDE" || exit 1; read y`),
+			strider.WithEnv("OUTSIDE="+outside),
+			strider.WithTempWorkdir(nil),
+			strider.WithSandbox(strider.SandboxPolicy{}),
+		)
+		term.WaitFor(strider.Text("never appears"))
+		return
+	}
+
+	script := `echo data > inside.txt && echo wrote inside; echo data > "$OUTSIDE" || echo denied outside; read y`
+	term := strider.Open(t, "/bin/sh",
+		strider.WithArgs("-c", script),
+		strider.WithEnv("OUTSIDE="+outside),
+		strider.WithTempWorkdir(nil),
+		strider.WithSandbox(strider.SandboxPolicy{}),
+	)
+	term.WaitFor(strider.All(strider.Text("wrote inside"), strider.Text("denied outside")))
+	if _, err := os.Stat(outside); err == nil {
+		t.Errorf("expected the sandbox to stop the write to %s", outside)
+	}
+	if _, err := os.Stat(filepath.Join(term.Dir(), "inside.txt")); err != nil {
+		t.Errorf("expected the write in the working directory to succeed: %v", err)
+	}
+
+	if python, err := exec.LookPath("python3"); err == nil {
+		connect := `import socket
+for family in (socket.AF_INET, socket.AF_UNIX):
+    try:
+        socket.socket(family).close()
+        print("socket", family.name, "allowed")
+    except PermissionError:
+        print("socket", family.name, "denied")
+input()`
+		net := strider.Open(t, python, strider.WithArgs("-c", connect), strider.WithSandbox(strider.SandboxPolicy{}))
+		net.WaitFor(strider.All(strider.Text("socket AF_INET denied"), strider.Text("socket AF_UNIX allowed")))
+		allowed := strider.Open(t, python, strider.WithArgs("-c", connect), strider.WithSandbox(strider.SandboxPolicy{AllowNetwork: true}))
+		allowed.WaitFor(strider.Text("socket AF_INET allowed"))
+	}
+
+	cmd := exec.Command(os.Args[0], "-test.run", "^TestWithSandbox$")
+	cmd.Env = append(os.Environ(), sandboxHelperEnv+"=1")
+	out, err := cmd.CombinedOutput()
+	if err == nil {
+		t.Fatalf("expected subprocess to fail, output:\n%s", out)
+	}
+	if want := "network none (WithSandbox); permission errors may come from it"; !strings.Contains(string(out), want) {
+		t.Errorf("expected output to contain %q, got:\n%s", want, out)
+	}
+}
+
+func TestWithUser(t *testing.T) {
+	term := strider.Open(t, "/bin/sh",
+		strider.WithArgs("-c", "echo user: $(id -un) $GREETING; read y"),
+		strider.WithEnv("GREETING=hello"),
+		strider.WithDir("/"),
+		strider.WithUser("nobody"),
+	)
+	term.WaitFor(strider.Text("user: nobody hello"))
+
+	term.Press(strider.Enter)
+	if code := term.WaitExit(); code != 0 {
+		t.Errorf("exit code = %d, want 0", code)
+	}
+}
+
+func TestTypeAt(t *testing.T) {
+	term := strider.Open(t, testBinary, strider.WithSize(40, 10))
+	term.WaitFor(strider.Text("ready>"))
+	term.Type("grid")
+	term.Press(strider.Enter)
+	term.WaitFor(strider.All(strider.Text("grid:"), strider.Cursor(1, 0)))
+
+	term.TypeAt(4, 12, "XY")
+	term.WaitFor(strider.All(strider.RegionEquals(4, 12, []string{"XY"}), strider.Cursor(4, 14)))
+
+	term.TypeAt(2, 3, "Z")
+	scr := term.WaitForScreen(strider.RegionEquals(2, 3, []string{"Z"}))
+	if row, col, ok := scr.CursorPosition(); !ok || row != 2 || col != 4 {
+		t.Errorf("CursorPosition() = %d, %d, %v; want 2, 4, true", row, col, ok)
+	}
+
+	term.Press(strider.Ctrl('d'))
+	term.WaitFor(strider.Text("ready>"))
+}
+
+func TestTypeAtNavigator(t *testing.T) {
+	var targets []string
+	term := strider.Open(t, testBinary,
+		strider.WithNavigator(func(term *strider.Terminal, row, col int) error {
+			targets = append(targets, fmt.Sprintf("%d,%d", row, col))
+			return nil
+		}),
+	)
+	term.WaitFor(strider.Text("ready>"))
+
+	term.TypeAt(0, 6, "here")
+	term.WaitFor(strider.Text("ready>here"))
+	if !slices.Equal(targets, []string{"0,6"}) {
+		t.Errorf("navigator targets = %v, want [0,6]", targets)
+	}
+}
+
+func TestResize(t *testing.T) {
+	term := strider.Open(t, testBinary, strider.WithSize(80, 24))
+	term.WaitFor(strider.Text("ready>"))
+
+	// Ask testbin to report size before resize.
+	term.Type("size")
+	term.Press(strider.Enter)
+	term.WaitFor(strider.Text("size: 80x24"))
+
+	// Resize.
+	term.Resize(120, 40)
+	term.WaitFor(strider.SizeIs(120, 40))
+
+	// Ask for size again.
+	term.Type("size")
+	term.Press(strider.Enter)
+	term.WaitFor(strider.Text("size: 120x40"))
+}
+
+func TestScrollback(t *testing.T) {
+	term := strider.Open(t, testBinary, strider.WithSize(80, 10))
+	term.WaitFor(strider.Text("ready>"))
+
+	// Generate enough lines to overflow the visible area.
+	term.Type("lines 20")
+	term.Press(strider.Enter)
+	term.WaitFor(strider.Text("ready>"))
+
+	// Poll until scrollback contains both early and late lines.
+	deadline := time.Now().Add(2 * time.Second)
+	for {
+		scrollback := term.Scrollback()
+		content := scrollback.String()
+		if strings.Contains(content, "line 1") && strings.Contains(content, "line 20") {
+			return
+		}
+		if time.Now().After(deadline) {
+			t.Fatalf("scrollback did not contain expected lines within timeout; last content:\n%s", content)
+		}
+		time.Sleep(50 * time.Millisecond)
+	}
+}
+
+func TestScrollbackVisibleRange(t *testing.T) {
+	term := strider.Open(t, testBinary, strider.WithSize(80, 10))
+	term.WaitFor(strider.Text("ready>"))
+
+	term.Type("lines 20")
+	term.Press(strider.Enter)
+	term.WaitFor(strider.Text("line 20"))
+
+	scrollback := term.Scrollback()
+	if w, h := scrollback.Size(); w != 80 || h != 10 {
+		t.Errorf("expected scrollback size 80x10, got %dx%d", w, h)
+	}
+	if total := scrollback.TotalLines(); total <= 10 {
+		t.Fatalf("expected more than 10 scrollback lines, got %d", total)
+	}
+
+	start, end := scrollback.VisibleRange()
+	if end-start != 10 || end != scrollback.TotalLines() {
+		t.Fatalf("expected visible range to cover the last 10 lines, got [%d, %d) of %d", start, end, scrollback.TotalLines())
+	}
+	visible := strings.Join(scrollback.Lines()[start:end], "\n")
+	if want := term.Screen().String(); visible != want {
+		t.Errorf("expected visible range to equal the screen\nvisible:\n%s\nscreen:\n%s", visible, want)
+	}
+}
+
+func TestWithEnv(t *testing.T) {
+	// Use testbin with env var and verify it through command output.
+	term := strider.Open(t, "/bin/sh",
+		strider.WithArgs("-c", "echo $STRIDER_TEST_VAR && read line"),
+		strider.WithEnv("STRIDER_TEST_VAR=hello_from_env"),
+	)
+	term.WaitFor(strider.Text("hello_from_env"))
+}
+
+func TestSizeEnv(t *testing.T) {
+	term := strider.Open(t, "/bin/sh",
+		strider.WithArgs("-c", `echo "size-env: $COLUMNS x $LINES" && read line`),
+		strider.WithSize(100, 30),
+	)
+	term.WaitFor(strider.Text("size-env: 100 x 30"))
+
+	override := strider.Open(t, "/bin/sh",
+		strider.WithArgs("-c", `echo "size-env: $COLUMNS x $LINES" && read line`),
+		strider.WithSize(100, 30),
+		strider.WithEnv("COLUMNS=7"),
+	)
+	override.WaitFor(strider.Text("size-env: 7 x 30"))
+
+	optOut := strider.Open(t, "/bin/sh",
+		strider.WithArgs("-c", `echo "size-env: $COLUMNS x $LINES" && read line`),
+		strider.WithSize(100, 30),
+		strider.WithoutSizeEnv(),
+	)
+	optOut.WaitFor(strider.Text("size-env:"))
+	optOut.WaitFor(strider.Not(strider.Text("100 x 30")))
+}
+
+func TestPinnedTerminfo(t *testing.T) {
+	term := strider.Open(t, "/bin/sh",
+		strider.WithArgs("-c", `test -f "$TERMINFO/t/$TERM" && echo "pinned: $TERM"; read line`),
+		strider.WithPinnedTerminfo(),
+	)
+	term.WaitFor(strider.Text("pinned: tmux-256color"))
+}
+
+func TestWithFrozenClock(t *testing.T) {
+	t0 := time.Date(2024, 2, 29, 13, 45, 0, 0, time.FixedZone("X", 3600))
+	term := strider.Open(t, "/bin/sh",
+		strider.WithArgs("-c", `echo "now=$STRIDER_NOW faketime=$FAKETIME tz=$TZ" && read line`),
+		strider.WithFrozenClock(t0),
+	)
+	term.WaitFor(strider.Text("now=2024-02-29T12:45:00Z faketime=2024-02-29 12:45:00 tz=UTC"))
+}
+
+func TestWithSeed(t *testing.T) {
+	term := strider.Open(t, "/bin/sh",
+		strider.WithArgs("-c", `echo "seed=$STRIDER_SEED" && read line`),
+		strider.WithSeed(42),
+	)
+	term.WaitFor(strider.Text("seed=42"))
+
+	if seed, ok := term.Seed(); !ok || seed != 42 {
+		t.Errorf("Seed() = %d, %v; want 42, true", seed, ok)
+	}
+}
+
+func TestWithRandomSeed(t *testing.T) {
+	t.Run("from environment", func(t *testing.T) {
+		t.Setenv("STRIDER_SEED", "1234")
+		term := strider.Open(t, "/bin/sh",
+			strider.WithArgs("-c", `echo "seed=$STRIDER_SEED" && read line`),
+			strider.WithRandomSeed(),
+		)
+		term.WaitFor(strider.Text("seed=1234"))
+	})
+
+	t.Run("generated", func(t *testing.T) {
+		t.Setenv("STRIDER_SEED", "")
+		term := strider.Open(t, "/bin/sh",
+			strider.WithArgs("-c", `echo "seed=$STRIDER_SEED" && read line`),
+			strider.WithRandomSeed(),
+		)
+		seed, ok := term.Seed()
+		if !ok {
+			t.Fatal("Seed() reported no seed")
+		}
+		term.WaitFor(strider.Textf("seed=%d", seed))
+	})
+}
+
+func TestWithReadyWhen(t *testing.T) {
+	term := strider.Open(t, testBinary, strider.WithReadyWhen(strider.Text("ready>")))
+
+	// Open returned only once the prompt was rendered.
+	if scr := term.Screen(); !scr.Contains("ready>") {
+		t.Errorf("expected screen to contain 'ready>' after Open, got:\n%s", scr)
+	}
+
+	term.Type("hello")
+	term.Press(strider.Enter)
+	term.WaitFor(strider.Text("echo: hello"))
+
+	term.Reset()
+	if scr := term.Screen(); !scr.Contains("ready>") || scr.Contains("echo: hello") {
+		t.Errorf("expected a fresh prompt after Reset, got:\n%s", scr)
+	}
+}
+
+func TestWithReadyWhenTimeout(t *testing.T) {
+	if os.Getenv(readyWhenHelperEnv) == "1" {
+		strider.Open(t, testBinary,
+			strider.WithReadyWhen(strider.Text("never appears")),
+			strider.WithTimeout(150*time.Millisecond),
+		)
+		return
+	}
+
+	if _, err := exec.LookPath("tmux"); err != nil {
+		t.Skip("tmux not found in PATH")
+	}
+
+	cmd := exec.Command(os.Args[0], "-test.run", "^TestWithReadyWhenTimeout$")
+	cmd.Env = append(os.Environ(), readyWhenHelperEnv+"=1")
+	out, err := cmd.CombinedOutput()
+	if err == nil {
+		t.Fatalf("expected subprocess to fail, output:\n%s", string(out))
+	}
+
+	output := string(out)
+	if !strings.Contains(output, "strider: open: ready-when: timed out") {
+		t.Fatalf("expected ready-when timeout message, got:\n%s", output)
+	}
+	if !strings.Contains(output, `waiting for: screen to contain "never appears"`) {
+		t.Fatalf("expected matcher description, got:\n%s", output)
+	}
+}
+
+func TestWithDir(t *testing.T) {
+	// WithDir sets the working directory.
+	term := strider.Open(t, "/bin/sh",
+		strider.WithArgs("-c", "pwd && read line"),
+		strider.WithDir(os.TempDir()),
+	)
+	// The output should contain a path.
+	term.WaitFor(strider.Regexp(`/`))
+}
+
+func TestWithTempWorkdir(t *testing.T) {
+	term := strider.Open(t, "/bin/sh",
+		strider.WithArgs("-c", "cat greeting.txt nested/dir/name.txt && read line"),
+		strider.WithTempWorkdir(map[string]string{
+			"greeting.txt":        "hello, ",
+			"nested/dir/name.txt": "fixture",
+		}),
+	)
+	term.WaitFor(strider.Text("hello, fixture"))
+
+	dir := term.Dir()
+	if dir == "" {
+		t.Fatal("expected Dir to return the temp workdir")
+	}
+	if _, err := os.Stat(filepath.Join(dir, "nested", "dir", "name.txt")); err != nil {
+		t.Errorf("expected fixture file in %s: %v", dir, err)
+	}
+
+	// The option given last sets the directory.
+	again := term.Reopen(strider.WithDir(dir))
+	again.WaitFor(strider.Text("hello, fixture"))
+	if again.Dir() != dir {
+		t.Errorf("expected WithDir to override WithTempWorkdir, got Dir %q, want %q", again.Dir(), dir)
+	}
+	fresh := strider.Open(t, "/bin/sh", strider.WithArgs("-c", "read line"),
+		strider.WithDir(dir), strider.WithTempWorkdir(nil))
+	if d := fresh.Dir(); d == "" || d == dir {
+		t.Errorf("expected WithTempWorkdir to override WithDir, got Dir %q", d)
+	}
+}
+
+func TestWithTimeout(t *testing.T) {
+	term := strider.Open(t, testBinary, strider.WithTimeout(10*time.Second))
+	term.WaitFor(strider.Text("ready>"))
+}
+
+func TestWithPollInterval(t *testing.T) {
+	term := strider.Open(t, testBinary, strider.WithPollInterval(100*time.Millisecond))
+	term.WaitFor(strider.Text("ready>"))
+}
+
+func TestCtrlC(t *testing.T) {
+	term := strider.Open(t, testBinary)
+	term.WaitFor(strider.Text("ready>"))
+
+	term.Press(strider.Ctrl('c'))
+	// Ctrl-C sends SIGINT; the process exits with a signal-based code.
+	code := term.WaitExit(strider.WithinTimeout(10 * time.Second))
+	// Accept any non-zero exit code (SIGINT typically yields 130 or 2).
+	_ = code
+}
 
 func TestMatchSnapshotUpdate(t *testing.T) {
 	// Only run snapshot update test when STRIDER_UPDATE is set.
@@ -381,93 +2359,1224 @@ func TestMatchSnapshotUpdate(t *testing.T) {
 
 	term := strider.Open(t, testBinary)
 	term.WaitFor(strider.Text("ready>"))
-	term.MatchSnapshot("ready-screen")
+	term.MatchSnapshot("ready-screen")
+}
+
+func TestParallelSubtests(t *testing.T) {
+	for i := 0; i < 5; i++ {
+		i := i
+		t.Run(fmt.Sprintf("subtest-%d", i), func(t *testing.T) {
+			t.Parallel()
+			term := strider.Open(t, testBinary)
+			term.WaitFor(strider.Text("ready>"))
+
+			msg := fmt.Sprintf("parallel-%d", i)
+			term.Type(msg)
+			term.Press(strider.Enter)
+			term.WaitFor(strider.Text("echo: " + msg))
+		})
+	}
+}
+
+func TestStressParallel(t *testing.T) {
+	// Run 25 parallel subtests to verify no cross-test leakage.
+	// Each subtest gets its own tmux session and verifies isolation.
+	for i := 0; i < 25; i++ {
+		i := i
+		t.Run(fmt.Sprintf("stress-%d", i), func(t *testing.T) {
+			t.Parallel()
+			term := strider.Open(t, testBinary)
+			term.WaitFor(strider.Text("ready>"))
+
+			msg := fmt.Sprintf("stress-msg-%d", i)
+			term.Type(msg)
+			term.Press(strider.Enter)
+			term.WaitFor(strider.Text("echo: " + msg))
+
+			// Verify the screen contains our message, not another test's.
+			screen := term.Screen()
+			if !screen.Contains("echo: " + msg) {
+				t.Errorf("expected screen to contain our echo, got:\n%s", screen)
+			}
+		})
+	}
+}
+
+func TestCursorMatcher(t *testing.T) {
+	term := strider.Open(t, testBinary)
+	term.WaitFor(strider.Text("ready>"))
+	term.WaitFor(strider.Cursor(0, 6))
+}
+
+func TestCursorWithinMatcher(t *testing.T) {
+	term := strider.Open(t, testBinary)
+	screen := term.WaitForScreen(strider.Text("ready>"))
+	term.WaitFor(strider.CursorWithin(0, 0, 0, 10))
+
+	ok, desc := strider.CursorWithin(5, 0, 10, 10)(screen)
+	if ok {
+		t.Fatal("expected CursorWithin to fail outside the rectangle")
+	}
+	if !strings.Contains(desc, "(actual: row=0, col=6)") {
+		t.Errorf("expected actual cursor position in description, got %q", desc)
+	}
+}
+
+func TestAlignmentMatchers(t *testing.T) {
+	term := strider.Open(t, "/bin/sh",
+		strider.WithArgs("-c", "printf '%80s\\n%43s\\n' clock middle && read line"),
+		strider.WithSize(80, 5),
+	)
+	term.WaitFor(strider.RightAlignedOn(0, "clock"))
+	term.WaitFor(strider.CenteredOn(1, "middle"))
+
+	screen := term.Screen()
+	if ok, desc := strider.CenteredOn(0, "clock")(screen); ok {
+		t.Errorf("expected right-aligned text not to be centered: %s", desc)
+	}
+	ok, desc := strider.RightAlignedOn(1, "middle")(screen)
+	if ok {
+		t.Fatal("expected centered text not to be right-aligned")
+	}
+	if !strings.Contains(desc, "(actual: ends at column 43)") {
+		t.Errorf("expected actual end column in description, got %q", desc)
+	}
+}
+
+func TestRegionEqualsMatcher(t *testing.T) {
+	term := strider.Open(t, "/bin/sh",
+		strider.WithArgs("-c", "printf '  +--+\\n  |ab|\\n  +--+\\n' && read line"),
+		strider.WithSize(20, 5),
+	)
+	term.WaitFor(strider.RegionEquals(0, 2, []string{
+		"+--+",
+		"|ab|",
+		"+--+",
+	}))
+	term.WaitFor(strider.RegionEquals(1, 3, []string{"ab"}))
+	term.WaitFor(strider.RegionEquals(1, 5, []string{"|  "}))
+
+	ok, desc := strider.RegionEquals(1, 3, []string{"ax"})(term.Screen())
+	if ok {
+		t.Fatal("expected RegionEquals to fail on different content")
+	}
+	if !strings.Contains(desc, `(row 1: actual "ab")`) {
+		t.Errorf("expected actual region content in description, got %q", desc)
+	}
+}
+
+func TestScreenBoxes(t *testing.T) {
+	term := strider.Open(t, "/bin/sh",
+		strider.WithArgs("-c", "printf 'title\\n +------+  +--+\\n | Save |  |  |\\n | Quit |  +--+\\n +------+\\n' && read line"),
+		strider.WithSize(30, 8),
+	)
+	term.WaitFor(strider.BoxContaining("Quit"))
+	term.WaitFor(strider.Not(strider.BoxContaining("title")))
+
+	boxes := term.Screen().Boxes()
+	if len(boxes) != 2 {
+		t.Fatalf("expected 2 boxes, got %d: %+v", len(boxes), boxes)
+	}
+
+	dialog := boxes[0]
+	if dialog.Top != 1 || dialog.Left != 1 || dialog.Bottom != 4 || dialog.Right != 8 {
+		t.Errorf("unexpected dialog bounds: %+v", dialog)
+	}
+	if got, want := dialog.String(), " Save \n Quit "; got != want {
+		t.Errorf("dialog content = %q, want %q", got, want)
+	}
+
+	small := boxes[1]
+	if small.Top != 1 || small.Left != 11 || small.Bottom != 3 || small.Right != 14 {
+		t.Errorf("unexpected small box bounds: %+v", small)
+	}
+}
+
+func TestModifiedKeys(t *testing.T) {
+	if got := strider.AltKey(strider.CtrlKey(strider.ShiftKey(strider.Left))); got != "M-C-S-Left" {
+		t.Errorf("composed modifiers: got %q, want %q", got, "M-C-S-Left")
+	}
+
+	// cat -v shows the escape sequences the program receives.
+	term := strider.Open(t, "/bin/sh", strider.WithArgs("-c", "stty -icanon -echo; echo started; exec cat -v"))
+	term.WaitFor(strider.Text("started"))
+	term.Press(strider.AltKey(strider.Left), strider.CtrlKey(strider.Right), strider.ShiftKey(strider.Up))
+	term.WaitFor(strider.Text("^[[1;3D^[[1;5C^[[1;2A"))
+}
+
+func TestClick(t *testing.T) {
+	for _, tc := range []struct {
+		name  string
+		modes string
+		want  string
+	}{
+		// cat -v shows the reports: ESC as ^[, and the legacy encoding's
+		// values offset by 32.
+		{"sgr", "1000h\\033[?1006h", "^[[<0;3;2M^[[<0;3;2m^[[<2;1;1M^[[<2;1;1m^[[<1;80;24M^[[<1;80;24m"},
+		{"legacy", "1000h", `^[[M #"^[[M##"^[[M"!!^[[M#!!^[[M!p8^[[M#p8`},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			script := `printf '\033[?` + tc.modes + `'; stty -icanon -echo; echo started; exec cat -v`
+			term := strider.Open(t, "/bin/sh", strider.WithArgs("-c", script))
+			term.WaitFor(strider.Text("started"))
+			term.Click(1, 2)
+			term.RightClick(0, 0)
+			term.MiddleClick(23, 79)
+			term.WaitFor(strider.Text(tc.want))
+		})
+	}
+}
+
+func TestCompose(t *testing.T) {
+	term := strider.Open(t, "/bin/sh", strider.WithArgs("-c", "stty -icanon -echo; echo started; exec cat -v"))
+	term.WaitFor(strider.Text("started"))
+
+	frames := term.Compose("x", "y", strider.Enter)
+	if len(frames) != 3 {
+		t.Fatalf("got %d frames, want one per key (3)", len(frames))
+	}
+	for i, want := range []string{"x", "xy"} {
+		if got := strings.TrimRight(frames[i].Line(1), " "); got != want {
+			t.Errorf("frame %d: line 1 is %q, want %q", i, got, want)
+		}
+	}
+	if _, col, _ := frames[2].CursorPosition(); col != 0 {
+		t.Errorf("frame 2: cursor at column %d, want 0 after Enter", col)
+	}
+}
+
+func TestStyles(t *testing.T) {
+	script := `printf '\033[1;31mFAIL\033[0m ok \033[4;38;5;208mX\033[0;48;2;1;2;3mY\033[0m\n'; echo done; exec cat`
+	term := strider.Open(t, "/bin/sh", strider.WithArgs("-c", script), strider.WithStyles())
+	term.WaitFor(strider.Text("done"))
+
+	scr := term.Screen()
+	if got := scr.Line(0); got != "FAIL ok XY" {
+		t.Fatalf("line 0 is %q, want the text without escape sequences", got)
+	}
+	for _, tc := range []struct {
+		col  int
+		want strider.Style
+	}{
+		{0, strider.Style{Fg: strider.ColorRed, Bold: true}},
+		{3, strider.Style{Fg: strider.ColorRed, Bold: true}},
+		{5, strider.Style{}},
+		{8, strider.Style{Fg: strider.IndexedColor(208), Underline: true}},
+		{9, strider.Style{Bg: strider.RGBColor(1, 2, 3)}},
+	} {
+		if got, ok := scr.StyleAt(0, tc.col); !ok || got != tc.want {
+			t.Errorf("StyleAt(0, %d) = %v, %v, want %v", tc.col, got, ok, tc.want)
+		}
+	}
+
+	term.WaitFor(strider.TextStyled("FAIL", strider.Style{Fg: strider.ColorRed, Bold: true}))
+	ok, desc := strider.TextStyled("ok", strider.Style{Bold: true})(scr)
+	if want := `screen to contain "ok" in style bold (found in style default)`; ok || desc != want {
+		t.Errorf("wrong style: got %v, %q, want false, %q", ok, desc, want)
+	}
+
+	plain := strider.NewScreen(20, 2, "FAIL")
+	if _, ok := plain.StyleAt(0, 0); ok || plain.Styled() {
+		t.Error("a screen captured without styles reported a style")
+	}
+	if ok, desc := strider.TextStyled("FAIL", strider.Style{})(plain); ok || !strings.Contains(desc, "WithStyles") {
+		t.Errorf("unstyled screen: got %v, %q, want false and a hint to use WithStyles", ok, desc)
+	}
+	if got := (strider.Style{Fg: strider.RGBColor(255, 0, 16), Reverse: true}).String(); got != "fg=#ff0010 reverse" {
+		t.Errorf("Style.String() = %q", got)
+	}
+}
+
+func TestValidateKey(t *testing.T) {
+	for _, k := range []strider.Key{
+		strider.Enter, strider.Delete, strider.F12, "x", "-", "\u00e9",
+		strider.Ctrl('c'), strider.Alt('x'), "C-M-Left", "S-Tab", "^a", "pgup", "KP5",
+		strider.Insert, strider.BTab, strider.ShiftF1, strider.ShiftF12, strider.KP0, strider.KP9,
+		strider.KPEnter, strider.KPPlus, strider.KPMinus, strider.KPStar, strider.KPSlash, strider.KPPeriod,
+		strider.AltKey(strider.Left), strider.CtrlKey(strider.ShiftKey(strider.End)), strider.AltKey(strider.Key("x")),
+	} {
+		if err := strider.ValidateKey(k); err != nil {
+			t.Errorf("ValidateKey(%q): %v", k, err)
+		}
+	}
+
+	for _, tt := range []struct {
+		key  strider.Key
+		want string
+	}{
+		{"Entr", `unknown key "Entr" (tmux would type it as text); did you mean "Enter"?`},
+		{"C-Uo", `unknown key "C-Uo" (tmux would type it as text); did you mean "C-Up"?`},
+		{"hello", `unknown key "hello" (tmux would type it as text)` + "\n"},
+		{"", "empty key"},
+	} {
+		err := strider.ValidateKey(tt.key)
+		if err == nil || !strings.HasPrefix(err.Error(), tt.want) {
+			t.Errorf("ValidateKey(%q) = %v, want an error starting with %q", tt.key, err, tt.want)
+		}
+	}
+	if err := strider.ValidateKey("Entr"); !strings.Contains(err.Error(), "valid key names: Enter, Escape,") {
+		t.Errorf("ValidateKey error does not list the valid key names: %v", err)
+	}
+}
+
+func TestSendKeys(t *testing.T) {
+	term := strider.Open(t, testBinary)
+	term.WaitFor(strider.Text("ready>"))
+
+	// Use raw SendKeys to send literal text.
+	term.SendKeys("h", "i")
+	term.WaitFor(strider.Text("hi"))
+}
+
+func TestMultipleCommands(t *testing.T) {
+	term := strider.Open(t, testBinary)
+	term.WaitFor(strider.Text("ready>"))
+
+	// First command.
+	term.Type("first")
+	term.Press(strider.Enter)
+	term.WaitFor(strider.Text("echo: first"))
+
+	// Second command.
+	term.Type("second")
+	term.Press(strider.Enter)
+	term.WaitFor(strider.Text("echo: second"))
+
+	// Third command.
+	term.Type("third")
+	term.Press(strider.Enter)
+	term.WaitFor(strider.Text("echo: third"))
+}
+
+func TestBackspace(t *testing.T) {
+	term := strider.Open(t, testBinary)
+	term.WaitFor(strider.Text("ready>"))
+
+	// Type text, use backspace to correct, then press Enter.
+	// The terminal line discipline handles backspace.
+	term.Type("helloo")
+	term.Press(strider.Backspace)
+	// After backspace, "hello" remains. Type more and send.
+	term.Press(strider.Enter)
+	term.WaitFor(strider.Text("echo: hello"))
+}
+
+func TestScrollbackTailInDiagnostics(t *testing.T) {
+	if os.Getenv(scrollbackTailHelperEnv) == "1" {
+		term := strider.Open(t, testBinary,
+			strider.WithSize(80, 10),
+			strider.WithScrollbackTail(40),
+		)
+		term.WaitFor(strider.Text("ready>"))
+		term.Type("lines 30")
+		term.Press(strider.Enter)
+		term.WaitFor(strider.Text("line 30"))
+		term.WaitFor(strider.Text("never appears"), strider.WithinTimeout(150*time.Millisecond))
+		return
+	}
+
+	if _, err := exec.LookPath("tmux"); err != nil {
+		t.Skip("tmux not found in PATH")
+	}
+
+	cmd := exec.Command(os.Args[0], "-test.run", "^TestScrollbackTailInDiagnostics$")
+	cmd.Env = append(os.Environ(), scrollbackTailHelperEnv+"=1")
+	out, err := cmd.CombinedOutput()
+	if err == nil {
+		t.Fatalf("expected subprocess to fail, output:\n%s", string(out))
+	}
+
+	output := string(out)
+	if !strings.Contains(output, "scrollback tail (last ") {
+		t.Fatalf("expected scrollback tail header, got:\n%s", output)
+	}
+	// "line 1" scrolled off the 10-row screen, so it can only come from
+	// the scrollback tail.
+	if !regexp.MustCompile(`\x{2502}line 1 +\x{2502}`).MatchString(output) {
+		t.Fatalf("expected scrolled-off line in scrollback tail, got:\n%s", output)
+	}
+}
+
+func TestFlagRunPatternUpdate(t *testing.T) {
+	if dir := os.Getenv(flagUpdateHelperEnv); dir != "" {
+		t.Chdir(dir)
+		term := strider.Open(t, testBinary)
+		term.WaitFor(strider.Text("ready>"))
+		term.MatchSnapshot("flag-update")
+		return
+	}
+
+	if _, err := exec.LookPath("tmux"); err != nil {
+		t.Skip("tmux not found in PATH")
+	}
+
+	dir := t.TempDir()
+	cmd := exec.Command(os.Args[0], "-test.run", "^TestFlagRunPatternUpdate$",
+		"-strider.run-pattern-update", "^TestFlagRunPatternUpdate$")
+	cmd.Env = append(os.Environ(), flagUpdateHelperEnv+"="+dir)
+	out, err := cmd.CombinedOutput()
+	if err != nil {
+		t.Fatalf("expected subprocess to pass, got %v, output:\n%s", err, string(out))
+	}
+
+	matches, err := filepath.Glob(filepath.Join(dir, "testdata", "TestFlagRunPatternUpdate-*", "flag-update.txt"))
+	if err != nil {
+		t.Fatalf("glob: %v", err)
+	}
+	if len(matches) != 1 {
+		t.Fatalf("expected one golden file to be written, found %v", matches)
+	}
+}
+
+func TestFlagTimeout(t *testing.T) {
+	if os.Getenv(flagTimeoutHelperEnv) == "1" {
+		term := strider.Open(t, testBinary)
+		term.WaitFor(strider.Text("ready>"))
+		term.WaitFor(strider.Text("never appears"))
+		return
+	}
+
+	if _, err := exec.LookPath("tmux"); err != nil {
+		t.Skip("tmux not found in PATH")
+	}
+
+	cmd := exec.Command(os.Args[0], "-test.run", "^TestFlagTimeout$", "-strider.timeout", "200ms")
+	cmd.Env = append(os.Environ(), flagTimeoutHelperEnv+"=1")
+	out, err := cmd.CombinedOutput()
+	if err == nil {
+		t.Fatalf("expected subprocess to fail, output:\n%s", string(out))
+	}
+
+	output := string(out)
+	if !strings.Contains(output, "strider: wait-for: timed out after 200ms") {
+		t.Fatalf("expected flag timeout to apply, got:\n%s", output)
+	}
+}
+
+func TestLineFailureDiff(t *testing.T) {
+	if os.Getenv(lineDiffHelperEnv) == "1" {
+		term := strider.Open(t, testBinary)
+		term.WaitFor(strider.Text("ready>"))
+		term.WaitFor(strider.Any(
+			strider.Line(0, "ready >"),
+			strider.LineContains(0, "read?"),
+			strider.LineMatches(99, "ready"),
+			// Not matches because Line does not; Line's note must not show.
+			strider.All(strider.Not(strider.Line(0, "nope")), strider.Text("absent")),
+		), strider.WithinTimeout(200*time.Millisecond))
+		return
+	}
+
+	if _, err := exec.LookPath("tmux"); err != nil {
+		t.Skip("tmux not found in PATH")
+	}
+
+	cmd := exec.Command(os.Args[0], "-test.run", "^TestLineFailureDiff$")
+	cmd.Env = append(os.Environ(), lineDiffHelperEnv+"=1")
+	out, err := cmd.CombinedOutput()
+	if err == nil {
+		t.Fatalf("expected subprocess to fail, output:\n%s", string(out))
+	}
+
+	// Remove the indentation the testing package adds to the failure
+	// message, keeping the message's own.
+	output := string(out)
+	if i := strings.Index(output, "    waiting for:"); i >= 0 {
+		indent := output[strings.LastIndex(output[:i], "\n")+1 : i]
+		output = strings.ReplaceAll(output, "\n"+indent, "\n")
+	}
+	for _, want := range []string{
+		"    line 0 differs at column 5:\n" +
+			"        expected: \"ready >\"\n" +
+			"        actual:   \"ready>\"\n" +
+			"                        ^\n",
+		"    line 0, closest match at column 0:\n" +
+			"        actual:   \"ready>\"\n" +
+			"        expected: \"read?\"\n" +
+			"                       ^\n",
+		"    line 99 is out of range (the screen has 24 lines)\n",
+	} {
+		if !strings.Contains(output, want) {
+			t.Errorf("expected failure output to contain:\n%s\ngot:\n%s", want, output)
+		}
+	}
+	if strings.Contains(output, "differs at column 0") {
+		t.Errorf("expected no note for the negated matcher, got:\n%s", output)
+	}
+}
+
+func TestWithTranscript(t *testing.T) {
+	if dir := os.Getenv(transcriptHelperEnv); dir != "" {
+		t.Chdir(dir)
+		term := strider.Open(t, testBinary, strider.WithTranscript("session"))
+		term.WaitFor(strider.Text("ready>"))
+		term.Type("hello")
+		term.Press(strider.Enter)
+		term.WaitFor(strider.Text("echo: hello"))
+		return
+	}
+
+	if _, err := exec.LookPath("tmux"); err != nil {
+		t.Skip("tmux not found in PATH")
+	}
+
+	dir := t.TempDir()
+	run := func(update bool) (string, error) {
+		cmd := exec.Command(os.Args[0], "-test.run", "^TestWithTranscript$")
+		cmd.Env = append(os.Environ(), transcriptHelperEnv+"="+dir, "STRIDER_UPDATE=")
+		if update {
+			cmd.Env = append(cmd.Env, "STRIDER_UPDATE=1")
+		}
+		out, err := cmd.CombinedOutput()
+		return string(out), err
+	}
+
+	if out, err := run(true); err != nil {
+		t.Fatalf("recording run failed: %v\n%s", err, out)
+	}
+	matches, _ := filepath.Glob(filepath.Join(dir, "testdata", "*", "session.transcript.txt"))
+	if len(matches) != 1 {
+		t.Fatalf("expected one golden transcript, found %v", matches)
+	}
+	golden, err := os.ReadFile(matches[0])
+	if err != nil {
+		t.Fatalf("read golden transcript: %v", err)
+	}
+	want := strings.Join([]string{
+		`< wait-for: screen to contain "ready>"`,
+		"| ready>",
+		`> type "hello"`,
+		"> press Enter",
+		`< wait-for: screen to contain "echo: hello"`,
+		"| ready>hello",
+		"| echo: hello",
+		"| ready>",
+	}, "\n") + "\n"
+	if string(golden) != want {
+		t.Errorf("golden transcript:\n%s\nwant:\n%s", golden, want)
+	}
+
+	if out, err := run(false); err != nil {
+		t.Fatalf("replay against the golden transcript failed: %v\n%s", err, out)
+	}
+}
+
+func TestSnapshotMismatchWritesPending(t *testing.T) {
+	if dir := os.Getenv(pendingSnapshotHelperEnv); dir != "" {
+		t.Chdir(dir)
+		term := strider.Open(t, testBinary)
+		term.WaitFor(strider.Text("ready>"))
+		term.MatchSnapshot("pending")
+		return
+	}
+
+	if _, err := exec.LookPath("tmux"); err != nil {
+		t.Skip("tmux not found in PATH")
+	}
+
+	dir := t.TempDir()
+	cmd := exec.Command(os.Args[0], "-test.run", "^TestSnapshotMismatchWritesPending$")
+	cmd.Env = append(os.Environ(), pendingSnapshotHelperEnv+"="+dir)
+	out, err := cmd.CombinedOutput()
+	if err == nil {
+		t.Fatalf("expected subprocess to fail, output:\n%s", string(out))
+	}
+	if !strings.Contains(string(out), "Pending snapshot:") {
+		t.Fatalf("expected pending snapshot path in output, got:\n%s", string(out))
+	}
+
+	matches, _ := filepath.Glob(filepath.Join(dir, "testdata", "*", "pending.txt.new"))
+	if len(matches) != 1 {
+		t.Fatalf("expected one pending snapshot, found %v", matches)
+	}
+	got, err := os.ReadFile(matches[0])
+	if err != nil {
+		t.Fatalf("read pending snapshot: %v", err)
+	}
+	if !strings.Contains(string(got), "ready>") {
+		t.Errorf("expected pending snapshot to hold the actual screen, got:\n%s", got)
+	}
+}
+
+func TestExpectForAndExpectSnapshot(t *testing.T) {
+	if dir := os.Getenv(expectHelperEnv); dir != "" {
+		t.Chdir(dir)
+		term := strider.Open(t, testBinary)
+		missing := term.ExpectFor(strider.Text("never appears"), strider.WithinTimeout(100*time.Millisecond))
+		snapshot := term.ExpectSnapshot("missing")
+		ready := term.ExpectFor(strider.Text("ready>"))
+		t.Logf("reached the end: %v %v %v", missing, snapshot, ready)
+		return
+	}
+
+	if _, err := exec.LookPath("tmux"); err != nil {
+		t.Skip("tmux not found in PATH")
+	}
+
+	cmd := exec.Command(os.Args[0], "-test.run", "^TestExpectForAndExpectSnapshot$")
+	cmd.Env = append(os.Environ(), expectHelperEnv+"="+t.TempDir(), "STRIDER_UPDATE=")
+	out, err := cmd.CombinedOutput()
+	if err == nil {
+		t.Fatalf("expected subprocess to fail, output:\n%s", out)
+	}
+	output := string(out)
+	for _, want := range []string{
+		"strider: expect-for: timed out after 100ms",
+		"strider: snapshot: golden file not found",
+		"reached the end: false false true",
+	} {
+		if !strings.Contains(output, want) {
+			t.Errorf("expected output to contain %q, got:\n%s", want, output)
+		}
+	}
+}
+
+func TestEscapedSnapshotFormat(t *testing.T) {
+	t.Chdir(t.TempDir())
+	term := strider.Open(t, "/bin/sh",
+		strider.WithArgs("-c", "printf 'ab   \\n'; read y"),
+		strider.WithSize(10, 3),
+	)
+	term.WaitFor(strider.Text("ab"))
+
+	t.Setenv("STRIDER_UPDATE", "1")
+	term.MatchSnapshot("spaces", strider.WithEscapedFormat())
+	t.Setenv("STRIDER_UPDATE", "")
+	term.MatchSnapshot("spaces", strider.WithEscapedFormat())
+
+	matches, _ := filepath.Glob(filepath.Join("testdata", "*", "spaces.escaped.txt"))
+	if len(matches) != 1 {
+		t.Fatalf("expected one escaped golden file, found %v", matches)
+	}
+	got, err := os.ReadFile(matches[0])
+	if err != nil {
+		t.Fatalf("read golden file: %v", err)
+	}
+	want := "strider escaped snapshot 10x3\n\"ab   \"\n\"\"\n\"\"\n"
+	if string(got) != want {
+		t.Errorf("golden file = %q, want %q", got, want)
+	}
+}
+
+func TestTmuxCompatSnapshot(t *testing.T) {
+	t.Chdir(t.TempDir())
+	term := strider.Open(t, testBinary)
+	term.WaitFor(strider.Text("ready>"))
+
+	t.Setenv("STRIDER_UPDATE", "1")
+	term.MatchSnapshot("compat")
+	t.Setenv("STRIDER_UPDATE", "")
+
+	// Simulate a golden file recorded by a tmux version that keeps
+	// variation selectors in captures.
+	matches, _ := filepath.Glob(filepath.Join("testdata", "*", "compat.txt"))
+	if len(matches) != 1 {
+		t.Fatalf("expected one golden file, found %v", matches)
+	}
+	golden, err := os.ReadFile(matches[0])
+	if err != nil {
+		t.Fatalf("read golden file: %v", err)
+	}
+	golden = []byte(strings.Replace(string(golden), "ready>", "ready>\uFE0F", 1))
+	if err := os.WriteFile(matches[0], golden, 0o644); err != nil {
+		t.Fatalf("write golden file: %v", err)
+	}
+
+	term.MatchSnapshot("compat", strider.WithTmuxCompat())
 }
 
-func TestParallelSubtests(t *testing.T) {
-	for i := 0; i < 5; i++ {
-		i := i
-		t.Run(fmt.Sprintf("subtest-%d", i), func(t *testing.T) {
-			t.Parallel()
+func TestMatchSnapshotAt(t *testing.T) {
+	if dir := os.Getenv(sharedSnapshotHelperEnv); dir != "" {
+		t.Chdir(dir)
+		for _, input := range []string{"one", "two"} {
+			t.Run(input, func(t *testing.T) {
+				term := strider.Open(t, testBinary)
+				term.WaitFor(strider.Text("ready>"))
+				term.Type(input)
+				term.Press(strider.Enter)
+				term.WaitFor(strider.Text("echo: " + input))
+				term.MatchSnapshotAt("echoed")
+			})
+		}
+		return
+	}
+
+	// Subtests that render a shared key differently fail, naming both.
+	dir := t.TempDir()
+	cmd := exec.Command(os.Args[0], "-test.run", "^TestMatchSnapshotAt$")
+	cmd.Env = append(os.Environ(), sharedSnapshotHelperEnv+"="+dir)
+	out, err := cmd.CombinedOutput()
+	if err == nil {
+		t.Fatalf("expected subprocess to fail, output:\n%s", string(out))
+	}
+	want := `snapshot key "echoed" is rendered differently by TestMatchSnapshotAt/one and TestMatchSnapshotAt/two`
+	if !strings.Contains(string(out), want) {
+		t.Fatalf("expected shared key conflict, got:\n%s", string(out))
+	}
+
+	t.Chdir(t.TempDir())
+	t.Setenv("STRIDER_UPDATE", "1")
+	for _, name := range []string{"first", "second"} {
+		t.Run(name, func(t *testing.T) {
 			term := strider.Open(t, testBinary)
 			term.WaitFor(strider.Text("ready>"))
+			term.MatchSnapshotAt("shared/ready")
+		})
+	}
 
-			msg := fmt.Sprintf("parallel-%d", i)
-			term.Type(msg)
-			term.Press(strider.Enter)
-			term.WaitFor(strider.Text("echo: " + msg))
+	files, _ := filepath.Glob(filepath.Join("testdata", "*", "*"))
+	if len(files) != 1 || files[0] != filepath.Join("testdata", "snapshots", "shared_ready.txt") {
+		t.Fatalf("expected a single shared golden file, found %v", files)
+	}
+}
+
+func TestSnapshotNameCollision(t *testing.T) {
+	if dir := os.Getenv(snapshotClashHelperEnv); dir != "" {
+		t.Chdir(dir)
+		term := strider.Open(t, testBinary)
+		scr := term.WaitForScreen(strider.Text("ready>"))
+		scr.MatchSnapshot(t, "main menu")
+		scr.MatchSnapshot(t, "main menu")
+		scr.MatchSnapshot(t, "main_menu")
+		return
+	}
+
+	if _, err := exec.LookPath("tmux"); err != nil {
+		t.Skip("tmux not found in PATH")
+	}
+
+	dir := t.TempDir()
+	cmd := exec.Command(os.Args[0], "-test.run", "^TestSnapshotNameCollision$")
+	cmd.Env = append(os.Environ(), snapshotClashHelperEnv+"="+dir, "STRIDER_UPDATE=1")
+	out, err := cmd.CombinedOutput()
+	if err == nil {
+		t.Fatalf("expected subprocess to fail, output:\n%s", string(out))
+	}
+	want := `is used by two snapshots of TestSnapshotNameCollision: "main menu" and "main_menu"`
+	if !strings.Contains(string(out), want) {
+		t.Fatalf("expected collision message, got:\n%s", string(out))
+	}
+
+	// The first snapshot was written atomically, leaving no temporary files.
+	files, _ := filepath.Glob(filepath.Join(dir, "testdata", "*", "*"))
+	hidden, _ := filepath.Glob(filepath.Join(dir, "testdata", "*", ".*"))
+	if len(files) != 1 || filepath.Base(files[0]) != "main_menu.txt" || len(hidden) != 0 {
+		t.Errorf("expected only main_menu.txt, found %v %v", files, hidden)
+	}
+}
+
+func TestCheckRequirements(t *testing.T) {
+	if _, err := exec.LookPath("tmux"); err != nil {
+		t.Skip("tmux not found in PATH")
+	}
+
+	if err := strider.CheckRequirements(); err != nil {
+		t.Fatalf("CheckRequirements() = %v, want nil", err)
+	}
+
+	t.Setenv("STRIDER_TMUX", filepath.Join(t.TempDir(), "no-such-tmux"))
+	err := strider.CheckRequirements()
+	if err == nil {
+		t.Fatal("expected an error for a missing STRIDER_TMUX binary")
+	}
+	if !strings.Contains(err.Error(), "strider: requirements: cannot run") {
+		t.Errorf("unexpected error: %v", err)
+	}
+}
+
+func TestCapabilities(t *testing.T) {
+	if _, err := exec.LookPath("tmux"); err != nil {
+		t.Skip("tmux not found in PATH")
+	}
+
+	caps, err := strider.DetectCapabilities()
+	if err != nil {
+		t.Fatalf("DetectCapabilities() = %v", err)
+	}
+	if !caps.TmuxAtLeast("3.0") || caps.TmuxAtLeast("99.0") {
+		t.Errorf("TmuxAtLeast is inconsistent with version %q", caps.TmuxVersion)
+	}
+
+	for _, tc := range []struct {
+		name     string
+		skip     func(testing.TB)
+		wantSkip bool
+	}{
+		{"older-than-3.0", func(t testing.TB) { strider.SkipIfTmuxOlderThan(t, "3.0") }, false},
+		{"older-than-99.0", func(t testing.TB) { strider.SkipIfTmuxOlderThan(t, "99.0") }, true},
+		{"no-true-color", strider.SkipIfNoTrueColor, !caps.TrueColor},
+	} {
+		var skipped bool
+		t.Run(tc.name, func(t *testing.T) {
+			t.Cleanup(func() { skipped = t.Skipped() })
+			tc.skip(t)
 		})
+		if skipped != tc.wantSkip {
+			t.Errorf("%s: skipped = %v, want %v", tc.name, skipped, tc.wantSkip)
+		}
 	}
 }
 
-func TestStressParallel(t *testing.T) {
-	// Run 25 parallel subtests to verify no cross-test leakage.
-	// Each subtest gets its own tmux session and verifies isolation.
-	for i := 0; i < 25; i++ {
-		i := i
-		t.Run(fmt.Sprintf("stress-%d", i), func(t *testing.T) {
-			t.Parallel()
-			term := strider.Open(t, testBinary)
-			term.WaitFor(strider.Text("ready>"))
+func TestSetMaxConcurrent(t *testing.T) {
+	previous := strider.SetMaxConcurrent(2)
+	t.Cleanup(func() { strider.SetMaxConcurrent(previous) })
+
+	var active, peak atomic.Int32
+	t.Run("group", func(t *testing.T) {
+		for i := 0; i < 6; i++ {
+			t.Run(fmt.Sprintf("limited-%d", i), func(t *testing.T) {
+				t.Parallel()
+				term := strider.Open(t, testBinary)
+
+				n := active.Add(1)
+				// Registered after Open, so it runs before the slot is released.
+				t.Cleanup(func() { active.Add(-1) })
+				for {
+					p := peak.Load()
+					if n <= p || peak.CompareAndSwap(p, n) {
+						break
+					}
+				}
+
+				term.WaitFor(strider.Text("ready>"))
+				time.Sleep(50 * time.Millisecond)
+			})
+		}
+	})
 
-			msg := fmt.Sprintf("stress-msg-%d", i)
-			term.Type(msg)
-			term.Press(strider.Enter)
-			term.WaitFor(strider.Text("echo: " + msg))
+	if p := peak.Load(); p > 2 {
+		t.Errorf("expected at most 2 concurrent terminals, saw %d", p)
+	}
+}
 
-			// Verify the screen contains our message, not another test's.
-			screen := term.Screen()
-			if !screen.Contains("echo: " + msg) {
-				t.Errorf("expected screen to contain our echo, got:\n%s", screen)
+func TestSetMaxConcurrentHeldByTest(t *testing.T) {
+	if os.Getenv(maxConcurrentHelperEnv) == "1" {
+		strider.SetMaxConcurrent(1)
+		term := strider.Open(t, testBinary)
+		term.Reopen()
+		return
+	}
+
+	if _, err := exec.LookPath("tmux"); err != nil {
+		t.Skip("tmux not found in PATH")
+	}
+
+	// The second terminal would wait forever for the slot the first holds.
+	start := time.Now()
+	cmd := exec.Command(os.Args[0], "-test.run", "^TestSetMaxConcurrentHeldByTest$")
+	cmd.Env = append(os.Environ(), maxConcurrentHelperEnv+"=1")
+	out, err := cmd.CombinedOutput()
+	if err == nil {
+		t.Fatalf("expected subprocess to fail, output:\n%s", out)
+	}
+	if want := "strider: open: the test's terminals already hold all 1 tmux server slots"; !strings.Contains(string(out), want) {
+		t.Errorf("expected output to contain %q, got:\n%s", want, out)
+	}
+	if elapsed := time.Since(start); elapsed > 4*time.Second {
+		t.Errorf("expected Open to fail at once, took %v", elapsed)
+	}
+}
+
+func TestSharedServer(t *testing.T) {
+	open := func(width int, text string) *strider.Terminal {
+		script := `echo "server=${TMUX%%,*}"; echo ` + text + `; read y`
+		term := strider.Open(t, "/bin/sh", strider.WithArgs("-c", script), strider.WithSharedServer(), strider.WithSize(width, 10))
+		term.WaitFor(strider.Text(text))
+		return term
+	}
+	first := open(50, "first")
+	second := open(60, "second")
+
+	server := func(term *strider.Terminal) string {
+		for _, line := range term.Screen().Lines() {
+			if s, ok := strings.CutPrefix(line, "server="); ok {
+				return s
 			}
+		}
+		return ""
+	}
+	if a, b := server(first), server(second); a == "" || a != b {
+		t.Errorf("expected both sessions on one server, got %q and %q", a, b)
+	}
+
+	// Sessions keep their own size, screen, and output.
+	if w, _ := first.Screen().Size(); w != 50 {
+		t.Errorf("first width = %d, want 50", w)
+	}
+	if w, _ := second.Screen().Size(); w != 60 {
+		t.Errorf("second width = %d, want 60", w)
+	}
+	if first.Screen().Contains("second") || second.Screen().Contains("first") {
+		t.Error("expected each session to show only its own program")
+	}
+	m := second.Mark()
+	first.Type("x")
+	first.Press(strider.Enter)
+	if code := first.WaitExit(); code != 0 {
+		t.Errorf("first exit status = %d, want 0", code)
+	}
+	if out := second.OutputSince(m); out != "" {
+		t.Errorf("expected no output in the second session, got %q", out)
+	}
+
+	// Reset restarts only its own session's program.
+	first.Reset()
+	first.WaitFor(strider.Text("first"))
+	second.WaitFor(strider.Text("second"))
+}
+
+func TestShellSession(t *testing.T) {
+	term := strider.Open(t, "/bin/sh", strider.WithSize(40, 10), strider.WithEnv("PS1=$ "))
+	sh := strider.NewShellSession(term, strider.ShellConfig{})
+
+	long := strings.Repeat("x", 100)
+	tests := []struct {
+		cmd  string
+		want string
+	}{
+		{"echo hello; echo world", "hello\nworld"},
+		{"true", ""},
+		{"echo " + long, long}, // wrapped by the terminal
+		{"seq 1 30", strings.TrimSpace(seqLines(30))}, // scrolled off the screen
+		{"echo '<done> $'", "<done> $"},               // ends like a prompt
+	}
+	for _, tt := range tests {
+		if got := sh.RunCommand(tt.cmd); got != tt.want {
+			t.Errorf("RunCommand(%q) = %q, want %q", tt.cmd, got, tt.want)
+		}
+	}
+
+	// State persists between commands, as in any shell.
+	sh.RunCommand("cd /tmp; x=42")
+	if got := sh.RunCommand(`echo "$x $(pwd)"`); got != "42 /tmp" {
+		t.Errorf("expected the shell's state kept, got %q", got)
+	}
+}
+
+func TestShellSessionREPL(t *testing.T) {
+	python, err := exec.LookPath("python3")
+	if err != nil {
+		t.Skip("python3 not found in PATH")
+	}
+	term := strider.Open(t, python, strider.WithArgs("-q", "-i"), strider.WithEnv("PYTHON_BASIC_REPL=1"))
+	repl := strider.NewShellSession(term, strider.ShellConfig{
+		Prompt: `^>>>$`,
+		Print:  func(m string) string { return fmt.Sprintf("print(%q %q)", m[:4], m[4:]) },
+	})
+	if got := repl.RunCommand("x = 6 * 7"); got != "" {
+		t.Errorf("assignment printed %q", got)
+	}
+	if got := repl.RunCommand("print(x); print('a' * 3)"); got != "42\naaa" {
+		t.Errorf("RunCommand = %q, want %q", got, "42\naaa")
+	}
+}
+
+// seqLines returns the output of seq 1 n.
+func seqLines(n int) string {
+	var b strings.Builder
+	for i := 1; i <= n; i++ {
+		fmt.Fprintf(&b, "%d\n", i)
+	}
+	return b.String()
+}
+
+func TestSignal(t *testing.T) {
+	script := `trap 'echo reloaded' HUP; trap 'echo stopping' TERM; echo ready; while :; do sleep 0.1; done`
+	term := strider.Open(t, "/bin/sh", strider.WithArgs("-c", script))
+	term.WaitFor(strider.Text("ready"))
+
+	term.Signal(syscall.SIGHUP)
+	term.WaitFor(strider.Text("reloaded"))
+	term.Signal(syscall.SIGTERM)
+	term.WaitFor(strider.Text("stopping"))
+
+	// A program killed by the signal reports 128 plus its number, or 0 when
+	// tmux is older than 3.3 or, occasionally, loses the status.
+	term.Signal(syscall.SIGKILL)
+	if code := term.WaitExit(); code != 128+int(syscall.SIGKILL) && code != 0 {
+		t.Errorf("expected exit status %d after SIGKILL, got %d", 128+int(syscall.SIGKILL), code)
+	}
+}
+
+func TestPoolReusesSessions(t *testing.T) {
+	pool := strider.NewPool(t, testBinary, 1, strider.WithSize(80, 24))
+
+	t.Run("first", func(t *testing.T) {
+		term := pool.Get(t)
+		term.WaitFor(strider.Text("ready>"))
+		term.Type("first borrower")
+		term.Press(strider.Enter)
+		term.WaitFor(strider.Text("echo: first borrower"))
+		term.Resize(100, 30)
+		term.WaitFor(strider.SizeIs(100, 30))
+	})
+
+	t.Run("second", func(t *testing.T) {
+		term := pool.Get(t)
+		term.WaitFor(strider.All(
+			strider.Text("ready>"),
+			strider.Not(strider.Text("first borrower")),
+			strider.SizeIs(80, 24),
+		))
+		if scrollback := term.Scrollback(); scrollback.Contains("first borrower") {
+			t.Errorf("expected history to be cleared, got:\n%s", scrollback)
+		}
+	})
+}
+
+func TestPoolRedactsSecretsInRecording(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "pool.cast")
+	t.Run("pool", func(t *testing.T) {
+		pool := strider.NewPool(t, testBinary, 1, strider.WithRecording(path))
+		t.Run("borrower", func(t *testing.T) {
+			term := pool.Get(t)
+			term.WaitFor(strider.Text("ready>"))
+			term.TypeSecret("hunter2")
+			term.Press(strider.Enter)
+			term.WaitFor(strider.Text("echo: "))
 		})
+	})
+
+	data, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if strings.Contains(string(data), "hunter2") {
+		t.Errorf("recording shows the secret a borrower typed:\n%s", data)
 	}
 }
 
-func TestCursorMatcher(t *testing.T) {
+func TestReset(t *testing.T) {
 	term := strider.Open(t, testBinary)
 	term.WaitFor(strider.Text("ready>"))
-	term.WaitFor(strider.Cursor(0, 6))
+	term.Type("quit")
+	term.Press(strider.Enter)
+	if code := term.WaitExit(); code != 0 {
+		t.Fatalf("expected exit code 0, got %d", code)
+	}
+
+	term.Reset()
+	term.WaitFor(strider.All(strider.Text("ready>"), strider.Not(strider.Text("quit"))))
 }
 
-func TestSendKeys(t *testing.T) {
-	term := strider.Open(t, testBinary)
-	term.WaitFor(strider.Text("ready>"))
+func TestExitClassifier(t *testing.T) {
+	classify := strider.WithExitClassifier(func(e strider.ExitState, scr *strider.Screen) error {
+		switch e.Code {
+		case 77:
+			if scr == nil {
+				return errors.New("no final screen")
+			}
+			return fmt.Errorf("%w: no GPU", strider.ErrSkip)
+		case 78:
+			return fmt.Errorf("configuration error during %s", e.Op)
+		}
+		return nil
+	})
 
-	// Use raw SendKeys to send literal text.
-	term.SendKeys("h", "i")
-	term.WaitFor(strider.Text("hi"))
+	if os.Getenv(exitClassifierHelperEnv) == "1" {
+		term := strider.Open(t, "/bin/sh", strider.WithArgs("-c", "exit 78"), classify)
+		term.WaitFor(strider.Text("never shown"))
+		return
+	}
+
+	var skipped bool
+	t.Run("skip", func(t *testing.T) {
+		t.Cleanup(func() { skipped = t.Skipped() })
+		term := strider.Open(t, "/bin/sh", strider.WithArgs("-c", "exit 77"), classify)
+		term.WaitFor(strider.Text("never shown"))
+		t.Error("WaitFor returned after the program exited")
+	})
+	if !skipped {
+		t.Error("exit 77 did not skip the test")
+	}
+
+	if _, err := exec.LookPath("tmux"); err != nil {
+		t.Skip("tmux not found in PATH")
+	}
+	cmd := exec.Command(os.Args[0], "-test.run", "^TestExitClassifier$")
+	cmd.Env = append(os.Environ(), exitClassifierHelperEnv+"=1")
+	out, err := cmd.CombinedOutput()
+	if err == nil {
+		t.Fatalf("expected subprocess to fail, output:\n%s", string(out))
+	}
+	if want := "process exited unexpectedly (status 78)\n"; !strings.Contains(string(out), want) {
+		t.Errorf("expected failure output to contain %q, got:\n%s", want, out)
+	}
+	if want := "exit: configuration error during wait-for"; !strings.Contains(string(out), want) {
+		t.Errorf("expected failure output to contain %q, got:\n%s", want, out)
+	}
 }
 
-func TestMultipleCommands(t *testing.T) {
-	term := strider.Open(t, testBinary)
+func TestExpectExit(t *testing.T) {
+	if os.Getenv(expectExitHelperEnv) == "1" {
+		term := strider.Open(t, "/bin/sh", strider.WithArgs("-c", "echo saving; echo disk full; exit 3"))
+		term.ExpectExit(0, 5*time.Second)
+		return
+	}
+
+	term := strider.Open(t, "/bin/sh", strider.WithArgs("-c", "read line; exit 4"))
+	term.Press(strider.Enter)
+	term.ExpectExit(4, 5*time.Second)
+
+	if _, err := exec.LookPath("tmux"); err != nil {
+		t.Skip("tmux not found in PATH")
+	}
+	cmd := exec.Command(os.Args[0], "-test.run", "^TestExpectExit$")
+	cmd.Env = append(os.Environ(), expectExitHelperEnv+"=1")
+	out, err := cmd.CombinedOutput()
+	if err == nil {
+		t.Fatalf("expected subprocess to fail, output:\n%s", string(out))
+	}
+	for _, want := range []string{
+		"strider: expect-exit: exited with status 3, want 0\n",
+		"final screen:",
+		"command: /bin/sh -c",
+		"\u2502disk full",
+	} {
+		if !strings.Contains(string(out), want) {
+			t.Errorf("expected failure output to contain %q, got:\n%s", want, out)
+		}
+	}
+}
+
+func TestQuiet(t *testing.T) {
+	if mode := os.Getenv(quietHelperEnv); mode != "" {
+		term := strider.Open(t, testBinary, strider.WithQuiet(), strider.WithSeed(7))
+		term.WaitFor(strider.Text("ready>"))
+		term.Step("greet", func() {
+			term.Type("hi")
+			term.Press(strider.Enter)
+			term.WaitFor(strider.Text("echo: hi"))
+		})
+		if mode == "fail" {
+			t.Error("forced failure")
+		}
+		return
+	}
+
+	if _, err := exec.LookPath("tmux"); err != nil {
+		t.Skip("tmux not found in PATH")
+	}
+	run := func(mode, debug string) string {
+		cmd := exec.Command(os.Args[0], "-test.run", "^TestQuiet$", "-test.v")
+		cmd.Env = append(os.Environ(), quietHelperEnv+"="+mode, "STRIDER_DEBUG="+debug)
+		out, err := cmd.CombinedOutput()
+		if failed := err != nil; failed != (mode == "fail") {
+			t.Fatalf("subprocess (mode %s): unexpected result %v, output:\n%s", mode, err, out)
+		}
+		return string(out)
+	}
+
+	if out := run("pass", ""); strings.Contains(out, "strider: seed") || strings.Contains(out, "strider: step") {
+		t.Errorf("a passing quiet test logged strider's lines:\n%s", out)
+	}
+	out := run("fail", "")
+	for _, want := range []string{"strider: quiet: log held until the failure:", "strider: seed 7", `strider: step "greet"`} {
+		if !strings.Contains(out, want) {
+			t.Errorf("failing quiet test: expected output to contain %q, got:\n%s", want, out)
+		}
+	}
+	out = run("pass", "1")
+	for _, want := range []string{"strider: seed 7", `strider: input: type "hi"`, "wait-for: poll 1: matched after"} {
+		if !strings.Contains(out, want) {
+			t.Errorf("STRIDER_DEBUG: expected output to contain %q, got:\n%s", want, out)
+		}
+	}
+}
+
+func TestWithBackendPTY(t *testing.T) {
+	term := strider.Open(t, testBinary, strider.WithBackend(strider.PTY), strider.WithSize(40, 10))
 	term.WaitFor(strider.Text("ready>"))
+	term.Type("hello")
+	term.Press(strider.Enter)
+	term.WaitFor(strider.Text("echo: hello"))
 
-	// First command.
-	term.Type("first")
+	term.Type("lines 20")
 	term.Press(strider.Enter)
-	term.WaitFor(strider.Text("echo: first"))
+	term.WaitFor(strider.Text("line 20"))
+	if scrollback := term.Scrollback(); !scrollback.Contains("echo: hello") || !scrollback.Contains("line 1") {
+		t.Errorf("expected the scrollback to keep the lines scrolled off, got:\n%s", scrollback)
+	}
+	if view := term.ScrollView(5); view.Line(0) != "line 7" {
+		t.Errorf("expected line 7 at the top five lines up, got:\n%s", view)
+	}
 
-	// Second command.
-	term.Type("second")
+	term.Resize(50, 12)
+	term.Type("size")
 	term.Press(strider.Enter)
-	term.WaitFor(strider.Text("echo: second"))
+	term.WaitFor(strider.All(strider.Text("size: 50x12"), strider.SizeIs(50, 12)))
 
-	// Third command.
-	term.Type("third")
+	term.Type("grid")
 	term.Press(strider.Enter)
-	term.WaitFor(strider.Text("echo: third"))
+	term.WaitFor(strider.Text("grid:"))
+	term.Press(strider.Down, strider.Right, strider.Right)
+	term.Type("x")
+	term.WaitFor(strider.Line(2, "  x"))
+	term.Press(strider.Ctrl('d'))
+	term.WaitFor(strider.Text("ready>"))
+
+	term.Signal(syscall.SIGTERM)
+	if code := term.WaitExit(); code != 128+int(syscall.SIGTERM) {
+		t.Errorf("expected exit status %d after SIGTERM, got %d", 128+int(syscall.SIGTERM), code)
+	}
+	term.Reset()
+	term.WaitFor(strider.All(strider.Text("ready>"), strider.Not(strider.Text("hello")), strider.SizeIs(40, 10)))
 }
 
-func TestBackspace(t *testing.T) {
-	term := strider.Open(t, testBinary)
-	term.WaitFor(strider.Text("ready>"))
+func TestWithBackendPTYKeypadEnter(t *testing.T) {
+	// The program prints the bytes each key sends, switching to the
+	// application keypad mode (DECKPAM) in between.
+	script := `stty raw -echo; printf 'ready\r\n'; dd bs=1 count=1 2>/dev/null | od -An -tx1; ` +
+		`printf '\033=app\r\n'; dd bs=1 count=3 2>/dev/null | od -An -tx1; exec cat`
+	term := strider.Open(t, "/bin/sh", strider.WithArgs("-c", script), strider.WithBackend(strider.PTY))
+	term.WaitFor(strider.Text("ready"))
+	term.Press(strider.KPEnter)
+	term.WaitFor(strider.All(strider.Text(" 0d"), strider.Text("app")))
+	term.Press(strider.KPEnter)
+	term.WaitFor(strider.Text(" 1b 4f 4d"))
+}
 
-	// Type text, use backspace to correct, then press Enter.
-	// The terminal line discipline handles backspace.
-	term.Type("helloo")
-	term.Press(strider.Backspace)
-	// After backspace, "hello" remains. Type more and send.
-	term.Press(strider.Enter)
-	term.WaitFor(strider.Text("echo: hello"))
+// TestPTYBackendMatchesTmux checks that the PTY backend's emulator renders
+// output as tmux does, so snapshots match on both backends.
+func TestPTYBackendMatchesTmux(t *testing.T) {
+	for _, tc := range []struct{ name, script string }{
+		{"styles", `printf '\033[1;31mFAIL\033[0m ok \033[4;38;5;208mX\033[0;48;2;1;2;3mY\033[0m\n'`},
+		{"wrap and scroll", `i=1; while [ $i -le 30 ]; do printf 'line %d abcdefghijklmnopqrstuvwxyz\n' $i; i=$((i+1)); done`},
+		{"cursor movement", `printf 'abc\033[2;5Hxy\033[1;1H\033[2Kz\033[5;1Hqrs\033[2D\033[1Pw\033[3@'`},
+		{"alternate screen", `printf 'before\033[?1049h\033[Hfull\033[?1049l after\n'`},
+		{"scroll region", `printf 'h1\nh2\033[3;5r\033[3Ha\nb\nc\nd\ne\033[r\033[8Hfoot\n'`},
+		{"wide characters", `printf 'ab世界\033[1;2Hx\n'`},
+		{"clear", `printf 'one\ntwo\033[2J\033[Hthree\n'`},
+		{"tabs and erasing", `printf 'a\tb\tc\rX\033[K\nrow\033[3X\n'`},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			var screens, scrollbacks [2]*strider.Screen
+			for i, backend := range []strider.Backend{strider.Tmux, strider.PTY} {
+				term := strider.Open(t, "/bin/sh",
+					strider.WithArgs("-c", tc.script+"; printf END; exec cat"),
+					strider.WithBackend(backend), strider.WithSize(30, 8), strider.WithStyles())
+				term.WaitFor(strider.Text("END"))
+				screens[i], scrollbacks[i] = term.Screen(), term.Scrollback()
+			}
+			tmux, pty := screens[0], screens[1]
+			if pty.String() != tmux.String() {
+				t.Errorf("screen differs from tmux's:\n%s\nwant:\n%s", pty, tmux)
+			}
+			tmuxRow, tmuxCol, _ := tmux.CursorPosition()
+			if row, col, _ := pty.CursorPosition(); row != tmuxRow || col != tmuxCol {
+				t.Errorf("cursor at %d,%d, tmux's at %d,%d", row, col, tmuxRow, tmuxCol)
+			}
+			for row := range 8 {
+				for col := range 30 {
+					want, _ := tmux.StyleAt(row, col)
+					if got, _ := pty.StyleAt(row, col); got != want {
+						t.Errorf("style at %d,%d is %v, tmux's is %v", row, col, got, want)
+					}
+				}
+			}
+			if scrollbacks[1].String() != scrollbacks[0].String() {
+				t.Errorf("scrollback differs from tmux's:\n%s\nwant:\n%s", scrollbacks[1], scrollbacks[0])
+			}
+		})
+	}
 }
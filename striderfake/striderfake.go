@@ -0,0 +1,299 @@
+// Package striderfake provides an in-memory stand-in for strider.Terminal,
+// for unit-testing helper libraries built on strider where tmux is not
+// available.
+//
+// A fake Terminal has the same method signatures as strider.Terminal for
+// sending input, capturing the screen, and waiting, so a helper that accepts
+// strider.Console (or a smaller interface such as strider.Inputter) can be
+// given either one. The test plays the program: it sets what the screen
+// shows, directly or from a Program that reacts to input, and checks the
+// input the helper sent.
+//
+//	fake := striderfake.New(t, striderfake.WithProgram(func(term *striderfake.Terminal, in striderfake.Input) {
+//		if in.Text != "" {
+//			term.SetScreen("Hello, " + in.Text)
+//		}
+//	}))
+//	greet(fake, "world") // the helper under test
+//	fake.WaitFor(strider.Text("Hello, world"))
+package striderfake
+
+import (
+	"fmt"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/cboone/strider"
+)
+
+// Default settings, matching strider's defaults where they apply.
+const (
+	defaultWidth        = 80
+	defaultHeight       = 24
+	defaultTimeout      = time.Second
+	defaultPollInterval = 10 * time.Millisecond
+)
+
+// Input is one Type, Press, or SendKeys call received by a fake Terminal.
+// Exactly one of the fields is set.
+type Input struct {
+	Text string        // the string passed to Type
+	Keys []strider.Key // the keys passed to Press
+	Raw  []string      // the tmux key names passed to SendKeys
+}
+
+// String formats in like the input lines of a strider transcript, for
+// example `type "hello"` or "press Enter".
+func (in Input) String() string {
+	switch {
+	case in.Keys != nil:
+		keys := make([]string, len(in.Keys))
+		for i, k := range in.Keys {
+			keys[i] = string(k)
+		}
+		return "press " + strings.Join(keys, " ")
+	case in.Raw != nil:
+		return "send-keys " + strings.Join(in.Raw, " ")
+	}
+	return fmt.Sprintf("type %q", in.Text)
+}
+
+// A Program plays the program under test: it is called with every input
+// the fake Terminal receives, and typically updates the screen with
+// SetScreen. It is called without locks held, from the goroutine sending
+// the input.
+type Program func(term *Terminal, in Input)
+
+// Option configures a fake Terminal.
+type Option func(*Terminal)
+
+// WithSize sets the screen size. The default is 80x24.
+func WithSize(width, height int) Option {
+	return func(term *Terminal) {
+		term.width, term.height = width, height
+	}
+}
+
+// WithTimeout sets how long waits wait for the screen to match before
+// failing the test. The default is 1 second: a fake screen changes only
+// when the test changes it, so waits usually succeed or fail at once.
+func WithTimeout(d time.Duration) Option {
+	return func(term *Terminal) {
+		term.timeout = d
+	}
+}
+
+// WithProgram sets the Program that reacts to input.
+func WithProgram(p Program) Option {
+	return func(term *Terminal) {
+		term.program = p
+	}
+}
+
+// Terminal is an in-memory fake of strider.Terminal. It implements
+// strider.Console. It is safe for concurrent use, so a Program may also
+// update the screen from another goroutine.
+type Terminal struct {
+	t       testing.TB
+	timeout time.Duration
+	program Program
+
+	mu        sync.Mutex
+	width     int
+	height    int
+	lines     []string
+	cursorRow int // -1 when unset
+	cursorCol int
+	inputs    []Input
+	exited    bool
+	exitCode  int
+}
+
+var _ strider.Console = (*Terminal)(nil)
+
+// New returns a fake Terminal with a blank screen. Failures are reported to
+// t, like strider.Open.
+func New(t testing.TB, opts ...Option) *Terminal {
+	t.Helper()
+	term := &Terminal{
+		t:         t,
+		timeout:   defaultTimeout,
+		width:     defaultWidth,
+		height:    defaultHeight,
+		cursorRow: -1,
+		cursorCol: -1,
+	}
+	for _, o := range opts {
+		o(term)
+	}
+	if term.width <= 0 || term.height <= 0 {
+		t.Fatalf("striderfake: new: width and height must be positive (got %dx%d)", term.width, term.height)
+	}
+	return term
+}
+
+// SetScreen replaces the screen content with lines, one per row.
+func (term *Terminal) SetScreen(lines ...string) {
+	term.mu.Lock()
+	defer term.mu.Unlock()
+	term.lines = append([]string(nil), lines...)
+}
+
+// SetCursor sets the cursor position (0-indexed) reported by captured
+// screens. Until it is called, captures have no cursor position.
+func (term *Terminal) SetCursor(row, col int) {
+	term.mu.Lock()
+	defer term.mu.Unlock()
+	term.cursorRow, term.cursorCol = row, col
+}
+
+// Exit marks the program as exited with code. Input then fails the test, as
+// with a real Terminal, and WaitExit returns code.
+func (term *Terminal) Exit(code int) {
+	term.mu.Lock()
+	defer term.mu.Unlock()
+	term.exited, term.exitCode = true, code
+}
+
+// Inputs returns the input received so far, in order.
+func (term *Terminal) Inputs() []Input {
+	term.mu.Lock()
+	defer term.mu.Unlock()
+	return append([]Input(nil), term.inputs...)
+}
+
+// Type records s as typed text and passes it to the Program.
+func (term *Terminal) Type(s string) {
+	term.t.Helper()
+	term.send(Input{Text: s})
+}
+
+// Press records keys as pressed and passes them to the Program.
+func (term *Terminal) Press(keys ...strider.Key) {
+	term.t.Helper()
+	term.send(Input{Keys: append([]strider.Key{}, keys...)})
+}
+
+// SendKeys records raw tmux key names and passes them to the Program.
+func (term *Terminal) SendKeys(keys ...string) {
+	term.t.Helper()
+	term.send(Input{Raw: append([]string{}, keys...)})
+}
+
+// send records in and runs the Program on it.
+func (term *Terminal) send(in Input) {
+	term.t.Helper()
+	term.mu.Lock()
+	if term.exited {
+		code := term.exitCode
+		term.mu.Unlock()
+		term.t.Fatalf("striderfake: send-keys: process exited unexpectedly (status %d)", code)
+		return
+	}
+	term.inputs = append(term.inputs, in)
+	term.mu.Unlock()
+
+	if term.program != nil {
+		term.program(term, in)
+	}
+}
+
+// Screen captures the current screen.
+func (term *Terminal) Screen() *strider.Screen {
+	term.mu.Lock()
+	defer term.mu.Unlock()
+	scr := strider.NewScreen(term.width, term.height, term.lines...)
+	if term.cursorRow >= 0 {
+		scr = scr.WithCursor(term.cursorRow, term.cursorCol)
+	}
+	return scr
+}
+
+// Resize changes the screen size. The content is kept.
+func (term *Terminal) Resize(width, height int) {
+	term.t.Helper()
+	if width <= 0 || height <= 0 {
+		term.t.Fatalf("striderfake: resize: width and height must be positive (got %dx%d)", width, height)
+	}
+	term.mu.Lock()
+	defer term.mu.Unlock()
+	term.width, term.height = width, height
+}
+
+// WaitFor waits until m matches the screen, and fails the test if it does
+// not within the fake's timeout. WaitOptions are accepted for compatibility
+// with strider.Terminal and ignored; set the timeout with WithTimeout.
+func (term *Terminal) WaitFor(m strider.Matcher, wopts ...strider.WaitOption) {
+	term.t.Helper()
+	term.wait("wait-for", m)
+}
+
+// WaitForScreen is like WaitFor and returns the matching screen.
+func (term *Terminal) WaitForScreen(m strider.Matcher, wopts ...strider.WaitOption) *strider.Screen {
+	term.t.Helper()
+	return term.wait("wait-for", m).Screen
+}
+
+// WaitForResult is like WaitFor and returns the matching screen with the
+// time the wait took and the number of captures it made.
+func (term *Terminal) WaitForResult(m strider.Matcher, wopts ...strider.WaitOption) strider.WaitResult {
+	term.t.Helper()
+	return term.wait("wait-for", m)
+}
+
+// wait polls the screen until m matches or the timeout expires.
+func (term *Terminal) wait(op string, m strider.Matcher) strider.WaitResult {
+	term.t.Helper()
+	start := time.Now()
+	deadline := start.Add(term.timeout)
+	polls := 0
+	for {
+		scr := term.Screen()
+		polls++
+		ok, desc := m(scr)
+		if ok {
+			return strider.WaitResult{Screen: scr, Elapsed: time.Since(start), Polls: polls}
+		}
+		if time.Now().After(deadline) {
+			term.t.Fatalf("striderfake: %s: timed out after %v\n    waiting for: %s\n    screen:\n%s",
+				op, term.timeout, desc, indent(scr.String()))
+		}
+		time.Sleep(defaultPollInterval)
+	}
+}
+
+// WaitExit waits until Exit is called and returns the exit code, and fails
+// the test if it is not called within the fake's timeout.
+func (term *Terminal) WaitExit(wopts ...strider.WaitOption) int {
+	term.t.Helper()
+	deadline := time.Now().Add(term.timeout)
+	for {
+		term.mu.Lock()
+		exited, code := term.exited, term.exitCode
+		term.mu.Unlock()
+		if exited {
+			return code
+		}
+		if time.Now().After(deadline) {
+			term.t.Fatalf("striderfake: wait-exit: timed out after %v\n    pane still alive", term.timeout)
+		}
+		time.Sleep(defaultPollInterval)
+	}
+}
+
+// ExpectExit waits like WaitExit and fails the test unless the exit code is
+// code. The fake's timeout applies; within is ignored.
+func (term *Terminal) ExpectExit(code int, within time.Duration) {
+	term.t.Helper()
+	if got := term.WaitExit(); got != code {
+		term.t.Fatalf("striderfake: expect-exit: exited with status %d, want %d\n    screen:\n%s",
+			got, code, indent(term.Screen().String()))
+	}
+}
+
+// indent prefixes every line of s with four spaces.
+func indent(s string) string {
+	return "    " + strings.ReplaceAll(s, "\n", "\n    ")
+}
@@ -0,0 +1,149 @@
+package striderfake_test
+
+import (
+	"fmt"
+	"runtime"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/cboone/strider"
+	"github.com/cboone/strider/striderfake"
+)
+
+// login is a helper of the kind the fake is meant for.
+func login(c strider.Console, user string) {
+	c.WaitFor(strider.Text("login:"))
+	c.Type(user)
+	c.Press(strider.Enter)
+	c.WaitFor(strider.Textf("Welcome, %s", user))
+}
+
+func TestLogin(t *testing.T) {
+	fake := striderfake.New(t, striderfake.WithProgram(func(term *striderfake.Terminal, in striderfake.Input) {
+		if in.Text != "" {
+			term.SetScreen("login: " + in.Text)
+		}
+		if len(in.Keys) > 0 && in.Keys[0] == strider.Enter {
+			name := strings.TrimPrefix(term.Screen().Line(0), "login: ")
+			term.SetScreen("Welcome, " + name)
+		}
+	}))
+	fake.SetScreen("login:")
+
+	login(fake, "alice")
+
+	var got []string
+	for _, in := range fake.Inputs() {
+		got = append(got, in.String())
+	}
+	if want := []string{`type "alice"`, "press Enter"}; strings.Join(got, "|") != strings.Join(want, "|") {
+		t.Errorf("Inputs() = %q, want %q", got, want)
+	}
+}
+
+func TestScreen(t *testing.T) {
+	fake := striderfake.New(t, striderfake.WithSize(20, 3))
+	fake.SetScreen("first", "second")
+
+	scr := fake.Screen()
+	if w, h := scr.Size(); w != 20 || h != 3 {
+		t.Errorf("Size() = %dx%d, want 20x3", w, h)
+	}
+	if got := scr.Lines(); len(got) != 3 || got[1] != "second" || got[2] != "" {
+		t.Errorf("Lines() = %q", got)
+	}
+	if _, _, ok := scr.CursorPosition(); ok {
+		t.Error("expected no cursor position before SetCursor")
+	}
+
+	fake.SetCursor(1, 6)
+	if ok, desc := strider.Cursor(1, 6)(fake.Screen()); !ok {
+		t.Errorf("expected the cursor at row 1, col 6: %s", desc)
+	}
+
+	fake.Resize(30, 5)
+	if w, h := fake.Screen().Size(); w != 30 || h != 5 {
+		t.Errorf("Size() after Resize = %dx%d, want 30x5", w, h)
+	}
+}
+
+func TestWaitForAsyncUpdate(t *testing.T) {
+	fake := striderfake.New(t)
+	go func() {
+		time.Sleep(50 * time.Millisecond)
+		fake.SetScreen("done")
+	}()
+	res := fake.WaitForResult(strider.Text("done"))
+	if res.Polls < 2 {
+		t.Errorf("expected several polls, got %d", res.Polls)
+	}
+}
+
+func TestWaitExit(t *testing.T) {
+	fake := striderfake.New(t)
+	fake.Exit(3)
+	if code := fake.WaitExit(); code != 3 {
+		t.Errorf("WaitExit() = %d, want 3", code)
+	}
+}
+
+func TestExpectExit(t *testing.T) {
+	msg := failure(t, func(tb testing.TB) {
+		fake := striderfake.New(tb)
+		fake.SetScreen("bye")
+		fake.Exit(2)
+		fake.ExpectExit(2, 0)
+		fake.ExpectExit(0, 0)
+	})
+	if want := "striderfake: expect-exit: exited with status 2, want 0\n    screen:\n    bye"; !strings.HasPrefix(msg, want) {
+		t.Errorf("failure = %q, want prefix %q", msg, want)
+	}
+}
+
+// recordingTB records the first fatal failure instead of failing the test.
+type recordingTB struct {
+	testing.TB
+	failure string
+}
+
+func (r *recordingTB) Helper() {}
+
+func (r *recordingTB) Fatalf(format string, args ...any) {
+	r.failure = fmt.Sprintf(format, args...)
+	runtime.Goexit()
+}
+
+// failure runs fn with a recordingTB and returns its failure message.
+func failure(t *testing.T, fn func(tb testing.TB)) string {
+	rec := &recordingTB{TB: t}
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		fn(rec)
+	}()
+	<-done
+	return rec.failure
+}
+
+func TestFailures(t *testing.T) {
+	got := failure(t, func(tb testing.TB) {
+		fake := striderfake.New(tb, striderfake.WithTimeout(50*time.Millisecond))
+		fake.SetScreen("loading")
+		fake.WaitFor(strider.Text("ready"))
+	})
+	if !strings.Contains(got, `striderfake: wait-for: timed out after 50ms`) ||
+		!strings.Contains(got, `waiting for: screen to contain "ready"`) ||
+		!strings.Contains(got, "    loading") {
+		t.Errorf("unexpected wait failure:\n%s", got)
+	}
+
+	got = failure(t, func(tb testing.TB) {
+		fake := striderfake.New(tb)
+		fake.Exit(1)
+		fake.Type("too late")
+	})
+	if got != "striderfake: send-keys: process exited unexpectedly (status 1)" {
+		t.Errorf("unexpected input failure: %q", got)
+	}
+}
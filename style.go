@@ -0,0 +1,328 @@
+package strider
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+
+	"github.com/cboone/strider/ansi"
+)
+
+// Color is a terminal color: the terminal's default, one of the 256
+// indexed colors, or a 24-bit RGB color. The zero Color is the default.
+type Color struct {
+	kind    colorKind
+	r, g, b uint8 // the index in r for indexed colors
+}
+
+type colorKind uint8
+
+const (
+	colorDefault colorKind = iota
+	colorIndexed
+	colorRGB
+)
+
+// DefaultColor is the terminal's default foreground or background color.
+var DefaultColor = Color{}
+
+// The 16 basic colors, selected with SGR 30-37, 40-47, 90-97, and 100-107.
+var (
+	ColorBlack   = IndexedColor(0)
+	ColorRed     = IndexedColor(1)
+	ColorGreen   = IndexedColor(2)
+	ColorYellow  = IndexedColor(3)
+	ColorBlue    = IndexedColor(4)
+	ColorMagenta = IndexedColor(5)
+	ColorCyan    = IndexedColor(6)
+	ColorWhite   = IndexedColor(7)
+
+	ColorBrightBlack   = IndexedColor(8)
+	ColorBrightRed     = IndexedColor(9)
+	ColorBrightGreen   = IndexedColor(10)
+	ColorBrightYellow  = IndexedColor(11)
+	ColorBrightBlue    = IndexedColor(12)
+	ColorBrightMagenta = IndexedColor(13)
+	ColorBrightCyan    = IndexedColor(14)
+	ColorBrightWhite   = IndexedColor(15)
+)
+
+// IndexedColor returns color n of the 256-color palette. Colors 0-15 are
+// the basic colors (see ColorRed and the others).
+func IndexedColor(n uint8) Color {
+	return Color{kind: colorIndexed, r: n}
+}
+
+// RGBColor returns a 24-bit color.
+func RGBColor(r, g, b uint8) Color {
+	return Color{kind: colorRGB, r: r, g: g, b: b}
+}
+
+var basicColorNames = [...]string{
+	"black", "red", "green", "yellow", "blue", "magenta", "cyan", "white",
+	"bright-black", "bright-red", "bright-green", "bright-yellow",
+	"bright-blue", "bright-magenta", "bright-cyan", "bright-white",
+}
+
+// String returns "default", the name of a basic color such as "red",
+// "color <n>" for other indexed colors, or "#rrggbb".
+func (c Color) String() string {
+	switch c.kind {
+	case colorIndexed:
+		if int(c.r) < len(basicColorNames) {
+			return basicColorNames[c.r]
+		}
+		return fmt.Sprintf("color %d", c.r)
+	case colorRGB:
+		return fmt.Sprintf("#%02x%02x%02x", c.r, c.g, c.b)
+	}
+	return "default"
+}
+
+// Style is the appearance of a screen cell: its colors and text attributes.
+// The zero Style is the terminal's default appearance.
+type Style struct {
+	Fg, Bg        Color
+	Bold          bool
+	Dim           bool
+	Italic        bool
+	Underline     bool
+	Blink         bool
+	Reverse       bool
+	Strikethrough bool
+}
+
+// String describes s, for example "fg=red bold" or "default".
+func (s Style) String() string {
+	var parts []string
+	if s.Fg != DefaultColor {
+		parts = append(parts, "fg="+s.Fg.String())
+	}
+	if s.Bg != DefaultColor {
+		parts = append(parts, "bg="+s.Bg.String())
+	}
+	for _, a := range []struct {
+		on   bool
+		name string
+	}{
+		{s.Bold, "bold"}, {s.Dim, "dim"}, {s.Italic, "italic"}, {s.Underline, "underline"},
+		{s.Blink, "blink"}, {s.Reverse, "reverse"}, {s.Strikethrough, "strikethrough"},
+	} {
+		if a.on {
+			parts = append(parts, a.name)
+		}
+	}
+	if len(parts) == 0 {
+		return "default"
+	}
+	return strings.Join(parts, " ")
+}
+
+// Styled reports whether s was captured with styles (see WithStyles).
+func (s *Screen) Styled() bool {
+	return s.hasStyles
+}
+
+// StyleAt returns the style of the cell at row and display column col
+// (0-indexed). A wide character has the same style in both of its columns,
+// and cells past the end of a row's content have the default style. ok is
+// false if the screen was captured without styles (see WithStyles) or the
+// cell is off the screen.
+func (s *Screen) StyleAt(row, col int) (st Style, ok bool) {
+	if !s.Styled() || row < 0 || row >= len(s.lines) || col < 0 || col >= max(s.width, displayWidth(s.lines[row])) {
+		return Style{}, false
+	}
+	s.stylesOnce.Do(func() { s.styles = parseStyles(s.styled) })
+	if row >= len(s.styles) || col >= len(s.styles[row]) {
+		return Style{}, true
+	}
+	return s.styles[row][col], true
+}
+
+// TextStyled matches if the screen contains text, on a single row, with
+// every cell of some occurrence in exactly the style st. It needs a Terminal
+// opened WithStyles; on a screen captured without styles it never matches.
+//
+//	term.WaitFor(strider.TextStyled("FAIL", strider.Style{Fg: strider.ColorRed, Bold: true}))
+func TextStyled(text string, st Style) Matcher {
+	return func(scr *Screen) (bool, string) {
+		desc := fmt.Sprintf("screen to contain %q in style %s", text, st)
+		if !scr.Styled() {
+			return false, desc + " (the capture has no styles; open the terminal with WithStyles)"
+		}
+		if text == "" {
+			return true, desc
+		}
+		var other *Style
+		for row, line := range scr.lines {
+			for off := 0; ; {
+				i := strings.Index(line[off:], text)
+				if i < 0 {
+					break
+				}
+				col := displayWidth(line[:off+i])
+				got, ok := scr.styleOfSpan(row, col, displayWidth(text), st)
+				if ok {
+					return true, desc
+				}
+				if other == nil {
+					other = &got
+				}
+				off += i + 1
+			}
+		}
+		if other != nil {
+			desc += fmt.Sprintf(" (found in style %s)", other)
+		}
+		return false, desc
+	}
+}
+
+// styleOfSpan reports whether the width cells of row starting at col all
+// have style st. If not, it returns the style of the first cell that
+// differs.
+func (s *Screen) styleOfSpan(row, col, width int, st Style) (Style, bool) {
+	for c := col; c < col+width; c++ {
+		if got, _ := s.StyleAt(row, c); got != st {
+			return got, false
+		}
+	}
+	return st, true
+}
+
+// parseStyles returns the style of every display cell of a capture made
+// with capture-pane -e, row by row. The style carries over from one row to
+// the next, as tmux writes it.
+func parseStyles(styled string) [][]Style {
+	var rows [][]Style
+	var cur Style
+	for _, line := range strings.Split(styled, "\n") {
+		var cells []Style
+		for seg := range ansi.Parse(line) {
+			switch {
+			case seg.Kind == ansi.CSI && seg.Final == 'm':
+				applySGR(&cur, seg.Params)
+			case seg.Kind == ansi.Text:
+				for _, r := range seg.Raw {
+					for i := runeWidth(r); i > 0; i-- {
+						cells = append(cells, cur)
+					}
+				}
+			}
+		}
+		rows = append(rows, cells)
+	}
+	return rows
+}
+
+// applySGR updates st with the Select Graphic Rendition parameters params,
+// the part of an ESC [ ... m sequence between the bracket and the m.
+func applySGR(st *Style, params string) {
+	if params == "" {
+		*st = Style{}
+		return
+	}
+	fields := strings.Split(params, ";")
+	for i := 0; i < len(fields); i++ {
+		// Subparameters (4:3 for a curly underline) only refine the
+		// attribute, except that 4:0 turns underlining off.
+		code, sub, _ := strings.Cut(fields[i], ":")
+		n, err := strconv.Atoi(code)
+		if err != nil && code != "" {
+			continue
+		}
+		switch {
+		case n == 0:
+			*st = Style{}
+		case n == 1:
+			st.Bold = true
+		case n == 2:
+			st.Dim = true
+		case n == 3:
+			st.Italic = true
+		case n == 4:
+			st.Underline = sub != "0"
+		case n == 5 || n == 6:
+			st.Blink = true
+		case n == 7:
+			st.Reverse = true
+		case n == 9:
+			st.Strikethrough = true
+		case n == 21:
+			st.Underline = true
+		case n == 22:
+			st.Bold, st.Dim = false, false
+		case n == 23:
+			st.Italic = false
+		case n == 24:
+			st.Underline = false
+		case n == 25:
+			st.Blink = false
+		case n == 27:
+			st.Reverse = false
+		case n == 29:
+			st.Strikethrough = false
+		case n >= 30 && n <= 37:
+			st.Fg = IndexedColor(uint8(n - 30))
+		case n == 38:
+			st.Fg, i = extendedColor(fields, i)
+		case n == 39:
+			st.Fg = DefaultColor
+		case n >= 40 && n <= 47:
+			st.Bg = IndexedColor(uint8(n - 40))
+		case n == 48:
+			st.Bg, i = extendedColor(fields, i)
+		case n == 49:
+			st.Bg = DefaultColor
+		case n == 58:
+			_, i = extendedColor(fields, i) // underline color, not tracked
+		case n >= 90 && n <= 97:
+			st.Fg = IndexedColor(uint8(n - 90 + 8))
+		case n >= 100 && n <= 107:
+			st.Bg = IndexedColor(uint8(n - 100 + 8))
+		}
+	}
+}
+
+// extendedColor parses the color of an SGR 38, 48, or 58 parameter at
+// fields[i], in either the 38;5;n or the 38:5:n form, and returns it with
+// the index of the last field it used.
+func extendedColor(fields []string, i int) (Color, int) {
+	if parts := strings.Split(fields[i], ":"); len(parts) > 1 {
+		// Colon form, which may include an empty color space ID: 38:2::r:g:b.
+		args := parts[1:]
+		if args[0] == "2" && len(args) >= 5 {
+			args = append([]string{"2"}, args[2:]...)
+		}
+		return colorArgs(args), i
+	}
+
+	// Semicolon form: the arguments are the following fields.
+	args := fields[i+1:]
+	used := 0
+	if len(args) > 0 && args[0] == "5" {
+		used = 2
+	} else if len(args) > 0 && args[0] == "2" {
+		used = 4
+	}
+	return colorArgs(args), min(i+used, len(fields)-1)
+}
+
+// colorArgs returns the color of the arguments of an extended color
+// parameter: 5 and an index, or 2 and red, green, and blue.
+func colorArgs(args []string) Color {
+	num := func(k int) uint8 {
+		if k >= len(args) {
+			return 0
+		}
+		n, _ := strconv.Atoi(args[k])
+		return uint8(n)
+	}
+	switch {
+	case len(args) > 0 && args[0] == "5":
+		return IndexedColor(num(1))
+	case len(args) > 0 && args[0] == "2":
+		return RGBColor(num(1), num(2), num(3))
+	}
+	return DefaultColor
+}
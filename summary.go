@@ -0,0 +1,119 @@
+package strider
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/cboone/strider/internal/tmuxcli"
+)
+
+// Summary holds counters for a whole test binary run, as reported by
+// EnableSummary.
+type Summary struct {
+	// Terminals is the number of Terminals opened, including the ones
+	// created by Pools.
+	Terminals int64 `json:"terminals"`
+	// TmuxInvocations is the number of tmux processes started.
+	TmuxInvocations int64 `json:"tmux_invocations"`
+	// Waits is the number of waits (WaitFor, WaitForScreen, WaitForResult,
+	// WaitExit, and the waits inside Open and Reset) that finished, whether
+	// they succeeded or failed.
+	Waits int64 `json:"waits"`
+	// WaitTime is the time spent in those waits.
+	WaitTime time.Duration `json:"wait_time_ns"`
+	// WaitRetries is the number of polls beyond the first one: each is a
+	// capture taken because the condition did not hold yet.
+	WaitRetries int64 `json:"wait_retries"`
+	// SlowWaits is the number of successful waits that exceeded the
+	// threshold set with WithSlowWaitWarning.
+	SlowWaits int64 `json:"slow_waits"`
+	// FailedWaits is the number of waits that did not succeed: they timed
+	// out, saw the program exit unexpectedly, or were aborted (NoClears).
+	FailedWaits int64 `json:"failed_waits"`
+}
+
+// String formats s the way EnableSummary prints it.
+func (s Summary) String() string {
+	return fmt.Sprintf("strider: summary: %d terminals, %d tmux invocations\n"+
+		"    waits: %d (%v total, %d retries, %d slow, %d failed)\n",
+		s.Terminals, s.TmuxInvocations,
+		s.Waits, s.WaitTime.Round(time.Millisecond), s.WaitRetries, s.SlowWaits, s.FailedWaits)
+}
+
+// suiteStats accumulates the counters of the Summary.
+var suiteStats struct {
+	terminals   atomic.Int64
+	waits       atomic.Int64
+	waitTime    atomic.Int64 // nanoseconds
+	waitRetries atomic.Int64
+	slowWaits   atomic.Int64
+	failedWaits atomic.Int64
+}
+
+// currentSummary returns the counters accumulated so far.
+func currentSummary() Summary {
+	return Summary{
+		Terminals:       suiteStats.terminals.Load(),
+		TmuxInvocations: tmuxcli.Invocations(),
+		Waits:           suiteStats.waits.Load(),
+		WaitTime:        time.Duration(suiteStats.waitTime.Load()),
+		WaitRetries:     suiteStats.waitRetries.Load(),
+		SlowWaits:       suiteStats.slowWaits.Load(),
+		FailedWaits:     suiteStats.failedWaits.Load(),
+	}
+}
+
+// recordWaitStats adds a finished wait to the Summary. polls is the number
+// of captures the wait took and failed whether it did not succeed.
+func (term *Terminal) recordWaitStats(elapsed time.Duration, polls int, failed bool) {
+	suiteStats.waits.Add(1)
+	suiteStats.waitTime.Add(int64(elapsed))
+	if polls > 1 {
+		suiteStats.waitRetries.Add(int64(polls - 1))
+	}
+	switch {
+	case failed:
+		suiteStats.failedWaits.Add(1)
+	case term.opts.slowWait > 0 && elapsed > term.opts.slowWait:
+		suiteStats.slowWaits.Add(1)
+	}
+}
+
+// EnableSummary runs the tests and then reports a Summary of the whole run:
+// terminals opened, tmux invocations, and the number, total time, retries,
+// and failures of waits. Use the numbers to find where a suite spends its
+// time. Call it from TestMain in place of m.Run:
+//
+//	func TestMain(m *testing.M) {
+//		os.Exit(strider.EnableSummary(m))
+//	}
+//
+// The summary is printed to standard error. When STRIDER_SUMMARY_JSON names
+// a file, the Summary is written there as JSON instead, for collection by
+// CI. It returns the exit code of m.Run, or 1 if the tests passed but the
+// JSON file could not be written.
+func EnableSummary(m *testing.M) int {
+	code := m.Run()
+	s := currentSummary()
+
+	path := os.Getenv("STRIDER_SUMMARY_JSON")
+	if path == "" {
+		fmt.Fprint(os.Stderr, s)
+		return code
+	}
+	data, err := json.MarshalIndent(s, "", "  ")
+	if err == nil {
+		err = os.WriteFile(path, append(data, '\n'), 0o644)
+	}
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "strider: summary: %v\n", err)
+		if code == 0 {
+			code = 1
+		}
+	}
+	return code
+}
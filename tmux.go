@@ -10,7 +10,6 @@ import (
 	"regexp"
 	"strconv"
 	"strings"
-	"testing"
 
 	"github.com/cboone/strider/internal/tmuxcli"
 )
@@ -22,44 +21,46 @@ const minTmuxVersion = "3.0"
 // 2. STRIDER_TMUX environment variable
 // 3. $PATH lookup
 //
-// Returns the resolved path and whether it was explicitly configured.
-func resolveTmuxPath(t testing.TB, configured string) (path string, explicit bool) {
-	t.Helper()
+// Returns the resolved path and whether it was explicitly configured. If
+// tmux is not found, the error skips the test, or fails it when strict (see
+// environmentError).
+func resolveTmuxPath(configured string, strict bool) (path string, explicit bool, err error) {
+	path, explicit, err = findTmux(configured)
+	if err != nil {
+		return "", false, environmentError(strict, "strider: open: tmux not found")
+	}
+	return path, explicit, nil
+}
 
+// findTmux is the error-returning core of resolveTmuxPath.
+func findTmux(configured string) (path string, explicit bool, err error) {
 	if configured != "" {
-		return configured, true
+		return configured, true, nil
 	}
 
 	if envPath := os.Getenv("STRIDER_TMUX"); envPath != "" {
-		return envPath, true
+		return envPath, true, nil
 	}
 
 	found, err := exec.LookPath("tmux")
 	if err != nil {
-		t.Skip("strider: open: tmux not found")
+		return "", false, err
 	}
-	return found, false
+	return found, false, nil
 }
 
 // checkTmuxVersion verifies the tmux version meets the minimum requirement.
-func checkTmuxVersion(t testing.TB, tmuxPath string, explicit bool) {
-	t.Helper()
-
+// If not, the error skips the test, or fails it when strict.
+func checkTmuxVersion(tmuxPath string, strict bool) error {
 	version, err := tmuxcli.Version(tmuxPath)
 	if err != nil {
-		if explicit {
-			t.Fatalf("strider: open: %v", err)
-		}
-		t.Skipf("strider: open: %v", err)
+		return environmentError(strict, "strider: open: %v", err)
 	}
 
 	if !versionAtLeast(version, minTmuxVersion) {
-		msg := fmt.Sprintf("strider: open: tmux version %s is below minimum %s", version, minTmuxVersion)
-		if explicit {
-			t.Fatal(msg)
-		}
-		t.Skip(msg)
+		return environmentError(strict, "strider: open: tmux version %s is below minimum %s", version, minTmuxVersion)
 	}
+	return nil
 }
 
 // versionAtLeast returns true if version >= minVersion.
@@ -89,35 +90,34 @@ func versionAtLeast(version, minVersion string) bool {
 	return vMinor >= mMinor
 }
 
-// generateSocketPath creates a unique, filesystem-safe socket path.
-func generateSocketPath(t testing.TB) string {
-	t.Helper()
-
-	sanitized := sanitizeName(t.Name())
+// generateSocketPath creates a unique, filesystem-safe socket path for a
+// Terminal of the test (or Session) called name.
+func generateSocketPath(name string) (string, error) {
+	sanitized := sanitizeName(name)
 
 	// Generate random suffix.
 	b := make([]byte, 4)
 	if _, err := rand.Read(b); err != nil {
-		t.Fatalf("strider: open: failed to generate random bytes: %v", err)
+		return "", fmt.Errorf("strider: open: failed to generate random bytes: %v", err)
 	}
 	suffix := hex.EncodeToString(b)
 
-	name := fmt.Sprintf("strider-%s-%s.sock", sanitized, suffix)
+	name = fmt.Sprintf("strider-%s-%s.sock", sanitized, suffix)
 	path := filepath.Join(os.TempDir(), name)
 
 	// Handle collision: if file exists, regenerate.
 	for i := 0; i < 10; i++ {
 		_, err := os.Stat(path)
 		if os.IsNotExist(err) {
-			return path
+			return path, nil
 		}
 		if err != nil {
 			// Non-existence check failed for a reason other than the file
 			// not existing (e.g., permission denied). Surface the real error.
-			t.Fatalf("strider: open: failed to check socket path: %v", err)
+			return "", fmt.Errorf("strider: open: failed to check socket path: %v", err)
 		}
 		if _, err := rand.Read(b); err != nil {
-			t.Fatalf("strider: open: failed to generate random bytes: %v", err)
+			return "", fmt.Errorf("strider: open: failed to generate random bytes: %v", err)
 		}
 		suffix = hex.EncodeToString(b)
 		name = fmt.Sprintf("strider-%s-%s.sock", sanitized, suffix)
@@ -125,8 +125,7 @@ func generateSocketPath(t testing.TB) string {
 	}
 
 	// Extremely unlikely: 10 collisions in a row.
-	t.Fatalf("strider: open: could not generate unique socket path after 10 attempts")
-	return ""
+	return "", fmt.Errorf("strider: open: could not generate unique socket path after 10 attempts")
 }
 
 // sanitizeName replaces characters that are not filesystem-safe.
@@ -149,26 +148,50 @@ func sanitizeName(name string) string {
 }
 
 // writeConfig writes a tmux config file with the needed session options.
-func writeConfig(configPath string, opts options) error {
+// A hook copies the pane's output to outputPath from the start, before the
+// program can write anything.
+func writeConfig(configPath, outputPath string, opts options) error {
 	histLimit := opts.historyLimit
 	if histLimit == 0 {
 		histLimit = defaultHistoryLimit
 	}
 
 	config := fmt.Sprintf("set-option -g history-limit %d\nset-option -g remain-on-exit on\nset-option -g status off\n", histLimit)
+	pipe := "pipe-pane -O " + tmuxQuote("exec cat >> "+shellQuote(outputPath))
+	config += "set-hook -g after-new-session " + tmuxQuote(pipe) + "\n"
 	if err := os.WriteFile(configPath, []byte(config), 0o644); err != nil {
 		return fmt.Errorf("strider: open: failed to write tmux config: %w", err)
 	}
 	return nil
 }
 
+// tmuxQuote quotes s as a double-quoted tmux command argument, escaping the
+// characters tmux interprets inside double quotes.
+func tmuxQuote(s string) string {
+	return `"` + tmuxQuoter.Replace(s) + `"`
+}
+
+var tmuxQuoter = strings.NewReplacer(`\`, `\\`, `"`, `\"`, `$`, `\$`)
+
 // startSession starts a new tmux session with the given configuration.
 func startSession(runner *tmuxcli.Runner, binary string, opts options) error {
-	args := []string{
-		"new-session", "-d",
+	if _, err := runner.Run(newSessionArgs("", binary, opts)...); err != nil {
+		return fmt.Errorf("strider: open: failed to start tmux session: %w", err)
+	}
+	return nil
+}
+
+// newSessionArgs returns the new-session command that starts binary, named
+// session unless session is "".
+func newSessionArgs(session, binary string, opts options) []string {
+	args := []string{"new-session", "-d"}
+	if session != "" {
+		args = append(args, "-s", session)
+	}
+	args = append(args,
 		"-x", strconv.Itoa(opts.width),
 		"-y", strconv.Itoa(opts.height),
-	}
+	)
 
 	// Set working directory if specified.
 	if opts.dir != "" {
@@ -177,13 +200,26 @@ func startSession(runner *tmuxcli.Runner, binary string, opts options) error {
 
 	// Build the command to run.
 	args = append(args, "--", binary)
-	args = append(args, opts.args...)
+	return append(args, opts.args...)
+}
 
-	if _, err := runner.Run(args...); err != nil {
-		return fmt.Errorf("strider: open: failed to start tmux session: %w", err)
+// respawnPane kills the pane's process (if still running) and starts
+// command in its place.
+func respawnPane(runner *tmuxcli.Runner, pane, dir string, command []string) error {
+	args := []string{"respawn-pane", "-k", "-t", pane}
+	if dir != "" {
+		args = append(args, "-c", dir)
 	}
+	args = append(args, "--")
+	args = append(args, command...)
+	_, err := runner.Run(args...)
+	return err
+}
 
-	return nil
+// clearHistory discards the pane's scrollback history.
+func clearHistory(runner *tmuxcli.Runner, pane string) error {
+	_, err := runner.Run("clear-history", "-t", pane)
+	return err
 }
 
 // capturePaneContent captures the visible pane content.
@@ -191,11 +227,81 @@ func capturePaneContent(runner *tmuxcli.Runner, pane string) (string, error) {
 	return runner.Run("capture-pane", "-p", "-t", pane)
 }
 
+// capturePaneStyled captures the visible pane content with SGR escape
+// sequences for its colors and attributes.
+func capturePaneStyled(runner *tmuxcli.Runner, pane string) (string, error) {
+	return runner.Run("capture-pane", "-p", "-e", "-t", pane)
+}
+
+// capturePaneExact captures the visible pane content, keeping the trailing
+// spaces capturePaneContent drops.
+func capturePaneExact(runner *tmuxcli.Runner, pane string) (string, error) {
+	return runner.Run("capture-pane", "-p", "-N", "-t", pane)
+}
+
+// capturePaneSaved captures the screen the pane is not displaying: the
+// primary screen while the alternate screen is active. It fails with "no
+// alternate screen" otherwise.
+func capturePaneSaved(runner *tmuxcli.Runner, pane string) (string, error) {
+	return runner.Run("capture-pane", "-p", "-a", "-t", pane)
+}
+
+// alternateScreenOn reports whether the pane is displaying the alternate
+// screen.
+func alternateScreenOn(runner *tmuxcli.Runner, pane string) (bool, error) {
+	output, err := runner.Run("display-message", "-p", "-t", pane, "#{alternate_on}")
+	if err != nil {
+		return false, err
+	}
+	return strings.TrimSpace(output) == "1", nil
+}
+
+// captureScrolledView scrolls the pane up by lines in copy mode and captures
+// the rows shown there, then leaves copy mode. tmux stops scrolling at the
+// top of the history, so the rows are taken from the scroll position tmux
+// reports rather than from lines.
+func captureScrolledView(runner *tmuxcli.Runner, pane string, lines, height int) (string, error) {
+	if _, err := runner.Run("copy-mode", "-t", pane); err != nil {
+		return "", err
+	}
+	defer func() {
+		_, _ = runner.Run("send-keys", "-t", pane, "-X", "cancel")
+	}()
+
+	if lines > 0 {
+		if _, err := runner.Run("send-keys", "-t", pane, "-X", "-N", strconv.Itoa(lines), "scroll-up"); err != nil {
+			return "", err
+		}
+	}
+
+	output, err := runner.Run("display-message", "-p", "-t", pane, "#{scroll_position}")
+	if err != nil {
+		return "", err
+	}
+	pos, err := strconv.Atoi(strings.TrimSpace(output))
+	if err != nil {
+		return "", fmt.Errorf("unexpected scroll position %q", strings.TrimSpace(output))
+	}
+
+	raw, err := runner.Run("capture-pane", "-p", "-t", pane,
+		"-S", strconv.Itoa(-pos), "-E", strconv.Itoa(height-1-pos))
+	if err != nil {
+		return "", err
+	}
+	return raw, nil
+}
+
 // capturePaneScrollback captures the full scrollback buffer.
 func capturePaneScrollback(runner *tmuxcli.Runner, pane string) (string, error) {
 	return runner.Run("capture-pane", "-p", "-t", pane, "-S", "-", "-E", "-")
 }
 
+// capturePaneJoined captures the full scrollback like capturePaneScrollback,
+// with the lines the terminal wrapped joined again (capture-pane -J).
+func capturePaneJoined(runner *tmuxcli.Runner, pane string) (string, error) {
+	return runner.Run("capture-pane", "-p", "-J", "-t", pane, "-S", "-", "-E", "-")
+}
+
 // sendKeys sends key sequences to the pane.
 func sendKeys(runner *tmuxcli.Runner, pane string, keys []string) error {
 	args := append([]string{"send-keys", "-t", pane}, keys...)
@@ -203,6 +309,42 @@ func sendKeys(runner *tmuxcli.Runner, pane string, keys []string) error {
 	return err
 }
 
+// sendLiteral sends s to the pane as literal text (send-keys -l), without
+// looking up key names.
+func sendLiteral(runner *tmuxcli.Runner, pane, s string) error {
+	_, err := runner.Run("send-keys", "-t", pane, "-l", s)
+	return err
+}
+
+// sendBytes sends b to the pane unchanged (send-keys -H), for input that is
+// not a key, such as mouse reports.
+func sendBytes(runner *tmuxcli.Runner, pane string, b []byte) error {
+	args := []string{"send-keys", "-t", pane, "-H"}
+	for _, c := range b {
+		args = append(args, strconv.FormatUint(uint64(c), 16))
+	}
+	_, err := runner.Run(args...)
+	return err
+}
+
+// mouseMode is the mouse reporting a pane's program has enabled.
+type mouseMode struct {
+	enabled bool // any mouse reporting mode (1000, 1002, or 1003)
+	sgr     bool // SGR encoding (1006)
+	utf8    bool // UTF-8 encoding (1005)
+}
+
+// getMouseMode queries the mouse reporting the pane's program has enabled.
+func getMouseMode(runner *tmuxcli.Runner, pane string) (mouseMode, error) {
+	output, err := runner.Run("display-message", "-p", "-t", pane, "#{mouse_any_flag} #{mouse_sgr_flag} #{mouse_utf8_flag}")
+	if err != nil {
+		return mouseMode{}, err
+	}
+	fields := strings.Fields(output)
+	flag := func(i int) bool { return i < len(fields) && fields[i] == "1" }
+	return mouseMode{enabled: flag(0), sgr: flag(1), utf8: flag(2)}, nil
+}
+
 // resizeWindow resizes the terminal window.
 func resizeWindow(runner *tmuxcli.Runner, pane string, width, height int) error {
 	_, err := runner.Run("resize-window", "-t", pane, "-x", strconv.Itoa(width), "-y", strconv.Itoa(height))
@@ -217,50 +359,150 @@ type paneState struct {
 
 // getPaneState queries the pane state.
 func getPaneState(runner *tmuxcli.Runner, pane string) (paneState, error) {
-	output, err := runner.Run("list-panes", "-t", pane, "-F", "#{pane_dead} #{pane_dead_status}")
+	output, err := runner.Run("list-panes", "-t", pane, "-F", "#{pane_dead}:#{pane_dead_status}:#{pane_dead_signal}")
 	if err != nil {
 		return paneState{}, err
 	}
+	return parsePaneState(strings.TrimSpace(output)), nil
+}
 
-	line := strings.TrimSpace(output)
-	parts := strings.SplitN(line, " ", 2)
+// parsePaneState parses the pane_dead, pane_dead_status, and
+// pane_dead_signal formats, separated by colons. A program killed by a
+// signal has no exit status; it is reported as 128 plus the signal number,
+// as shells report it. tmux older than 3.3 does not report the signal.
+func parsePaneState(field string) paneState {
+	flag, rest, _ := strings.Cut(field, ":")
+	if flag != "1" {
+		return paneState{}
+	}
+	status, signal, _ := strings.Cut(rest, ":")
+	state := paneState{dead: true}
+	if status == "" && signal != "" {
+		if n, err := strconv.Atoi(signal); err == nil {
+			state.exitStatus = 128 + n
+		}
+		return state
+	}
+	state.exitStatus, _ = strconv.Atoi(status)
+	return state
+}
 
-	dead := parts[0] == "1"
-	status := 0
-	if dead && len(parts) >= 2 {
-		status, _ = strconv.Atoi(parts[1])
+// getPanePID returns the process ID of the program running in the pane,
+// which leads the pane's process group.
+func getPanePID(runner *tmuxcli.Runner, pane string) (int, error) {
+	output, err := runner.Run("display-message", "-p", "-t", pane, "#{pane_pid}")
+	if err != nil {
+		return 0, err
+	}
+	pid, err := strconv.Atoi(strings.TrimSpace(output))
+	if err != nil {
+		return 0, fmt.Errorf("parsing pane_pid: %w", err)
 	}
+	return pid, nil
+}
 
-	return paneState{dead: dead, exitStatus: status}, nil
+// paneGeometry holds the cursor position and actual size of a pane.
+type paneGeometry struct {
+	cursorRow int
+	cursorCol int
+	width     int
+	height    int
 }
 
-// getCursorPosition queries the cursor position.
-func getCursorPosition(runner *tmuxcli.Runner, pane string) (row, col int, err error) {
-	output, err := runner.Run("display-message", "-p", "-t", pane, "#{cursor_x} #{cursor_y}")
+// getPaneGeometry queries the cursor position and pane size in one call.
+func getPaneGeometry(runner *tmuxcli.Runner, pane string) (paneGeometry, error) {
+	output, err := runner.Run("display-message", "-p", "-t", pane, "#{cursor_x} #{cursor_y} #{pane_width} #{pane_height}")
 	if err != nil {
-		return 0, 0, err
+		return paneGeometry{}, err
 	}
 
-	line := strings.TrimSpace(output)
-	parts := strings.SplitN(line, " ", 2)
-	if len(parts) < 2 {
-		return 0, 0, fmt.Errorf("unexpected display-message output: %q", line)
-	}
+	return parsePaneGeometry(strings.TrimSpace(output))
+}
 
-	col, err = strconv.Atoi(parts[0])
+// paneStateFormat queries the pane state and geometry in one line, read by
+// parsePaneStateLine.
+const paneStateFormat = "#{pane_dead}:#{pane_dead_status}:#{pane_dead_signal} #{cursor_x} #{cursor_y} #{pane_width} #{pane_height}"
+
+// capturePaneWithState captures the visible pane content, with styles if
+// styled, together with the pane state and geometry, in one tmux call, so
+// that a poll starts at most one tmux process.
+func capturePaneWithState(runner *tmuxcli.Runner, pane string, styled bool) (string, paneState, paneGeometry, error) {
+	args := []string{"display-message", "-p", "-t", pane, paneStateFormat, ";", "capture-pane", "-p"}
+	if styled {
+		args = append(args, "-e")
+	}
+	args = append(args, "-t", pane)
+	output, err := runner.Run(args...)
 	if err != nil {
-		return 0, 0, fmt.Errorf("parsing cursor_x: %w", err)
+		return "", paneState{}, paneGeometry{}, err
 	}
-	row, err = strconv.Atoi(parts[1])
+	line, raw, _ := strings.Cut(output, "\n")
+	state, g, err := parsePaneStateLine(line)
 	if err != nil {
-		return 0, 0, fmt.Errorf("parsing cursor_y: %w", err)
+		return "", paneState{}, paneGeometry{}, err
 	}
+	return raw, state, g, nil
+}
 
-	return row, col, nil
+// parsePaneStateLine parses a line of paneStateFormat.
+func parsePaneStateLine(line string) (paneState, paneGeometry, error) {
+	stateField, geometry, _ := strings.Cut(line, " ")
+	if !strings.Contains(stateField, ":") {
+		return paneState{}, paneGeometry{}, fmt.Errorf("unexpected display-message output: %q", line)
+	}
+	state := parsePaneState(stateField)
+	g, err := parsePaneGeometry(geometry)
+	if err != nil {
+		return paneState{}, paneGeometry{}, err
+	}
+	return state, g, nil
 }
 
-// killServer kills the tmux server.
+// parsePaneGeometry parses the line getPaneGeometry queries. It runs on
+// every capture, so it cuts the fields out of line rather than splitting it.
+func parsePaneGeometry(line string) (paneGeometry, error) {
+	var g paneGeometry
+	fields := [...]struct {
+		name string
+		dst  *int
+	}{
+		{"cursor_x", &g.cursorCol},
+		{"cursor_y", &g.cursorRow},
+		{"pane_width", &g.width},
+		{"pane_height", &g.height},
+	}
+	rest := line
+	for _, f := range fields {
+		var field string
+		field, rest, _ = strings.Cut(strings.TrimLeft(rest, " "), " ")
+		if field == "" {
+			return paneGeometry{}, fmt.Errorf("unexpected display-message output: %q", line)
+		}
+		v, err := strconv.Atoi(field)
+		if err != nil {
+			return paneGeometry{}, fmt.Errorf("parsing %s: %w", f.name, err)
+		}
+		*f.dst = v
+	}
+
+	return g, nil
+}
+
+// killServer kills the tmux server, closing the runner's control client
+// first so that it does not see the server go away mid-command.
 func killServer(runner *tmuxcli.Runner) error {
+	runner.Close()
 	_, err := runner.Run("kill-server")
 	return err
 }
+
+// stopSession ends a Terminal's tmux session: the whole server, or only
+// session if it is on a shared server.
+func stopSession(runner *tmuxcli.Runner, session string) error {
+	if session == "" {
+		return killServer(runner)
+	}
+	runner.Close()
+	_, err := runner.Run("kill-session", "-t", "="+session)
+	return err
+}
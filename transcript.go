@@ -0,0 +1,49 @@
+package strider
+
+import (
+	"fmt"
+	"strings"
+)
+
+// record appends an input or lifecycle event to the transcript, if
+// WithTranscript is set. Events are written as "> <event>".
+func (term *Terminal) record(format string, args ...any) {
+	term.host.Helper()
+	term.debugf("strider: input: "+format, args...)
+	if term.transcript == nil {
+		return
+	}
+	term.transcript.WriteString("> " + term.redact(fmt.Sprintf(format, args...)) + "\n")
+}
+
+// recordScreen appends a screen that satisfied a wait to the transcript, if
+// WithTranscript is set: a "< <description>" header followed by the
+// normalized screen rows, each prefixed with "| ".
+func (term *Terminal) recordScreen(desc string, scr *Screen) {
+	if term.transcript == nil {
+		return
+	}
+	fmt.Fprintf(term.transcript, "< %s\n", term.redact(desc))
+	content := strings.TrimSuffix(NormalizeScreen(scr.String()), "\n")
+	for _, line := range strings.Split(content, "\n") {
+		term.transcript.WriteString(strings.TrimRight("| "+line, " ") + "\n")
+	}
+}
+
+// matchTranscript compares the recorded transcript to its golden file. It
+// runs during test cleanup and does nothing if the test already failed,
+// since the transcript of a failed test is incomplete.
+func (term *Terminal) matchTranscript(name string) {
+	term.t.Helper()
+	if term.t.Failed() {
+		return
+	}
+	matchGolden(term.t, goldenFile{
+		op:   "transcript",
+		what: "transcript",
+		name: name,
+		dir:  snapshotDir(term.t),
+		file: sanitizeName(name) + ".transcript.txt",
+		term: term,
+	}, term.transcript.String())
+}
@@ -0,0 +1,49 @@
+package strider
+
+import (
+	"os"
+	"os/exec"
+	"strings"
+)
+
+// suScript execs the command su passes to the shell as its arguments.
+const suScript = `exec "$0" "$@"`
+
+// requireUserSwitch returns the command and leading arguments that start
+// the program as username: su when running as root, or sudo when it allows
+// it without a password. If neither can, the error skips the test, or fails
+// it when strict.
+func requireUserSwitch(username string, strict bool) (string, []string, error) {
+	if os.Geteuid() == 0 {
+		path, err := exec.LookPath("su")
+		if err != nil {
+			return "", nil, environmentError(strict, "strider: open: WithUser: su not found")
+		}
+		return path, []string{"-s", "/bin/sh", "-c", suScript, "--", username}, nil
+	}
+
+	path, err := exec.LookPath("sudo")
+	if err != nil {
+		return "", nil, environmentError(strict, "strider: open: WithUser: not running as root and sudo not found")
+	}
+	prefix := []string{"-n", "-u", username, "--"}
+	out, err := exec.Command(path, append(prefix, "true")...).CombinedOutput()
+	if err != nil {
+		msg := strings.TrimSpace(string(out))
+		if msg == "" {
+			msg = err.Error()
+		}
+		return "", nil, environmentError(strict, "strider: open: WithUser: cannot run commands as %s with sudo: %s", username, msg)
+	}
+	return path, prefix, nil
+}
+
+// userCommand returns the command that runs binary with args through the
+// user switch command path with its leading arguments prefix.
+func userCommand(path string, prefix []string, binary string, args []string) (string, []string) {
+	wrapped := make([]string, 0, len(prefix)+1+len(args))
+	wrapped = append(wrapped, prefix...)
+	wrapped = append(wrapped, binary)
+	wrapped = append(wrapped, args...)
+	return path, wrapped
+}
@@ -0,0 +1,50 @@
+package strider
+
+import "github.com/cboone/strider/internal/cellwidth"
+
+// runeWidth returns the number of terminal columns r occupies (see
+// cellwidth.Rune).
+func runeWidth(r rune) int {
+	return cellwidth.Rune(r)
+}
+
+// displayWidth returns the number of terminal columns s occupies.
+func displayWidth(s string) int {
+	return cellwidth.String(s)
+}
+
+// cellAt returns the rune occupying display column col (0-indexed) of line.
+// A wide character is returned for both of the columns it covers. Columns
+// past the end of the line are blank and return ' '.
+func cellAt(line string, col int) rune {
+	pos := 0
+	for _, r := range line {
+		w := runeWidth(r)
+		if w == 0 {
+			continue
+		}
+		if col < pos+w {
+			return r
+		}
+		pos += w
+	}
+	return ' '
+}
+
+// cellSlice returns the content of line between display columns left
+// (inclusive) and left+width (exclusive) (see cellwidth.Slice).
+func cellSlice(line string, left, width int) string {
+	return cellwidth.Slice(line, left, width)
+}
+
+// lineCells returns the runes of line laid out by display column: a wide
+// character fills both of its columns and zero-width characters are dropped.
+func lineCells(line string) []rune {
+	cells := make([]rune, 0, len(line))
+	for _, r := range line {
+		for i := runeWidth(r); i > 0; i-- {
+			cells = append(cells, r)
+		}
+	}
+	return cells
+}
@@ -0,0 +1,23 @@
+package strider
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+)
+
+// writeWorkdirFiles populates dir with the fixture files given to
+// WithTempWorkdir. The paths have been checked by options.validate to be
+// relative and stay inside dir.
+func writeWorkdirFiles(dir string, files map[string]string) error {
+	for name, content := range files {
+		path := filepath.Join(dir, filepath.FromSlash(name))
+		if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+			return fmt.Errorf("temp workdir: %w", err)
+		}
+		if err := os.WriteFile(path, []byte(content), 0o644); err != nil {
+			return fmt.Errorf("temp workdir: %w", err)
+		}
+	}
+	return nil
+}